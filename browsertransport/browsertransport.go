@@ -182,6 +182,14 @@ type PageRequest struct {
 	Timeout       time.Duration
 	WaitSelector  string
 	StealthScript string
+	// WaitConditions are awaited in order after WaitSelector, or after the
+	// body is ready when WaitSelector is empty.
+	WaitConditions []WaitCondition
+	// Capture selects screenshots, PDF and MHTML to return on Result.
+	Capture CaptureOptions
+	// CaptureResponses are regular expressions matched against the URLs the
+	// page requests; matching responses are returned on Result.Responses.
+	CaptureResponses []string
 }
 
 // Config keeps the historical one-shot render surface used by jseval callers.
@@ -194,6 +202,17 @@ type Config struct {
 	ExecPath                   string
 	StealthScript              string
 	AdditionalAllocatorOptions []chromedp.ExecAllocatorOption
+	WaitConditions             []WaitCondition
+	Capture                    CaptureOptions
+	CaptureResponses           []string
+	// MaxSessions and MaxTabsPerSession bound the browsers RenderPages starts;
+	// see PoolOptions for the defaults.
+	MaxSessions       int
+	MaxTabsPerSession int
+	// Pool, when set, serves RenderPages instead of a pool per call, so that
+	// browsers are reused across calls. Its own limits and launch options
+	// apply.
+	Pool *Pool
 }
 
 // Result holds the rendered page content.
@@ -201,6 +220,14 @@ type Result struct {
 	HTML     string
 	Title    string
 	FinalURL string
+	// Screenshots holds the full-page screenshot first, when requested, then
+	// one per CaptureOptions.ElementScreenshots selector in order.
+	Screenshots []Screenshot
+	PDF         []byte
+	MHTML       []byte
+	// Responses holds the responses matching PageRequest.CaptureResponses in
+	// the order they arrived.
+	Responses []NetworkResponse
 }
 
 // Session owns a browser instance bound to one browser transport profile.
@@ -212,6 +239,7 @@ type Session struct {
 	forwarder     *socksForwarder
 	profile       BrowserProfile
 	closed        bool
+	broken        bool
 }
 
 var (
@@ -381,6 +409,9 @@ func (session *Session) WithTab(ctx context.Context, tabOptions TabOptions, run
 	defer close(cancelDone)
 
 	if tabInitError := chromedpRunner(tabRunCtx); tabInitError != nil {
+		if ctx.Err() == nil && tabRunCtx.Err() == nil {
+			session.markBroken()
+		}
 		return fmt.Errorf("initializing browser tab: %w", tabInitError)
 	}
 
@@ -402,6 +433,14 @@ func (session *Session) WithTab(ctx context.Context, tabOptions TabOptions, run
 
 // RenderPage renders a page through an existing session.
 func (session *Session) RenderPage(ctx context.Context, pageRequest PageRequest) (*Result, error) {
+	if captureError := pageRequest.Capture.validate(); captureError != nil {
+		return nil, captureError
+	}
+	responsePatterns, patternError := compileResponsePatterns(pageRequest.CaptureResponses)
+	if patternError != nil {
+		return nil, patternError
+	}
+
 	stealthScript := strings.TrimSpace(pageRequest.StealthScript)
 	if stealthScript == "" {
 		stealthScript = DefaultStealthScript
@@ -419,20 +458,34 @@ func (session *Session) RenderPage(ctx context.Context, pageRequest PageRequest)
 		Timeout:        pageRequest.Timeout,
 		PreludeActions: preludeActions,
 	}, func(runContext context.Context) error {
+		readyCondition := staticWaitCondition("body ready", chromedp.WaitReady("body", chromedp.ByQuery))
+		if pageRequest.WaitSelector != "" {
+			readyCondition = WaitVisible(pageRequest.WaitSelector)
+		}
+		waitActions, armError := armWaitConditions(runContext, append([]WaitCondition{readyCondition}, pageRequest.WaitConditions...))
+		if armError != nil {
+			return armError
+		}
+		collector := newResponseCollector(runContext, responsePatterns)
+		if collector != nil {
+			chromedpListenTarget(runContext, collector.handleEvent)
+		}
+
 		actions := []chromedp.Action{
 			chromedp.Navigate(pageRequest.TargetURL),
 		}
-		if pageRequest.WaitSelector != "" {
-			actions = append(actions, chromedp.WaitVisible(pageRequest.WaitSelector, chromedp.ByQuery))
-		} else {
-			actions = append(actions, chromedp.WaitReady("body", chromedp.ByQuery))
-		}
+		actions = append(actions, waitActions...)
 		actions = append(actions,
 			chromedp.OuterHTML("html", &result.HTML),
 			chromedp.Title(&result.Title),
 			chromedp.Location(&result.FinalURL),
 		)
-		return chromedpRunner(runContext, actions...)
+		actions = append(actions, pageRequest.Capture.captureActions(result)...)
+		if runError := chromedpRunner(runContext, actions...); runError != nil {
+			return runError
+		}
+		result.Responses = collector.responses(runContext)
+		return nil
 	})
 	if renderError != nil {
 		return nil, renderError
@@ -441,6 +494,20 @@ func (session *Session) RenderPage(ctx context.Context, pageRequest PageRequest)
 	return result, nil
 }
 
+// alive reports whether the session can still open tabs: it is open, its
+// browser context is live and no tab failed to start on it.
+func (session *Session) alive() bool {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return !session.closed && !session.broken && session.browserCtx != nil && session.browserCtx.Err() == nil
+}
+
+func (session *Session) markBroken() {
+	session.mu.Lock()
+	session.broken = true
+	session.mu.Unlock()
+}
+
 // Close releases the browser process and any local proxy forwarder.
 func (session *Session) Close() {
 	if session == nil {
@@ -491,37 +558,61 @@ func RenderPage(ctx context.Context, targetURL string, config Config) (*Result,
 	defer session.Close()
 
 	return session.RenderPage(ctx, PageRequest{
-		TargetURL:     targetURL,
-		Timeout:       config.Timeout,
-		WaitSelector:  config.WaitSelector,
-		StealthScript: config.StealthScript,
+		TargetURL:        targetURL,
+		Timeout:          config.Timeout,
+		WaitSelector:     config.WaitSelector,
+		StealthScript:    config.StealthScript,
+		WaitConditions:   config.WaitConditions,
+		Capture:          config.Capture,
+		CaptureResponses: config.CaptureResponses,
 	})
 }
 
-// RenderPages renders multiple URLs concurrently and returns results in input order.
+// RenderPages renders multiple URLs concurrently on a bounded Pool of browser
+// sessions and returns results in input order.
 func RenderPages(ctx context.Context, targetURLs []string, config Config) ([]*Result, []error) {
 	results := make([]*Result, len(targetURLs))
 	errorsByIndex := make([]error, len(targetURLs))
 
-	type indexedResult struct {
-		index  int
-		result *Result
-		err    error
+	browserProfile, profileError := InferBrowserProfile(config.ProxyURL, config.IgnoreCertErrors)
+	if profileError != nil {
+		for index := range errorsByIndex {
+			errorsByIndex[index] = profileError
+		}
+		return results, errorsByIndex
+	}
+
+	pool := config.Pool
+	if pool == nil {
+		pool = NewPool(PoolOptions{
+			Launch: LaunchOptions{
+				ExecPath:                   config.ExecPath,
+				UserAgent:                  config.UserAgent,
+				AdditionalAllocatorOptions: config.AdditionalAllocatorOptions,
+			},
+			MaxSessionsPerProfile: config.MaxSessions,
+			MaxTabsPerSession:     config.MaxTabsPerSession,
+		})
+		defer pool.Close()
 	}
 
-	resultChannel := make(chan indexedResult, len(targetURLs))
+	var waitGroup sync.WaitGroup
 	for targetIndex, targetURL := range targetURLs {
+		waitGroup.Add(1)
 		go func(index int, url string) {
-			result, renderError := RenderPage(ctx, url, config)
-			resultChannel <- indexedResult{index: index, result: result, err: renderError}
+			defer waitGroup.Done()
+			results[index], errorsByIndex[index] = pool.RenderPage(ctx, browserProfile, PageRequest{
+				TargetURL:        url,
+				Timeout:          config.Timeout,
+				WaitSelector:     config.WaitSelector,
+				StealthScript:    config.StealthScript,
+				WaitConditions:   config.WaitConditions,
+				Capture:          config.Capture,
+				CaptureResponses: config.CaptureResponses,
+			})
 		}(targetIndex, targetURL)
 	}
-
-	for range targetURLs {
-		indexed := <-resultChannel
-		results[indexed.index] = indexed.result
-		errorsByIndex[indexed.index] = indexed.err
-	}
+	waitGroup.Wait()
 
 	return results, errorsByIndex
 }
@@ -0,0 +1,190 @@
+package browsertransport
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image/jpeg"
+	"image/png"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// ScreenshotFormat is the image encoding of captured screenshots.
+type ScreenshotFormat string
+
+const (
+	// ScreenshotPNG captures lossless PNG screenshots. It is the default.
+	ScreenshotPNG ScreenshotFormat = "png"
+	// ScreenshotJPEG captures JPEG screenshots at CaptureOptions.ScreenshotQuality.
+	ScreenshotJPEG ScreenshotFormat = "jpeg"
+)
+
+const defaultJPEGQuality = 90
+
+// CaptureOptions selects the evidence a render captures once its wait
+// conditions are met. The zero value captures nothing beyond the HTML.
+type CaptureOptions struct {
+	// FullPageScreenshot captures the whole page, beyond the viewport.
+	FullPageScreenshot bool
+	// ElementScreenshots captures one screenshot per selector, each of the
+	// first visible element it matches.
+	ElementScreenshots []string
+	// ScreenshotFormat defaults to ScreenshotPNG.
+	ScreenshotFormat ScreenshotFormat
+	// ScreenshotQuality is the JPEG quality from 1 to 100. Zero means 90.
+	ScreenshotQuality int
+	// PDF prints the page to PDF with backgrounds.
+	PDF bool
+	// MHTML snapshots the page and its resources as one MHTML document.
+	MHTML bool
+}
+
+// Screenshot is one captured image.
+type Screenshot struct {
+	// Selector is the element captured, or empty for the full page.
+	Selector string
+	Format   ScreenshotFormat
+	Image    []byte
+}
+
+// Artifact is captured content with a file name suggested for persisting it,
+// for example through a crawler.FilePersister.
+type Artifact struct {
+	Name    string
+	Content []byte
+}
+
+// Artifacts lists the captured screenshots, PDF and MHTML as files: the
+// full-page screenshot as "screenshot.<ext>", element screenshots as
+// "screenshot-<n>.<ext>" numbered from 1 in CaptureOptions order, "page.pdf"
+// and "page.mhtml".
+func (result *Result) Artifacts() []Artifact {
+	if result == nil {
+		return nil
+	}
+	var artifacts []Artifact
+	elementNumber := 0
+	for _, screenshot := range result.Screenshots {
+		name := "screenshot." + string(screenshot.Format)
+		if screenshot.Selector != "" {
+			elementNumber++
+			name = fmt.Sprintf("screenshot-%d.%s", elementNumber, screenshot.Format)
+		}
+		artifacts = append(artifacts, Artifact{Name: name, Content: screenshot.Image})
+	}
+	if result.PDF != nil {
+		artifacts = append(artifacts, Artifact{Name: "page.pdf", Content: result.PDF})
+	}
+	if result.MHTML != nil {
+		artifacts = append(artifacts, Artifact{Name: "page.mhtml", Content: result.MHTML})
+	}
+	return artifacts
+}
+
+func (options CaptureOptions) validate() error {
+	switch options.ScreenshotFormat {
+	case "", ScreenshotPNG, ScreenshotJPEG:
+	default:
+		return fmt.Errorf("unsupported screenshot format %q", options.ScreenshotFormat)
+	}
+	if options.ScreenshotQuality < 0 || options.ScreenshotQuality > 100 {
+		return fmt.Errorf("screenshot quality %d is outside 1-100", options.ScreenshotQuality)
+	}
+	for selectorIndex, selector := range options.ElementScreenshots {
+		if selector == "" {
+			return fmt.Errorf("element screenshot selector %d is empty", selectorIndex)
+		}
+	}
+	return nil
+}
+
+func (options CaptureOptions) format() ScreenshotFormat {
+	if options.ScreenshotFormat == "" {
+		return ScreenshotPNG
+	}
+	return options.ScreenshotFormat
+}
+
+func (options CaptureOptions) quality() int {
+	if options.ScreenshotQuality == 0 {
+		return defaultJPEGQuality
+	}
+	return options.ScreenshotQuality
+}
+
+// captureActions returns the actions that fill result with the requested
+// captures.
+func (options CaptureOptions) captureActions(result *Result) []chromedp.Action {
+	format := options.format()
+	var actions []chromedp.Action
+	if options.FullPageScreenshot {
+		actions = append(actions, chromedp.ActionFunc(func(ctx context.Context) error {
+			capture := page.CaptureScreenshot().
+				WithCaptureBeyondViewport(true).
+				WithFromSurface(true).
+				WithFormat(page.CaptureScreenshotFormat(format))
+			if format == ScreenshotJPEG {
+				capture = capture.WithQuality(int64(options.quality()))
+			}
+			image, captureError := capture.Do(ctx)
+			if captureError != nil {
+				return fmt.Errorf("capturing full-page screenshot: %w", captureError)
+			}
+			result.Screenshots = append(result.Screenshots, Screenshot{Format: format, Image: image})
+			return nil
+		}))
+	}
+	for _, selector := range options.ElementScreenshots {
+		actions = append(actions, chromedp.ActionFunc(func(ctx context.Context) error {
+			var image []byte
+			if captureError := chromedp.Screenshot(selector, &image, chromedp.ByQuery).Do(ctx); captureError != nil {
+				return fmt.Errorf("capturing screenshot of %q: %w", selector, captureError)
+			}
+			// Chrome clips element screenshots as PNG only.
+			if format == ScreenshotJPEG {
+				transcoded, transcodeError := transcodePNGToJPEG(image, options.quality())
+				if transcodeError != nil {
+					return fmt.Errorf("capturing screenshot of %q: %w", selector, transcodeError)
+				}
+				image = transcoded
+			}
+			result.Screenshots = append(result.Screenshots, Screenshot{Selector: selector, Format: format, Image: image})
+			return nil
+		}))
+	}
+	if options.PDF {
+		actions = append(actions, chromedp.ActionFunc(func(ctx context.Context) error {
+			document, _, printError := page.PrintToPDF().WithPrintBackground(true).Do(ctx)
+			if printError != nil {
+				return fmt.Errorf("printing page to PDF: %w", printError)
+			}
+			result.PDF = document
+			return nil
+		}))
+	}
+	if options.MHTML {
+		actions = append(actions, chromedp.ActionFunc(func(ctx context.Context) error {
+			snapshot, snapshotError := page.CaptureSnapshot().WithFormat(page.CaptureSnapshotFormatMhtml).Do(ctx)
+			if snapshotError != nil {
+				return fmt.Errorf("capturing MHTML snapshot: %w", snapshotError)
+			}
+			result.MHTML = []byte(snapshot)
+			return nil
+		}))
+	}
+	return actions
+}
+
+func transcodePNGToJPEG(pngImage []byte, quality int) ([]byte, error) {
+	decoded, decodeError := png.Decode(bytes.NewReader(pngImage))
+	if decodeError != nil {
+		return nil, fmt.Errorf("decoding PNG screenshot: %w", decodeError)
+	}
+	var encoded bytes.Buffer
+	if encodeError := jpeg.Encode(&encoded, decoded, &jpeg.Options{Quality: quality}); encodeError != nil {
+		return nil, fmt.Errorf("encoding JPEG screenshot: %w", encodeError)
+	}
+	return encoded.Bytes(), nil
+}
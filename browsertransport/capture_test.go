@@ -0,0 +1,138 @@
+package browsertransport
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/chromedp/chromedp"
+)
+
+func TestCaptureOptionsValidate(t *testing.T) {
+	validOptions := []CaptureOptions{
+		{},
+		{FullPageScreenshot: true, ScreenshotFormat: ScreenshotJPEG, ScreenshotQuality: 75},
+		{ElementScreenshots: []string{"#price"}, PDF: true, MHTML: true},
+	}
+	for _, options := range validOptions {
+		if validateError := options.validate(); validateError != nil {
+			t.Fatalf("validate(%#v) error = %v", options, validateError)
+		}
+	}
+
+	invalidOptions := map[string]CaptureOptions{
+		"unsupported screenshot format": {ScreenshotFormat: "webp"},
+		"outside 1-100":                 {ScreenshotQuality: 101},
+		"selector 1 is empty":           {ElementScreenshots: []string{"#price", ""}},
+	}
+	for want, options := range invalidOptions {
+		if validateError := options.validate(); validateError == nil || !strings.Contains(validateError.Error(), want) {
+			t.Fatalf("validate(%#v) error = %v, want %q", options, validateError, want)
+		}
+	}
+
+	if actions := (CaptureOptions{}).captureActions(&Result{}); len(actions) != 0 {
+		t.Fatalf("captureActions(zero) = %d actions", len(actions))
+	}
+	allOptions := CaptureOptions{FullPageScreenshot: true, ElementScreenshots: []string{"#a", "#b"}, PDF: true, MHTML: true}
+	if actions := allOptions.captureActions(&Result{}); len(actions) != 5 {
+		t.Fatalf("captureActions(all) = %d actions, want 5", len(actions))
+	}
+}
+
+func TestResultArtifacts(t *testing.T) {
+	result := &Result{
+		Screenshots: []Screenshot{
+			{Format: ScreenshotJPEG, Image: []byte("full")},
+			{Selector: "#price", Format: ScreenshotJPEG, Image: []byte("price")},
+			{Selector: ".gallery", Format: ScreenshotJPEG, Image: []byte("gallery")},
+		},
+		PDF:   []byte("%PDF"),
+		MHTML: []byte("MIME-Version: 1.0"),
+	}
+
+	artifacts := result.Artifacts()
+	wantNames := []string{"screenshot.jpeg", "screenshot-1.jpeg", "screenshot-2.jpeg", "page.pdf", "page.mhtml"}
+	if len(artifacts) != len(wantNames) {
+		t.Fatalf("Artifacts() = %#v", artifacts)
+	}
+	for artifactIndex, wantName := range wantNames {
+		if artifacts[artifactIndex].Name != wantName {
+			t.Fatalf("Artifacts()[%d].Name = %q, want %q", artifactIndex, artifacts[artifactIndex].Name, wantName)
+		}
+	}
+	if string(artifacts[2].Content) != "gallery" {
+		t.Fatalf("Artifacts()[2].Content = %q", artifacts[2].Content)
+	}
+
+	if artifacts := (&Result{HTML: "<html></html>"}).Artifacts(); len(artifacts) != 0 {
+		t.Fatalf("Artifacts(no captures) = %#v", artifacts)
+	}
+	if artifacts := (*Result)(nil).Artifacts(); artifacts != nil {
+		t.Fatalf("Artifacts(nil) = %#v", artifacts)
+	}
+}
+
+func TestTranscodePNGToJPEG(t *testing.T) {
+	source := image.NewRGBA(image.Rect(0, 0, 8, 4))
+	for x := range 8 {
+		for y := range 4 {
+			source.Set(x, y, color.RGBA{R: 200, G: 30, B: 30, A: 255})
+		}
+	}
+	var pngImage bytes.Buffer
+	if encodeError := png.Encode(&pngImage, source); encodeError != nil {
+		t.Fatalf("png.Encode() error = %v", encodeError)
+	}
+
+	jpegImage, transcodeError := transcodePNGToJPEG(pngImage.Bytes(), 80)
+	if transcodeError != nil {
+		t.Fatalf("transcodePNGToJPEG() error = %v", transcodeError)
+	}
+	decoded, decodeError := jpeg.Decode(bytes.NewReader(jpegImage))
+	if decodeError != nil {
+		t.Fatalf("jpeg.Decode() error = %v", decodeError)
+	}
+	if bounds := decoded.Bounds(); bounds.Dx() != 8 || bounds.Dy() != 4 {
+		t.Fatalf("decoded bounds = %v", bounds)
+	}
+
+	if _, transcodeError := transcodePNGToJPEG([]byte("not a png"), 80); transcodeError == nil || !strings.Contains(transcodeError.Error(), "decoding PNG screenshot") {
+		t.Fatalf("transcodePNGToJPEG(invalid) error = %v", transcodeError)
+	}
+}
+
+func TestSessionRenderPageRejectsInvalidCapture(t *testing.T) {
+	restoreHooks := resetBrowserTransportHooks()
+	defer restoreHooks()
+
+	chromedpNewExecAllocator = allocatorContext
+	chromedpNewContext = browserContext
+	runnerCalls := 0
+	chromedpRunner = func(ctx context.Context, actions ...chromedp.Action) error {
+		runnerCalls++
+		return nil
+	}
+
+	session, sessionError := NewSession(context.Background(), BrowserProfile{}, LaunchOptions{})
+	if sessionError != nil {
+		t.Fatalf("NewSession() error = %v", sessionError)
+	}
+	defer session.Close()
+
+	callsBeforeRender := runnerCalls
+	if _, renderError := session.RenderPage(context.Background(), PageRequest{
+		TargetURL: "https://example.com",
+		Capture:   CaptureOptions{ScreenshotFormat: "gif"},
+	}); renderError == nil || !strings.Contains(renderError.Error(), `unsupported screenshot format "gif"`) {
+		t.Fatalf("RenderPage() error = %v", renderError)
+	}
+	if runnerCalls != callsBeforeRender {
+		t.Fatalf("runner calls = %d, want no tab for invalid capture options", runnerCalls-callsBeforeRender)
+	}
+}
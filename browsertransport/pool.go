@@ -0,0 +1,273 @@
+package browsertransport
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPoolSessionsPerProfile = 2
+	defaultPoolTabsPerSession     = 4
+	defaultPoolLaunchTimeout      = 30 * time.Second
+)
+
+// PoolOptions controls how many browsers a Pool runs and how long it keeps them.
+type PoolOptions struct {
+	// Launch is applied to every session the pool starts.
+	Launch LaunchOptions
+	// MaxSessionsPerProfile caps the browser processes per BrowserProfile.
+	// Defaults to 2.
+	MaxSessionsPerProfile int
+	// MaxTabsPerSession caps the tabs rendering at once in one browser.
+	// Defaults to 4.
+	MaxTabsPerSession int
+	// MaxRendersPerSession retires a browser after it served this many tabs,
+	// bounding the memory a long-lived Chrome accumulates. Zero keeps
+	// browsers until they crash or the pool closes.
+	MaxRendersPerSession int
+	// LaunchTimeout bounds how long starting one browser may take; a launch
+	// still running after it is cancelled. Defaults to 30 seconds.
+	LaunchTimeout time.Duration
+}
+
+// Pool shares a bounded set of browser sessions between renders. Each
+// BrowserProfile gets up to MaxSessionsPerProfile sessions of up to
+// MaxTabsPerSession concurrent tabs; further renders wait for a free tab.
+// Sessions are started on demand and replaced after MaxRendersPerSession
+// renders or when their browser dies. A Pool is safe for concurrent use.
+type Pool struct {
+	options PoolOptions
+
+	mu       sync.Mutex
+	profiles map[BrowserProfile]*profileSessions
+	changed  chan struct{}
+	closed   bool
+}
+
+type profileSessions struct {
+	sessions  []*pooledSession
+	launching int
+}
+
+type pooledSession struct {
+	key      BrowserProfile
+	session  *Session
+	tabs     int
+	renders  int
+	retiring bool
+}
+
+var newPoolSession = NewSession
+
+// NewPool returns an empty pool; sessions start with the first render.
+func NewPool(options PoolOptions) *Pool {
+	if options.MaxSessionsPerProfile <= 0 {
+		options.MaxSessionsPerProfile = defaultPoolSessionsPerProfile
+	}
+	if options.MaxTabsPerSession <= 0 {
+		options.MaxTabsPerSession = defaultPoolTabsPerSession
+	}
+	if options.LaunchTimeout <= 0 {
+		options.LaunchTimeout = defaultPoolLaunchTimeout
+	}
+	return &Pool{
+		options:  options,
+		profiles: make(map[BrowserProfile]*profileSessions),
+		changed:  make(chan struct{}),
+	}
+}
+
+// WithTab leases a tab on a session for browserProfile, waiting while every
+// session is at capacity, and runs the callback in it like Session.WithTab.
+func (pool *Pool) WithTab(ctx context.Context, browserProfile BrowserProfile, tabOptions TabOptions, run func(context.Context) error) error {
+	leased, leaseError := pool.lease(ctx, browserProfile)
+	if leaseError != nil {
+		return leaseError
+	}
+	tabError := leased.session.WithTab(ctx, tabOptions, run)
+	pool.release(leased)
+	return tabError
+}
+
+// RenderPage renders a page on a pooled session for browserProfile.
+func (pool *Pool) RenderPage(ctx context.Context, browserProfile BrowserProfile, pageRequest PageRequest) (*Result, error) {
+	leased, leaseError := pool.lease(ctx, browserProfile)
+	if leaseError != nil {
+		return nil, leaseError
+	}
+	result, renderError := leased.session.RenderPage(ctx, pageRequest)
+	pool.release(leased)
+	return result, renderError
+}
+
+// Close shuts every browser down. Tabs still rendering fail and later renders
+// are rejected.
+func (pool *Pool) Close() {
+	pool.mu.Lock()
+	if pool.closed {
+		pool.mu.Unlock()
+		return
+	}
+	pool.closed = true
+	var sessions []*Session
+	for _, profile := range pool.profiles {
+		for _, pooled := range profile.sessions {
+			sessions = append(sessions, pooled.session)
+		}
+	}
+	pool.profiles = make(map[BrowserProfile]*profileSessions)
+	pool.notifyLocked()
+	pool.mu.Unlock()
+
+	for _, session := range sessions {
+		session.Close()
+	}
+}
+
+// lease reserves a tab on the least busy live session for browserProfile,
+// starting a session when all are full and the profile is below its cap. Idle
+// sessions whose browser died are dropped first so they free their slot.
+func (pool *Pool) lease(ctx context.Context, browserProfile BrowserProfile) (*pooledSession, error) {
+	key, normalizeError := normalizeBrowserProfile(browserProfile)
+	if normalizeError != nil {
+		return nil, normalizeError
+	}
+
+	pool.mu.Lock()
+	for {
+		if pool.closed {
+			pool.mu.Unlock()
+			return nil, fmt.Errorf("browser pool is closed")
+		}
+		profile := pool.profiles[key]
+		if profile == nil {
+			profile = &profileSessions{}
+			pool.profiles[key] = profile
+		}
+		if dead := pool.pruneDeadLocked(profile); len(dead) > 0 {
+			pool.mu.Unlock()
+			for _, session := range dead {
+				session.Close()
+			}
+			pool.mu.Lock()
+			pool.notifyLocked()
+			continue
+		}
+		if leased := pool.leastBusyLocked(profile); leased != nil {
+			leased.tabs++
+			pool.mu.Unlock()
+			return leased, nil
+		}
+		if len(profile.sessions)+profile.launching < pool.options.MaxSessionsPerProfile {
+			profile.launching++
+			pool.mu.Unlock()
+			return pool.launch(key, profile)
+		}
+		changed := pool.changed
+		pool.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		pool.mu.Lock()
+	}
+}
+
+// pruneDeadLocked removes the profile's idle sessions whose browser died and
+// returns them for closing outside the lock.
+func (pool *Pool) pruneDeadLocked(profile *profileSessions) []*Session {
+	var dead []*Session
+	live := profile.sessions[:0]
+	for _, pooled := range profile.sessions {
+		if pooled.tabs == 0 && !pooled.session.alive() {
+			dead = append(dead, pooled.session)
+			continue
+		}
+		live = append(live, pooled)
+	}
+	clear(profile.sessions[len(live):])
+	profile.sessions = live
+	return dead
+}
+
+func (pool *Pool) leastBusyLocked(profile *profileSessions) *pooledSession {
+	var leastBusy *pooledSession
+	for _, pooled := range profile.sessions {
+		if pooled.retiring || pooled.tabs >= pool.options.MaxTabsPerSession || !pooled.session.alive() {
+			continue
+		}
+		if leastBusy == nil || pooled.tabs < leastBusy.tabs {
+			leastBusy = pooled
+		}
+	}
+	return leastBusy
+}
+
+// launch starts a session for a reserved launch slot and leases its first tab.
+// The session outlives the render that triggered it, so it is not bound to
+// the render's context. The browser runs under launchCtx for its whole life,
+// so LaunchTimeout cancels it through a timer stopped once the launch returns
+// rather than through a deadline.
+func (pool *Pool) launch(key BrowserProfile, profile *profileSessions) (*pooledSession, error) {
+	launchCtx, cancelLaunch := context.WithCancel(context.Background())
+	launchTimer := time.AfterFunc(pool.options.LaunchTimeout, cancelLaunch)
+	session, sessionError := newPoolSession(launchCtx, key, pool.options.Launch)
+	if !launchTimer.Stop() {
+		if session != nil {
+			session.Close()
+		}
+		session, sessionError = nil, fmt.Errorf("starting browser session: timed out after %s", pool.options.LaunchTimeout)
+	}
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	profile.launching--
+	pool.notifyLocked()
+	if sessionError != nil {
+		return nil, sessionError
+	}
+	if pool.closed {
+		session.Close()
+		return nil, fmt.Errorf("browser pool is closed")
+	}
+	leased := &pooledSession{key: key, session: session, tabs: 1}
+	profile.sessions = append(profile.sessions, leased)
+	return leased, nil
+}
+
+// release returns a tab and retires its session once it crashed or served
+// MaxRendersPerSession tabs, closing it when its last tab is returned.
+func (pool *Pool) release(leased *pooledSession) {
+	pool.mu.Lock()
+	leased.tabs--
+	leased.renders++
+	if !leased.session.alive() || pool.options.MaxRendersPerSession > 0 && leased.renders >= pool.options.MaxRendersPerSession {
+		leased.retiring = true
+	}
+	retired := leased.retiring && leased.tabs == 0
+	if retired {
+		if profile := pool.profiles[leased.key]; profile != nil {
+			for index, pooled := range profile.sessions {
+				if pooled == leased {
+					profile.sessions = append(profile.sessions[:index], profile.sessions[index+1:]...)
+					break
+				}
+			}
+		}
+	}
+	pool.notifyLocked()
+	pool.mu.Unlock()
+
+	if retired {
+		leased.session.Close()
+	}
+}
+
+// notifyLocked wakes every lease waiting for capacity.
+func (pool *Pool) notifyLocked() {
+	close(pool.changed)
+	pool.changed = make(chan struct{})
+}
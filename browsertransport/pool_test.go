@@ -0,0 +1,277 @@
+package browsertransport
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+func stubPoolBrowsers(t *testing.T) *atomic.Int32 {
+	t.Helper()
+	restoreHooks := resetBrowserTransportHooks()
+	t.Cleanup(restoreHooks)
+
+	var launches atomic.Int32
+	chromedpNewExecAllocator = func(parent context.Context, options ...chromedp.ExecAllocatorOption) (context.Context, context.CancelFunc) {
+		launches.Add(1)
+		return context.WithCancel(parent)
+	}
+	chromedpNewContext = browserContext
+	chromedpRunner = func(ctx context.Context, actions ...chromedp.Action) error {
+		return nil
+	}
+	return &launches
+}
+
+func TestPoolBoundsSessionsAndTabs(t *testing.T) {
+	launches := stubPoolBrowsers(t)
+	pool := NewPool(PoolOptions{MaxSessionsPerProfile: 2, MaxTabsPerSession: 2})
+	defer pool.Close()
+
+	var active, maxActive atomic.Int32
+	release := make(chan struct{})
+	var waitGroup sync.WaitGroup
+	tabErrors := make(chan error, 6)
+	for range 6 {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			tabErrors <- pool.WithTab(context.Background(), BrowserProfile{}, TabOptions{}, func(context.Context) error {
+				current := active.Add(1)
+				for {
+					observed := maxActive.Load()
+					if current <= observed || maxActive.CompareAndSwap(observed, current) {
+						break
+					}
+				}
+				<-release
+				active.Add(-1)
+				return nil
+			})
+		}()
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for active.Load() < 4 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := active.Load(); got != 4 {
+		t.Fatalf("active tabs = %d, want 4", got)
+	}
+	close(release)
+	waitGroup.Wait()
+	close(tabErrors)
+
+	for tabError := range tabErrors {
+		if tabError != nil {
+			t.Fatalf("WithTab() error = %v", tabError)
+		}
+	}
+	if got := maxActive.Load(); got != 4 {
+		t.Fatalf("max active tabs = %d, want 4", got)
+	}
+	if got := launches.Load(); got != 2 {
+		t.Fatalf("browser launches = %d, want 2", got)
+	}
+}
+
+func TestPoolRecyclesSessions(t *testing.T) {
+	launches := stubPoolBrowsers(t)
+	pool := NewPool(PoolOptions{MaxSessionsPerProfile: 1, MaxRendersPerSession: 2})
+	defer pool.Close()
+
+	noop := func(context.Context) error { return nil }
+	for renderIndex := range 5 {
+		if tabError := pool.WithTab(context.Background(), BrowserProfile{}, TabOptions{}, noop); tabError != nil {
+			t.Fatalf("WithTab(%d) error = %v", renderIndex, tabError)
+		}
+	}
+	if got := launches.Load(); got != 3 {
+		t.Fatalf("browser launches after render cap = %d, want 3", got)
+	}
+
+	var failTabInit atomic.Bool
+	failTabInit.Store(true)
+	chromedpRunner = func(ctx context.Context, actions ...chromedp.Action) error {
+		if len(actions) == 0 && failTabInit.Swap(false) {
+			return errors.New("target crashed")
+		}
+		return nil
+	}
+	if tabError := pool.WithTab(context.Background(), BrowserProfile{}, TabOptions{}, noop); tabError == nil || !strings.Contains(tabError.Error(), "initializing browser tab") {
+		t.Fatalf("WithTab(crashed) error = %v", tabError)
+	}
+	launchesBeforeRetry := launches.Load()
+	if tabError := pool.WithTab(context.Background(), BrowserProfile{}, TabOptions{}, noop); tabError != nil {
+		t.Fatalf("WithTab(after crash) error = %v", tabError)
+	}
+	if got := launches.Load(); got != launchesBeforeRetry+1 {
+		t.Fatalf("browser launches after crash = %d, want %d", got, launchesBeforeRetry+1)
+	}
+}
+
+func TestPoolWaitCancellationAndClose(t *testing.T) {
+	stubPoolBrowsers(t)
+	pool := NewPool(PoolOptions{MaxSessionsPerProfile: 1, MaxTabsPerSession: 1})
+
+	holding := make(chan struct{})
+	release := make(chan struct{})
+	holderDone := make(chan error, 1)
+	go func() {
+		holderDone <- pool.WithTab(context.Background(), BrowserProfile{}, TabOptions{}, func(context.Context) error {
+			close(holding)
+			<-release
+			return nil
+		})
+	}()
+	<-holding
+
+	waitCtx, cancelWait := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelWait()
+	if _, renderError := pool.RenderPage(waitCtx, BrowserProfile{}, PageRequest{TargetURL: "https://example.com"}); !errors.Is(renderError, context.DeadlineExceeded) {
+		t.Fatalf("RenderPage(waiting) error = %v, want deadline exceeded", renderError)
+	}
+
+	close(release)
+	if holderError := <-holderDone; holderError != nil {
+		t.Fatalf("WithTab(holder) error = %v", holderError)
+	}
+
+	pool.Close()
+	pool.Close()
+	if tabError := pool.WithTab(context.Background(), BrowserProfile{}, TabOptions{}, func(context.Context) error { return nil }); tabError == nil || !strings.Contains(tabError.Error(), "browser pool is closed") {
+		t.Fatalf("WithTab(closed) error = %v", tabError)
+	}
+	if _, renderError := pool.RenderPage(context.Background(), BrowserProfile{URL: "://bad\x00proxy"}, PageRequest{}); renderError == nil {
+		t.Fatal("RenderPage(invalid profile) error = nil")
+	}
+}
+
+func TestRenderPagesUsesBoundedPool(t *testing.T) {
+	launches := stubPoolBrowsers(t)
+
+	targetURLs := []string{"https://example.com/a", "https://example.com/b", "https://example.com/c"}
+	results, resultErrors := RenderPages(context.Background(), targetURLs, Config{MaxSessions: 1, MaxTabsPerSession: 1})
+	for resultIndex, renderError := range resultErrors {
+		if renderError != nil || results[resultIndex] == nil {
+			t.Fatalf("RenderPages()[%d] = %#v, %v", resultIndex, results[resultIndex], renderError)
+		}
+	}
+	if got := launches.Load(); got != 1 {
+		t.Fatalf("browser launches = %d, want 1", got)
+	}
+
+	sharedPool := NewPool(PoolOptions{})
+	defer sharedPool.Close()
+	for range 2 {
+		if _, resultErrors := RenderPages(context.Background(), targetURLs, Config{Pool: sharedPool}); resultErrors[0] != nil {
+			t.Fatalf("RenderPages(shared pool) error = %v", resultErrors[0])
+		}
+	}
+	if got := launches.Load(); got > 3 {
+		t.Fatalf("browser launches with shared pool = %d, want at most 3", got)
+	}
+
+	_, resultErrors = RenderPages(context.Background(), targetURLs, Config{ProxyURL: "://bad\x00proxy"})
+	for resultIndex, renderError := range resultErrors {
+		if renderError == nil {
+			t.Fatalf("RenderPages(invalid proxy)[%d] error = nil", resultIndex)
+		}
+	}
+}
+
+func TestPoolReplacesIdleSessionsWhoseBrowserDied(t *testing.T) {
+	launches := stubPoolBrowsers(t)
+	pool := NewPool(PoolOptions{MaxSessionsPerProfile: 1})
+	defer pool.Close()
+
+	noop := func(context.Context) error { return nil }
+	if tabError := pool.WithTab(context.Background(), BrowserProfile{}, TabOptions{}, noop); tabError != nil {
+		t.Fatalf("WithTab(first) error = %v", tabError)
+	}
+	key, _ := normalizeBrowserProfile(BrowserProfile{})
+	pool.mu.Lock()
+	idle := pool.profiles[key].sessions[0].session
+	pool.mu.Unlock()
+	idle.browserCancel()
+
+	leaseCtx, cancelLease := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancelLease()
+	if tabError := pool.WithTab(leaseCtx, BrowserProfile{}, TabOptions{}, noop); tabError != nil {
+		t.Fatalf("WithTab(after browser died) error = %v", tabError)
+	}
+	if got := launches.Load(); got != 2 {
+		t.Fatalf("browser launches = %d, want 2", got)
+	}
+	idle.mu.Lock()
+	closed := idle.closed
+	idle.mu.Unlock()
+	if !closed {
+		t.Fatal("dead idle session was not closed")
+	}
+	pool.mu.Lock()
+	sessions := len(pool.profiles[key].sessions)
+	pool.mu.Unlock()
+	if sessions != 1 {
+		t.Fatalf("pooled sessions = %d, want 1", sessions)
+	}
+}
+
+func TestPoolTimesOutHangingLaunches(t *testing.T) {
+	launches := stubPoolBrowsers(t)
+	chromedpRunner = func(ctx context.Context, actions ...chromedp.Action) error {
+		<-ctx.Done()
+		return nil
+	}
+	pool := NewPool(PoolOptions{MaxSessionsPerProfile: 1, LaunchTimeout: 20 * time.Millisecond})
+	defer pool.Close()
+	if pool.options.LaunchTimeout != 20*time.Millisecond {
+		t.Fatalf("LaunchTimeout = %s, want 20ms", pool.options.LaunchTimeout)
+	}
+
+	noop := func(context.Context) error { return nil }
+	if tabError := pool.WithTab(context.Background(), BrowserProfile{}, TabOptions{}, noop); tabError == nil || !strings.Contains(tabError.Error(), "timed out after 20ms") {
+		t.Fatalf("WithTab(hanging launch) error = %v", tabError)
+	}
+
+	chromedpRunner = func(ctx context.Context, actions ...chromedp.Action) error {
+		return nil
+	}
+	if tabError := pool.WithTab(context.Background(), BrowserProfile{}, TabOptions{}, noop); tabError != nil {
+		t.Fatalf("WithTab(after timeout) error = %v", tabError)
+	}
+	if got := launches.Load(); got != 2 {
+		t.Fatalf("browser launches = %d, want 2", got)
+	}
+	if got := NewPool(PoolOptions{}).options.LaunchTimeout; got != defaultPoolLaunchTimeout {
+		t.Fatalf("default LaunchTimeout = %s, want %s", got, defaultPoolLaunchTimeout)
+	}
+}
+
+func TestPoolClosesSessionsLaunchedAfterClose(t *testing.T) {
+	stubPoolBrowsers(t)
+	originalNewPoolSession := newPoolSession
+	defer func() { newPoolSession = originalNewPoolSession }()
+
+	pool := NewPool(PoolOptions{})
+	var launched *Session
+	newPoolSession = func(ctx context.Context, browserProfile BrowserProfile, launchOptions LaunchOptions) (*Session, error) {
+		pool.Close()
+		session, sessionError := NewSession(ctx, browserProfile, launchOptions)
+		launched = session
+		return session, sessionError
+	}
+	if tabError := pool.WithTab(context.Background(), BrowserProfile{}, TabOptions{}, func(context.Context) error { return nil }); tabError == nil || !strings.Contains(tabError.Error(), "browser pool is closed") {
+		t.Fatalf("WithTab(closed during launch) error = %v", tabError)
+	}
+	if launched.alive() {
+		t.Fatal("session launched after Close is still alive")
+	}
+}
@@ -0,0 +1,202 @@
+package browsertransport
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// NetworkResponse is a response the page received from a URL matching one of
+// PageRequest.CaptureResponses, such as the XHR or fetch call that loads a
+// price.
+type NetworkResponse struct {
+	URL        string
+	Method     string
+	Status     int
+	StatusText string
+	Headers    http.Header
+	MIMEType   string
+	Body       []byte
+	// BodyError explains a nil Body: the request failed, the body could not
+	// be read from the browser, or it was still loading when the render
+	// finished.
+	BodyError error
+}
+
+var fetchResponseBody = func(ctx context.Context, requestID network.RequestID) ([]byte, error) {
+	var body []byte
+	fetchError := chromedp.Run(ctx, chromedp.ActionFunc(func(runContext context.Context) error {
+		var bodyError error
+		body, bodyError = network.GetResponseBody(requestID).Do(runContext)
+		return bodyError
+	}))
+	return body, fetchError
+}
+
+func compileResponsePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		expression, compileError := regexp.Compile(pattern)
+		if compileError != nil {
+			return nil, fmt.Errorf("invalid response pattern %q: %w", pattern, compileError)
+		}
+		compiled = append(compiled, expression)
+	}
+	return compiled, nil
+}
+
+// responseCollector records the responses of matching requests in the order
+// they arrive and reads each body once its request finished loading.
+type responseCollector struct {
+	ctx      context.Context
+	patterns []*regexp.Regexp
+
+	mu        sync.Mutex
+	methods   map[network.RequestID]string
+	byRequest map[network.RequestID]*collectedResponse
+	ordered   []*collectedResponse
+}
+
+type collectedResponse struct {
+	response NetworkResponse
+	// settled is closed once the body was read or failed.
+	settled chan struct{}
+	loading bool
+}
+
+// newResponseCollector returns nil when there is nothing to capture.
+func newResponseCollector(ctx context.Context, patterns []*regexp.Regexp) *responseCollector {
+	if len(patterns) == 0 {
+		return nil
+	}
+	return &responseCollector{
+		ctx:       ctx,
+		patterns:  patterns,
+		methods:   make(map[network.RequestID]string),
+		byRequest: make(map[network.RequestID]*collectedResponse),
+	}
+}
+
+func (collector *responseCollector) matches(targetURL string) bool {
+	for _, pattern := range collector.patterns {
+		if pattern.MatchString(targetURL) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleEvent runs on the tab's event loop, so body reads are started in
+// their own goroutines.
+func (collector *responseCollector) handleEvent(event any) {
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	switch typedEvent := event.(type) {
+	case *network.EventRequestWillBeSent:
+		if typedEvent.Request != nil && collector.matches(typedEvent.Request.URL) {
+			collector.methods[typedEvent.RequestID] = typedEvent.Request.Method
+		}
+	case *network.EventResponseReceived:
+		if typedEvent.Response == nil || !collector.matches(typedEvent.Response.URL) {
+			return
+		}
+		collected := &collectedResponse{
+			response: NetworkResponse{
+				URL:        typedEvent.Response.URL,
+				Method:     collector.methods[typedEvent.RequestID],
+				Status:     int(typedEvent.Response.Status),
+				StatusText: typedEvent.Response.StatusText,
+				Headers:    responseHeaders(typedEvent.Response.Headers),
+				MIMEType:   typedEvent.Response.MimeType,
+			},
+			settled: make(chan struct{}),
+		}
+		collector.byRequest[typedEvent.RequestID] = collected
+		collector.ordered = append(collector.ordered, collected)
+	case *network.EventLoadingFinished:
+		collected := collector.byRequest[typedEvent.RequestID]
+		if collected == nil || collected.loading {
+			return
+		}
+		collected.loading = true
+		go collector.readBody(typedEvent.RequestID, collected)
+	case *network.EventLoadingFailed:
+		collected := collector.byRequest[typedEvent.RequestID]
+		if collected == nil || collected.loading {
+			return
+		}
+		collected.loading = true
+		collected.response.BodyError = fmt.Errorf("loading response body: %s", typedEvent.ErrorText)
+		close(collected.settled)
+	}
+}
+
+func (collector *responseCollector) readBody(requestID network.RequestID, collected *collectedResponse) {
+	body, bodyError := fetchResponseBody(collector.ctx, requestID)
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	if bodyError != nil {
+		collected.response.BodyError = fmt.Errorf("reading response body: %w", bodyError)
+	} else {
+		collected.response.Body = body
+	}
+	close(collected.settled)
+}
+
+// responses waits, until ctx ends, for the bodies of finished requests and
+// returns every response captured so far.
+func (collector *responseCollector) responses(ctx context.Context) []NetworkResponse {
+	if collector == nil {
+		return nil
+	}
+
+	collector.mu.Lock()
+	pending := make([]*collectedResponse, 0, len(collector.ordered))
+	for _, collected := range collector.ordered {
+		if collected.loading {
+			pending = append(pending, collected)
+		}
+	}
+	collector.mu.Unlock()
+
+	for _, collected := range pending {
+		select {
+		case <-collected.settled:
+		case <-ctx.Done():
+		}
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	responses := make([]NetworkResponse, 0, len(collector.ordered))
+	for _, collected := range collector.ordered {
+		response := collected.response
+		select {
+		case <-collected.settled:
+		default:
+			response.Body = nil
+			response.BodyError = fmt.Errorf("response body still loading when the render finished")
+		}
+		responses = append(responses, response)
+	}
+	return responses
+}
+
+// responseHeaders converts CDP headers, which join repeated values with
+// newlines, to an http.Header.
+func responseHeaders(headers network.Headers) http.Header {
+	converted := make(http.Header, len(headers))
+	for name, value := range headers {
+		for _, line := range strings.Split(fmt.Sprint(value), "\n") {
+			converted.Add(name, line)
+		}
+	}
+	return converted
+}
@@ -0,0 +1,127 @@
+package browsertransport
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+func TestResponseCollectorCapturesMatchingResponses(t *testing.T) {
+	restoreHooks := resetBrowserTransportHooks()
+	defer restoreHooks()
+
+	fetchResponseBody = func(ctx context.Context, requestID network.RequestID) ([]byte, error) {
+		if requestID == "stock" {
+			return nil, errors.New("no resource with given identifier")
+		}
+		return []byte(`{"price":19.99}`), nil
+	}
+
+	patterns, compileError := compileResponsePatterns([]string{`/api/price`, `/api/(stock|reviews)`})
+	if compileError != nil {
+		t.Fatalf("compileResponsePatterns() error = %v", compileError)
+	}
+	collector := newResponseCollector(context.Background(), patterns)
+
+	for _, event := range []any{
+		&network.EventRequestWillBeSent{RequestID: "price", Request: &network.Request{URL: "https://shop.example.com/api/price?sku=1", Method: "POST"}},
+		&network.EventRequestWillBeSent{RequestID: "page", Request: &network.Request{URL: "https://shop.example.com/product/1", Method: "GET"}},
+		&network.EventResponseReceived{RequestID: "page", Response: &network.Response{URL: "https://shop.example.com/product/1", Status: 200}},
+		&network.EventResponseReceived{RequestID: "price", Response: &network.Response{
+			URL:        "https://shop.example.com/api/price?sku=1",
+			Status:     200,
+			StatusText: "OK",
+			Headers:    network.Headers{"content-type": "application/json", "set-cookie": "a=1\nb=2"},
+			MimeType:   "application/json",
+		}},
+		&network.EventResponseReceived{RequestID: "stock", Response: &network.Response{URL: "https://shop.example.com/api/stock", Status: 200}},
+		&network.EventResponseReceived{RequestID: "reviews", Response: &network.Response{URL: "https://shop.example.com/api/reviews", Status: 503}},
+		&network.EventResponseReceived{RequestID: "reviews-retry", Response: &network.Response{URL: "https://shop.example.com/api/reviews?retry=1", Status: 200}},
+		&network.EventLoadingFinished{RequestID: "page"},
+		&network.EventLoadingFinished{RequestID: "price"},
+		&network.EventLoadingFinished{RequestID: "stock"},
+		&network.EventLoadingFailed{RequestID: "reviews", ErrorText: "net::ERR_ABORTED"},
+	} {
+		collector.handleEvent(event)
+	}
+
+	responses := collector.responses(context.Background())
+	if len(responses) != 4 {
+		t.Fatalf("responses = %#v", responses)
+	}
+
+	price := responses[0]
+	if price.Method != "POST" || price.Status != 200 || price.StatusText != "OK" || price.MIMEType != "application/json" || string(price.Body) != `{"price":19.99}` || price.BodyError != nil {
+		t.Fatalf("price response = %#v", price)
+	}
+	if price.Headers.Get("Content-Type") != "application/json" || len(price.Headers.Values("Set-Cookie")) != 2 {
+		t.Fatalf("price headers = %#v", price.Headers)
+	}
+	if stock := responses[1]; stock.Body != nil || stock.BodyError == nil || !strings.Contains(stock.BodyError.Error(), "reading response body") {
+		t.Fatalf("stock response = %#v", stock)
+	}
+	if reviews := responses[2]; reviews.Status != 503 || reviews.BodyError == nil || !strings.Contains(reviews.BodyError.Error(), "net::ERR_ABORTED") {
+		t.Fatalf("reviews response = %#v", reviews)
+	}
+	if retry := responses[3]; retry.BodyError == nil || !strings.Contains(retry.BodyError.Error(), "still loading") {
+		t.Fatalf("unfinished response = %#v", retry)
+	}
+
+	if newResponseCollector(context.Background(), nil) != nil {
+		t.Fatal("newResponseCollector(no patterns) != nil")
+	}
+	if responses := (*responseCollector)(nil).responses(context.Background()); responses != nil {
+		t.Fatalf("nil collector responses = %#v", responses)
+	}
+}
+
+func TestSessionRenderPageCapturesResponses(t *testing.T) {
+	restoreHooks := resetBrowserTransportHooks()
+	defer restoreHooks()
+
+	chromedpNewExecAllocator = allocatorContext
+	chromedpNewContext = browserContext
+	var listener func(any)
+	chromedpListenTarget = func(ctx context.Context, handleEvent func(any)) {
+		listener = handleEvent
+	}
+	fetchResponseBody = func(ctx context.Context, requestID network.RequestID) ([]byte, error) {
+		return []byte(`{"in_stock":true}`), nil
+	}
+	chromedpRunner = func(ctx context.Context, actions ...chromedp.Action) error {
+		if len(actions) > 1 && listener != nil {
+			listener(&network.EventRequestWillBeSent{RequestID: "1", Request: &network.Request{URL: "https://shop.example.com/api/stock", Method: "GET"}})
+			listener(&network.EventResponseReceived{RequestID: "1", Response: &network.Response{URL: "https://shop.example.com/api/stock", Status: 200}})
+			listener(&network.EventLoadingFinished{RequestID: "1"})
+		}
+		return nil
+	}
+
+	session, sessionError := NewSession(context.Background(), BrowserProfile{}, LaunchOptions{})
+	if sessionError != nil {
+		t.Fatalf("NewSession() error = %v", sessionError)
+	}
+	defer session.Close()
+
+	result, renderError := session.RenderPage(context.Background(), PageRequest{
+		TargetURL:        "https://shop.example.com/product/1",
+		CaptureResponses: []string{`/api/stock`},
+	})
+	if renderError != nil {
+		t.Fatalf("RenderPage() error = %v", renderError)
+	}
+	if len(result.Responses) != 1 || string(result.Responses[0].Body) != `{"in_stock":true}` {
+		t.Fatalf("Responses = %#v", result.Responses)
+	}
+
+	if _, renderError := session.RenderPage(context.Background(), PageRequest{
+		TargetURL:        "https://shop.example.com/product/1",
+		CaptureResponses: []string{`/api/(stock`},
+	}); renderError == nil || !strings.Contains(renderError.Error(), "invalid response pattern") {
+		t.Fatalf("RenderPage(invalid pattern) error = %v", renderError)
+	}
+}
@@ -18,6 +18,7 @@ func resetBrowserTransportHooks() func() {
 	originalChromedpListenTarget := chromedpListenTarget
 	originalSetupProxyAuthFn := setupProxyAuthFn
 	originalProxyAuthRunner := proxyAuthRunner
+	originalFetchResponseBody := fetchResponseBody
 
 	return func() {
 		netListen = originalNetListen
@@ -29,6 +30,7 @@ func resetBrowserTransportHooks() func() {
 		chromedpListenTarget = originalChromedpListenTarget
 		setupProxyAuthFn = originalSetupProxyAuthFn
 		proxyAuthRunner = originalProxyAuthRunner
+		fetchResponseBody = originalFetchResponseBody
 	}
 }
 
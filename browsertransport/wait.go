@@ -0,0 +1,225 @@
+package browsertransport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	defaultWaitPollInterval  = 100 * time.Millisecond
+	defaultScrollPause       = 500 * time.Millisecond
+	defaultMaxScrolls        = 50
+	networkIdleCheckInterval = 50 * time.Millisecond
+)
+
+// WaitCondition is one thing a render waits for after navigation. Build
+// conditions with the Wait* and ScrollToBottom helpers and list them in
+// PageRequest.WaitConditions; they are awaited in order. A condition that is
+// not met before its own timeout or the render timeout fails the render with an
+// error naming the condition.
+type WaitCondition struct {
+	description string
+	timeout     time.Duration
+	// arm runs in the tab before navigation, so conditions that observe page
+	// activity see it from the start, and returns the action that waits.
+	arm func(ctx context.Context) chromedp.Action
+}
+
+// String describes the condition as it appears in wait errors.
+func (condition WaitCondition) String() string {
+	return condition.description
+}
+
+// WithTimeout returns a copy of the condition that fails after timeout even
+// when the render timeout leaves more time. Non-positive values keep only the
+// render timeout.
+func (condition WaitCondition) WithTimeout(timeout time.Duration) WaitCondition {
+	condition.timeout = timeout
+	return condition
+}
+
+// WaitVisible waits for selector to match a visible element.
+func WaitVisible(selector string) WaitCondition {
+	return staticWaitCondition(fmt.Sprintf("selector %q visible", selector), chromedp.WaitVisible(selector, chromedp.ByQuery))
+}
+
+// WaitHidden waits for the element matching selector to become invisible. The
+// element must be present; use WaitRemoved for elements that go away.
+func WaitHidden(selector string) WaitCondition {
+	return staticWaitCondition(fmt.Sprintf("selector %q hidden", selector), chromedp.WaitNotVisible(selector, chromedp.ByQuery))
+}
+
+// WaitRemoved waits until no element matches selector.
+func WaitRemoved(selector string) WaitCondition {
+	return staticWaitCondition(fmt.Sprintf("selector %q removed", selector), chromedp.WaitNotPresent(selector, chromedp.ByQuery))
+}
+
+// WaitFunction waits for the JavaScript expression to evaluate to a truthy
+// value, polling every 100ms.
+func WaitFunction(expression string) WaitCondition {
+	return pollWaitCondition(fmt.Sprintf("predicate %q", expression), expression)
+}
+
+// WaitText waits for the page's visible text to contain text.
+func WaitText(text string) WaitCondition {
+	encodedText, _ := json.Marshal(text)
+	expression := fmt.Sprintf(`document.body !== null && document.body.innerText.includes(%s)`, encodedText)
+	return pollWaitCondition(fmt.Sprintf("text %q", text), expression)
+}
+
+// WaitDelay waits a fixed settle delay.
+func WaitDelay(delay time.Duration) WaitCondition {
+	return staticWaitCondition(fmt.Sprintf("delay of %s", delay), chromedp.Sleep(delay))
+}
+
+// WaitNetworkIdle waits until the tab has had no request in flight for quiet.
+// Requests are tracked from before navigation, so the condition also covers
+// the page's own load.
+func WaitNetworkIdle(quiet time.Duration) WaitCondition {
+	return WaitCondition{
+		description: fmt.Sprintf("network idle for %s", quiet),
+		arm: func(ctx context.Context) chromedp.Action {
+			tracker := newNetworkTracker()
+			chromedpListenTarget(ctx, tracker.handleEvent)
+			return chromedp.ActionFunc(func(waitCtx context.Context) error {
+				return tracker.waitIdle(waitCtx, quiet)
+			})
+		},
+	}
+}
+
+// ScrollToBottom scrolls the page to the bottom repeatedly, pausing after each
+// scroll for lazy-loaded content, until the page height stops growing or
+// maxScrolls scrolls were made. A non-positive pause defaults to 500ms and a
+// non-positive maxScrolls to 50.
+func ScrollToBottom(pause time.Duration, maxScrolls int) WaitCondition {
+	if pause <= 0 {
+		pause = defaultScrollPause
+	}
+	if maxScrolls <= 0 {
+		maxScrolls = defaultMaxScrolls
+	}
+	return staticWaitCondition("scroll to bottom", chromedp.ActionFunc(func(ctx context.Context) error {
+		var previousHeight int64 = -1
+		for range maxScrolls {
+			var height int64
+			if scrollError := chromedp.Evaluate(`(() => { window.scrollTo(0, document.body.scrollHeight); return document.body.scrollHeight; })()`, &height).Do(ctx); scrollError != nil {
+				return scrollError
+			}
+			if height == previousHeight {
+				return nil
+			}
+			previousHeight = height
+			if sleepError := chromedp.Sleep(pause).Do(ctx); sleepError != nil {
+				return sleepError
+			}
+		}
+		return nil
+	}))
+}
+
+func staticWaitCondition(description string, action chromedp.Action) WaitCondition {
+	return WaitCondition{
+		description: description,
+		arm: func(context.Context) chromedp.Action {
+			return action
+		},
+	}
+}
+
+func pollWaitCondition(description string, expression string) WaitCondition {
+	return WaitCondition{
+		description: description,
+		arm: func(context.Context) chromedp.Action {
+			var satisfied any
+			return chromedp.Poll(expression, &satisfied, chromedp.WithPollingInterval(defaultWaitPollInterval), chromedp.WithPollingTimeout(0))
+		},
+	}
+}
+
+// armWaitConditions prepares every condition in the tab and returns the
+// actions that await them, each failing with an error naming its condition.
+func armWaitConditions(ctx context.Context, conditions []WaitCondition) ([]chromedp.Action, error) {
+	actions := make([]chromedp.Action, 0, len(conditions))
+	for conditionIndex, condition := range conditions {
+		if condition.arm == nil {
+			return nil, fmt.Errorf("wait condition %d is not configured", conditionIndex)
+		}
+		actions = append(actions, condition.waitAction(condition.arm(ctx)))
+	}
+	return actions, nil
+}
+
+func (condition WaitCondition) waitAction(action chromedp.Action) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		waitCtx := ctx
+		if condition.timeout > 0 {
+			var cancelWait context.CancelFunc
+			waitCtx, cancelWait = context.WithTimeout(ctx, condition.timeout)
+			defer cancelWait()
+		}
+		if waitError := action.Do(waitCtx); waitError != nil {
+			if contextError := waitCtx.Err(); contextError != nil {
+				return fmt.Errorf("waiting for %s: %w", condition.description, contextError)
+			}
+			return fmt.Errorf("waiting for %s: %w", condition.description, waitError)
+		}
+		return nil
+	})
+}
+
+// networkTracker counts a tab's in-flight requests and when the last one
+// started or ended.
+type networkTracker struct {
+	mu           sync.Mutex
+	inFlight     map[network.RequestID]struct{}
+	lastActivity time.Time
+}
+
+func newNetworkTracker() *networkTracker {
+	return &networkTracker{
+		inFlight:     make(map[network.RequestID]struct{}),
+		lastActivity: time.Now(),
+	}
+}
+
+func (tracker *networkTracker) handleEvent(event any) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	switch typedEvent := event.(type) {
+	case *network.EventRequestWillBeSent:
+		tracker.inFlight[typedEvent.RequestID] = struct{}{}
+	case *network.EventLoadingFinished:
+		delete(tracker.inFlight, typedEvent.RequestID)
+	case *network.EventLoadingFailed:
+		delete(tracker.inFlight, typedEvent.RequestID)
+	default:
+		return
+	}
+	tracker.lastActivity = time.Now()
+}
+
+func (tracker *networkTracker) idleFor(quiet time.Duration) bool {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	return len(tracker.inFlight) == 0 && time.Since(tracker.lastActivity) >= quiet
+}
+
+func (tracker *networkTracker) waitIdle(ctx context.Context, quiet time.Duration) error {
+	ticker := time.NewTicker(networkIdleCheckInterval)
+	defer ticker.Stop()
+	for !tracker.idleFor(quiet) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
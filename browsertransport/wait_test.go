@@ -0,0 +1,128 @@
+package browsertransport
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+func TestWaitConditionDescriptions(t *testing.T) {
+	testCases := []struct {
+		condition WaitCondition
+		want      string
+	}{
+		{WaitVisible("#app"), `selector "#app" visible`},
+		{WaitHidden(".spinner"), `selector ".spinner" hidden`},
+		{WaitRemoved(".skeleton"), `selector ".skeleton" removed`},
+		{WaitFunction("window.ready === true"), `predicate "window.ready === true"`},
+		{WaitText("In stock"), `text "In stock"`},
+		{WaitDelay(250 * time.Millisecond), "delay of 250ms"},
+		{WaitNetworkIdle(500 * time.Millisecond), "network idle for 500ms"},
+		{ScrollToBottom(0, 0), "scroll to bottom"},
+	}
+	for _, testCase := range testCases {
+		if got := testCase.condition.String(); got != testCase.want {
+			t.Fatalf("String() = %q, want %q", got, testCase.want)
+		}
+		if testCase.condition.arm == nil {
+			t.Fatalf("%s: arm = nil", testCase.want)
+		}
+	}
+}
+
+func TestWaitConditionErrorsNameCondition(t *testing.T) {
+	blocking := staticWaitCondition("hydration", chromedp.ActionFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return errors.New("poll aborted")
+	})).WithTimeout(10 * time.Millisecond)
+
+	actions, armError := armWaitConditions(context.Background(), []WaitCondition{blocking})
+	if armError != nil {
+		t.Fatalf("armWaitConditions() error = %v", armError)
+	}
+	waitError := actions[0].Do(context.Background())
+	if waitError == nil || !strings.Contains(waitError.Error(), "waiting for hydration") || !errors.Is(waitError, context.DeadlineExceeded) {
+		t.Fatalf("wait error = %v", waitError)
+	}
+
+	failing := staticWaitCondition("banner", chromedp.ActionFunc(func(context.Context) error {
+		return errors.New("node not found")
+	}))
+	actions, _ = armWaitConditions(context.Background(), []WaitCondition{failing})
+	if waitError := actions[0].Do(context.Background()); waitError == nil || waitError.Error() != "waiting for banner: node not found" {
+		t.Fatalf("wait error = %v", waitError)
+	}
+
+	if _, armError := armWaitConditions(context.Background(), []WaitCondition{WaitDelay(0), {}}); armError == nil || !strings.Contains(armError.Error(), "wait condition 1") {
+		t.Fatalf("armWaitConditions(zero condition) error = %v", armError)
+	}
+}
+
+func TestWaitNetworkIdleTracksRequests(t *testing.T) {
+	restoreHooks := resetBrowserTransportHooks()
+	defer restoreHooks()
+
+	var handleEvent func(any)
+	chromedpListenTarget = func(ctx context.Context, listener func(any)) {
+		handleEvent = listener
+	}
+
+	actions, armError := armWaitConditions(context.Background(), []WaitCondition{WaitNetworkIdle(30 * time.Millisecond)})
+	if armError != nil || handleEvent == nil {
+		t.Fatalf("armWaitConditions() error = %v, listener registered = %v", armError, handleEvent != nil)
+	}
+	handleEvent(&network.EventRequestWillBeSent{RequestID: "document"})
+	handleEvent(&network.EventRequestWillBeSent{RequestID: "xhr"})
+	handleEvent(&network.EventLoadingFinished{RequestID: "document"})
+
+	busyCtx, cancelBusy := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancelBusy()
+	if waitError := actions[0].Do(busyCtx); !errors.Is(waitError, context.DeadlineExceeded) || !strings.Contains(waitError.Error(), "network idle for 30ms") {
+		t.Fatalf("wait with request in flight error = %v", waitError)
+	}
+
+	handleEvent(&network.EventLoadingFailed{RequestID: "xhr"})
+	started := time.Now()
+	if waitError := actions[0].Do(context.Background()); waitError != nil {
+		t.Fatalf("wait after requests settled error = %v", waitError)
+	}
+	if elapsed := time.Since(started); elapsed < 20*time.Millisecond {
+		t.Fatalf("network idle returned after %s, before the quiet period", elapsed)
+	}
+}
+
+func TestSessionRenderPageRejectsUnconfiguredWaitCondition(t *testing.T) {
+	restoreHooks := resetBrowserTransportHooks()
+	defer restoreHooks()
+
+	chromedpNewExecAllocator = allocatorContext
+	chromedpNewContext = browserContext
+	runnerCalls := 0
+	chromedpRunner = func(ctx context.Context, actions ...chromedp.Action) error {
+		runnerCalls++
+		return nil
+	}
+
+	session, sessionError := NewSession(context.Background(), BrowserProfile{}, LaunchOptions{})
+	if sessionError != nil {
+		t.Fatalf("NewSession() error = %v", sessionError)
+	}
+	defer session.Close()
+
+	callsBeforeRender := runnerCalls
+	_, renderError := session.RenderPage(context.Background(), PageRequest{
+		TargetURL:      "https://example.com",
+		WaitConditions: []WaitCondition{WaitText("ready"), {}},
+	})
+	if renderError == nil || !strings.Contains(renderError.Error(), "wait condition 2 is not configured") {
+		t.Fatalf("RenderPage() error = %v", renderError)
+	}
+	if renderCalls := runnerCalls - callsBeforeRender; renderCalls != 2 {
+		t.Fatalf("runner calls = %d, want tab init and prelude only", renderCalls)
+	}
+}
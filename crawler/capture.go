@@ -0,0 +1,510 @@
+package crawler
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gocolly/colly/v2"
+)
+
+const (
+	captureHeader          = "X-Crawler-Capture"
+	ctxCaptureKey          = "crawler_capture"
+	defaultCaptureBodySize = 1 << 20
+	harExtension           = "har"
+)
+
+// ErrCaptureOutputRequired is reported by Config.Validate when capture is
+// enabled with neither an OutputDirectory nor a FilePersister to write to.
+var ErrCaptureOutputRequired = errors.New("crawler: capture requires an output directory or file persister")
+
+// CaptureConfig enables debug capture: every HTTP exchange of a selected
+// product, redirects and retries included, is recorded with its headers,
+// httptrace timings and body, and saved through the FilePersister as
+// "<product id>.har" once the product's result is emitted. Capture is enabled
+// when SampleRate or Filter is set and needs OutputDirectory or FilePersister.
+type CaptureConfig struct {
+	// SampleRate is the fraction of products captured, between 0 and 1.
+	// Defaults to 1 when only Filter is set.
+	SampleRate float64
+	// Filter is optional; when set only products it accepts are sampled.
+	Filter func(Product) bool
+	// MaxBodyBytes caps the response body recorded per exchange. Optional;
+	// defaults to 1 MiB.
+	MaxBodyBytes int
+}
+
+// Enabled reports whether any product can be captured.
+func (cfg CaptureConfig) Enabled() bool {
+	return cfg.SampleRate > 0 || cfg.Filter != nil
+}
+
+// Validate checks the numeric bounds, reporting every problem.
+func (cfg CaptureConfig) Validate() error {
+	var problems []error
+	if cfg.SampleRate < 0 || cfg.SampleRate > 1 {
+		problems = append(problems, fmt.Errorf("capture sample rate must be between 0 and 1 (got %g)", cfg.SampleRate))
+	}
+	if cfg.MaxBodyBytes < 0 {
+		problems = append(problems, fmt.Errorf("capture max body bytes must be non-negative (got %d)", cfg.MaxBodyBytes))
+	}
+	return errors.Join(problems...)
+}
+
+func (cfg CaptureConfig) selects(product Product) bool {
+	if !cfg.Enabled() || cfg.Filter != nil && !cfg.Filter(product) {
+		return false
+	}
+	rate := cfg.SampleRate
+	if rate == 0 {
+		rate = 1
+	}
+	return rate >= 1 || rand.Float64() < rate
+}
+
+func (cfg CaptureConfig) maxBodyBytes() int {
+	if cfg.MaxBodyBytes == 0 {
+		return defaultCaptureBodySize
+	}
+	return cfg.MaxBodyBytes
+}
+
+// captureRegistry tracks the recordings of products being captured, keyed by
+// the token carried in captureHeader.
+type captureRegistry struct {
+	config   CaptureConfig
+	sequence atomic.Uint64
+	mu       sync.Mutex
+	active   map[string]*productCapture
+}
+
+func newCaptureRegistry(config CaptureConfig) *captureRegistry {
+	if !config.Enabled() {
+		return nil
+	}
+	return &captureRegistry{config: config, active: make(map[string]*productCapture)}
+}
+
+// start opens a recording for product when the config selects it.
+func (registry *captureRegistry) start(ctx *colly.Context, product Product) {
+	if registry == nil || !registry.config.selects(product) {
+		return
+	}
+	token := strconv.FormatUint(registry.sequence.Add(1), 10)
+	registry.mu.Lock()
+	registry.active[token] = &productCapture{}
+	registry.mu.Unlock()
+	ctx.Put(ctxCaptureKey, token)
+}
+
+func (registry *captureRegistry) lookup(token string) *productCapture {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	return registry.active[token]
+}
+
+// finish closes the product's recording and returns it as a HAR document.
+func (registry *captureRegistry) finish(ctx *colly.Context) ([]byte, bool, error) {
+	if registry == nil || ctx == nil {
+		return nil, false, nil
+	}
+	token := ctx.Get(ctxCaptureKey)
+	registry.mu.Lock()
+	capture, ok := registry.active[token]
+	delete(registry.active, token)
+	registry.mu.Unlock()
+	if !ok {
+		return nil, false, nil
+	}
+	encoded, err := json.MarshalIndent(harDocument{Log: harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: "github.com/tyemirov/utils/crawler", Version: "1"},
+		Entries: capture.snapshot(),
+	}}, "", "  ")
+	return encoded, true, err
+}
+
+// registerCapture copies the capture token onto every attempt so
+// captureTransport can record it.
+func registerCapture(collector *colly.Collector) {
+	collector.OnRequest(func(request *colly.Request) {
+		if token := request.Ctx.Get(ctxCaptureKey); token != "" {
+			request.Headers.Set(captureHeader, token)
+		}
+	})
+}
+
+// saveCapture persists the product's HAR document, if it was captured.
+func (service *Service) saveCapture(resp *colly.Response) {
+	if service.captures == nil || resp == nil {
+		return
+	}
+	document, ok, err := service.captures.finish(resp.Ctx)
+	if !ok {
+		return
+	}
+	productID := getProductIDFromContext(resp)
+	if err == nil {
+		err = service.filePersister.Save(productID, fmt.Sprintf("%s.%s", productID, harExtension), document)
+	}
+	if err != nil {
+		service.logger.Error("Failed to save capture for ProductID %s: %v", productID, err)
+	}
+}
+
+type productCapture struct {
+	mu      sync.Mutex
+	entries []harEntry
+}
+
+func (capture *productCapture) add(entry harEntry) {
+	capture.mu.Lock()
+	defer capture.mu.Unlock()
+	capture.entries = append(capture.entries, entry)
+}
+
+func (capture *productCapture) snapshot() []harEntry {
+	capture.mu.Lock()
+	defer capture.mu.Unlock()
+	return append([]harEntry{}, capture.entries...)
+}
+
+// captureTransport records the exchanges of captured requests. It sits next
+// to the base transport so timings and headers are those on the wire.
+type captureTransport struct {
+	base     http.RoundTripper
+	registry *captureRegistry
+}
+
+func newCaptureTransport(base http.RoundTripper, registry *captureRegistry) http.RoundTripper {
+	if registry == nil {
+		return base
+	}
+	return &captureTransport{base: base, registry: registry}
+}
+
+func (transport *captureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token := req.Header.Get(captureHeader)
+	if token == "" {
+		return transport.base.RoundTrip(req)
+	}
+	outbound := req.Clone(req.Context())
+	outbound.Header.Del(captureHeader)
+	capture := transport.registry.lookup(token)
+	if capture == nil {
+		return transport.base.RoundTrip(outbound)
+	}
+
+	exchange := &capturedExchange{capture: capture, started: time.Now(), limit: transport.registry.config.maxBodyBytes()}
+	outbound = outbound.WithContext(httptrace.WithClientTrace(outbound.Context(), exchange.trace()))
+	exchange.entry.Request = harRequestFor(outbound)
+	resp, err := transport.base.RoundTrip(outbound)
+	exchange.entry.Proxy = sanitizeProxyURL(recordedProxyURL(outbound.Context()))
+	if err != nil || resp == nil {
+		exchange.entry.Error = fmt.Sprint(err)
+		exchange.finish(nil)
+		return resp, err
+	}
+	exchange.entry.Response = harResponseFor(resp)
+	if resp.Body == nil {
+		exchange.finish(nil)
+	} else {
+		resp.Body = &capturingBody{ReadCloser: resp.Body, exchange: exchange}
+	}
+	resp.Request = req
+	return resp, nil
+}
+
+// capturedExchange assembles one HAR entry while the request runs.
+type capturedExchange struct {
+	capture *productCapture
+	entry   harEntry
+	limit   int
+
+	mu         sync.Mutex
+	started    time.Time
+	dnsStart   time.Time
+	dnsDone    time.Time
+	connStart  time.Time
+	connDone   time.Time
+	tlsStart   time.Time
+	tlsDone    time.Time
+	gotConn    time.Time
+	wrote      time.Time
+	firstByte  time.Time
+	body       bytes.Buffer
+	bodySize   int
+	remoteAddr string
+	reused     bool
+	once       sync.Once
+}
+
+func (exchange *capturedExchange) mark(target *time.Time) {
+	exchange.mu.Lock()
+	defer exchange.mu.Unlock()
+	if target.IsZero() {
+		*target = time.Now()
+	}
+}
+
+func (exchange *capturedExchange) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { exchange.mark(&exchange.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { exchange.mark(&exchange.dnsDone) },
+		ConnectStart:      func(string, string) { exchange.mark(&exchange.connStart) },
+		ConnectDone:       func(string, string, error) { exchange.mark(&exchange.connDone) },
+		TLSHandshakeStart: func() { exchange.mark(&exchange.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { exchange.mark(&exchange.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			exchange.mark(&exchange.gotConn)
+			exchange.mu.Lock()
+			exchange.reused = info.Reused
+			if info.Conn != nil {
+				exchange.remoteAddr = info.Conn.RemoteAddr().String()
+			}
+			exchange.mu.Unlock()
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { exchange.mark(&exchange.wrote) },
+		GotFirstResponseByte: func() { exchange.mark(&exchange.firstByte) },
+	}
+}
+
+func (exchange *capturedExchange) record(chunk []byte) {
+	exchange.mu.Lock()
+	defer exchange.mu.Unlock()
+	exchange.bodySize += len(chunk)
+	if room := exchange.limit - exchange.body.Len(); room > 0 {
+		exchange.body.Write(chunk[:min(room, len(chunk))])
+	}
+}
+
+// finish stores the entry once, when the body is closed or the exchange
+// failed.
+func (exchange *capturedExchange) finish(readErr error) {
+	exchange.once.Do(func() {
+		finished := time.Now()
+		exchange.mu.Lock()
+		entry := exchange.entry
+		entry.StartedDateTime = exchange.started.Format(time.RFC3339Nano)
+		entry.Time = milliseconds(exchange.started, finished)
+		entry.ServerIPAddress, entry.Connection = exchange.remoteAddr, ""
+		if exchange.reused {
+			entry.Connection = "reused"
+		}
+		entry.Timings = exchange.timings(finished)
+		if entry.Response.Status != 0 {
+			entry.Response.Content = harContentFor(entry.Response.Content.MimeType, exchange.body.Bytes(), exchange.bodySize)
+			entry.Response.BodySize = exchange.bodySize
+		}
+		exchange.mu.Unlock()
+		if readErr != nil && readErr != io.EOF && entry.Error == "" {
+			entry.Error = readErr.Error()
+		}
+		exchange.capture.add(entry)
+	})
+}
+
+// timings splits the exchange into HAR phases; phases that did not happen,
+// such as DNS on a reused connection, are -1.
+func (exchange *capturedExchange) timings(finished time.Time) harTimings {
+	timings := harTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1}
+	if !exchange.gotConn.IsZero() {
+		timings.Blocked = milliseconds(exchange.started, exchange.gotConn)
+	}
+	if !exchange.dnsDone.IsZero() {
+		timings.DNS = milliseconds(exchange.dnsStart, exchange.dnsDone)
+	}
+	if !exchange.connDone.IsZero() {
+		timings.Connect = milliseconds(exchange.connStart, exchange.connDone)
+	}
+	if !exchange.tlsDone.IsZero() {
+		timings.SSL = milliseconds(exchange.tlsStart, exchange.tlsDone)
+	}
+	if !exchange.wrote.IsZero() {
+		timings.Send = milliseconds(exchange.gotConn, exchange.wrote)
+		if !exchange.firstByte.IsZero() {
+			timings.Wait = milliseconds(exchange.wrote, exchange.firstByte)
+		}
+	}
+	if !exchange.firstByte.IsZero() {
+		timings.Receive = milliseconds(exchange.firstByte, finished)
+	}
+	return timings
+}
+
+// capturingBody tees the response body into its exchange.
+type capturingBody struct {
+	io.ReadCloser
+	exchange *capturedExchange
+}
+
+func (body *capturingBody) Read(buffer []byte) (int, error) {
+	count, err := body.ReadCloser.Read(buffer)
+	body.exchange.record(buffer[:count])
+	if err != nil {
+		body.exchange.finish(err)
+	}
+	return count, err
+}
+
+func (body *capturingBody) Close() error {
+	err := body.ReadCloser.Close()
+	body.exchange.finish(nil)
+	return err
+}
+
+func milliseconds(from, to time.Time) float64 {
+	if from.IsZero() || to.Before(from) {
+		return 0
+	}
+	return float64(to.Sub(from).Microseconds()) / 1000
+}
+
+func harRequestFor(req *http.Request) harRequest {
+	query := []harNameValue{}
+	for name, values := range req.URL.Query() {
+		for _, value := range values {
+			query = append(query, harNameValue{Name: name, Value: value})
+		}
+	}
+	headers := harHeaders(req.Header)
+	if req.Host != "" {
+		headers = append([]harNameValue{{Name: "Host", Value: req.Host}}, headers...)
+	}
+	return harRequest{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: req.Proto,
+		Headers:     headers,
+		QueryString: query,
+		Cookies:     []harNameValue{},
+		HeadersSize: -1,
+		BodySize:    -1,
+	}
+}
+
+func harResponseFor(resp *http.Response) harResponse {
+	return harResponse{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: resp.Proto,
+		Headers:     harHeaders(resp.Header),
+		Cookies:     []harNameValue{},
+		Content:     harContent{MimeType: resp.Header.Get("Content-Type")},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+	}
+}
+
+func harContentFor(mimeType string, recorded []byte, size int) harContent {
+	content := harContent{Size: size, MimeType: mimeType}
+	if utf8.Valid(recorded) {
+		content.Text = string(recorded)
+	} else {
+		content.Text = base64.StdEncoding.EncodeToString(recorded)
+		content.Encoding = "base64"
+	}
+	if len(recorded) < size {
+		content.Comment = fmt.Sprintf("truncated to %d of %d bytes", len(recorded), size)
+	}
+	return content
+}
+
+func harHeaders(header http.Header) []harNameValue {
+	headers := []harNameValue{}
+	for name, values := range header {
+		for _, value := range values {
+			headers = append(headers, harNameValue{Name: name, Value: value})
+		}
+	}
+	return headers
+}
+
+// The har* types follow the HTTP Archive 1.2 format; fields prefixed with an
+// underscore are custom extensions.
+type harDocument struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Connection      string      `json:"connection,omitempty"`
+	Proxy           string      `json:"_proxy,omitempty"`
+	Error           string      `json:"_error,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	SSL     float64 `json:"ssl"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
@@ -63,6 +63,9 @@ func (cfg Config) Validate() error {
 	if cfg.RuleEvaluator == nil {
 		return errors.New("crawler: rule evaluator is required")
 	}
+	if cfg.Scraper.Capture.Enabled() && cfg.FilePersister == nil && cfg.OutputDirectory == "" {
+		return ErrCaptureOutputRequired
+	}
 	return nil
 }
 
@@ -77,23 +80,111 @@ type ScraperConfig struct {
 	ProxyList                  []string
 	SaveFiles                  bool
 	ProxyCircuitBreakerEnabled bool
+
+	// ProductTimeout bounds the total time from a product's first request
+	// through all of its retries and proxy rotations, unlike HTTPTimeout which
+	// bounds one idle connection. A product that runs out fails with
+	// ErrProductTimeout and frees its slot. Optional; zero leaves products
+	// unbounded and Product.Timeout overrides it.
+	ProductTimeout time.Duration
+
+	// Capture is optional; when enabled it records the HTTP exchanges of
+	// selected products into HAR files for debugging.
+	Capture CaptureConfig
+
+	// MaxBodySize caps the bytes read from a product page. Larger pages fail
+	// with ErrBodyTooLarge without being parsed or retried. Optional; defaults
+	// to 10 MiB.
+	MaxBodySize int
+
+	// ProxyTags is optional; it attributes proxies, keyed by their entry in
+	// ProxyList or ProxySource, for example with country, vendor and type.
+	// Products that set Product.ProxyTags only use proxies that match.
+	ProxyTags map[string]ProxyTags
+
+	// ProxySource is optional; when set it replaces ProxyList and is consulted
+	// before every request so proxies can be added or retired mid-run.
+	ProxySource ProxySource
+
+	// ProxyProbe is optional; when its ProbeURL is set Run checks every proxy
+	// before crawling and fails fast when too few are usable.
+	ProxyProbe ProxyProbeConfig
+
+	// CoalesceDuplicateURLs shares one fetch between products that request the
+	// same normalized URL concurrently. Each product still gets its own Result.
+	// Products selected by Capture are not coalesced, so each gets its HAR.
+	CoalesceDuplicateURLs bool
+
+	// ExtractStructuredData parses JSON-LD, microdata and OpenGraph data from
+	// each page before BeforeEvaluation handlers run; read it with
+	// structured.FromContext(resp.Ctx).
+	ExtractStructuredData bool
+
+	// RespectRobotsTxt fetches robots.txt once per host through the configured
+	// proxies before the host's first product, without holding up products for
+	// other hosts. Disallowed products fail with ErrDisallowedByRobots without
+	// being visited. A Crawl-delay becomes the host's colly limit: one request
+	// at a time, spaced by the longer of Crawl-delay and RateLimit.
+	RespectRobotsTxt bool
+
+	// RobotsUserAgent selects the robots.txt group to obey. Optional; the "*"
+	// group applies when it is empty or unmatched.
+	RobotsUserAgent string
 }
 
-// Validate checks that essential numeric fields are positive.
+// Validate checks that essential numeric fields are positive. Every problem is
+// reported, joined with errors.Join.
 func (cfg ScraperConfig) Validate() error {
+	var problems []error
 	if cfg.Parallelism <= 0 {
-		return fmt.Errorf("parallelism must be greater than zero (got %d)", cfg.Parallelism)
+		problems = append(problems, fmt.Errorf("parallelism must be greater than zero (got %d)", cfg.Parallelism))
 	}
 	if cfg.RetryCount < 0 {
-		return fmt.Errorf("retry count must be non-negative (got %d)", cfg.RetryCount)
+		problems = append(problems, fmt.Errorf("retry count must be non-negative (got %d)", cfg.RetryCount))
 	}
 	if cfg.MaxDepth < 0 {
-		return fmt.Errorf("max depth must be non-negative (got %d)", cfg.MaxDepth)
+		problems = append(problems, fmt.Errorf("max depth must be non-negative (got %d)", cfg.MaxDepth))
 	}
 	if cfg.RateLimit < 0 {
-		return fmt.Errorf("rate limit must be non-negative (got %s)", cfg.RateLimit)
+		problems = append(problems, fmt.Errorf("rate limit must be non-negative (got %s)", cfg.RateLimit))
 	}
-	return nil
+	if cfg.ProductTimeout < 0 {
+		problems = append(problems, fmt.Errorf("product timeout must be non-negative (got %s)", cfg.ProductTimeout))
+	}
+	if cfg.MaxBodySize < 0 {
+		problems = append(problems, fmt.Errorf("max body size must be non-negative (got %d)", cfg.MaxBodySize))
+	}
+	if err := cfg.Capture.Validate(); err != nil {
+		problems = append(problems, err)
+	}
+	if cfg.ProxyProbe.Enabled() {
+		if err := cfg.ProxyProbe.Validate(); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	return errors.Join(problems...)
+}
+
+func (cfg ScraperConfig) maxBodySize() int {
+	if cfg.MaxBodySize == 0 {
+		return defaultMaxBodySize
+	}
+	return cfg.MaxBodySize
+}
+
+func (cfg ScraperConfig) currentProxies() ([]string, error) {
+	if cfg.ProxySource == nil {
+		return append([]string(nil), cfg.ProxyList...), nil
+	}
+	return cachedProxies(cfg.ProxySource)
+}
+
+func (cfg ScraperConfig) proxyPoolSize() int {
+	if cfg.ProxySource == nil {
+		return len(cfg.ProxyList)
+	}
+	proxies, _ := cfg.currentProxies()
+	return countProxies(proxies)
 }
 
 // PlatformConfig restricts the crawler to known domains and provides selectors.
@@ -101,12 +192,32 @@ type PlatformConfig struct {
 	AllowedDomains      []string
 	CookieDomains       []string
 	SkipRulesOnRedirect bool
+
+	// ProductIDPatterns are regular expressions that extract the product ID
+	// from a product URL, through a group named "id" or the first capture
+	// group. When set, a redirect is inferred whenever the canonical or final
+	// URL carries a different ID and PlatformHooks did not report one.
+	ProductIDPatterns []string
 }
 
-// Validate ensures the platform configuration is usable.
+// Validate ensures the platform configuration is usable. Every problem is
+// reported, joined with errors.Join.
 func (cfg PlatformConfig) Validate() error {
+	var problems []error
 	if len(cfg.AllowedDomains) == 0 {
-		return errors.New("allowed domains required")
+		problems = append(problems, errors.New("allowed domains required"))
 	}
-	return nil
+	for _, pattern := range cfg.ProductIDPatterns {
+		if _, err := CompileProductURLPatterns([]string{pattern}); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// productIDPatterns compiles ProductIDPatterns; Validate has already
+// reported any invalid expression.
+func (cfg PlatformConfig) productIDPatterns() ProductURLPatterns {
+	patterns, _ := CompileProductURLPatterns(cfg.ProductIDPatterns)
+	return patterns
 }
@@ -15,6 +15,9 @@ const (
 	ctxRedirectedProductKey = "crawler_redirected_product_id"
 	ctxFinalURLKey          = "crawler_final_url"
 	ctxCanonicalURLKey      = "crawler_canonical_url"
+	ctxRedirectTraceKey     = "crawler_redirect_trace"
+	ctxRedirectChainKey     = "crawler_redirect_chain"
+	ctxProxyTagsKey         = "crawler_proxy_tags"
 
 	pageNotFoundText     = "Page Not Found"
 	unknownProductID     = "UnknownProductID"
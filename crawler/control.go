@@ -0,0 +1,131 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrDrainTimeout is returned by Drain when in-flight products did not finish
+// in time and were aborted.
+var ErrDrainTimeout = errors.New("crawler: drain timed out; in-flight products aborted")
+
+// runControl gates product dispatch for Pause, Resume and SetParallelism.
+// Every change closes the current changes channel so a waiting dispatch loop
+// re-evaluates the gate.
+type runControl struct {
+	mu      sync.Mutex
+	paused  bool
+	limit   int
+	changed chan struct{}
+}
+
+func newRunControl(limit int) *runControl {
+	return &runControl{limit: limit, changed: make(chan struct{})}
+}
+
+// admits reports whether another product may be dispatched while inFlight
+// products hold a slot.
+func (control *runControl) admits(inFlight int) bool {
+	control.mu.Lock()
+	defer control.mu.Unlock()
+	return !control.paused && inFlight < control.limit
+}
+
+// changes returns a channel closed on the next state change.
+func (control *runControl) changes() <-chan struct{} {
+	control.mu.Lock()
+	defer control.mu.Unlock()
+	return control.changed
+}
+
+func (control *runControl) update(apply func()) {
+	control.mu.Lock()
+	defer control.mu.Unlock()
+	apply()
+	close(control.changed)
+	control.changed = make(chan struct{})
+}
+
+// Pause stops dispatching new products. Products already in flight, including
+// their retries, run to completion and queued products stay queued. Pausing
+// before Run makes the run start paused.
+func (service *Service) Pause() {
+	service.control.update(func() { service.control.paused = true })
+	service.logger.Info("Crawler paused")
+}
+
+// Resume lifts a Pause.
+func (service *Service) Resume() {
+	service.control.update(func() { service.control.paused = false })
+	service.logger.Info("Crawler resumed")
+}
+
+// SetParallelism changes how many products may be in flight at once, taking
+// effect for the next dispatch; products above a lowered limit finish
+// normally. The limit must be between 1 and ScraperConfig.Parallelism, which
+// sizes the underlying collector.
+func (service *Service) SetParallelism(parallelism int) error {
+	maximum := service.config.Scraper.Parallelism
+	if parallelism < 1 || parallelism > maximum {
+		return fmt.Errorf("crawler: parallelism must be between 1 and %d (got %d)", maximum, parallelism)
+	}
+	service.control.update(func() { service.control.limit = parallelism })
+	service.logger.Info("Crawler parallelism set to %d", parallelism)
+	return nil
+}
+
+// Drain stops the running crawl gracefully: queued products are removed and
+// returned undispatched, and products in flight get up to timeout to finish
+// before they are aborted, in which case ErrDrainTimeout is returned with the
+// queued products. A non-positive timeout waits for them indefinitely. Drain
+// returns once Run has returned, and ErrServiceNotRunning when no Run is
+// active.
+func (service *Service) Drain(timeout time.Duration) ([]Product, error) {
+	service.queueMu.Lock()
+	queue, cancel, done := service.queue, service.runCancel, service.runDone
+	service.queueMu.Unlock()
+	if queue == nil {
+		return nil, ErrServiceNotRunning
+	}
+
+	remaining := queue.drain()
+	service.logger.Info("Draining crawler: %d queued products returned, waiting for %d in flight", len(remaining), service.productsInFlight())
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case <-done:
+		return remaining, nil
+	case <-expired:
+	}
+	service.logger.Warning("Drain timed out after %s; aborting in-flight products", timeout)
+	cancel()
+	<-done
+	return remaining, ErrDrainTimeout
+}
+
+// waitForDispatch blocks until the control gate admits another product, the
+// queue stops accepting work or has run dry with nothing in flight, or ctx
+// ends. A paused crawl with nothing left to do still finishes.
+func (service *Service) waitForDispatch(ctx context.Context, queue *productQueue) {
+	for {
+		changes := service.control.changes()
+		inFlight := service.productsInFlight()
+		if queue.isClosed() || inFlight == 0 && queue.isEmpty() || service.control.admits(inFlight) {
+			return
+		}
+		select {
+		case <-queue.wake:
+		case <-changes:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// blockingPlatform answers product pages once release is closed, tracking how
+// many requests it holds at once.
+type blockingPlatform struct {
+	server      *httptest.Server
+	release     chan struct{}
+	arrived     chan string
+	hits        atomic.Int32
+	mu          sync.Mutex
+	concurrent  int
+	maxObserved int
+}
+
+func newBlockingPlatform(t *testing.T) *blockingPlatform {
+	t.Helper()
+	platform := &blockingPlatform{release: make(chan struct{}), arrived: make(chan string, 64)}
+	platform.server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		platform.hits.Add(1)
+		platform.mu.Lock()
+		platform.concurrent++
+		platform.maxObserved = max(platform.maxObserved, platform.concurrent)
+		platform.mu.Unlock()
+		defer func() {
+			platform.mu.Lock()
+			platform.concurrent--
+			platform.mu.Unlock()
+		}()
+		platform.arrived <- request.URL.Path
+		select {
+		case <-platform.release:
+		case <-request.Context().Done():
+			return
+		}
+		writer.Header().Set("Content-Type", "text/html")
+		_, _ = writer.Write([]byte("<html><head><title>Product</title></head><body></body></html>"))
+	}))
+	t.Cleanup(platform.server.Close)
+	return platform
+}
+
+func (platform *blockingPlatform) service(t *testing.T, parallelism, buffer int) (*Service, chan *Result) {
+	t.Helper()
+	parsed, err := url.Parse(platform.server.URL)
+	require.NoError(t, err)
+	results := make(chan *Result, buffer)
+	service, err := NewService(Config{
+		PlatformID:    "TEST",
+		Scraper:       ScraperConfig{MaxDepth: 1, Parallelism: parallelism},
+		Platform:      PlatformConfig{AllowedDomains: []string{parsed.Hostname()}},
+		RuleEvaluator: fixedRuleEvaluator{},
+		Logger:        noopLogger{},
+	}, results)
+	require.NoError(t, err)
+	return service, results
+}
+
+func (platform *blockingPlatform) products(count int) []Product {
+	products := make([]Product, 0, count)
+	for index := range count {
+		productID := fmt.Sprintf("%d", index)
+		products = append(products, Product{ID: productID, Platform: "TEST", URL: platform.server.URL + "/p/" + productID})
+	}
+	return products
+}
+
+func runInBackground(service *Service, products []Product) <-chan error {
+	runErr := make(chan error, 1)
+	go func() { runErr <- service.Run(context.Background(), products) }()
+	return runErr
+}
+
+func TestServicePauseHoldsDispatchUntilResume(t *testing.T) {
+	t.Parallel()
+
+	platform := newBlockingPlatform(t)
+	close(platform.release)
+	service, results := platform.service(t, 2, 3)
+
+	service.Pause()
+	runErr := runInBackground(service, platform.products(3))
+	time.Sleep(100 * time.Millisecond)
+	require.Zero(t, platform.hits.Load())
+	require.NoError(t, service.Enqueue())
+
+	service.Resume()
+	require.NoError(t, <-runErr)
+	require.Len(t, results, 3)
+	require.EqualValues(t, 3, platform.hits.Load())
+}
+
+func TestServiceSetParallelismThrottlesDispatch(t *testing.T) {
+	t.Parallel()
+
+	platform := newBlockingPlatform(t)
+	service, results := platform.service(t, 4, 4)
+	require.ErrorContains(t, service.SetParallelism(0), "between 1 and 4")
+	require.ErrorContains(t, service.SetParallelism(5), "between 1 and 4")
+	require.NoError(t, service.SetParallelism(1))
+
+	runErr := runInBackground(service, platform.products(4))
+	<-platform.arrived
+	time.Sleep(50 * time.Millisecond)
+	require.EqualValues(t, 1, platform.hits.Load())
+
+	require.NoError(t, service.SetParallelism(4))
+	for range 3 {
+		<-platform.arrived
+	}
+	close(platform.release)
+	require.NoError(t, <-runErr)
+	require.Len(t, results, 4)
+	require.Equal(t, 4, platform.maxObserved)
+}
+
+func TestServiceDrainFinishesInFlightAndReturnsQueued(t *testing.T) {
+	t.Parallel()
+
+	platform := newBlockingPlatform(t)
+	service, results := platform.service(t, 2, 5)
+	products := platform.products(5)
+	runErr := runInBackground(service, products)
+	<-platform.arrived
+	<-platform.arrived
+
+	drained := make(chan []Product, 1)
+	go func() {
+		remaining, err := service.Drain(0)
+		require.NoError(t, err)
+		drained <- remaining
+	}()
+	time.Sleep(50 * time.Millisecond)
+	require.Len(t, results, 0)
+	close(platform.release)
+
+	require.Equal(t, products[2:], <-drained)
+	require.NoError(t, <-runErr)
+	require.Len(t, results, 2)
+	for range 2 {
+		require.True(t, (<-results).Success)
+	}
+	require.ErrorIs(t, service.Enqueue(products[0]), ErrServiceNotRunning)
+}
+
+func TestServiceDrainAbortsInFlightAfterTimeout(t *testing.T) {
+	t.Parallel()
+
+	platform := newBlockingPlatform(t)
+	service, results := platform.service(t, 1, 2)
+	service.Pause()
+	_, err := service.Drain(time.Second)
+	require.ErrorIs(t, err, ErrServiceNotRunning)
+	service.Resume()
+
+	products := platform.products(2)
+	runErr := runInBackground(service, products)
+	<-platform.arrived
+
+	started := time.Now()
+	remaining, err := service.Drain(50 * time.Millisecond)
+	require.ErrorIs(t, err, ErrDrainTimeout)
+	require.Less(t, time.Since(started), time.Second)
+	require.Equal(t, products[1:], remaining)
+	require.NoError(t, <-runErr)
+
+	result := <-results
+	require.False(t, result.Success)
+	require.Contains(t, result.ErrorMessage, "context canceled")
+}
@@ -0,0 +1,286 @@
+// Package crawlerconfig loads the declarative part of a crawler.Config —
+// platform ID, output location, ScraperConfig and PlatformConfig — from a YAML
+// file with environment overrides, so services stop hand-mapping their own
+// config files. Collaborators such as the RuleEvaluator and PlatformHooks are
+// still supplied in Go on top of Settings.Config.
+//
+// Every key can be overridden by an environment variable named after its path
+// under the prefix, with dots replaced by underscores: scraper.proxy_probe.url
+// becomes CRAWLER_SCRAPER_PROXY_PROBE_URL. Lists are comma separated and
+// durations use time.ParseDuration syntax.
+package crawlerconfig
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/tyemirov/utils/crawler"
+)
+
+// DefaultEnvPrefix prefixes the environment overrides unless WithEnvPrefix
+// says otherwise.
+const DefaultEnvPrefix = "CRAWLER"
+
+// Settings mirrors the config file. Keys are the mapstructure tags.
+type Settings struct {
+	PlatformID      string           `mapstructure:"platform_id"`
+	OutputDirectory string           `mapstructure:"output_directory"`
+	RunFolder       string           `mapstructure:"run_folder"`
+	Scraper         ScraperSettings  `mapstructure:"scraper"`
+	Platform        PlatformSettings `mapstructure:"platform"`
+}
+
+// ScraperSettings is the file form of crawler.ScraperConfig.
+type ScraperSettings struct {
+	MaxDepth                   int                `mapstructure:"max_depth"`
+	Parallelism                int                `mapstructure:"parallelism"`
+	RetryCount                 int                `mapstructure:"retry_count"`
+	HTTPTimeout                time.Duration      `mapstructure:"http_timeout"`
+	InsecureSkipVerify         bool               `mapstructure:"insecure_skip_verify"`
+	RateLimit                  time.Duration      `mapstructure:"rate_limit"`
+	ProxyList                  []string           `mapstructure:"proxy_list"`
+	SaveFiles                  bool               `mapstructure:"save_files"`
+	ProxyCircuitBreakerEnabled bool               `mapstructure:"proxy_circuit_breaker_enabled"`
+	ProductTimeout             time.Duration      `mapstructure:"product_timeout"`
+	MaxBodySize                int                `mapstructure:"max_body_size"`
+	ProxyTags                  []ProxyTagSettings `mapstructure:"proxy_tags"`
+	ProxyProbe                 ProxyProbeSettings `mapstructure:"proxy_probe"`
+	CoalesceDuplicateURLs      bool               `mapstructure:"coalesce_duplicate_urls"`
+	ExtractStructuredData      bool               `mapstructure:"extract_structured_data"`
+	RespectRobotsTxt           bool               `mapstructure:"respect_robots_txt"`
+	RobotsUserAgent            string             `mapstructure:"robots_user_agent"`
+	Capture                    CaptureSettings    `mapstructure:"capture"`
+}
+
+// CaptureSettings is the file form of crawler.CaptureConfig. ProductIDs, when
+// set, limits capture to those products.
+type CaptureSettings struct {
+	SampleRate   float64  `mapstructure:"sample_rate"`
+	ProductIDs   []string `mapstructure:"product_ids"`
+	MaxBodyBytes int      `mapstructure:"max_body_bytes"`
+}
+
+// ProxyProbeSettings is the file form of crawler.ProxyProbeConfig.
+type ProxyProbeSettings struct {
+	URL                string        `mapstructure:"url"`
+	Timeout            time.Duration `mapstructure:"timeout"`
+	Concurrency        int           `mapstructure:"concurrency"`
+	MinHealthy         int           `mapstructure:"min_healthy"`
+	InsecureSkipVerify bool          `mapstructure:"insecure_skip_verify"`
+}
+
+// ProxyTagSettings attributes one proxy. It is a list entry rather than a map
+// keyed by proxy URL because Viper lower-cases and splits map keys.
+type ProxyTagSettings struct {
+	Proxy string            `mapstructure:"proxy"`
+	Tags  map[string]string `mapstructure:"tags"`
+}
+
+// PlatformSettings is the file form of crawler.PlatformConfig.
+type PlatformSettings struct {
+	AllowedDomains      []string `mapstructure:"allowed_domains"`
+	CookieDomains       []string `mapstructure:"cookie_domains"`
+	SkipRulesOnRedirect bool     `mapstructure:"skip_rules_on_redirect"`
+	ProductIDPatterns   []string `mapstructure:"product_id_patterns"`
+}
+
+// Option customises Load.
+type Option func(*loader)
+
+type loader struct {
+	envPrefix string
+}
+
+// WithEnvPrefix replaces DefaultEnvPrefix. An empty prefix matches the bare
+// key names, for example SCRAPER_PARALLELISM.
+func WithEnvPrefix(prefix string) Option {
+	return func(l *loader) {
+		l.envPrefix = strings.TrimSpace(prefix)
+	}
+}
+
+// Load reads configPath, applies environment overrides and validates the
+// result. configPath may be empty to configure from the environment alone;
+// a file without an extension is read as YAML. Unknown keys, undecodable
+// values and every validation problem are reported together in one error.
+func Load(configPath string, options ...Option) (Settings, error) {
+	l := loader{envPrefix: DefaultEnvPrefix}
+	for _, option := range options {
+		if option != nil {
+			option(&l)
+		}
+	}
+
+	instance := viper.New()
+	instance.SetEnvPrefix(l.envPrefix)
+	instance.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	for _, key := range settingKeys(reflect.TypeOf(Settings{}), "") {
+		// BindEnv only fails for an empty key, which settingKeys never yields.
+		_ = instance.BindEnv(key)
+	}
+
+	cleanPath := strings.TrimSpace(configPath)
+	if cleanPath != "" {
+		instance.SetConfigFile(cleanPath)
+		if filepath.Ext(cleanPath) == "" {
+			instance.SetConfigType("yaml")
+		}
+		if err := instance.ReadInConfig(); err != nil {
+			return Settings{}, fmt.Errorf("crawlerconfig: read %s: %w", cleanPath, err)
+		}
+	}
+
+	var settings Settings
+	if err := instance.UnmarshalExact(&settings); err != nil {
+		return Settings{}, fmt.Errorf("crawlerconfig: decode: %w", err)
+	}
+	if err := settings.Validate(); err != nil {
+		return Settings{}, err
+	}
+	return settings, nil
+}
+
+// Validate reports every problem that crawler.Config.Validate would find in
+// these settings, instead of stopping at the first. Each problem is prefixed
+// with its config section. Rules that depend on collaborators supplied in Go,
+// such as the RuleEvaluator or the FilePersister capture can write to instead
+// of output_directory, are left to crawler.Config.Validate.
+func (settings Settings) Validate() error {
+	var problems []error
+	if strings.TrimSpace(settings.PlatformID) == "" {
+		problems = append(problems, errors.New("platform_id is required"))
+	}
+	problems = append(problems, sectionProblems("scraper", settings.ScraperConfig().Validate())...)
+	problems = append(problems, sectionProblems("platform", settings.PlatformConfig().Validate())...)
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("crawlerconfig: invalid config:\n%w", errors.Join(problems...))
+}
+
+// Config returns a crawler.Config with the loaded fields set. Callers add the
+// RuleEvaluator and any other collaborators before passing it to
+// crawler.NewService.
+func (settings Settings) Config() crawler.Config {
+	return crawler.Config{
+		PlatformID:      settings.PlatformID,
+		OutputDirectory: settings.OutputDirectory,
+		RunFolder:       settings.RunFolder,
+		Scraper:         settings.ScraperConfig(),
+		Platform:        settings.PlatformConfig(),
+	}
+}
+
+// ScraperConfig maps the scraper section onto crawler.ScraperConfig.
+func (settings Settings) ScraperConfig() crawler.ScraperConfig {
+	scraper := settings.Scraper
+	return crawler.ScraperConfig{
+		MaxDepth:                   scraper.MaxDepth,
+		Parallelism:                scraper.Parallelism,
+		RetryCount:                 scraper.RetryCount,
+		HTTPTimeout:                scraper.HTTPTimeout,
+		InsecureSkipVerify:         scraper.InsecureSkipVerify,
+		RateLimit:                  scraper.RateLimit,
+		ProxyList:                  trimmed(scraper.ProxyList),
+		SaveFiles:                  scraper.SaveFiles,
+		ProxyCircuitBreakerEnabled: scraper.ProxyCircuitBreakerEnabled,
+		ProductTimeout:             scraper.ProductTimeout,
+		MaxBodySize:                scraper.MaxBodySize,
+		ProxyTags:                  proxyTags(scraper.ProxyTags),
+		ProxyProbe: crawler.ProxyProbeConfig{
+			ProbeURL:           scraper.ProxyProbe.URL,
+			Timeout:            scraper.ProxyProbe.Timeout,
+			Concurrency:        scraper.ProxyProbe.Concurrency,
+			MinHealthy:         scraper.ProxyProbe.MinHealthy,
+			InsecureSkipVerify: scraper.ProxyProbe.InsecureSkipVerify,
+		},
+		CoalesceDuplicateURLs: scraper.CoalesceDuplicateURLs,
+		ExtractStructuredData: scraper.ExtractStructuredData,
+		RespectRobotsTxt:      scraper.RespectRobotsTxt,
+		RobotsUserAgent:       scraper.RobotsUserAgent,
+		Capture:               captureConfig(scraper.Capture),
+	}
+}
+
+// PlatformConfig maps the platform section onto crawler.PlatformConfig.
+func (settings Settings) PlatformConfig() crawler.PlatformConfig {
+	platform := settings.Platform
+	return crawler.PlatformConfig{
+		AllowedDomains:      trimmed(platform.AllowedDomains),
+		CookieDomains:       trimmed(platform.CookieDomains),
+		SkipRulesOnRedirect: platform.SkipRulesOnRedirect,
+		ProductIDPatterns:   platform.ProductIDPatterns,
+	}
+}
+
+// sectionProblems splits a joined validation error and prefixes each
+// problem with section.
+func sectionProblems(section string, err error) []error {
+	if err == nil {
+		return nil
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{fmt.Errorf("%s: %w", section, err)}
+	}
+	var problems []error
+	for _, problem := range joined.Unwrap() {
+		problems = append(problems, sectionProblems(section, problem)...)
+	}
+	return problems
+}
+
+// settingKeys lists the dotted mapstructure key of every leaf field in
+// settingsType, so each can be bound to its environment variable.
+func settingKeys(settingsType reflect.Type, prefix string) []string {
+	var keys []string
+	for index := 0; index < settingsType.NumField(); index++ {
+		field := settingsType.Field(index)
+		key := prefix + field.Tag.Get("mapstructure")
+		if field.Type.Kind() == reflect.Struct {
+			keys = append(keys, settingKeys(field.Type, key+".")...)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func proxyTags(entries []ProxyTagSettings) map[string]crawler.ProxyTags {
+	if len(entries) == 0 {
+		return nil
+	}
+	tags := make(map[string]crawler.ProxyTags, len(entries))
+	for _, entry := range entries {
+		tags[strings.TrimSpace(entry.Proxy)] = entry.Tags
+	}
+	return tags
+}
+
+func captureConfig(capture CaptureSettings) crawler.CaptureConfig {
+	config := crawler.CaptureConfig{SampleRate: capture.SampleRate, MaxBodyBytes: capture.MaxBodyBytes}
+	if productIDs := trimmed(capture.ProductIDs); len(productIDs) > 0 {
+		config.Filter = func(product crawler.Product) bool {
+			return slices.Contains(productIDs, product.ID)
+		}
+	}
+	return config
+}
+
+// trimmed drops surrounding whitespace and empty entries, which comma
+// separated environment values tend to carry.
+func trimmed(values []string) []string {
+	var cleaned []string
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			cleaned = append(cleaned, value)
+		}
+	}
+	return cleaned
+}
@@ -0,0 +1,122 @@
+package crawlerconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/tyemirov/utils/preflight"
+)
+
+const redactedPasswordPrefix = "sha256-"
+
+// jsonMarshalFunc is the JSON marshal function used to encode reports. It
+// defaults to json.Marshal and can be replaced for dependency injection.
+var jsonMarshalFunc = json.Marshal
+
+// Reporter emits loaded Settings as a preflight effective-config payload. It
+// implements preflight.ConfigReporter.
+type Reporter struct {
+	settings Settings
+}
+
+var _ preflight.ConfigReporter = (*Reporter)(nil)
+
+// NewReporter reports settings, normally the value returned by Load.
+func NewReporter(settings Settings) *Reporter {
+	return &Reporter{settings: settings}
+}
+
+// Build renders the settings under their config keys, with durations in
+// time.Duration string form. RedactionModeRedacted replaces each proxy
+// password with the SHA-256 of the password, so reports can still be compared
+// without exposing credentials.
+func (reporter *Reporter) Build(mode preflight.RedactionMode) (json.RawMessage, error) {
+	settings := reporter.settings
+	scraper := settings.Scraper
+	proxies := make([]string, 0, len(scraper.ProxyList))
+	for _, proxy := range trimmed(scraper.ProxyList) {
+		if mode != preflight.RedactionModeFull {
+			proxy = redactProxyPassword(proxy)
+		}
+		proxies = append(proxies, proxy)
+	}
+	proxyTags := make([]map[string]any, 0, len(scraper.ProxyTags))
+	for _, entry := range scraper.ProxyTags {
+		proxy := strings.TrimSpace(entry.Proxy)
+		if mode != preflight.RedactionModeFull {
+			proxy = redactProxyPassword(proxy)
+		}
+		proxyTags = append(proxyTags, map[string]any{"proxy": proxy, "tags": entry.Tags})
+	}
+	payload := map[string]any{
+		"platform_id":      settings.PlatformID,
+		"output_directory": settings.OutputDirectory,
+		"run_folder":       settings.RunFolder,
+		"scraper": map[string]any{
+			"max_depth":                     scraper.MaxDepth,
+			"parallelism":                   scraper.Parallelism,
+			"retry_count":                   scraper.RetryCount,
+			"http_timeout":                  scraper.HTTPTimeout.String(),
+			"insecure_skip_verify":          scraper.InsecureSkipVerify,
+			"rate_limit":                    scraper.RateLimit.String(),
+			"proxy_list":                    proxies,
+			"save_files":                    scraper.SaveFiles,
+			"proxy_circuit_breaker_enabled": scraper.ProxyCircuitBreakerEnabled,
+			"product_timeout":               scraper.ProductTimeout.String(),
+			"max_body_size":                 scraper.MaxBodySize,
+			"proxy_tags":                    proxyTags,
+			"proxy_probe": map[string]any{
+				"url":                  scraper.ProxyProbe.URL,
+				"timeout":              scraper.ProxyProbe.Timeout.String(),
+				"concurrency":          scraper.ProxyProbe.Concurrency,
+				"min_healthy":          scraper.ProxyProbe.MinHealthy,
+				"insecure_skip_verify": scraper.ProxyProbe.InsecureSkipVerify,
+			},
+			"coalesce_duplicate_urls": scraper.CoalesceDuplicateURLs,
+			"extract_structured_data": scraper.ExtractStructuredData,
+			"respect_robots_txt":      scraper.RespectRobotsTxt,
+			"robots_user_agent":       scraper.RobotsUserAgent,
+			"capture": map[string]any{
+				"sample_rate":    scraper.Capture.SampleRate,
+				"product_ids":    nonNil(trimmed(scraper.Capture.ProductIDs)),
+				"max_body_bytes": scraper.Capture.MaxBodyBytes,
+			},
+		},
+		"platform": map[string]any{
+			"allowed_domains":        nonNil(trimmed(settings.Platform.AllowedDomains)),
+			"cookie_domains":         nonNil(trimmed(settings.Platform.CookieDomains)),
+			"skip_rules_on_redirect": settings.Platform.SkipRulesOnRedirect,
+			"product_id_patterns":    nonNil(settings.Platform.ProductIDPatterns),
+		},
+	}
+	encoded, err := jsonMarshalFunc(payload)
+	if err != nil {
+		return nil, fmt.Errorf("crawlerconfig: encode report: %w", err)
+	}
+	return encoded, nil
+}
+
+// redactProxyPassword swaps the password in proxyURL for its hash. Entries
+// that do not parse are reduced to a hash of the whole entry, since a
+// malformed URL may still carry credentials.
+func redactProxyPassword(proxyURL string) string {
+	parsed, err := url.Parse(proxyURL)
+	if err != nil {
+		return redactedPasswordPrefix + preflight.HashSHA256Hex([]byte(proxyURL))
+	}
+	password, hasPassword := parsed.User.Password()
+	if !hasPassword {
+		return proxyURL
+	}
+	parsed.User = url.UserPassword(parsed.User.Username(), redactedPasswordPrefix+preflight.HashSHA256Hex([]byte(password)))
+	return parsed.String()
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
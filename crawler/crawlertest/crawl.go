@@ -0,0 +1,46 @@
+package crawlertest
+
+import (
+	"context"
+	"sort"
+
+	"github.com/tyemirov/utils/crawler"
+)
+
+// Crawl builds a crawler.Service from cfg, runs it over products and returns
+// every emitted result ordered by OriginalProductID. The error is the one
+// returned by NewService or Run.
+func Crawl(ctx context.Context, cfg crawler.Config, products []crawler.Product, options ...crawler.ServiceOption) ([]*crawler.Result, error) {
+	results := make(chan *crawler.Result, len(products))
+	service, err := crawler.NewService(cfg, results, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	collected := make(chan []*crawler.Result, 1)
+	go func() {
+		var received []*crawler.Result
+		for result := range results {
+			received = append(received, result)
+		}
+		collected <- received
+	}()
+	runErr := service.Run(ctx, products)
+	close(results)
+
+	received := <-collected
+	sort.SliceStable(received, func(left, right int) bool {
+		return received[left].OriginalProductID < received[right].OriginalProductID
+	})
+	return received, runErr
+}
+
+// ByProductID indexes results by OriginalProductID, the ID the product was
+// submitted with, so redirected products are still found under their own ID.
+func ByProductID(results []*crawler.Result) map[string]*crawler.Result {
+	indexed := make(map[string]*crawler.Result, len(results))
+	for _, result := range results {
+		indexed[result.OriginalProductID] = result
+	}
+	return indexed
+}
@@ -0,0 +1,261 @@
+package crawlertest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/require"
+	"github.com/tyemirov/utils/crawler"
+)
+
+type priceRuleEvaluator struct{}
+
+func (priceRuleEvaluator) Evaluate(_ string, document *goquery.Document) (crawler.RuleEvaluation, error) {
+	price := strings.TrimSpace(document.Find(".price").Text())
+	return crawler.RuleEvaluation{
+		RuleResults:        []crawler.RuleResult{{ID: "price", Passed: price != "", Message: price}},
+		ConfiguredVerifier: 1,
+	}, nil
+}
+
+func (priceRuleEvaluator) ConfiguredVerifierCount() int { return 1 }
+
+type captchaPlatformHooks struct {
+	completeSelector string
+}
+
+func (captchaPlatformHooks) NormalizeTitle(title string) string { return title }
+func (captchaPlatformHooks) ExtractDOMTitle(*goquery.Document) string {
+	return ""
+}
+func (hooks captchaPlatformHooks) IsContentComplete(document *goquery.Document) bool {
+	return document.Find(hooks.completeSelector).Length() > 0
+}
+func (captchaPlatformHooks) InferRedirect(string, string, string, string) (bool, string) {
+	return false, ""
+}
+func (captchaPlatformHooks) ShouldRetry(title string, _ *goquery.Document) crawler.RetryDecision {
+	if title == CaptchaTitle {
+		return crawler.RetryDecision{ShouldRetry: true, Message: "captcha page", Policy: crawler.RetryPolicyRotateProxy}
+	}
+	return crawler.RetryDecision{}
+}
+
+func platformConfig(platform *Platform) crawler.Config {
+	return crawler.Config{
+		PlatformID: "FAKE",
+		Scraper:    crawler.ScraperConfig{MaxDepth: 1, Parallelism: 4, HTTPTimeout: 2 * time.Second},
+		Platform: crawler.PlatformConfig{
+			AllowedDomains:    []string{platform.Host()},
+			ProductIDPatterns: []string{`/p/(\w+)`},
+		},
+		RuleEvaluator: priceRuleEvaluator{},
+		PlatformHooks: captchaPlatformHooks{completeSelector: ".price"},
+	}
+}
+
+func TestCrawlReportsScriptedPlatformBehaviour(t *testing.T) {
+	t.Parallel()
+
+	platform := NewPlatform()
+	defer platform.Close()
+	platform.Handle("/p/ok", Page("Kettle", `<span class="price">$10</span>`))
+	platform.Handle("/p/old", Redirect(http.StatusMovedPermanently, "/p/new"))
+	platform.Handle("/p/new", Page("New kettle", `<span class="price">$12</span>`))
+	platform.Handle("/p/captcha", Captcha())
+	platform.Handle("/p/gone", NotFound())
+	platform.Handle("/p/truncated", Truncated(PageDocument("Kettle", strings.Repeat("x", 4096)), 64))
+	platform.Handle("/p/slow", SlowBody(PageDocument("Slow kettle", `<span class="price">$9</span>`), 16, time.Millisecond))
+	platform.Handle("/p/reset", ResetConnection())
+
+	var products []crawler.Product
+	for _, productID := range []string{"ok", "old", "captcha", "gone", "truncated", "slow", "reset"} {
+		products = append(products, crawler.Product{ID: productID, Platform: "FAKE", URL: platform.URLFor("/p/" + productID)})
+	}
+	results, err := Crawl(context.Background(), platformConfig(platform), products)
+	require.NoError(t, err)
+	require.Len(t, results, len(products))
+	require.Equal(t, "captcha", results[0].OriginalProductID)
+	byProduct := ByProductID(results)
+
+	require.True(t, byProduct["ok"].Success)
+	require.Equal(t, "$10", byProduct["ok"].RuleResults[0].Message)
+
+	redirected := byProduct["old"]
+	require.True(t, redirected.Success)
+	require.Equal(t, "new", redirected.ProductID)
+	require.Len(t, redirected.RedirectChain, 2)
+	require.Equal(t, http.StatusMovedPermanently, redirected.RedirectChain[0].StatusCode)
+
+	require.False(t, byProduct["captcha"].Success)
+	require.Equal(t, "captcha page", byProduct["captcha"].ErrorMessage)
+
+	require.False(t, byProduct["gone"].Success)
+	require.Equal(t, http.StatusNotFound, byProduct["gone"].HTTPStatusCode)
+
+	require.False(t, byProduct["truncated"].Success)
+	require.NotEmpty(t, byProduct["truncated"].ErrorMessage)
+
+	require.True(t, byProduct["slow"].Success)
+	require.Equal(t, "Slow kettle", byProduct["slow"].ProductTitle)
+
+	require.False(t, byProduct["reset"].Success)
+	require.NotEmpty(t, byProduct["reset"].ErrorMessage)
+
+	require.Equal(t, 1, platform.Hits("/p/ok"))
+	require.Equal(t, 1, platform.Hits("/p/new"))
+	require.Zero(t, platform.Hits("/p/unknown"))
+}
+
+func TestPlatformPlaysBehavioursInSequence(t *testing.T) {
+	t.Parallel()
+
+	platform := NewPlatform()
+	defer platform.Close()
+	platform.Handle("/p/1", Status(http.StatusServiceUnavailable), Delay(time.Millisecond, Page("Kettle", "")))
+
+	statuses := make([]int, 0, 3)
+	for range 3 {
+		response, err := http.Get(platform.URLFor("/p/1"))
+		require.NoError(t, err)
+		require.NoError(t, response.Body.Close())
+		statuses = append(statuses, response.StatusCode)
+	}
+	require.Equal(t, []int{http.StatusServiceUnavailable, http.StatusOK, http.StatusOK}, statuses)
+	require.Equal(t, 3, platform.Hits("/p/1"))
+
+	platform.Handle("/p/1", HTML(http.StatusGone, "<html></html>"))
+	require.Zero(t, platform.Hits("/p/1"))
+	response, err := http.Get(platform.URLFor("/p/1"))
+	require.NoError(t, err)
+	require.NoError(t, response.Body.Close())
+	require.Equal(t, http.StatusGone, response.StatusCode)
+
+	response, err = http.Get(platform.URLFor("/missing"))
+	require.NoError(t, err)
+	require.NoError(t, response.Body.Close())
+	require.Equal(t, http.StatusNotFound, response.StatusCode)
+	require.Equal(t, 1, platform.Hits("/missing"))
+	require.Equal(t, platform.URL()+"/missing", platform.URLFor("/missing"))
+}
+
+func TestCrawlThroughFailingProxy(t *testing.T) {
+	t.Parallel()
+
+	platform := NewPlatform()
+	defer platform.Close()
+	platform.Handle("/p/1", Page("Kettle", `<span class="price">$10</span>`))
+	proxy := NewProxy()
+	defer proxy.Close()
+
+	cfg := platformConfig(platform)
+	cfg.Scraper.ProxyList = []string{proxy.URL()}
+	products := []crawler.Product{{ID: "1", Platform: "FAKE", URL: platform.URLFor("/p/1")}}
+
+	proxy.FailWithStatus(http.StatusBadGateway)
+	results, err := Crawl(context.Background(), cfg, products)
+	require.NoError(t, err)
+	require.False(t, results[0].Success)
+	require.Equal(t, http.StatusBadGateway, results[0].HTTPStatusCode)
+	require.Zero(t, platform.Hits("/p/1"))
+
+	proxy.FailWithReset()
+	results, err = Crawl(context.Background(), cfg, products)
+	require.NoError(t, err)
+	require.False(t, results[0].Success)
+	require.Zero(t, platform.Hits("/p/1"))
+
+	proxy.Heal()
+	results, err = Crawl(context.Background(), cfg, products)
+	require.NoError(t, err)
+	require.True(t, results[0].Success)
+	require.Equal(t, 1, platform.Hits("/p/1"))
+	require.Equal(t, 3, proxy.Requests())
+}
+
+func TestProxyRejectsRequestsItCannotForward(t *testing.T) {
+	t.Parallel()
+
+	proxy := NewProxy()
+	defer proxy.Close()
+	response, err := http.Get(proxy.URL() + "/relative")
+	require.NoError(t, err)
+	require.NoError(t, response.Body.Close())
+	require.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
+func TestCrawlReturnsConfigurationErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := Crawl(context.Background(), crawler.Config{}, nil)
+	require.Error(t, err)
+}
+
+func TestProxyRelaysRedirectsAndReportsUnreachableUpstreams(t *testing.T) {
+	t.Parallel()
+
+	platform := NewPlatform()
+	defer platform.Close()
+	platform.Handle("/old", Redirect(http.StatusFound, "/new"))
+	proxy := NewProxy()
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL())
+	require.NoError(t, err)
+	client := &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	response, err := client.Get(platform.URLFor("/old"))
+	require.NoError(t, err)
+	require.NoError(t, response.Body.Close())
+	require.Equal(t, http.StatusFound, response.StatusCode)
+	require.Equal(t, "/new", response.Header.Get("Location"))
+
+	unreachable := NewPlatform()
+	unreachable.Close()
+	response, err = client.Get(unreachable.URLFor("/p/1"))
+	require.NoError(t, err)
+	require.NoError(t, response.Body.Close())
+	require.Equal(t, http.StatusBadGateway, response.StatusCode)
+	require.Equal(t, 2, proxy.Requests())
+}
+
+func TestPlatformBehavioursStopWhenTheClientLeaves(t *testing.T) {
+	t.Parallel()
+
+	platform := NewPlatform()
+	defer platform.Close()
+	platform.Handle("/slow", SlowBody(PageDocument("Kettle", ""), 1, time.Minute))
+	platform.Handle("/delayed", Delay(time.Minute, Page("Kettle", "")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, platform.URLFor("/slow"), nil)
+	require.NoError(t, err)
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	cancel()
+	require.NoError(t, response.Body.Close())
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	request, err = http.NewRequestWithContext(ctx, http.MethodGet, platform.URLFor("/delayed"), nil)
+	require.NoError(t, err)
+	_, err = http.DefaultClient.Do(request)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	recorder := httptest.NewRecorder()
+	ResetConnection()(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Zero(t, recorder.Body.Len())
+}
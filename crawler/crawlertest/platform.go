@@ -0,0 +1,212 @@
+// Package crawlertest provides local stand-ins for the sites and proxies a
+// crawler.Service talks to, so PlatformHooks and RuleEvaluators can be tested
+// end to end without network access. Platform is an httptest server whose
+// paths are scripted with Behaviors (pages, redirects, 404s, captcha pages,
+// truncated or slow bodies, connection resets), Proxy is a forward proxy that
+// can be told to fail, and Crawl runs a Service and collects its results.
+package crawlertest
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Behavior answers one request to a Platform path.
+type Behavior func(writer http.ResponseWriter, request *http.Request)
+
+// Platform is a fake product platform. Every path is scripted with a sequence
+// of behaviors: the n-th request to a path gets the n-th behavior and the last
+// one repeats, so retries can see a different answer. Unscripted paths answer
+// 404. It is safe for concurrent use.
+type Platform struct {
+	server *httptest.Server
+
+	mu     sync.Mutex
+	routes map[string][]Behavior
+	hits   map[string]int
+}
+
+// NewPlatform starts a Platform. Close it when the test ends.
+func NewPlatform() *Platform {
+	platform := &Platform{routes: make(map[string][]Behavior), hits: make(map[string]int)}
+	platform.server = httptest.NewServer(http.HandlerFunc(platform.serve))
+	return platform
+}
+
+// Handle scripts the responses for path, replacing earlier scripts and
+// resetting its hit count.
+func (platform *Platform) Handle(path string, behaviors ...Behavior) {
+	platform.mu.Lock()
+	defer platform.mu.Unlock()
+	platform.routes[path] = behaviors
+	delete(platform.hits, path)
+}
+
+// URL returns the platform's base URL.
+func (platform *Platform) URL() string {
+	return platform.server.URL
+}
+
+// URLFor returns the absolute URL of path.
+func (platform *Platform) URLFor(path string) string {
+	return platform.server.URL + path
+}
+
+// Host returns the host name to list in crawler.PlatformConfig.AllowedDomains.
+func (platform *Platform) Host() string {
+	parsed, _ := url.Parse(platform.server.URL)
+	return parsed.Hostname()
+}
+
+// Hits returns how many requests reached path, including unscripted ones.
+func (platform *Platform) Hits(path string) int {
+	platform.mu.Lock()
+	defer platform.mu.Unlock()
+	return platform.hits[path]
+}
+
+// Close shuts the server down, dropping any connections still open.
+func (platform *Platform) Close() {
+	platform.server.CloseClientConnections()
+	platform.server.Close()
+}
+
+func (platform *Platform) serve(writer http.ResponseWriter, request *http.Request) {
+	platform.mu.Lock()
+	behaviors := platform.routes[request.URL.Path]
+	attempt := platform.hits[request.URL.Path]
+	platform.hits[request.URL.Path] = attempt + 1
+	platform.mu.Unlock()
+
+	if len(behaviors) == 0 {
+		NotFound()(writer, request)
+		return
+	}
+	behaviors[min(attempt, len(behaviors)-1)](writer, request)
+}
+
+// HTML answers with status and document as text/html.
+func HTML(status int, document string) Behavior {
+	return func(writer http.ResponseWriter, _ *http.Request) {
+		writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+		writer.WriteHeader(status)
+		_, _ = writer.Write([]byte(document))
+	}
+}
+
+// Page answers 200 with a product page titled title whose body is body.
+func Page(title, body string) Behavior {
+	return HTML(http.StatusOK, PageDocument(title, body))
+}
+
+// PageDocument renders the HTML document Page serves.
+func PageDocument(title, body string) string {
+	return fmt.Sprintf("<!DOCTYPE html><html><head><title>%s</title></head><body>%s</body></html>", title, body)
+}
+
+// CaptchaTitle is the title of the page Captcha serves.
+const CaptchaTitle = "Robot Check"
+
+// Captcha answers 200 with a bot-check interstitial, the way many platforms
+// block crawlers without an error status.
+func Captcha() Behavior {
+	return Page(CaptchaTitle, `<form action="/errors/validateCaptcha"><img src="/captcha.jpg"><input name="field-keywords"></form>`)
+}
+
+// NotFound answers 404.
+func NotFound() Behavior {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		http.NotFound(writer, request)
+	}
+}
+
+// Status answers with an empty body and status.
+func Status(status int) Behavior {
+	return func(writer http.ResponseWriter, _ *http.Request) {
+		writer.WriteHeader(status)
+	}
+}
+
+// Redirect answers status with a Location header pointing at location.
+func Redirect(status int, location string) Behavior {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		http.Redirect(writer, request, location, status)
+	}
+}
+
+// Truncated announces the full length of document but sends only its first
+// sendBytes bytes before closing the connection, so the client sees a body
+// that ends early.
+func Truncated(document string, sendBytes int) Behavior {
+	return func(writer http.ResponseWriter, _ *http.Request) {
+		sent := max(0, min(sendBytes, len(document)))
+		writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+		writer.Header().Set("Content-Length", strconv.Itoa(len(document)))
+		writer.WriteHeader(http.StatusOK)
+		_, _ = writer.Write([]byte(document[:sent]))
+		if flusher, ok := writer.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		if connection, _, err := http.NewResponseController(writer).Hijack(); err == nil {
+			_ = connection.Close()
+		}
+	}
+}
+
+// SlowBody sends the headers at once and then document in chunks of
+// chunkBytes, pausing delay before each chunk.
+func SlowBody(document string, chunkBytes int, delay time.Duration) Behavior {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		chunk := max(1, chunkBytes)
+		controller := http.NewResponseController(writer)
+		writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+		writer.WriteHeader(http.StatusOK)
+		_ = controller.Flush()
+		for start := 0; start < len(document); start += chunk {
+			select {
+			case <-time.After(delay):
+			case <-request.Context().Done():
+				return
+			}
+			_, _ = writer.Write([]byte(document[start:min(start+chunk, len(document))]))
+			_ = controller.Flush()
+		}
+	}
+}
+
+// Delay waits before handing the request to next, or gives up when the
+// client goes away.
+func Delay(delay time.Duration, next Behavior) Behavior {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		select {
+		case <-time.After(delay):
+			next(writer, request)
+		case <-request.Context().Done():
+		}
+	}
+}
+
+// ResetConnection aborts the connection with a TCP reset before any response
+// is written.
+func ResetConnection() Behavior {
+	return func(writer http.ResponseWriter, _ *http.Request) {
+		connection, _, err := http.NewResponseController(writer).Hijack()
+		if err != nil {
+			return
+		}
+		resetConnection(connection)
+	}
+}
+
+func resetConnection(connection net.Conn) {
+	if tcpConnection, ok := connection.(*net.TCPConn); ok {
+		_ = tcpConnection.SetLinger(0)
+	}
+	_ = connection.Close()
+}
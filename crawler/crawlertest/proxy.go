@@ -0,0 +1,122 @@
+package crawlertest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+type proxyMode int
+
+const (
+	proxyForward proxyMode = iota
+	proxyFailStatus
+	proxyFailReset
+)
+
+// Proxy is a plain-HTTP forward proxy for ScraperConfig.ProxyList. It relays
+// requests for absolute http:// URLs until told to fail, and counts every
+// request it receives. HTTPS tunnelling via CONNECT is not supported. It is
+// safe for concurrent use.
+type Proxy struct {
+	server *httptest.Server
+	client *http.Client
+
+	mu         sync.Mutex
+	mode       proxyMode
+	failStatus int
+	requests   int
+}
+
+// NewProxy starts a healthy Proxy. Close it when the test ends.
+func NewProxy() *Proxy {
+	proxy := &Proxy{client: &http.Client{
+		Transport: &http.Transport{Proxy: nil},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+	proxy.server = httptest.NewServer(http.HandlerFunc(proxy.serve))
+	return proxy
+}
+
+// URL returns the proxy URL to put in ScraperConfig.ProxyList.
+func (proxy *Proxy) URL() string {
+	return proxy.server.URL
+}
+
+// FailWithStatus makes the proxy answer every request with status, the way a
+// proxy reports an unreachable upstream or rejected credentials.
+func (proxy *Proxy) FailWithStatus(status int) {
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+	proxy.mode, proxy.failStatus = proxyFailStatus, status
+}
+
+// FailWithReset makes the proxy reset every connection without answering.
+func (proxy *Proxy) FailWithReset() {
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+	proxy.mode = proxyFailReset
+}
+
+// Heal makes the proxy relay requests again.
+func (proxy *Proxy) Heal() {
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+	proxy.mode = proxyForward
+}
+
+// Requests returns how many requests reached the proxy, including failed ones.
+func (proxy *Proxy) Requests() int {
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+	return proxy.requests
+}
+
+// Close shuts the proxy down.
+func (proxy *Proxy) Close() {
+	proxy.server.CloseClientConnections()
+	proxy.server.Close()
+	proxy.client.CloseIdleConnections()
+}
+
+func (proxy *Proxy) serve(writer http.ResponseWriter, request *http.Request) {
+	proxy.mu.Lock()
+	proxy.requests++
+	mode, failStatus := proxy.mode, proxy.failStatus
+	proxy.mu.Unlock()
+
+	switch mode {
+	case proxyFailStatus:
+		http.Error(writer, http.StatusText(failStatus), failStatus)
+		return
+	case proxyFailReset:
+		ResetConnection()(writer, request)
+		return
+	}
+
+	if !request.URL.IsAbs() || request.URL.Scheme != "http" {
+		http.Error(writer, "crawlertest: proxy only forwards absolute http URLs", http.StatusBadRequest)
+		return
+	}
+	outbound := request.Clone(request.Context())
+	outbound.RequestURI = ""
+	outbound.Header.Del("Proxy-Authorization")
+	outbound.Header.Del("Proxy-Connection")
+
+	response, err := proxy.client.Do(outbound)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer response.Body.Close()
+	for key, values := range response.Header {
+		for _, value := range values {
+			writer.Header().Add(key, value)
+		}
+	}
+	writer.WriteHeader(response.StatusCode)
+	_, _ = io.Copy(writer, response.Body)
+}
@@ -0,0 +1,212 @@
+package crawler
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ChangeKind classifies a ChangeEvent.
+type ChangeKind string
+
+// Change kinds emitted by DiffResults.
+const (
+	ChangeCrawlFailed           ChangeKind = "crawl_failed"
+	ChangeCrawlRecovered        ChangeKind = "crawl_recovered"
+	ChangeHTTPStatus            ChangeKind = "http_status_changed"
+	ChangeTitle                 ChangeKind = "title_changed"
+	ChangeRedirect              ChangeKind = "redirect_changed"
+	ChangeCanonicalURL          ChangeKind = "canonical_url_changed"
+	ChangeRuleRegressed         ChangeKind = "rule_regressed"
+	ChangeRuleRecovered         ChangeKind = "rule_recovered"
+	ChangeRuleAdded             ChangeKind = "rule_added"
+	ChangeRuleRemoved           ChangeKind = "rule_removed"
+	ChangeVerificationRegressed ChangeKind = "verification_regressed"
+	ChangeVerificationRecovered ChangeKind = "verification_recovered"
+	ChangeVerificationValue     ChangeKind = "verification_value_changed"
+	ChangeVerificationAdded     ChangeKind = "verification_added"
+	ChangeVerificationRemoved   ChangeKind = "verification_removed"
+)
+
+// ChangeEvent describes one difference between two results for a product.
+// RuleID and VerificationID are set for rule and verification events; they
+// fall back to the description when the evaluator left the ID empty.
+// Previous and Current hold the compared values rendered as text.
+type ChangeEvent struct {
+	ProductID      string
+	Kind           ChangeKind
+	RuleID         string
+	VerificationID string
+	Previous       string
+	Current        string
+}
+
+// IsRegression reports whether the event is worth alerting on: a crawl that
+// started failing or a rule or verification that went from passed to failed.
+func (event ChangeEvent) IsRegression() bool {
+	switch event.Kind {
+	case ChangeCrawlFailed, ChangeRuleRegressed, ChangeVerificationRegressed:
+		return true
+	}
+	return false
+}
+
+// Regressions filters events down to those reporting IsRegression.
+func Regressions(events []ChangeEvent) []ChangeEvent {
+	var regressions []ChangeEvent
+	for _, event := range events {
+		if event.IsRegression() {
+			regressions = append(regressions, event)
+		}
+	}
+	return regressions
+}
+
+// DiffResults compares two crawls of the same product and returns the changes
+// in a stable order: page-level fields first, then rules and their
+// verifications in the current result's order, then removed rules. A nil
+// previous result yields no events. Rules are only compared when both crawls
+// succeeded, so an outage does not read as every rule being removed.
+func DiffResults(previous, current *Result) []ChangeEvent {
+	if previous == nil || current == nil {
+		return nil
+	}
+	differ := resultDiffer{productID: current.ProductID}
+
+	switch {
+	case previous.Success && !current.Success:
+		differ.add(ChangeEvent{Kind: ChangeCrawlFailed, Previous: crawlOutcome(previous), Current: crawlOutcome(current)})
+	case !previous.Success && current.Success:
+		differ.add(ChangeEvent{Kind: ChangeCrawlRecovered, Previous: crawlOutcome(previous), Current: crawlOutcome(current)})
+	}
+	if previous.HTTPStatusCode != current.HTTPStatusCode {
+		differ.add(ChangeEvent{Kind: ChangeHTTPStatus, Previous: strconv.Itoa(previous.HTTPStatusCode), Current: strconv.Itoa(current.HTTPStatusCode)})
+	}
+	if !previous.Success || !current.Success {
+		return differ.events
+	}
+
+	differ.compareText(ChangeTitle, previous.ProductTitle, current.ProductTitle)
+	differ.compareText(ChangeRedirect, redirectTarget(previous), redirectTarget(current))
+	differ.compareText(ChangeCanonicalURL, previous.CanonicalURL, current.CanonicalURL)
+	differ.compareRules(previous.RuleResults, current.RuleResults)
+	return differ.events
+}
+
+// crawlOutcome renders a crawl outcome for ChangeCrawlFailed/Recovered events.
+func crawlOutcome(result *Result) string {
+	if result.Success {
+		return "success"
+	}
+	if result.ErrorMessage != "" {
+		return result.ErrorMessage
+	}
+	return "failure"
+}
+
+// redirectTarget is the final URL when the crawl ended somewhere other than
+// the requested product URL.
+func redirectTarget(result *Result) string {
+	if result.FinalURL == "" || result.FinalURL == result.ProductURL {
+		return ""
+	}
+	return result.FinalURL
+}
+
+type resultDiffer struct {
+	productID string
+	events    []ChangeEvent
+}
+
+func (differ *resultDiffer) add(event ChangeEvent) {
+	event.ProductID = differ.productID
+	differ.events = append(differ.events, event)
+}
+
+func (differ *resultDiffer) compareText(kind ChangeKind, previous, current string) {
+	if strings.TrimSpace(previous) != strings.TrimSpace(current) {
+		differ.add(ChangeEvent{Kind: kind, Previous: previous, Current: current})
+	}
+}
+
+func (differ *resultDiffer) compareRules(previous, current []RuleResult) {
+	previousByID := make(map[string]RuleResult, len(previous))
+	for _, rule := range previous {
+		previousByID[changeKey(rule.ID, rule.Description)] = rule
+	}
+	seen := make(map[string]struct{}, len(current))
+	for _, rule := range current {
+		ruleID := changeKey(rule.ID, rule.Description)
+		seen[ruleID] = struct{}{}
+		before, existed := previousByID[ruleID]
+		if !existed {
+			differ.add(ChangeEvent{Kind: ChangeRuleAdded, RuleID: ruleID, Current: strconv.FormatBool(rule.Passed)})
+			continue
+		}
+		switch {
+		case before.Passed && !rule.Passed:
+			differ.add(ChangeEvent{Kind: ChangeRuleRegressed, RuleID: ruleID, Previous: before.Message, Current: rule.Message})
+		case !before.Passed && rule.Passed:
+			differ.add(ChangeEvent{Kind: ChangeRuleRecovered, RuleID: ruleID, Previous: before.Message, Current: rule.Message})
+		}
+		differ.compareVerifications(ruleID, before.VerificationResults, rule.VerificationResults)
+	}
+	for _, rule := range previous {
+		ruleID := changeKey(rule.ID, rule.Description)
+		if _, stillPresent := seen[ruleID]; !stillPresent {
+			differ.add(ChangeEvent{Kind: ChangeRuleRemoved, RuleID: ruleID, Previous: strconv.FormatBool(rule.Passed)})
+		}
+	}
+}
+
+func (differ *resultDiffer) compareVerifications(ruleID string, previous, current []VerificationResult) {
+	previousByID := make(map[string]VerificationResult, len(previous))
+	for _, verification := range previous {
+		previousByID[changeKey(verification.ID, verification.Description)] = verification
+	}
+	seen := make(map[string]struct{}, len(current))
+	for _, verification := range current {
+		verificationID := changeKey(verification.ID, verification.Description)
+		seen[verificationID] = struct{}{}
+		before, existed := previousByID[verificationID]
+		event := ChangeEvent{RuleID: ruleID, VerificationID: verificationID}
+		switch {
+		case !existed:
+			event.Kind = ChangeVerificationAdded
+			event.Current = strconv.FormatBool(verification.Passed)
+		case before.Passed && !verification.Passed:
+			event.Kind = ChangeVerificationRegressed
+			event.Previous, event.Current = verificationSummary(before), verificationSummary(verification)
+		case !before.Passed && verification.Passed:
+			event.Kind = ChangeVerificationRecovered
+			event.Previous, event.Current = verificationSummary(before), verificationSummary(verification)
+		case before.Value != verification.Value:
+			event.Kind = ChangeVerificationValue
+			event.Previous, event.Current = before.Value, verification.Value
+		default:
+			continue
+		}
+		differ.add(event)
+	}
+	for _, verification := range previous {
+		verificationID := changeKey(verification.ID, verification.Description)
+		if _, stillPresent := seen[verificationID]; !stillPresent {
+			differ.add(ChangeEvent{Kind: ChangeVerificationRemoved, RuleID: ruleID, VerificationID: verificationID, Previous: strconv.FormatBool(verification.Passed)})
+		}
+	}
+}
+
+// verificationSummary prefers the value and falls back to the message so
+// pass/fail events carry what the verifier saw.
+func verificationSummary(verification VerificationResult) string {
+	if verification.Value != "" {
+		return verification.Value
+	}
+	return verification.Message
+}
+
+func changeKey(id, description string) string {
+	if id != "" {
+		return id
+	}
+	return description
+}
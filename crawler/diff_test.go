@@ -0,0 +1,250 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func baselineDiffResult() *Result {
+	return &Result{
+		ProductID:      "B01",
+		ProductURL:     "https://shop.test/p/B01",
+		FinalURL:       "https://shop.test/p/B01",
+		CanonicalURL:   "https://shop.test/p/B01",
+		ProductTitle:   "Kettle",
+		Success:        true,
+		HTTPStatusCode: 200,
+		RuleResults: []RuleResult{
+			{ID: "content", Passed: true, Message: "2 of 2 checks passed", VerificationResults: []VerificationResult{
+				{ID: "title", Passed: true, Value: "Kettle"},
+				{ID: "price", Passed: true, Value: "$10"},
+				{Description: "Bullets", Passed: true},
+			}},
+			{Description: "Images", Passed: false, Message: "0 of 1 checks passed", VerificationResults: []VerificationResult{
+				{ID: "hero", Passed: false, Message: "missing"},
+			}},
+			{ID: "legacy", Passed: true},
+		},
+	}
+}
+
+func TestDiffResultsReportsRuleAndVerificationChanges(t *testing.T) {
+	t.Parallel()
+
+	previous := baselineDiffResult()
+	current := baselineDiffResult()
+	current.ProductTitle = "Kettle 2.0"
+	current.FinalURL = "https://shop.test/p/B02"
+	current.RuleResults = []RuleResult{
+		{ID: "content", Passed: false, Message: "1 of 2 checks passed", VerificationResults: []VerificationResult{
+			{ID: "title", Passed: false, Message: "title too short"},
+			{ID: "price", Passed: true, Value: "$12"},
+			{ID: "stock", Passed: true},
+		}},
+		{Description: "Images", Passed: true, Message: "1 of 1 checks passed", VerificationResults: []VerificationResult{
+			{ID: "hero", Passed: true, Value: "hero.jpg"},
+		}},
+		{ID: "reviews", Passed: true},
+	}
+
+	events := DiffResults(previous, current)
+	require.Equal(t, []ChangeEvent{
+		{ProductID: "B01", Kind: ChangeTitle, Previous: "Kettle", Current: "Kettle 2.0"},
+		{ProductID: "B01", Kind: ChangeRedirect, Previous: "", Current: "https://shop.test/p/B02"},
+		{ProductID: "B01", Kind: ChangeRuleRegressed, RuleID: "content", Previous: "2 of 2 checks passed", Current: "1 of 2 checks passed"},
+		{ProductID: "B01", Kind: ChangeVerificationRegressed, RuleID: "content", VerificationID: "title", Previous: "Kettle", Current: "title too short"},
+		{ProductID: "B01", Kind: ChangeVerificationValue, RuleID: "content", VerificationID: "price", Previous: "$10", Current: "$12"},
+		{ProductID: "B01", Kind: ChangeVerificationAdded, RuleID: "content", VerificationID: "stock", Current: "true"},
+		{ProductID: "B01", Kind: ChangeVerificationRemoved, RuleID: "content", VerificationID: "Bullets", Previous: "true"},
+		{ProductID: "B01", Kind: ChangeRuleRecovered, RuleID: "Images", Previous: "0 of 1 checks passed", Current: "1 of 1 checks passed"},
+		{ProductID: "B01", Kind: ChangeVerificationRecovered, RuleID: "Images", VerificationID: "hero", Previous: "missing", Current: "hero.jpg"},
+		{ProductID: "B01", Kind: ChangeRuleAdded, RuleID: "reviews", Current: "true"},
+		{ProductID: "B01", Kind: ChangeRuleRemoved, RuleID: "legacy", Previous: "true"},
+	}, events)
+
+	regressions := Regressions(events)
+	require.Len(t, regressions, 2)
+	require.Equal(t, ChangeRuleRegressed, regressions[0].Kind)
+	require.Equal(t, ChangeVerificationRegressed, regressions[1].Kind)
+}
+
+func TestDiffResultsComparesOnlyOutcomeWhenACrawlFailed(t *testing.T) {
+	t.Parallel()
+
+	previous := baselineDiffResult()
+	failed := &Result{ProductID: "B01", HTTPStatusCode: 503}
+	events := DiffResults(previous, failed)
+	require.Equal(t, []ChangeEvent{
+		{ProductID: "B01", Kind: ChangeCrawlFailed, Previous: "success", Current: "failure"},
+		{ProductID: "B01", Kind: ChangeHTTPStatus, Previous: "200", Current: "503"},
+	}, events)
+	require.True(t, events[0].IsRegression())
+	require.False(t, events[1].IsRegression())
+
+	failed.ErrorMessage = "timeout"
+	recovered := DiffResults(failed, baselineDiffResult())
+	require.Equal(t, ChangeEvent{ProductID: "B01", Kind: ChangeCrawlRecovered, Previous: "timeout", Current: "success"}, recovered[0])
+	require.Len(t, recovered, 2)
+
+	require.Empty(t, DiffResults(baselineDiffResult(), baselineDiffResult()))
+	require.Nil(t, DiffResults(nil, baselineDiffResult()))
+	require.Nil(t, DiffResults(baselineDiffResult(), nil))
+}
+
+func TestDiffAgainstStoreKeepsLastSuccessfulResult(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := NewMemoryResultStore()
+
+	events, err := DiffAgainstStore(ctx, store, baselineDiffResult())
+	require.NoError(t, err)
+	require.Empty(t, events)
+
+	events, err = DiffAgainstStore(ctx, store, &Result{ProductID: "B01", HTTPStatusCode: 500})
+	require.NoError(t, err)
+	require.Equal(t, ChangeCrawlFailed, events[0].Kind)
+	stored, found, err := store.LoadResult(ctx, "B01")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.False(t, stored.Latest.Success)
+	require.True(t, stored.LastSuccess.Success)
+
+	stored.LastSuccess.RuleResults[0].VerificationResults[0].Passed = false
+	regressed := baselineDiffResult()
+	regressed.RuleResults[0].VerificationResults[0].Passed = false
+	events, err = DiffAgainstStore(ctx, store, regressed)
+	require.NoError(t, err)
+	require.Equal(t, []ChangeKind{ChangeCrawlRecovered, ChangeHTTPStatus, ChangeVerificationRegressed}, changeKinds(events))
+
+	_, found, err = store.LoadResult(ctx, "unknown")
+	require.NoError(t, err)
+	require.False(t, found)
+	require.Error(t, store.SaveResult(ctx, "B01", StoredResult{}))
+
+	score := 80
+	withScore := &Result{ProductID: "S", ScoreOverride: &score}
+	require.NoError(t, store.SaveResult(ctx, "S", StoredResult{Latest: withScore}))
+	score = 10
+	loaded, _, err := store.LoadResult(ctx, "S")
+	require.NoError(t, err)
+	require.Equal(t, 80, *loaded.Latest.ScoreOverride)
+	require.Nil(t, loaded.LastSuccess)
+}
+
+func TestChangeDetectorReportsCrawlOutcomeOncePerTransition(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	var received [][]ChangeEvent
+	detector, err := NewChangeDetector(NewMemoryResultStore(), func(_ context.Context, events []ChangeEvent) error {
+		received = append(received, events)
+		return nil
+	})
+	require.NoError(t, err)
+
+	outage := &Result{ProductID: "B01", HTTPStatusCode: 503, ErrorMessage: "unavailable"}
+	recovered := baselineDiffResult()
+	recovered.ProductTitle = "Kettle 2"
+	for _, result := range []*Result{baselineDiffResult(), outage, outage, recovered} {
+		require.NoError(t, detector.WriteResults(ctx, []*Result{result}))
+	}
+
+	require.Len(t, received, 2)
+	require.Equal(t, []ChangeKind{ChangeCrawlFailed, ChangeHTTPStatus}, changeKinds(received[0]))
+	require.Equal(t, "unavailable", received[0][0].Current)
+	require.Equal(t, []ChangeKind{ChangeCrawlRecovered, ChangeHTTPStatus, ChangeTitle}, changeKinds(received[1]))
+	require.Equal(t, "unavailable", received[1][0].Previous)
+	require.Equal(t, "Kettle", received[1][2].Previous)
+}
+
+func TestDiffAgainstStoreRecoversWithoutEarlierSuccess(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := NewMemoryResultStore()
+
+	events, err := DiffAgainstStore(ctx, store, &Result{ProductID: "B01", HTTPStatusCode: 500})
+	require.NoError(t, err)
+	require.Empty(t, events)
+	events, err = DiffAgainstStore(ctx, store, baselineDiffResult())
+	require.NoError(t, err)
+	require.Equal(t, []ChangeKind{ChangeCrawlRecovered, ChangeHTTPStatus}, changeKinds(events))
+}
+
+func changeKinds(events []ChangeEvent) []ChangeKind {
+	kinds := make([]ChangeKind, 0, len(events))
+	for _, event := range events {
+		kinds = append(kinds, event.Kind)
+	}
+	return kinds
+}
+
+type failingResultStore struct {
+	loadErr error
+	saveErr error
+}
+
+func (store failingResultStore) LoadResult(context.Context, string) (StoredResult, bool, error) {
+	return StoredResult{}, false, store.loadErr
+}
+
+func (store failingResultStore) SaveResult(context.Context, string, StoredResult) error {
+	return store.saveErr
+}
+
+func TestDiffAgainstStoreReportsStoreErrors(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	_, err := DiffAgainstStore(ctx, nil, baselineDiffResult())
+	require.ErrorContains(t, err, "result store is required")
+	_, err = DiffAgainstStore(ctx, NewMemoryResultStore(), nil)
+	require.ErrorContains(t, err, "result is required")
+	_, err = DiffAgainstStore(ctx, failingResultStore{loadErr: errors.New("offline")}, baselineDiffResult())
+	require.ErrorContains(t, err, "load previous result for B01: offline")
+	_, err = DiffAgainstStore(ctx, failingResultStore{saveErr: errors.New("read only")}, baselineDiffResult())
+	require.ErrorContains(t, err, "save result for B01: read only")
+}
+
+func TestChangeDetectorForwardsEventsPerProduct(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := NewMemoryResultStore()
+	var received [][]ChangeEvent
+	detector, err := NewChangeDetector(store, func(_ context.Context, events []ChangeEvent) error {
+		received = append(received, events)
+		if events[0].ProductID == "B02" {
+			return errors.New("pager offline")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	other := baselineDiffResult()
+	other.ProductID = "B02"
+	require.NoError(t, detector.WriteResults(ctx, []*Result{baselineDiffResult(), other, nil}))
+	require.Empty(t, received)
+
+	changed := baselineDiffResult()
+	changed.ProductTitle = "Renamed"
+	failedOther := &Result{ProductID: "B02"}
+	err = detector.WriteResults(ctx, []*Result{changed, failedOther})
+	require.ErrorContains(t, err, "handle changes for B02: pager offline")
+	require.Len(t, received, 2)
+	require.Equal(t, ChangeTitle, received[0][0].Kind)
+	require.NoError(t, detector.Close())
+
+	broken, err := NewChangeDetector(failingResultStore{loadErr: errors.New("offline")}, func(context.Context, []ChangeEvent) error { return nil })
+	require.NoError(t, err)
+	require.ErrorContains(t, broken.WriteResults(ctx, []*Result{changed}), "offline")
+
+	_, err = NewChangeDetector(nil, func(context.Context, []ChangeEvent) error { return nil })
+	require.ErrorContains(t, err, "result store is required")
+	_, err = NewChangeDetector(store, nil)
+	require.ErrorContains(t, err, "change handler is required")
+}
@@ -0,0 +1,112 @@
+package distributed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tyemirov/utils/crawler"
+)
+
+const defaultCollectBatchSize = 64
+
+// resultMessage is the payload Workers publish to the result queue. ProductID
+// is the published Product.ID with surrounding spaces trimmed, which the Result
+// may not carry verbatim when the crawl was redirected to another product.
+type resultMessage struct {
+	ProductID string          `json:"product_id"`
+	Result    *crawler.Result `json:"result"`
+}
+
+// Coordinator publishes products to the work queue and collects their results.
+type Coordinator struct {
+	work     WorkQueue
+	results  WorkQueue
+	settings settings
+}
+
+// NewCoordinator constructs a Coordinator over the work and result queues.
+func NewCoordinator(work, results WorkQueue, options ...Option) (*Coordinator, error) {
+	if work == nil || results == nil {
+		return nil, errors.New("distributed: work and result queues are required")
+	}
+	configured := newSettings(options)
+	configured.logger = crawler.EnsureLogger(configured.logger)
+	return &Coordinator{work: work, results: results, settings: configured}, nil
+}
+
+// Run publishes products and collects one result per product into results.
+func (coordinator *Coordinator) Run(ctx context.Context, products []crawler.Product, results chan<- *crawler.Result) error {
+	if err := coordinator.Publish(ctx, products); err != nil {
+		return err
+	}
+	return coordinator.Collect(ctx, products, results)
+}
+
+// Publish encodes products onto the work queue.
+func (coordinator *Coordinator) Publish(ctx context.Context, products []crawler.Product) error {
+	payloads := make([][]byte, 0, len(products))
+	for _, product := range products {
+		payload, err := jsonMarshalFunc(product)
+		if err != nil {
+			return fmt.Errorf("distributed: encode product %s: %w", product.ID, err)
+		}
+		payloads = append(payloads, payload)
+	}
+	if err := coordinator.work.Publish(ctx, payloads...); err != nil {
+		return fmt.Errorf("distributed: publish products: %w", err)
+	}
+	coordinator.settings.logger.Info("Published %d products", len(products))
+	return nil
+}
+
+// Collect drains the result queue until every product in products has
+// reported once, sending each first result to results. Duplicate results from
+// re-delivered products and results for unknown products are acknowledged and
+// dropped. Collect returns ctx.Err() if ctx ends first; unacknowledged results
+// stay on the queue for a later Collect.
+func (coordinator *Coordinator) Collect(ctx context.Context, products []crawler.Product, results chan<- *crawler.Result) error {
+	if results == nil {
+		return errors.New("distributed: results channel is required")
+	}
+	pending := make(map[string]struct{}, len(products))
+	for _, product := range products {
+		pending[strings.TrimSpace(product.ID)] = struct{}{}
+	}
+	logger := coordinator.settings.logger
+
+	for len(pending) > 0 {
+		leases, err := coordinator.results.Lease(ctx, defaultCollectBatchSize, coordinator.settings.visibilityTimeout)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logger.Error("Failed to lease results: %v", err)
+		}
+		if len(leases) == 0 {
+			if !sleep(ctx, coordinator.settings.pollInterval) {
+				return ctx.Err()
+			}
+			continue
+		}
+		for _, lease := range leases {
+			var message resultMessage
+			if decodeErr := json.Unmarshal(lease.Payload, &message); decodeErr != nil || message.Result == nil {
+				logger.Error("Dropping undecodable result message %s: %v", lease.MessageID, decodeErr)
+			} else if _, wanted := pending[message.ProductID]; wanted {
+				select {
+				case results <- message.Result:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				delete(pending, message.ProductID)
+			}
+			if ackErr := coordinator.results.Ack(ctx, lease); ackErr != nil {
+				logger.Warning("Failed to acknowledge result message %s: %v", lease.MessageID, ackErr)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+// Package distributed spreads one crawl over several processes. A Coordinator
+// publishes crawler.Products to a work WorkQueue; Workers, each running its
+// own crawler.Service, lease batches of products, crawl them and publish every
+// crawler.Result to a result WorkQueue before acknowledging the products. The
+// Coordinator collects one result per product.
+//
+// Queues deliver at least once: a lease that is not acknowledged within its
+// visibility timeout, because a worker crashed or was stopped mid-batch, is
+// delivered again, so a product can be crawled and reported more than once.
+// The Coordinator keeps the first result per product. MemoryQueue serves tests
+// and single-process runs; SQLQueue shares a GORM database (for example
+// SQLite) between processes.
+package distributed
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/tyemirov/utils/crawler"
+)
+
+const (
+	defaultPollInterval      = time.Second
+	defaultVisibilityTimeout = 5 * time.Minute
+)
+
+// jsonMarshalFunc is the JSON marshal function used to encode queue messages.
+// It defaults to json.Marshal and can be replaced for dependency injection.
+var jsonMarshalFunc = json.Marshal
+
+// ErrLeaseLost is returned by Ack when the message was acknowledged already or
+// re-leased to another consumer after its visibility timeout.
+var ErrLeaseLost = errors.New("distributed: lease lost")
+
+// ErrQueueClosed is returned by queue operations after Close.
+var ErrQueueClosed = errors.New("distributed: queue is closed")
+
+// Lease is a message handed to one consumer until its visibility timeout
+// expires or it is acknowledged.
+type Lease struct {
+	// MessageID identifies the message across deliveries.
+	MessageID string
+	// Token identifies this delivery; Ack must present it.
+	Token string
+	// Payload is the published message.
+	Payload []byte
+	// Deliveries counts how often the message has been leased, this lease
+	// included.
+	Deliveries int
+}
+
+// WorkQueue is an at-least-once message queue with visibility timeouts.
+type WorkQueue interface {
+	// Publish appends messages to the queue.
+	Publish(ctx context.Context, payloads ...[]byte) error
+	// Lease hands out up to limit visible messages in publication order and
+	// hides them for visibility. It returns an empty slice rather than
+	// blocking when none is visible.
+	Lease(ctx context.Context, limit int, visibility time.Duration) ([]Lease, error)
+	// Ack removes a leased message. It returns ErrLeaseLost when the lease
+	// is no longer the message's current one.
+	Ack(ctx context.Context, lease Lease) error
+	// Close releases the queue's resources.
+	Close() error
+}
+
+// Option customises a Coordinator or Worker.
+type Option func(*settings)
+
+type settings struct {
+	pollInterval      time.Duration
+	visibilityTimeout time.Duration
+	batchSize         int
+	logger            crawler.Logger
+	serviceOptions    []crawler.ServiceOption
+}
+
+func newSettings(options []Option) settings {
+	configured := settings{
+		pollInterval:      defaultPollInterval,
+		visibilityTimeout: defaultVisibilityTimeout,
+	}
+	for _, option := range options {
+		if option != nil {
+			option(&configured)
+		}
+	}
+	return configured
+}
+
+// WithPollInterval sets how long an idle Worker or Coordinator waits before
+// leasing again. Defaults to one second; non-positive values are ignored.
+func WithPollInterval(interval time.Duration) Option {
+	return func(configured *settings) {
+		if interval > 0 {
+			configured.pollInterval = interval
+		}
+	}
+}
+
+// WithVisibilityTimeout sets how long a leased message stays hidden. For a
+// Worker it must cover crawling a whole batch, or products are delivered to
+// another worker meanwhile. Defaults to five minutes; non-positive values are
+// ignored.
+func WithVisibilityTimeout(timeout time.Duration) Option {
+	return func(configured *settings) {
+		if timeout > 0 {
+			configured.visibilityTimeout = timeout
+		}
+	}
+}
+
+// WithBatchSize sets how many products a Worker leases per batch. Defaults to
+// the service's Scraper.Parallelism; non-positive values are ignored.
+func WithBatchSize(size int) Option {
+	return func(configured *settings) {
+		if size > 0 {
+			configured.batchSize = size
+		}
+	}
+}
+
+// WithLogger reports queue failures and progress. A Worker defaults to the
+// crawler.Config logger.
+func WithLogger(logger crawler.Logger) Option {
+	return func(configured *settings) {
+		configured.logger = logger
+	}
+}
+
+// WithServiceOptions passes options to every crawler.Service a Worker builds.
+func WithServiceOptions(options ...crawler.ServiceOption) Option {
+	return func(configured *settings) {
+		configured.serviceOptions = append(configured.serviceOptions, options...)
+	}
+}
+
+// sleep waits for duration or until ctx ends, reporting whether it waited.
+func sleep(ctx context.Context, duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// newLeaseToken returns a random delivery token.
+func newLeaseToken() string {
+	token := make([]byte, 16)
+	_, _ = rand.Read(token)
+	return hex.EncodeToString(token)
+}
@@ -0,0 +1,709 @@
+package distributed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/glebarez/sqlite"
+	"github.com/gocolly/colly/v2"
+	"github.com/stretchr/testify/require"
+	"github.com/tyemirov/utils/crawler"
+	"github.com/tyemirov/utils/crawler/crawlertest"
+	"gorm.io/gorm"
+)
+
+type passingRuleEvaluator struct{}
+
+func (passingRuleEvaluator) Evaluate(string, *goquery.Document) (crawler.RuleEvaluation, error) {
+	return crawler.RuleEvaluation{}, nil
+}
+
+func (passingRuleEvaluator) ConfiguredVerifierCount() int { return 0 }
+
+type manualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (clock *manualClock) Now() time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	return clock.now
+}
+
+func (clock *manualClock) Advance(duration time.Duration) {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	clock.now = clock.now.Add(duration)
+}
+
+func payloads(leases []Lease) []string {
+	values := make([]string, 0, len(leases))
+	for _, lease := range leases {
+		values = append(values, string(lease.Payload))
+	}
+	return values
+}
+
+// exerciseQueue checks the WorkQueue contract against queue, whose clock is
+// driven by clock.
+func exerciseQueue(t *testing.T, queue WorkQueue, clock *manualClock) {
+	t.Helper()
+	ctx := context.Background()
+	require.NoError(t, queue.Publish(ctx, []byte("a"), []byte("b"), []byte("c")))
+
+	first, err := queue.Lease(ctx, 2, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, payloads(first))
+	require.Equal(t, 1, first[0].Deliveries)
+
+	second, err := queue.Lease(ctx, 5, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c"}, payloads(second))
+	require.NoError(t, queue.Ack(ctx, first[0]))
+	require.ErrorIs(t, queue.Ack(ctx, first[0]), ErrLeaseLost)
+
+	empty, err := queue.Lease(ctx, 5, time.Minute)
+	require.NoError(t, err)
+	require.Empty(t, empty)
+
+	clock.Advance(2 * time.Minute)
+	redelivered, err := queue.Lease(ctx, 5, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, []string{"b", "c"}, payloads(redelivered))
+	require.Equal(t, 2, redelivered[0].Deliveries)
+	require.ErrorIs(t, queue.Ack(ctx, first[1]), ErrLeaseLost)
+	for _, lease := range redelivered {
+		require.NoError(t, queue.Ack(ctx, lease))
+	}
+
+	require.NoError(t, queue.Close())
+	require.ErrorIs(t, queue.Publish(ctx, []byte("d")), ErrQueueClosed)
+}
+
+func TestMemoryQueueRedeliversAfterVisibilityTimeout(t *testing.T) {
+	clock := &manualClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	queue := NewMemoryQueue()
+	queue.now = clock.Now
+	exerciseQueue(t, queue, clock)
+	require.Zero(t, queue.Len())
+}
+
+func TestSQLQueueRedeliversAfterVisibilityTimeout(t *testing.T) {
+	database, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "queue.db")), &gorm.Config{})
+	require.NoError(t, err)
+	_, err = NewSQLQueue(SQLQueueConfig{Database: database})
+	require.ErrorContains(t, err, "name is required")
+	_, err = NewSQLQueue(SQLQueueConfig{Name: "work"})
+	require.ErrorContains(t, err, "database is required")
+
+	clock := &manualClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	work, err := NewSQLQueue(SQLQueueConfig{Database: database, Name: "work"})
+	require.NoError(t, err)
+	work.now = clock.Now
+	require.NoError(t, work.Migrate(context.Background()))
+
+	results, err := NewSQLQueue(SQLQueueConfig{Database: database, Name: "results"})
+	require.NoError(t, err)
+	require.NoError(t, results.Publish(context.Background(), []byte("result")))
+
+	exerciseQueue(t, work, clock)
+
+	leased, err := results.Lease(context.Background(), 5, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, []string{"result"}, payloads(leased))
+}
+
+func TestWorkersCrawlPublishedProductsAndRecoverAbandonedLeases(t *testing.T) {
+	platform := crawlertest.NewPlatform()
+	t.Cleanup(platform.Close)
+	products := make([]crawler.Product, 0, 6)
+	for index := range 6 {
+		path := fmt.Sprintf("/p/%d", index)
+		if index == 5 {
+			platform.Handle(path, crawlertest.NotFound())
+		} else {
+			platform.Handle(path, crawlertest.Page(fmt.Sprintf("Product %d", index), "<p>in stock</p>"))
+		}
+		products = append(products, crawler.Product{ID: fmt.Sprint(index), Platform: "FAKE", URL: platform.URLFor(path)})
+	}
+
+	work, results := NewMemoryQueue(), NewMemoryQueue()
+	coordinator, err := NewCoordinator(work, results, WithPollInterval(10*time.Millisecond))
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	require.NoError(t, coordinator.Publish(ctx, products))
+
+	// A worker that leased the first product and died before acknowledging it.
+	abandoned, err := work.Lease(ctx, 1, 100*time.Millisecond)
+	require.NoError(t, err)
+	require.Len(t, abandoned, 1)
+	// Leftovers on the result queue that Collect must drop.
+	foreign, err := json.Marshal(resultMessage{ProductID: "other-run", Result: &crawler.Result{ProductID: "other-run"}})
+	require.NoError(t, err)
+	require.NoError(t, results.Publish(ctx, foreign, []byte("not json")))
+
+	cfg := crawler.Config{
+		PlatformID:    "FAKE",
+		Scraper:       crawler.ScraperConfig{MaxDepth: 1, Parallelism: 2, HTTPTimeout: 2 * time.Second},
+		Platform:      crawler.PlatformConfig{AllowedDomains: []string{platform.Host()}},
+		RuleEvaluator: passingRuleEvaluator{},
+	}
+	_, err = NewWorker(crawler.Config{}, work, results)
+	require.Error(t, err)
+
+	workerCtx, stopWorkers := context.WithCancel(ctx)
+	var workers sync.WaitGroup
+	for range 2 {
+		worker, workerErr := NewWorker(cfg, work, results, WithPollInterval(10*time.Millisecond))
+		require.NoError(t, workerErr)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			require.ErrorIs(t, worker.Run(workerCtx), context.Canceled)
+		}()
+	}
+
+	collected := make(chan *crawler.Result, len(products))
+	require.NoError(t, coordinator.Collect(ctx, products, collected))
+	stopWorkers()
+	workers.Wait()
+	close(collected)
+
+	byProduct := crawlertest.ByProductID(drain(collected))
+	require.Len(t, byProduct, len(products))
+	for index := range 5 {
+		result := byProduct[fmt.Sprint(index)]
+		require.True(t, result.Success, result.ErrorMessage)
+		require.Equal(t, fmt.Sprintf("Product %d", index), result.ProductTitle)
+	}
+	require.False(t, byProduct["5"].Success)
+	require.Equal(t, http.StatusNotFound, byProduct["5"].HTTPStatusCode)
+	require.Zero(t, work.Len())
+	require.Zero(t, results.Len())
+	require.ErrorIs(t, work.Ack(ctx, abandoned[0]), ErrLeaseLost)
+}
+
+func TestCoordinatorCollectStopsWithContext(t *testing.T) {
+	coordinator, err := NewCoordinator(NewMemoryQueue(), NewMemoryQueue(), WithPollInterval(time.Millisecond))
+	require.NoError(t, err)
+	_, err = NewCoordinator(nil, NewMemoryQueue())
+	require.Error(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = coordinator.Collect(ctx, []crawler.Product{{ID: "1"}}, make(chan *crawler.Result, 1))
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func drain(results <-chan *crawler.Result) []*crawler.Result {
+	var collected []*crawler.Result
+	for result := range results {
+		collected = append(collected, result)
+	}
+	return collected
+}
+
+type recordingLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (logger *recordingLogger) record(format string, args ...interface{}) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	logger.messages = append(logger.messages, fmt.Sprintf(format, args...))
+}
+
+func (logger *recordingLogger) Debug(format string, args ...interface{}) {
+	logger.record(format, args...)
+}
+
+func (logger *recordingLogger) Info(format string, args ...interface{}) {
+	logger.record(format, args...)
+}
+
+func (logger *recordingLogger) Warning(format string, args ...interface{}) {
+	logger.record(format, args...)
+}
+
+func (logger *recordingLogger) Error(format string, args ...interface{}) {
+	logger.record(format, args...)
+}
+
+func (logger *recordingLogger) contains(fragment string) bool {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	for _, message := range logger.messages {
+		if strings.Contains(message, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// scriptedQueue is a MemoryQueue whose operations can be made to fail.
+type scriptedQueue struct {
+	*MemoryQueue
+	publishErr error
+	leaseErr   error
+	ackErr     error
+}
+
+func (queue *scriptedQueue) Publish(ctx context.Context, payloads ...[]byte) error {
+	if queue.publishErr != nil {
+		return queue.publishErr
+	}
+	return queue.MemoryQueue.Publish(ctx, payloads...)
+}
+
+func (queue *scriptedQueue) Lease(ctx context.Context, limit int, visibility time.Duration) ([]Lease, error) {
+	if queue.leaseErr != nil {
+		return nil, queue.leaseErr
+	}
+	return queue.MemoryQueue.Lease(ctx, limit, visibility)
+}
+
+func (queue *scriptedQueue) Ack(ctx context.Context, lease Lease) error {
+	if queue.ackErr != nil {
+		return queue.ackErr
+	}
+	return queue.MemoryQueue.Ack(ctx, lease)
+}
+
+// resultRewriter edits every result before the crawler emits it.
+type resultRewriter struct {
+	crawler.NoopResponseHandler
+	rewrite func(*crawler.Result)
+}
+
+func (rewriter resultRewriter) AfterEvaluation(_ *colly.Response, _ *goquery.Document, result *crawler.Result) {
+	rewriter.rewrite(result)
+}
+
+func newTestConfig(platform *crawlertest.Platform) crawler.Config {
+	return crawler.Config{
+		PlatformID:    "FAKE",
+		Scraper:       crawler.ScraperConfig{MaxDepth: 1, Parallelism: 2, HTTPTimeout: 2 * time.Second},
+		Platform:      crawler.PlatformConfig{AllowedDomains: []string{platform.Host()}},
+		RuleEvaluator: passingRuleEvaluator{},
+	}
+}
+
+func TestOptionsConfigureSettings(t *testing.T) {
+	logger := &recordingLogger{}
+	serviceOption := crawler.WithServiceHook(nil)
+	configured := newSettings([]Option{
+		nil,
+		WithPollInterval(time.Millisecond),
+		WithVisibilityTimeout(time.Minute),
+		WithBatchSize(3),
+		WithLogger(logger),
+		WithServiceOptions(serviceOption),
+		WithServiceOptions(serviceOption, serviceOption),
+	})
+	require.Equal(t, time.Millisecond, configured.pollInterval)
+	require.Equal(t, time.Minute, configured.visibilityTimeout)
+	require.Equal(t, 3, configured.batchSize)
+	require.Same(t, logger, configured.logger)
+	require.Len(t, configured.serviceOptions, 3)
+
+	defaults := newSettings([]Option{WithPollInterval(0), WithVisibilityTimeout(-time.Second), WithBatchSize(0)})
+	require.Equal(t, defaultPollInterval, defaults.pollInterval)
+	require.Equal(t, defaultVisibilityTimeout, defaults.visibilityTimeout)
+	require.Zero(t, defaults.batchSize)
+	require.Nil(t, defaults.logger)
+}
+
+func TestNewWorkerDefaultsBatchSizeAndLoggerFromConfig(t *testing.T) {
+	platform := crawlertest.NewPlatform()
+	t.Cleanup(platform.Close)
+	cfg := newTestConfig(platform)
+	configLogger := &recordingLogger{}
+	cfg.Logger = configLogger
+	work, results := NewMemoryQueue(), NewMemoryQueue()
+
+	worker, err := NewWorker(cfg, work, results)
+	require.NoError(t, err)
+	require.Equal(t, cfg.Scraper.Parallelism, worker.settings.batchSize)
+	require.Same(t, configLogger, worker.settings.logger)
+
+	optionLogger := &recordingLogger{}
+	worker, err = NewWorker(cfg, work, results, WithBatchSize(7), WithLogger(optionLogger))
+	require.NoError(t, err)
+	require.Equal(t, 7, worker.settings.batchSize)
+	require.Same(t, optionLogger, worker.settings.logger)
+
+	_, err = NewWorker(cfg, nil, results)
+	require.ErrorContains(t, err, "queues are required")
+}
+
+func TestCoordinatorRunCollectsResultsForPaddedProductIDs(t *testing.T) {
+	platform := crawlertest.NewPlatform()
+	t.Cleanup(platform.Close)
+	platform.Handle("/p/7", crawlertest.Page("Product 7", "<p>in stock</p>"))
+	platform.Handle("/p/8", crawlertest.Page("Product 8", "<p>in stock</p>"))
+	products := []crawler.Product{
+		{ID: "  7 ", Platform: "FAKE", URL: platform.URLFor("/p/7")},
+		{ID: "8", Platform: "FAKE", URL: platform.URLFor("/p/8")},
+	}
+
+	work, results := NewMemoryQueue(), NewMemoryQueue()
+	coordinatorLogger := &recordingLogger{}
+	coordinator, err := NewCoordinator(work, results,
+		WithPollInterval(5*time.Millisecond),
+		WithVisibilityTimeout(time.Minute),
+		WithLogger(coordinatorLogger),
+	)
+	require.NoError(t, err)
+
+	var evaluated sync.Map
+	rewriter := resultRewriter{rewrite: func(result *crawler.Result) { evaluated.Store(result.ProductID, true) }}
+	workerLogger := &recordingLogger{}
+	worker, err := NewWorker(newTestConfig(platform), work, results,
+		WithPollInterval(5*time.Millisecond),
+		WithVisibilityTimeout(time.Minute),
+		WithBatchSize(1),
+		WithLogger(workerLogger),
+		WithServiceOptions(crawler.WithResponseHandlers(rewriter)),
+	)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	workerCtx, stopWorker := context.WithCancel(ctx)
+	workerDone := make(chan error, 1)
+	go func() { workerDone <- worker.Run(workerCtx) }()
+
+	collected := make(chan *crawler.Result, len(products))
+	require.NoError(t, coordinator.Run(ctx, products, collected))
+	stopWorker()
+	require.ErrorIs(t, <-workerDone, context.Canceled)
+	close(collected)
+
+	byProduct := crawlertest.ByProductID(drain(collected))
+	require.Len(t, byProduct, 2)
+	require.Equal(t, "Product 7", byProduct["  7 "].ProductTitle)
+	require.Equal(t, "Product 8", byProduct["8"].ProductTitle)
+	for _, productID := range []string{"7", "8"} {
+		_, ok := evaluated.Load(productID)
+		require.True(t, ok, productID)
+	}
+	require.Zero(t, work.Len())
+	require.Zero(t, results.Len())
+	require.True(t, coordinatorLogger.contains("Published 2 products"))
+	require.False(t, workerLogger.contains("unleased product"))
+}
+
+func TestCoordinatorReportsPublishFailures(t *testing.T) {
+	work := NewMemoryQueue()
+	require.NoError(t, work.Close())
+	coordinator, err := NewCoordinator(work, NewMemoryQueue())
+	require.NoError(t, err)
+	err = coordinator.Run(context.Background(), []crawler.Product{{ID: "1"}}, make(chan *crawler.Result, 1))
+	require.ErrorIs(t, err, ErrQueueClosed)
+
+	encodeErr := errors.New("encode failed")
+	originalMarshal := jsonMarshalFunc
+	jsonMarshalFunc = func(any) ([]byte, error) { return nil, encodeErr }
+	defer func() { jsonMarshalFunc = originalMarshal }()
+
+	coordinator, err = NewCoordinator(NewMemoryQueue(), NewMemoryQueue())
+	require.NoError(t, err)
+	err = coordinator.Publish(context.Background(), []crawler.Product{{ID: "1"}})
+	require.ErrorIs(t, err, encodeErr)
+	require.ErrorContains(t, err, "encode product 1")
+}
+
+func TestCoordinatorCollectHandlesQueueFailures(t *testing.T) {
+	resultFor := func(productID string) []byte {
+		payload, err := json.Marshal(resultMessage{ProductID: productID, Result: &crawler.Result{ProductID: productID}})
+		require.NoError(t, err)
+		return payload
+	}
+
+	coordinator, err := NewCoordinator(NewMemoryQueue(), NewMemoryQueue())
+	require.NoError(t, err)
+	require.ErrorContains(t, coordinator.Collect(context.Background(), nil, nil), "results channel is required")
+
+	logger := &recordingLogger{}
+	failing := &scriptedQueue{MemoryQueue: NewMemoryQueue(), leaseErr: errors.New("lease failed")}
+	coordinator, err = NewCoordinator(NewMemoryQueue(), failing, WithPollInterval(time.Millisecond), WithLogger(logger))
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = coordinator.Collect(ctx, []crawler.Product{{ID: "1"}}, make(chan *crawler.Result, 1))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.True(t, logger.contains("Failed to lease results: lease failed"))
+
+	canceled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	err = coordinator.Collect(canceled, []crawler.Product{{ID: "1"}}, make(chan *crawler.Result, 1))
+	require.ErrorIs(t, err, context.Canceled)
+
+	blocked := NewMemoryQueue()
+	require.NoError(t, blocked.Publish(context.Background(), resultFor("1")))
+	coordinator, err = NewCoordinator(NewMemoryQueue(), blocked)
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = coordinator.Collect(ctx, []crawler.Product{{ID: "1"}}, make(chan *crawler.Result))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, 1, blocked.Len())
+
+	logger = &recordingLogger{}
+	unacknowledged := &scriptedQueue{MemoryQueue: NewMemoryQueue(), ackErr: errors.New("ack failed")}
+	require.NoError(t, unacknowledged.Publish(context.Background(), resultFor("1")))
+	coordinator, err = NewCoordinator(NewMemoryQueue(), unacknowledged, WithLogger(logger))
+	require.NoError(t, err)
+	collected := make(chan *crawler.Result, 1)
+	require.NoError(t, coordinator.Collect(context.Background(), []crawler.Product{{ID: "1"}}, collected))
+	require.Len(t, collected, 1)
+	require.True(t, logger.contains("Failed to acknowledge result message"))
+}
+
+func TestMemoryQueueRejectsCanceledAndClosedOperations(t *testing.T) {
+	queue := NewMemoryQueue()
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, queue.Publish(canceled, []byte("a")), context.Canceled)
+	_, err := queue.Lease(canceled, 1, time.Minute)
+	require.ErrorIs(t, err, context.Canceled)
+	require.ErrorIs(t, queue.Ack(canceled, Lease{}), context.Canceled)
+
+	require.ErrorIs(t, queue.Ack(context.Background(), Lease{MessageID: "missing"}), ErrLeaseLost)
+	require.NoError(t, queue.Close())
+	_, err = queue.Lease(context.Background(), 1, time.Minute)
+	require.ErrorIs(t, err, ErrQueueClosed)
+	require.ErrorIs(t, queue.Ack(context.Background(), Lease{}), ErrQueueClosed)
+}
+
+func TestWorkerRunSurvivesLeaseFailures(t *testing.T) {
+	platform := crawlertest.NewPlatform()
+	t.Cleanup(platform.Close)
+	logger := &recordingLogger{}
+	work := &scriptedQueue{MemoryQueue: NewMemoryQueue(), leaseErr: errors.New("lease failed")}
+	worker, err := NewWorker(newTestConfig(platform), work, NewMemoryQueue(), WithPollInterval(time.Millisecond), WithLogger(logger))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, worker.Run(ctx), context.DeadlineExceeded)
+	require.True(t, logger.contains("Failed to lease products: lease failed"))
+
+	canceled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	require.ErrorIs(t, worker.Run(canceled), context.Canceled)
+}
+
+func TestWorkerRunStopsWhenTheServiceCannotBeBuilt(t *testing.T) {
+	platform := crawlertest.NewPlatform()
+	t.Cleanup(platform.Close)
+	cfg := newTestConfig(platform)
+	cfg.Scraper.ProxySource = crawler.ProxySourceFunc(func() ([]string, error) {
+		return nil, errors.New("proxy source down")
+	})
+	work := NewMemoryQueue()
+	product, err := json.Marshal(crawler.Product{ID: "1", Platform: "FAKE", URL: platform.URLFor("/p/1")})
+	require.NoError(t, err)
+	require.NoError(t, work.Publish(context.Background(), product))
+
+	worker, err := NewWorker(cfg, work, NewMemoryQueue(), WithLogger(&recordingLogger{}))
+	require.NoError(t, err)
+	err = worker.Run(context.Background())
+	require.ErrorContains(t, err, "build crawler service")
+	require.ErrorContains(t, err, "proxy source down")
+}
+
+func TestWorkerProcessBatchReportsEveryFailure(t *testing.T) {
+	platform := crawlertest.NewPlatform()
+	t.Cleanup(platform.Close)
+	platform.Handle("/p/1", crawlertest.Page("Product 1", "<p>in stock</p>"))
+
+	testCases := []struct {
+		name        string
+		configure   func(*crawler.Config)
+		rewrite     func(context.CancelFunc, *crawler.Result)
+		publishErr  error
+		ackErr      error
+		marshalErr  error
+		published   int
+		remaining   int
+		logFragment string
+	}{
+		{
+			name:      "falls back to the product id",
+			rewrite:   func(_ context.CancelFunc, result *crawler.Result) { result.OriginalProductID = "" },
+			published: 1,
+		},
+		{
+			name:        "drops results for unleased products",
+			rewrite:     func(_ context.CancelFunc, result *crawler.Result) { result.OriginalProductID = "other" },
+			remaining:   1,
+			logFragment: "unleased product other",
+		},
+		{
+			name:        "keeps the lease when the result cannot be encoded",
+			marshalErr:  errors.New("encode failed"),
+			remaining:   1,
+			logFragment: "Failed to encode result for product 1",
+		},
+		{
+			name:        "keeps the lease when the result cannot be published",
+			publishErr:  errors.New("publish failed"),
+			remaining:   1,
+			logFragment: "Failed to publish result for product 1",
+		},
+		{
+			name:        "warns when the product cannot be acknowledged",
+			ackErr:      errors.New("ack failed"),
+			published:   1,
+			remaining:   2,
+			logFragment: "Failed to acknowledge product message",
+		},
+		{
+			name:      "skips results once the context ends",
+			rewrite:   func(cancel context.CancelFunc, _ *crawler.Result) { cancel() },
+			remaining: 1,
+		},
+		{
+			name: "logs a failed crawler run",
+			configure: func(cfg *crawler.Config) {
+				cfg.Scraper.ProxyList = []string{"http://127.0.0.1:1"}
+				cfg.Scraper.ProxyProbe = crawler.ProxyProbeConfig{ProbeURL: platform.URLFor("/probe"), Timeout: time.Second}
+			},
+			remaining:   1,
+			logFragment: "Crawler run failed",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			cfg := newTestConfig(platform)
+			if testCase.configure != nil {
+				testCase.configure(&cfg)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			var options []Option
+			if testCase.rewrite != nil {
+				rewriter := resultRewriter{rewrite: func(result *crawler.Result) { testCase.rewrite(cancel, result) }}
+				options = append(options, WithServiceOptions(crawler.WithResponseHandlers(rewriter)))
+			}
+			if testCase.marshalErr != nil {
+				originalMarshal := jsonMarshalFunc
+				jsonMarshalFunc = func(any) ([]byte, error) { return nil, testCase.marshalErr }
+				defer func() { jsonMarshalFunc = originalMarshal }()
+			}
+
+			work := &scriptedQueue{MemoryQueue: NewMemoryQueue(), ackErr: testCase.ackErr}
+			results := &scriptedQueue{MemoryQueue: NewMemoryQueue(), publishErr: testCase.publishErr}
+			product, err := json.Marshal(crawler.Product{ID: "1", Platform: "FAKE", URL: platform.URLFor("/p/1")})
+			require.NoError(t, err)
+			require.NoError(t, work.MemoryQueue.Publish(ctx, product, []byte("not json")))
+			leases, err := work.MemoryQueue.Lease(ctx, 2, time.Minute)
+			require.NoError(t, err)
+
+			logger := &recordingLogger{}
+			worker, err := NewWorker(cfg, work, results, append(options, WithLogger(logger))...)
+			require.NoError(t, err)
+			require.NoError(t, worker.processBatch(ctx, leases))
+
+			require.Equal(t, testCase.published, results.Len())
+			require.Equal(t, testCase.remaining, work.Len())
+			require.True(t, logger.contains("Dropping undecodable product message"))
+			if testCase.logFragment != "" {
+				require.True(t, logger.contains(testCase.logFragment), logger.messages)
+			}
+		})
+	}
+}
+
+func TestWorkerProcessBatchWithOnlyUndecodableMessages(t *testing.T) {
+	platform := crawlertest.NewPlatform()
+	t.Cleanup(platform.Close)
+	work := NewMemoryQueue()
+	require.NoError(t, work.Publish(context.Background(), []byte("not json")))
+	leases, err := work.Lease(context.Background(), 1, time.Minute)
+	require.NoError(t, err)
+
+	worker, err := NewWorker(newTestConfig(platform), work, NewMemoryQueue(), WithLogger(&recordingLogger{}))
+	require.NoError(t, err)
+	require.NoError(t, worker.processBatch(context.Background(), leases))
+	require.Zero(t, work.Len())
+}
+
+func TestSQLQueueReportsDatabaseFailures(t *testing.T) {
+	openQueue := func(t *testing.T) (*SQLQueue, *gorm.DB) {
+		t.Helper()
+		database, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "queue.db")), &gorm.Config{})
+		require.NoError(t, err)
+		queue, err := NewSQLQueue(SQLQueueConfig{Database: database, Name: "work", TableName: "jobs"})
+		require.NoError(t, err)
+		require.NoError(t, queue.Migrate(context.Background()))
+		return queue, database
+	}
+	ctx := context.Background()
+
+	t.Run("empty and invalid input", func(t *testing.T) {
+		queue, _ := openQueue(t)
+		require.NoError(t, queue.Publish(ctx))
+		leases, err := queue.Lease(ctx, 0, time.Minute)
+		require.NoError(t, err)
+		require.Empty(t, leases)
+		require.ErrorIs(t, queue.Ack(ctx, Lease{MessageID: "not-a-number"}), ErrLeaseLost)
+
+		require.NoError(t, queue.Close())
+		_, err = queue.Lease(ctx, 1, time.Minute)
+		require.ErrorIs(t, err, ErrQueueClosed)
+		require.ErrorIs(t, queue.Ack(ctx, Lease{MessageID: "1"}), ErrQueueClosed)
+	})
+
+	t.Run("closed database", func(t *testing.T) {
+		queue, database := openQueue(t)
+		require.NoError(t, queue.Publish(ctx, []byte("a")))
+		leases, err := queue.Lease(ctx, 1, time.Minute)
+		require.NoError(t, err)
+		sqlDatabase, err := database.DB()
+		require.NoError(t, err)
+		require.NoError(t, sqlDatabase.Close())
+
+		require.ErrorContains(t, queue.Migrate(ctx), "migrate jobs")
+		require.ErrorContains(t, queue.Publish(ctx, []byte("b")), "publish to work")
+		_, err = queue.Lease(ctx, 1, time.Minute)
+		require.ErrorContains(t, err, "lease from work")
+		require.ErrorContains(t, queue.Ack(ctx, leases[0]), "ack 1 on work")
+	})
+
+	t.Run("failed claim", func(t *testing.T) {
+		queue, database := openQueue(t)
+		require.NoError(t, queue.Publish(ctx, []byte("a"), []byte("b")))
+		require.NoError(t, database.Callback().Update().Before("gorm:update").Register("fail_claim", func(db *gorm.DB) {
+			_ = db.AddError(errors.New("claim failed"))
+		}))
+		leases, err := queue.Lease(ctx, 2, time.Minute)
+		require.ErrorContains(t, err, "claim failed")
+		require.Empty(t, leases)
+	})
+
+	t.Run("claim lost to another consumer", func(t *testing.T) {
+		queue, database := openQueue(t)
+		require.NoError(t, queue.Publish(ctx, []byte("a"), []byte("b")))
+		require.NoError(t, database.Callback().Query().After("gorm:query").Register("steal_first", func(db *gorm.DB) {
+			db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Table("jobs").Where("id = ?", 1).Update("token", "stolen")
+		}))
+		leases, err := queue.Lease(ctx, 2, time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, []string{"b"}, payloads(leases))
+	})
+}
@@ -0,0 +1,121 @@
+package distributed
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type memoryMessage struct {
+	id         string
+	payload    []byte
+	visibleAt  time.Time
+	token      string
+	deliveries int
+}
+
+// MemoryQueue is an in-process WorkQueue. It is safe for concurrent use and
+// loses its messages when the process exits.
+type MemoryQueue struct {
+	mu       sync.Mutex
+	now      func() time.Time
+	sequence int
+	messages []*memoryMessage
+	closed   bool
+}
+
+// NewMemoryQueue constructs an empty MemoryQueue.
+func NewMemoryQueue() *MemoryQueue {
+	return &MemoryQueue{now: time.Now}
+}
+
+// Publish appends payloads in order.
+func (queue *MemoryQueue) Publish(ctx context.Context, payloads ...[]byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	if queue.closed {
+		return ErrQueueClosed
+	}
+	for _, payload := range payloads {
+		queue.sequence++
+		queue.messages = append(queue.messages, &memoryMessage{
+			id:      strconv.Itoa(queue.sequence),
+			payload: append([]byte(nil), payload...),
+		})
+	}
+	return nil
+}
+
+// Lease hands out up to limit visible messages, oldest first.
+func (queue *MemoryQueue) Lease(ctx context.Context, limit int, visibility time.Duration) ([]Lease, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	if queue.closed {
+		return nil, ErrQueueClosed
+	}
+	now := queue.now()
+	leases := make([]Lease, 0, max(limit, 0))
+	for _, message := range queue.messages {
+		if len(leases) >= limit {
+			break
+		}
+		if message.visibleAt.After(now) {
+			continue
+		}
+		message.visibleAt = now.Add(visibility)
+		message.token = newLeaseToken()
+		message.deliveries++
+		leases = append(leases, Lease{
+			MessageID:  message.id,
+			Token:      message.token,
+			Payload:    append([]byte(nil), message.payload...),
+			Deliveries: message.deliveries,
+		})
+	}
+	return leases, nil
+}
+
+// Ack removes the leased message.
+func (queue *MemoryQueue) Ack(ctx context.Context, lease Lease) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	if queue.closed {
+		return ErrQueueClosed
+	}
+	for index, message := range queue.messages {
+		if message.id != lease.MessageID {
+			continue
+		}
+		if message.token != lease.Token {
+			return ErrLeaseLost
+		}
+		queue.messages = append(queue.messages[:index], queue.messages[index+1:]...)
+		return nil
+	}
+	return ErrLeaseLost
+}
+
+// Len reports how many messages, leased or not, are still unacknowledged.
+func (queue *MemoryQueue) Len() int {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	return len(queue.messages)
+}
+
+// Close rejects further operations.
+func (queue *MemoryQueue) Close() error {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	queue.closed = true
+	return nil
+}
@@ -0,0 +1,177 @@
+package distributed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const defaultSQLQueueTableName = "crawler_queue"
+
+// queueRecord is the row layout of a SQLQueue table. Several named queues can
+// share one table.
+type queueRecord struct {
+	ID         uint      `gorm:"primaryKey"`
+	Queue      string    `gorm:"size:128;not null;index:idx_queue_visible,priority:1"`
+	VisibleAt  time.Time `gorm:"not null;index:idx_queue_visible,priority:2"`
+	Token      string    `gorm:"size:64"`
+	Deliveries int
+	Payload    []byte
+	CreatedAt  time.Time
+}
+
+// SQLQueueConfig configures a SQLQueue.
+type SQLQueueConfig struct {
+	// Database is the GORM handle the queue runs on. Mandatory.
+	Database *gorm.DB
+	// TableName defaults to "crawler_queue".
+	TableName string
+	// Name separates queues sharing a table, such as "work" and "results".
+	// Mandatory.
+	Name string
+}
+
+// SQLQueue is a WorkQueue stored in a table managed with GORM, so processes
+// sharing the database share the queue. Leases are claimed with a conditional
+// update on the previous token, so two consumers never hold the same delivery.
+// The database handle is owned by the caller; Close does not close it.
+type SQLQueue struct {
+	database  *gorm.DB
+	tableName string
+	name      string
+	now       func() time.Time
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewSQLQueue validates cfg. Call Migrate to create the table.
+func NewSQLQueue(cfg SQLQueueConfig) (*SQLQueue, error) {
+	if cfg.Database == nil {
+		return nil, errors.New("distributed: sql queue database is required")
+	}
+	name := strings.TrimSpace(cfg.Name)
+	if name == "" {
+		return nil, errors.New("distributed: sql queue name is required")
+	}
+	tableName := strings.TrimSpace(cfg.TableName)
+	if tableName == "" {
+		tableName = defaultSQLQueueTableName
+	}
+	return &SQLQueue{database: cfg.Database, tableName: tableName, name: name, now: time.Now}, nil
+}
+
+// Migrate creates or updates the queue table.
+func (queue *SQLQueue) Migrate(ctx context.Context) error {
+	if err := queue.database.WithContext(ctx).Table(queue.tableName).AutoMigrate(&queueRecord{}); err != nil {
+		return fmt.Errorf("distributed: migrate %s: %w", queue.tableName, err)
+	}
+	return nil
+}
+
+// Publish inserts payloads in a single statement.
+func (queue *SQLQueue) Publish(ctx context.Context, payloads ...[]byte) error {
+	if err := queue.ensureOpen(); err != nil {
+		return err
+	}
+	if len(payloads) == 0 {
+		return nil
+	}
+	now := queue.now().UTC()
+	records := make([]queueRecord, 0, len(payloads))
+	for _, payload := range payloads {
+		records = append(records, queueRecord{Queue: queue.name, VisibleAt: now, Payload: payload})
+	}
+	if err := queue.database.WithContext(ctx).Table(queue.tableName).Create(&records).Error; err != nil {
+		return fmt.Errorf("distributed: publish to %s: %w", queue.name, err)
+	}
+	return nil
+}
+
+// Lease claims up to limit visible messages, oldest first. A message claimed
+// by another consumer between the select and the update is skipped.
+func (queue *SQLQueue) Lease(ctx context.Context, limit int, visibility time.Duration) ([]Lease, error) {
+	if err := queue.ensureOpen(); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		return []Lease{}, nil
+	}
+	database := queue.database.WithContext(ctx).Table(queue.tableName)
+	now := queue.now().UTC()
+	var candidates []queueRecord
+	err := database.Where("queue = ? AND visible_at <= ?", queue.name, now).
+		Order("id").Limit(limit).Find(&candidates).Error
+	if err != nil {
+		return nil, fmt.Errorf("distributed: lease from %s: %w", queue.name, err)
+	}
+
+	leases := make([]Lease, 0, len(candidates))
+	for _, candidate := range candidates {
+		token := newLeaseToken()
+		claimed := queue.database.WithContext(ctx).Table(queue.tableName).
+			Where("id = ? AND token = ?", candidate.ID, candidate.Token).
+			Updates(map[string]any{
+				"token":      token,
+				"visible_at": now.Add(visibility),
+				"deliveries": gorm.Expr("deliveries + 1"),
+			})
+		if claimed.Error != nil {
+			return leases, fmt.Errorf("distributed: lease from %s: %w", queue.name, claimed.Error)
+		}
+		if claimed.RowsAffected == 0 {
+			continue
+		}
+		leases = append(leases, Lease{
+			MessageID:  strconv.FormatUint(uint64(candidate.ID), 10),
+			Token:      token,
+			Payload:    candidate.Payload,
+			Deliveries: candidate.Deliveries + 1,
+		})
+	}
+	return leases, nil
+}
+
+// Ack deletes the leased message.
+func (queue *SQLQueue) Ack(ctx context.Context, lease Lease) error {
+	if err := queue.ensureOpen(); err != nil {
+		return err
+	}
+	messageID, err := strconv.ParseUint(lease.MessageID, 10, 64)
+	if err != nil {
+		return ErrLeaseLost
+	}
+	deleted := queue.database.WithContext(ctx).Table(queue.tableName).
+		Where("id = ? AND queue = ? AND token = ?", messageID, queue.name, lease.Token).
+		Delete(&queueRecord{})
+	if deleted.Error != nil {
+		return fmt.Errorf("distributed: ack %s on %s: %w", lease.MessageID, queue.name, deleted.Error)
+	}
+	if deleted.RowsAffected == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// Close rejects further operations.
+func (queue *SQLQueue) Close() error {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	queue.closed = true
+	return nil
+}
+
+func (queue *SQLQueue) ensureOpen() error {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	if queue.closed {
+		return ErrQueueClosed
+	}
+	return nil
+}
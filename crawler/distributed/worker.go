@@ -0,0 +1,140 @@
+package distributed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tyemirov/utils/crawler"
+)
+
+// Worker leases products from the work queue, crawls each batch with a fresh
+// crawler.Service and publishes the results. A product is acknowledged only
+// after its result is published, so a worker that dies mid-batch leaves its
+// products to be re-delivered once their visibility timeout expires.
+type Worker struct {
+	config   crawler.Config
+	work     WorkQueue
+	results  WorkQueue
+	settings settings
+}
+
+// NewWorker validates cfg and constructs a Worker over the work and result
+// queues.
+func NewWorker(cfg crawler.Config, work, results WorkQueue, options ...Option) (*Worker, error) {
+	if work == nil || results == nil {
+		return nil, errors.New("distributed: work and result queues are required")
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	configured := newSettings(options)
+	if configured.batchSize <= 0 {
+		configured.batchSize = cfg.Scraper.Parallelism
+	}
+	if configured.logger == nil {
+		configured.logger = cfg.Logger
+	}
+	configured.logger = crawler.EnsureLogger(configured.logger)
+	return &Worker{config: cfg, work: work, results: results, settings: configured}, nil
+}
+
+// Run processes batches until ctx ends and returns ctx.Err(). Results of a
+// batch interrupted by ctx are neither published nor acknowledged.
+func (worker *Worker) Run(ctx context.Context) error {
+	logger := worker.settings.logger
+	for {
+		leases, err := worker.work.Lease(ctx, worker.settings.batchSize, worker.settings.visibilityTimeout)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logger.Error("Failed to lease products: %v", err)
+		}
+		if len(leases) == 0 {
+			if !sleep(ctx, worker.settings.pollInterval) {
+				return ctx.Err()
+			}
+			continue
+		}
+		if err := worker.processBatch(ctx, leases); err != nil {
+			return err
+		}
+	}
+}
+
+// processBatch crawls the leased products, publishing and acknowledging each
+// result as it arrives. Leases and results are matched by the trimmed product
+// ID, the form the crawler reports.
+func (worker *Worker) processBatch(ctx context.Context, leases []Lease) error {
+	logger := worker.settings.logger
+	products := make([]crawler.Product, 0, len(leases))
+	leasesByProduct := make(map[string][]Lease, len(leases))
+	for _, lease := range leases {
+		var product crawler.Product
+		if err := json.Unmarshal(lease.Payload, &product); err != nil {
+			logger.Error("Dropping undecodable product message %s: %v", lease.MessageID, err)
+			worker.ack(ctx, lease)
+			continue
+		}
+		productID := strings.TrimSpace(product.ID)
+		if _, seen := leasesByProduct[productID]; !seen {
+			products = append(products, product)
+		}
+		leasesByProduct[productID] = append(leasesByProduct[productID], lease)
+	}
+	if len(products) == 0 {
+		return nil
+	}
+
+	results := make(chan *crawler.Result, len(products))
+	service, err := crawler.NewService(worker.config, results, worker.settings.serviceOptions...)
+	if err != nil {
+		return fmt.Errorf("distributed: build crawler service: %w", err)
+	}
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- service.Run(ctx, products)
+		close(results)
+	}()
+
+	for result := range results {
+		if ctx.Err() != nil {
+			continue
+		}
+		productID := strings.TrimSpace(result.OriginalProductID)
+		if productID == "" {
+			productID = strings.TrimSpace(result.ProductID)
+		}
+		productLeases, ok := leasesByProduct[productID]
+		if !ok {
+			logger.Warning("Crawler reported a result for unleased product %s", productID)
+			continue
+		}
+		payload, encodeErr := jsonMarshalFunc(resultMessage{ProductID: productID, Result: result})
+		if encodeErr != nil {
+			logger.Error("Failed to encode result for product %s: %v", productID, encodeErr)
+			continue
+		}
+		if publishErr := worker.results.Publish(ctx, payload); publishErr != nil {
+			logger.Error("Failed to publish result for product %s: %v", productID, publishErr)
+			continue
+		}
+		delete(leasesByProduct, productID)
+		for _, lease := range productLeases {
+			worker.ack(ctx, lease)
+		}
+	}
+	if err := <-runErr; err != nil && ctx.Err() == nil {
+		logger.Error("Crawler run failed: %v", err)
+	}
+	return nil
+}
+
+func (worker *Worker) ack(ctx context.Context, lease Lease) {
+	if err := worker.work.Ack(ctx, lease); err != nil {
+		worker.settings.logger.Warning("Failed to acknowledge product message %s: %v", lease.MessageID, err)
+	}
+}
@@ -0,0 +1,22 @@
+package crawler
+
+import "net/http"
+
+// NewHTTPClient returns an http.Client that leaves through the same transport
+// a Service builds from scraper: dial and idle timeouts, TLS settings, proxy
+// rotation from ProxyList or ProxySource, and response-body panic recovery.
+// Auxiliary fetches such as sitemap ingestion use it so they share the
+// crawler's network identity. The client has no total timeout; bound requests
+// with their context.
+func NewHTTPClient(scraper ScraperConfig, logger Logger) (*http.Client, error) {
+	logger = EnsureLogger(logger)
+	transport := newCrawlerHTTPTransport(scraper.InsecureSkipVerify, scraper.HTTPTimeout)
+	proxyFn, _, err := newScraperProxyFunc(scraper, logger)
+	if err != nil {
+		return nil, err
+	}
+	if proxyFn != nil {
+		transport.Proxy = proxyFn
+	}
+	return &http.Client{Transport: newPanicSafeTransport(transport, logger)}, nil
+}
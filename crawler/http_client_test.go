@@ -0,0 +1,55 @@
+package crawler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHTTPClientRoutesThroughConfiguredProxy(t *testing.T) {
+	t.Parallel()
+
+	proxiedHosts := make(chan string, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		proxiedHosts <- request.Host
+		_, _ = io.WriteString(writer, "via proxy")
+	}))
+	t.Cleanup(proxy.Close)
+
+	client, err := NewHTTPClient(ScraperConfig{ProxyList: []string{proxy.URL}}, nil)
+	require.NoError(t, err)
+
+	response, err := client.Get("http://origin.test/sitemap.xml")
+	require.NoError(t, err)
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	require.NoError(t, response.Body.Close())
+	require.Equal(t, "via proxy", string(body))
+	require.Equal(t, "origin.test", <-proxiedHosts)
+}
+
+func TestNewHTTPClientWithoutProxiesDialsDirectly(t *testing.T) {
+	t.Parallel()
+
+	origin := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(origin.Close)
+
+	client, err := NewHTTPClient(ScraperConfig{}, noopLogger{})
+	require.NoError(t, err)
+	response, err := client.Get(origin.URL)
+	require.NoError(t, err)
+	require.NoError(t, response.Body.Close())
+	require.Equal(t, http.StatusNoContent, response.StatusCode)
+}
+
+func TestNewHTTPClientRejectsInvalidProxies(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewHTTPClient(ScraperConfig{ProxyList: []string{"://bad"}}, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,559 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"  // register GIF decoding for image summaries
+	_ "image/jpeg" // register JPEG decoding for image summaries
+	_ "image/png"  // register PNG decoding for image summaries
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"github.com/gocolly/colly/v2"
+)
+
+const (
+	defaultImageSelector       = "img"
+	defaultMaxImagesPerProduct = 10
+	defaultMaxImageBytes       = 10 << 20
+	defaultMaxImagePixels      = 40_000_000
+	defaultImageFetches        = 4
+	defaultImageWaitTimeout    = 30 * time.Second
+
+	ctxImageBatchKey = "crawler_image_batch"
+	ctxImageIndexKey = "crawler_image_index"
+	ctxImageAbortKey = "crawler_image_abort_reason"
+)
+
+var defaultImageAttributes = []string{"src", "data-src", "srcset"}
+
+// ImageSummary describes one image retrieved for a product. Error is set when
+// the image was skipped or could not be downloaded or decoded; the other
+// fields then hold whatever was learned before the failure.
+type ImageSummary struct {
+	URL            string `json:"url"`
+	ContentType    string `json:"content_type,omitempty"`
+	Bytes          int    `json:"bytes,omitempty"`
+	Width          int    `json:"width,omitempty"`
+	Height         int    `json:"height,omitempty"`
+	PerceptualHash string `json:"perceptual_hash,omitempty"`
+	FileName       string `json:"file_name,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// ImageRetrievalConfig controls which images an ImageRetrievalHandler fetches.
+type ImageRetrievalConfig struct {
+	// Selectors are CSS selectors for image elements. Optional; defaults to "img".
+	Selectors []string
+
+	// Attributes are read in order and the first non-empty one wins. A srcset
+	// contributes its first candidate. Optional; defaults to src, data-src, srcset.
+	Attributes []string
+
+	// AllowedDomains limits the hosts images are fetched from. Optional; any
+	// host is allowed when empty, since images usually live on a CDN.
+	AllowedDomains []string
+
+	// AllowedContentTypes lists accepted media types such as "image/png".
+	// Optional; any "image/" type is accepted when empty.
+	AllowedContentTypes []string
+
+	// MaxImages caps images per product. Optional; defaults to 10.
+	MaxImages int
+
+	// MaxBytes caps the size of one image. Optional; defaults to 10 MiB.
+	MaxBytes int
+
+	// MaxPixels caps the width times height of one image. Larger images are
+	// reported from their header without being decoded. Optional; defaults to
+	// 40 megapixels.
+	MaxPixels int
+
+	// MaxConcurrentFetches caps the images downloaded and decoded at once,
+	// across all products. Optional; defaults to 4.
+	MaxConcurrentFetches int
+
+	// WaitTimeout bounds how long the product's result waits for its images.
+	// The wait runs in the product's response callback, holding its slot and a
+	// colly worker, so it also ends when the product's time budget or the run
+	// does. Optional; defaults to 30s.
+	WaitTimeout time.Duration
+
+	// PersistFiles saves every accepted image through the service's
+	// FilePersister as image-NN.<ext>.
+	PersistFiles bool
+}
+
+// ImageRetrievalHandler is a ResponseHandler that downloads the images of each
+// product page through the crawler's collector, so requests share its proxies,
+// transport and rate limits, and attaches an ImageSummary per image to
+// Result.Images. Dimensions and a 64-bit difference hash are computed for
+// GIF, JPEG and PNG images. Register it with WithResponseHandlers.
+type ImageRetrievalHandler struct {
+	NoopResponseHandler
+
+	config        ImageRetrievalConfig
+	selectors     []cascadia.Selector
+	collector     *colly.Collector
+	filePersister FilePersister
+	logger        Logger
+	// fetchSlots holds one token per image download in flight.
+	fetchSlots chan struct{}
+}
+
+// NewImageRetrievalHandler validates config and constructs the handler.
+func NewImageRetrievalHandler(config ImageRetrievalConfig, logger Logger) (*ImageRetrievalHandler, error) {
+	if config.MaxImages < 0 {
+		return nil, fmt.Errorf("crawler: max images must be non-negative (got %d)", config.MaxImages)
+	}
+	if config.MaxBytes < 0 {
+		return nil, fmt.Errorf("crawler: max image bytes must be non-negative (got %d)", config.MaxBytes)
+	}
+	if config.MaxPixels < 0 {
+		return nil, fmt.Errorf("crawler: max image pixels must be non-negative (got %d)", config.MaxPixels)
+	}
+	if config.MaxConcurrentFetches < 0 {
+		return nil, fmt.Errorf("crawler: max concurrent image fetches must be non-negative (got %d)", config.MaxConcurrentFetches)
+	}
+	if config.WaitTimeout < 0 {
+		return nil, fmt.Errorf("crawler: image wait timeout must be non-negative (got %s)", config.WaitTimeout)
+	}
+	if len(config.Selectors) == 0 {
+		config.Selectors = []string{defaultImageSelector}
+	}
+	if len(config.Attributes) == 0 {
+		config.Attributes = defaultImageAttributes
+	}
+	if config.MaxImages == 0 {
+		config.MaxImages = defaultMaxImagesPerProduct
+	}
+	if config.MaxBytes == 0 {
+		config.MaxBytes = defaultMaxImageBytes
+	}
+	if config.MaxPixels == 0 {
+		config.MaxPixels = defaultMaxImagePixels
+	}
+	if config.MaxConcurrentFetches == 0 {
+		config.MaxConcurrentFetches = defaultImageFetches
+	}
+	if config.WaitTimeout == 0 {
+		config.WaitTimeout = defaultImageWaitTimeout
+	}
+
+	handler := &ImageRetrievalHandler{
+		config:     config,
+		logger:     EnsureLogger(logger),
+		fetchSlots: make(chan struct{}, config.MaxConcurrentFetches),
+	}
+	for _, selector := range config.Selectors {
+		matcher, err := cascadia.Compile(selector)
+		if err != nil {
+			return nil, fmt.Errorf("crawler: invalid image selector %q: %w", selector, err)
+		}
+		handler.selectors = append(handler.selectors, matcher)
+	}
+	return handler, nil
+}
+
+// BindRuntime implements ResponseHandlerRuntimeBinder. Images are fetched by a
+// clone of collector that shares its HTTP backend but has its own callbacks,
+// so image failures never reach the product's retry and result handling. The
+// clone is synchronous; MaxConcurrentFetches workers drive it.
+func (handler *ImageRetrievalHandler) BindRuntime(collector *colly.Collector, filePersister FilePersister, _ RetryHandler) {
+	imageCollector := collector.Clone()
+	imageCollector.Async = false
+	imageCollector.AllowedDomains = handler.config.AllowedDomains
+	imageCollector.MaxDepth = 0
+	imageCollector.MaxBodySize = handler.config.MaxBytes + 1
+	imageCollector.OnRequest(handler.skipAbandoned)
+	imageCollector.OnResponseHeaders(handler.checkHeaders)
+	imageCollector.OnResponse(handler.handleImage)
+	imageCollector.OnError(handler.handleImageError)
+	handler.collector = imageCollector
+	handler.filePersister = filePersister
+}
+
+// BeforeEvaluation queues the product's images for download. Each waits for
+// one of the MaxConcurrentFetches slots in its own goroutine, so the
+// product's callbacks carry on meanwhile. A batch left by an earlier attempt
+// of the product is abandoned first.
+func (handler *ImageRetrievalHandler) BeforeEvaluation(resp *colly.Response, document *goquery.Document) {
+	if handler.collector == nil || resp == nil || resp.Ctx == nil || resp.Request == nil || document == nil {
+		return
+	}
+	if previous, ok := resp.Ctx.GetAny(ctxImageBatchKey).(*imageBatch); ok {
+		previous.abandon()
+	}
+	imageURLs := handler.imageURLs(resp, document)
+	batch := &imageBatch{productID: resp.Ctx.Get(ctxProductIDKey), summaries: make([]ImageSummary, len(imageURLs))}
+	resp.Ctx.Put(ctxImageBatchKey, batch)
+
+	headers := http.Header{}
+	headers.Set("Referer", resp.Request.URL.String())
+	if resp.Request.Headers != nil {
+		if userAgent := resp.Request.Headers.Get("User-Agent"); userAgent != "" {
+			headers.Set("User-Agent", userAgent)
+		}
+	}
+	for index, imageURL := range imageURLs {
+		batch.summaries[index].URL = imageURL
+		batch.pending.Add(1)
+		go handler.fetchImage(batch, index, imageURL, headers.Clone())
+	}
+}
+
+// fetchImage downloads one image once a fetch slot is free. Images of a
+// product whose result was already emitted are skipped.
+func (handler *ImageRetrievalHandler) fetchImage(batch *imageBatch, index int, imageURL string, headers http.Header) {
+	handler.fetchSlots <- struct{}{}
+	defer func() { <-handler.fetchSlots }()
+	if batch.isAbandoned() {
+		return
+	}
+	imageContext := colly.NewContext()
+	imageContext.Put(ctxImageBatchKey, batch)
+	imageContext.Put(ctxImageIndexKey, index)
+	if err := handler.collector.Request(http.MethodGet, imageURL, nil, imageContext, headers); err != nil {
+		// Failed downloads were already reported through handleImageError;
+		// this covers requests colly refused to send.
+		batch.finish(index, func(summary *ImageSummary) { summary.Error = err.Error() })
+	}
+}
+
+// AfterEvaluation waits for the product's images and copies their summaries
+// into the result. The wait ends after WaitTimeout, when the product's time
+// budget runs out or when the run is cancelled, whichever comes first; images
+// still in flight are then reported as timed out and abandoned.
+func (handler *ImageRetrievalHandler) AfterEvaluation(resp *colly.Response, _ *goquery.Document, result *Result) {
+	if resp == nil || resp.Ctx == nil || result == nil {
+		return
+	}
+	batch, ok := resp.Ctx.GetAny(ctxImageBatchKey).(*imageBatch)
+	if !ok || len(batch.summaries) == 0 {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		batch.pending.Wait()
+		close(done)
+	}()
+	wait := handler.config.WaitTimeout
+	if deadline, ok := productDeadline(resp.Ctx); ok {
+		wait = min(wait, time.Until(deadline))
+	}
+	runCtx, ok := resp.Ctx.GetAny(ctxRunContextKey).(context.Context)
+	if !ok {
+		runCtx = context.Background()
+	}
+	timer := time.NewTimer(max(wait, 0))
+	defer timer.Stop()
+	timedOut := false
+	select {
+	case <-done:
+	case <-timer.C:
+		timedOut = true
+		handler.logger.Warning("Timed out waiting for images of product %s", batch.productID)
+	case <-runCtx.Done():
+		timedOut = true
+	}
+	result.Images = batch.snapshot(timedOut)
+	batch.abandon()
+}
+
+// skipAbandoned stops image requests of a batch nobody waits for any more
+// before they are sent.
+func (handler *ImageRetrievalHandler) skipAbandoned(request *colly.Request) {
+	batch, index, ok := imageBatchFromContext(request.Ctx)
+	if !ok || !batch.isAbandoned() {
+		return
+	}
+	request.Abort()
+	batch.finish(index, func(summary *ImageSummary) { summary.Error = "abandoned" })
+}
+
+func (handler *ImageRetrievalHandler) imageURLs(resp *colly.Response, document *goquery.Document) []string {
+	seen := make(map[string]struct{})
+	var imageURLs []string
+	for _, matcher := range handler.selectors {
+		document.FindMatcher(matcher).EachWithBreak(func(_ int, selection *goquery.Selection) bool {
+			for _, attribute := range handler.config.Attributes {
+				value := strings.TrimSpace(selection.AttrOr(attribute, ""))
+				if attribute == "srcset" {
+					value = firstSrcsetCandidate(value)
+				}
+				if value == "" || strings.HasPrefix(value, "data:") {
+					continue
+				}
+				absolute := resp.Request.AbsoluteURL(value)
+				if absolute == "" {
+					continue
+				}
+				if _, duplicate := seen[absolute]; !duplicate {
+					seen[absolute] = struct{}{}
+					imageURLs = append(imageURLs, absolute)
+				}
+				break
+			}
+			return len(imageURLs) < handler.config.MaxImages
+		})
+		if len(imageURLs) >= handler.config.MaxImages {
+			break
+		}
+	}
+	return imageURLs
+}
+
+func firstSrcsetCandidate(srcset string) string {
+	first, _, _ := strings.Cut(srcset, ",")
+	fields := strings.Fields(first)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// checkHeaders aborts downloads of abandoned batches and those whose declared
+// type or length is rejected.
+// Error statuses are left alone so they are reported as such.
+func (handler *ImageRetrievalHandler) checkHeaders(resp *colly.Response) {
+	if batch, _, ok := imageBatchFromContext(resp.Ctx); ok && batch.isAbandoned() {
+		resp.Ctx.Put(ctxImageAbortKey, "abandoned")
+		resp.Request.Abort()
+		return
+	}
+	if resp.Headers == nil || resp.StatusCode >= http.StatusBadRequest {
+		return
+	}
+	contentType := mediaType(resp.Headers.Get("Content-Type"))
+	if contentType != "" && !handler.acceptsContentType(contentType) {
+		resp.Ctx.Put(ctxImageAbortKey, fmt.Sprintf("content type %s not allowed", contentType))
+		resp.Request.Abort()
+		return
+	}
+	if length, err := strconv.Atoi(resp.Headers.Get("Content-Length")); err == nil && length > handler.config.MaxBytes {
+		resp.Ctx.Put(ctxImageAbortKey, fmt.Sprintf("%d bytes exceeds limit of %d", length, handler.config.MaxBytes))
+		resp.Request.Abort()
+	}
+}
+
+func (handler *ImageRetrievalHandler) handleImage(resp *colly.Response) {
+	batch, index, ok := imageBatchFromContext(resp.Ctx)
+	if !ok {
+		return
+	}
+	batch.finish(index, func(summary *ImageSummary) {
+		handler.summarize(batch.productID, index, resp, summary)
+	})
+}
+
+func (handler *ImageRetrievalHandler) summarize(productID string, index int, resp *colly.Response, summary *ImageSummary) {
+	summary.Bytes = len(resp.Body)
+	summary.ContentType = mediaType(resp.Headers.Get("Content-Type"))
+	if summary.ContentType == "" {
+		summary.ContentType = mediaType(http.DetectContentType(resp.Body))
+	}
+	if len(resp.Body) > handler.config.MaxBytes {
+		summary.Error = fmt.Sprintf("body exceeds limit of %d bytes", handler.config.MaxBytes)
+		return
+	}
+	if !handler.acceptsContentType(summary.ContentType) {
+		summary.Error = fmt.Sprintf("content type %s not allowed", summary.ContentType)
+		return
+	}
+
+	if handler.config.PersistFiles && handler.filePersister != nil {
+		fileName := fmt.Sprintf("image-%02d%s", index+1, imageExtension(summary.ContentType))
+		if err := handler.filePersister.Save(productID, fileName, resp.Body); err != nil {
+			handler.logger.Error("Failed to save image %s for ProductID %s: %v", summary.URL, productID, err)
+		} else {
+			summary.FileName = fileName
+		}
+	}
+
+	header, _, err := image.DecodeConfig(bytes.NewReader(resp.Body))
+	if err != nil {
+		summary.Error = fmt.Sprintf("decode: %v", err)
+		return
+	}
+	summary.Width, summary.Height = header.Width, header.Height
+	if int64(header.Width)*int64(header.Height) > int64(handler.config.MaxPixels) {
+		summary.Error = fmt.Sprintf("%dx%d pixels exceeds limit of %d", header.Width, header.Height, handler.config.MaxPixels)
+		return
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(resp.Body))
+	if err != nil {
+		summary.Error = fmt.Sprintf("decode: %v", err)
+		return
+	}
+	summary.PerceptualHash = fmt.Sprintf("%016x", differenceHash(decoded))
+}
+
+func (handler *ImageRetrievalHandler) handleImageError(resp *colly.Response, err error) {
+	if resp == nil {
+		return
+	}
+	batch, index, ok := imageBatchFromContext(resp.Ctx)
+	if !ok {
+		return
+	}
+	reason := err.Error()
+	if aborted := resp.Ctx.Get(ctxImageAbortKey); aborted != "" && errors.Is(err, colly.ErrAbortedAfterHeaders) {
+		reason = aborted
+	}
+	batch.finish(index, func(summary *ImageSummary) {
+		summary.Error = reason
+		if resp.Headers != nil {
+			summary.ContentType = mediaType(resp.Headers.Get("Content-Type"))
+		}
+	})
+}
+
+func (handler *ImageRetrievalHandler) acceptsContentType(contentType string) bool {
+	if len(handler.config.AllowedContentTypes) == 0 {
+		return strings.HasPrefix(contentType, "image/")
+	}
+	for _, allowed := range handler.config.AllowedContentTypes {
+		if strings.EqualFold(strings.TrimSpace(allowed), contentType) {
+			return true
+		}
+	}
+	return false
+}
+
+func imageBatchFromContext(ctx *colly.Context) (*imageBatch, int, bool) {
+	if ctx == nil {
+		return nil, 0, false
+	}
+	batch, ok := ctx.GetAny(ctxImageBatchKey).(*imageBatch)
+	if !ok {
+		return nil, 0, false
+	}
+	index, ok := ctx.GetAny(ctxImageIndexKey).(int)
+	if !ok || index < 0 || index >= len(batch.summaries) {
+		return nil, 0, false
+	}
+	return batch, index, true
+}
+
+// imageBatch tracks the image downloads started for one product response.
+type imageBatch struct {
+	productID string
+	pending   sync.WaitGroup
+
+	mu        sync.Mutex
+	summaries []ImageSummary
+	finished  []bool
+	abandoned bool
+}
+
+func (batch *imageBatch) finish(index int, update func(summary *ImageSummary)) {
+	batch.mu.Lock()
+	defer batch.mu.Unlock()
+	if batch.finished == nil {
+		batch.finished = make([]bool, len(batch.summaries))
+	}
+	if batch.finished[index] {
+		return
+	}
+	update(&batch.summaries[index])
+	batch.finished[index] = true
+	batch.pending.Done()
+}
+
+// abandon marks the batch as no longer awaited, once its summaries were
+// copied into the product's result or the product was retried.
+func (batch *imageBatch) abandon() {
+	batch.mu.Lock()
+	defer batch.mu.Unlock()
+	batch.abandoned = true
+}
+
+func (batch *imageBatch) isAbandoned() bool {
+	batch.mu.Lock()
+	defer batch.mu.Unlock()
+	return batch.abandoned
+}
+
+func (batch *imageBatch) snapshot(timedOut bool) []ImageSummary {
+	batch.mu.Lock()
+	defer batch.mu.Unlock()
+	summaries := append([]ImageSummary(nil), batch.summaries...)
+	if timedOut {
+		for index := range summaries {
+			if batch.finished == nil || !batch.finished[index] {
+				summaries[index].Error = "timed out"
+			}
+		}
+	}
+	return summaries
+}
+
+func mediaType(contentType string) string {
+	parsed, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return parsed
+}
+
+func imageExtension(contentType string) string {
+	switch contentType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/svg+xml":
+		return ".svg"
+	}
+	if subtype, found := strings.CutPrefix(contentType, "image/"); found && subtype != "" && !strings.ContainsAny(subtype, "+./") {
+		return "." + subtype
+	}
+	return ".img"
+}
+
+// differenceHash computes a 64-bit dHash: the image is reduced to a 9x8
+// grayscale grid by averaging, and each bit records whether a cell is
+// brighter than its right neighbour. Similar images differ in few bits.
+func differenceHash(source image.Image) uint64 {
+	const columns, rows = 9, 8
+	bounds := source.Bounds()
+	var grid [rows][columns]float64
+	for row := 0; row < rows; row++ {
+		top := bounds.Min.Y + row*bounds.Dy()/rows
+		bottom := max(bounds.Min.Y+(row+1)*bounds.Dy()/rows, top+1)
+		for column := 0; column < columns; column++ {
+			left := bounds.Min.X + column*bounds.Dx()/columns
+			right := max(bounds.Min.X+(column+1)*bounds.Dx()/columns, left+1)
+			var total float64
+			var count int
+			for y := top; y < bottom && y < bounds.Max.Y; y++ {
+				for x := left; x < right && x < bounds.Max.X; x++ {
+					red, green, blue, _ := source.At(x, y).RGBA()
+					total += 0.299*float64(red) + 0.587*float64(green) + 0.114*float64(blue)
+					count++
+				}
+			}
+			if count > 0 {
+				grid[row][column] = total / float64(count)
+			}
+		}
+	}
+
+	var hash uint64
+	for row := 0; row < rows; row++ {
+		for column := 0; column < columns-1; column++ {
+			hash <<= 1
+			if grid[row][column] > grid[row][column+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
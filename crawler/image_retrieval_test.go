@@ -0,0 +1,375 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"math/bits"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func gradientPNG(t *testing.T, width, height int, invert bool) []byte {
+	t.Helper()
+	canvas := image.NewGray(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		shade := uint8(x * 255 / width)
+		if invert {
+			shade = 255 - shade
+		}
+		for y := 0; y < height; y++ {
+			canvas.SetGray(x, y, color.Gray{Y: shade})
+		}
+	}
+	var encoded bytes.Buffer
+	require.NoError(t, png.Encode(&encoded, canvas))
+	return encoded.Bytes()
+}
+
+func TestImageRetrievalHandlerSummarizesProductImages(t *testing.T) {
+	t.Parallel()
+
+	heroImage := gradientPNG(t, 36, 16, false)
+	largeImage := gradientPNG(t, 400, 400, true)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch request.URL.Path {
+		case "/p/1":
+			writer.Header().Set("Content-Type", "text/html")
+			_, _ = writer.Write([]byte(`<html><head><title>Product</title></head><body>
+				<div id="gallery">
+					<img src="/img/hero.png">
+					<img src="data:image/png;base64,AAAA" data-src="/img/hero.png">
+					<img srcset="/img/large.png 2x, /img/small.png 1x">
+					<img data-src="/img/notes">
+					<img src="/img/missing.png">
+					<img src="/img/broken.png">
+				</div>
+				<img src="/img/outside.png">
+			</body></html>`))
+		case "/img/hero.png":
+			writer.Header().Set("Content-Type", "image/png")
+			_, _ = writer.Write(heroImage)
+		case "/img/large.png":
+			writer.Header().Set("Content-Type", "image/png")
+			writer.Header().Set("Content-Length", strconv.Itoa(len(largeImage)))
+			_, _ = writer.Write(largeImage)
+		case "/img/notes":
+			writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_, _ = writer.Write([]byte("not an image"))
+		case "/img/broken.png":
+			writer.Header().Set("Content-Type", "image/png")
+			_, _ = writer.Write([]byte("truncated"))
+		default:
+			http.NotFound(writer, request)
+		}
+	}))
+	defer server.Close()
+	parsed, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	handler, err := NewImageRetrievalHandler(ImageRetrievalConfig{
+		Selectors:    []string{"#gallery img"},
+		MaxBytes:     len(heroImage) + 16,
+		PersistFiles: true,
+	}, noopLogger{})
+	require.NoError(t, err)
+
+	outputDirectory := t.TempDir()
+	results := make(chan *Result, 1)
+	service, err := NewService(Config{
+		PlatformID:      "TEST",
+		OutputDirectory: outputDirectory,
+		RunFolder:       "run",
+		Scraper:         ScraperConfig{MaxDepth: 1, Parallelism: 1},
+		Platform:        PlatformConfig{AllowedDomains: []string{parsed.Hostname()}},
+		RuleEvaluator:   fixedRuleEvaluator{},
+		Logger:          noopLogger{},
+	}, results, WithResponseHandlers(handler))
+	require.NoError(t, err)
+
+	require.NoError(t, service.Run(context.Background(), []Product{{ID: "1", Platform: "TEST", URL: server.URL + "/p/1"}}))
+	result := <-results
+	require.True(t, result.Success)
+	require.Len(t, result.Images, 5)
+
+	hero := result.Images[0]
+	require.Equal(t, server.URL+"/img/hero.png", hero.URL)
+	require.Equal(t, "image/png", hero.ContentType)
+	require.Equal(t, len(heroImage), hero.Bytes)
+	require.Equal(t, 36, hero.Width)
+	require.Equal(t, 16, hero.Height)
+	require.Len(t, hero.PerceptualHash, 16)
+	require.Equal(t, "image-01.png", hero.FileName)
+	require.Empty(t, hero.Error)
+	saved, err := os.ReadFile(filepath.Join(outputDirectory, "TEST", "1", "run", "image-01.png"))
+	require.NoError(t, err)
+	require.Equal(t, heroImage, saved)
+
+	require.Equal(t, server.URL+"/img/large.png", result.Images[1].URL)
+	require.Contains(t, result.Images[1].Error, "exceeds limit")
+	require.Equal(t, server.URL+"/img/notes", result.Images[2].URL)
+	require.Equal(t, "content type text/plain not allowed", result.Images[2].Error)
+	require.Contains(t, result.Images[3].Error, "Not Found")
+	require.Contains(t, result.Images[4].Error, "decode")
+	require.Equal(t, "image-05.png", result.Images[4].FileName)
+}
+
+func TestNewImageRetrievalHandlerValidatesConfig(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewImageRetrievalHandler(ImageRetrievalConfig{Selectors: []string{"img["}}, nil)
+	require.ErrorContains(t, err, "invalid image selector")
+	_, err = NewImageRetrievalHandler(ImageRetrievalConfig{MaxImages: -1}, nil)
+	require.ErrorContains(t, err, "max images must be non-negative")
+	_, err = NewImageRetrievalHandler(ImageRetrievalConfig{MaxBytes: -1}, nil)
+	require.ErrorContains(t, err, "max image bytes must be non-negative")
+	_, err = NewImageRetrievalHandler(ImageRetrievalConfig{MaxPixels: -1}, nil)
+	require.ErrorContains(t, err, "max image pixels must be non-negative")
+	_, err = NewImageRetrievalHandler(ImageRetrievalConfig{MaxConcurrentFetches: -1}, nil)
+	require.ErrorContains(t, err, "max concurrent image fetches must be non-negative")
+	_, err = NewImageRetrievalHandler(ImageRetrievalConfig{WaitTimeout: -time.Second}, nil)
+	require.ErrorContains(t, err, "image wait timeout must be non-negative")
+
+	handler, err := NewImageRetrievalHandler(ImageRetrievalConfig{AllowedContentTypes: []string{" image/PNG "}}, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"img"}, handler.config.Selectors)
+	require.Equal(t, defaultMaxImagesPerProduct, handler.config.MaxImages)
+	require.Equal(t, defaultMaxImagePixels, handler.config.MaxPixels)
+	require.Equal(t, defaultImageFetches, cap(handler.fetchSlots))
+	require.True(t, handler.acceptsContentType("image/png"))
+	require.False(t, handler.acceptsContentType("image/gif"))
+}
+
+func TestImageRetrievalHandlerReportsTimedOutImages(t *testing.T) {
+	t.Parallel()
+
+	handler, err := NewImageRetrievalHandler(ImageRetrievalConfig{WaitTimeout: 10 * time.Millisecond}, nil)
+	require.NoError(t, err)
+
+	batch := &imageBatch{productID: "1", summaries: []ImageSummary{{URL: "a"}, {URL: "b"}}}
+	batch.pending.Add(2)
+	batch.finish(0, func(summary *ImageSummary) { summary.Width = 4 })
+	batch.finish(0, func(summary *ImageSummary) { summary.Width = 8 })
+	ctx := colly.NewContext()
+	ctx.Put(ctxImageBatchKey, batch)
+
+	result := &Result{}
+	handler.AfterEvaluation(&colly.Response{Ctx: ctx}, nil, result)
+	require.Equal(t, []ImageSummary{{URL: "a", Width: 4}, {URL: "b", Error: "timed out"}}, result.Images)
+
+	empty := &Result{}
+	handler.AfterEvaluation(&colly.Response{Ctx: colly.NewContext()}, nil, empty)
+	require.Nil(t, empty.Images)
+	handler.BeforeEvaluation(&colly.Response{Ctx: ctx}, nil)
+	handler.handleImageError(nil, nil)
+	_, _, ok := imageBatchFromContext(nil)
+	require.False(t, ok)
+}
+
+func TestImageRetrievalHandlerWaitEndsWithTheProductBudgetAndRun(t *testing.T) {
+	t.Parallel()
+
+	handler, err := NewImageRetrievalHandler(ImageRetrievalConfig{WaitTimeout: time.Hour}, nil)
+	require.NoError(t, err)
+	pendingContext := func() (*colly.Context, *imageBatch) {
+		batch := &imageBatch{productID: "1", summaries: []ImageSummary{{URL: "a"}}}
+		batch.pending.Add(1)
+		ctx := colly.NewContext()
+		ctx.Put(ctxImageBatchKey, batch)
+		return ctx, batch
+	}
+
+	budgeted, batch := pendingContext()
+	startProductBudget(budgeted, 20*time.Millisecond, time.Now())
+	result := &Result{}
+	started := time.Now()
+	handler.AfterEvaluation(&colly.Response{Ctx: budgeted}, nil, result)
+	require.Less(t, time.Since(started), time.Second)
+	require.Equal(t, []ImageSummary{{URL: "a", Error: "timed out"}}, result.Images)
+	require.True(t, batch.isAbandoned())
+
+	cancelledRun, batch := pendingContext()
+	runCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cancelledRun.Put(ctxRunContextKey, runCtx)
+	result = &Result{}
+	handler.AfterEvaluation(&colly.Response{Ctx: cancelledRun}, nil, result)
+	require.Equal(t, []ImageSummary{{URL: "a", Error: "timed out"}}, result.Images)
+	require.True(t, batch.isAbandoned())
+}
+
+func TestImageRetrievalHandlerAbandonsTheBatchOfAnEarlierAttempt(t *testing.T) {
+	t.Parallel()
+
+	var imageHits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		imageHits.Add(1)
+		writer.Header().Set("Content-Type", "image/png")
+	}))
+	defer server.Close()
+
+	handler, err := NewImageRetrievalHandler(ImageRetrievalConfig{}, nil)
+	require.NoError(t, err)
+	handler.BindRuntime(colly.NewCollector(), nil, nil)
+
+	earlier := &imageBatch{productID: "1", summaries: []ImageSummary{{URL: server.URL + "/a.png"}}}
+	earlier.pending.Add(1)
+	ctx := colly.NewContext()
+	ctx.Put(ctxImageBatchKey, earlier)
+	pageURL, err := url.Parse(server.URL + "/p/1")
+	require.NoError(t, err)
+	document, err := goquery.NewDocumentFromReader(strings.NewReader(`<p>no images</p>`))
+	require.NoError(t, err)
+	handler.BeforeEvaluation(&colly.Response{Ctx: ctx, Request: &colly.Request{URL: pageURL}}, document)
+	require.True(t, earlier.isAbandoned())
+	require.NotSame(t, earlier, ctx.GetAny(ctxImageBatchKey))
+
+	// A download of the abandoned batch that was already queued is never sent.
+	imageContext := colly.NewContext()
+	imageContext.Put(ctxImageBatchKey, earlier)
+	imageContext.Put(ctxImageIndexKey, 0)
+	require.NoError(t, handler.collector.Request(http.MethodGet, server.URL+"/a.png", nil, imageContext, nil))
+	require.Equal(t, "abandoned", earlier.summaries[0].Error)
+	require.Zero(t, imageHits.Load())
+
+	// One already sent is cut off at its headers.
+	sent := &imageBatch{productID: "1", summaries: []ImageSummary{{URL: server.URL + "/b.png"}}}
+	sent.pending.Add(1)
+	imageContext = colly.NewContext()
+	imageContext.Put(ctxImageBatchKey, sent)
+	imageContext.Put(ctxImageIndexKey, 0)
+	handler.collector.OnRequest(func(*colly.Request) { sent.abandon() })
+	require.Error(t, handler.collector.Request(http.MethodGet, server.URL+"/b.png", nil, imageContext, nil))
+	require.Equal(t, "abandoned", sent.summaries[0].Error)
+	require.Equal(t, int32(1), imageHits.Load())
+}
+
+func TestImageRetrievalHandlerSummarizeRejectsImages(t *testing.T) {
+	t.Parallel()
+
+	persister := &mockFilePersister{saveErr: errors.New("disk full")}
+	handler, err := NewImageRetrievalHandler(ImageRetrievalConfig{MaxBytes: 4096, MaxPixels: 400, PersistFiles: true}, nil)
+	require.NoError(t, err)
+	handler.filePersister = persister
+	summarize := func(contentType string, body []byte) ImageSummary {
+		headers := http.Header{}
+		if contentType != "" {
+			headers.Set("Content-Type", contentType)
+		}
+		summary := ImageSummary{URL: "https://cdn.test/a.png"}
+		handler.summarize("1", 0, &colly.Response{Body: body, Headers: &headers}, &summary)
+		return summary
+	}
+
+	small := gradientPNG(t, 20, 10, false)
+	accepted := summarize("", small)
+	require.Empty(t, accepted.Error)
+	require.Equal(t, "image/png", accepted.ContentType)
+	require.Len(t, accepted.PerceptualHash, 16)
+	require.Empty(t, accepted.FileName, "a failed save leaves no file name")
+
+	wide := summarize("image/png", gradientPNG(t, 40, 20, false))
+	require.Equal(t, "40x20 pixels exceeds limit of 400", wide.Error)
+	require.Equal(t, 40, wide.Width)
+	require.Equal(t, 20, wide.Height)
+	require.Empty(t, wide.PerceptualHash)
+
+	truncated := summarize("image/png", small[:64])
+	require.Contains(t, truncated.Error, "decode:")
+	require.Equal(t, 20, truncated.Width, "the header was read before the pixels failed to decode")
+
+	require.Contains(t, summarize("image/png", []byte("not a png")).Error, "decode:")
+	require.Equal(t, "body exceeds limit of 4096 bytes", summarize("image/png", make([]byte, 4097)).Error)
+	require.Equal(t, "content type text/plain not allowed", summarize("", []byte("plain text")).Error)
+}
+
+func TestImageRetrievalHandlerFetchesWithinItsOwnSlots(t *testing.T) {
+	t.Parallel()
+
+	handler, err := NewImageRetrievalHandler(ImageRetrievalConfig{AllowedDomains: []string{"cdn.test"}, MaxConcurrentFetches: 1}, nil)
+	require.NoError(t, err)
+	handler.BindRuntime(colly.NewCollector(colly.Async()), nil, nil)
+	require.False(t, handler.collector.Async)
+
+	batch := &imageBatch{productID: "1", summaries: []ImageSummary{{URL: "http://shop.test/a.png"}, {URL: "http://cdn.test/b.png"}}}
+	batch.pending.Add(2)
+	handler.fetchImage(batch, 0, "http://shop.test/a.png", http.Header{})
+	require.Contains(t, batch.summaries[0].Error, "Forbidden domain")
+
+	// Once the product's result took its images, queued fetches are skipped.
+	batch.abandon()
+	handler.fetchImage(batch, 1, "http://cdn.test/b.png", http.Header{})
+	require.Empty(t, batch.summaries[1].Error)
+	require.Empty(t, handler.fetchSlots)
+}
+
+func TestImageRetrievalHandlerIgnoresForeignResponses(t *testing.T) {
+	t.Parallel()
+
+	handler, err := NewImageRetrievalHandler(ImageRetrievalConfig{Selectors: []string{"img", "p img"}, MaxImages: 1}, nil)
+	require.NoError(t, err)
+
+	document, err := goquery.NewDocumentFromReader(strings.NewReader(`<img src="#top"><img src="/a.png"><img src="/b.png"><p><img src="/c.png"></p>`))
+	require.NoError(t, err)
+	pageURL, err := url.Parse("https://shop.test/p/1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"https://shop.test/a.png"}, handler.imageURLs(&colly.Response{Request: &colly.Request{URL: pageURL}}, document))
+
+	handler.AfterEvaluation(nil, nil, nil)
+	handler.handleImage(&colly.Response{Ctx: colly.NewContext()})
+	handler.handleImageError(&colly.Response{Ctx: colly.NewContext()}, errors.New("refused"))
+
+	batch := &imageBatch{summaries: []ImageSummary{{URL: "a"}}}
+	ctx := colly.NewContext()
+	ctx.Put(ctxImageBatchKey, batch)
+	_, _, ok := imageBatchFromContext(ctx)
+	require.False(t, ok, "missing index")
+	ctx.Put(ctxImageIndexKey, 1)
+	_, _, ok = imageBatchFromContext(ctx)
+	require.False(t, ok, "index out of range")
+}
+
+func TestDifferenceHashSeparatesDistinctImages(t *testing.T) {
+	t.Parallel()
+
+	decode := func(encoded []byte) image.Image {
+		decoded, err := png.Decode(bytes.NewReader(encoded))
+		require.NoError(t, err)
+		return decoded
+	}
+	small := differenceHash(decode(gradientPNG(t, 36, 16, false)))
+	scaled := differenceHash(decode(gradientPNG(t, 360, 160, false)))
+	inverted := differenceHash(decode(gradientPNG(t, 36, 16, true)))
+
+	require.LessOrEqual(t, bits.OnesCount64(small^scaled), 4)
+	require.GreaterOrEqual(t, bits.OnesCount64(small^inverted), 48)
+	require.Zero(t, differenceHash(image.NewGray(image.Rect(0, 0, 0, 0))))
+}
+
+func TestImageHelpers(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "/a.png", firstSrcsetCandidate(" /a.png 1x, /b.png 2x"))
+	require.Empty(t, firstSrcsetCandidate(" "))
+	require.Equal(t, ".jpg", imageExtension("image/jpeg"))
+	require.Equal(t, ".svg", imageExtension("image/svg+xml"))
+	require.Equal(t, ".webp", imageExtension("image/webp"))
+	require.Equal(t, ".img", imageExtension("image/vnd.microsoft.icon"))
+	require.Equal(t, ".img", imageExtension("application/octet-stream"))
+	require.Empty(t, mediaType(";"))
+}
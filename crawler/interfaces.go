@@ -120,6 +120,9 @@ func (NoopResponseHandler) AfterEvaluation(*colly.Response, *goquery.Document, *
 type ServiceHook interface {
 	// AfterInit is called after the collector, transport, and response processor
 	// are fully wired. Use for binding domain-specific network configuration.
+	// A replacement transport should wrap the one passed in: the crawler's
+	// per-request state travels through it, and its innermost layer keeps the
+	// internal X-Crawler-* headers from reaching the origin.
 	AfterInit(collector *colly.Collector, transport http.RoundTripper)
 
 	// BeforeRun is called before the product visit loop starts.
@@ -0,0 +1,271 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Orchestrator routes products to per-platform crawler services by
+// Product.Platform, shares one concurrency budget across them and merges their
+// results into a single channel.
+type Orchestrator struct {
+	results        chan<- *Result
+	maxConcurrency int
+	logger         Logger
+	now            func() time.Time
+
+	mu        sync.Mutex
+	platforms map[string]orchestratedPlatform
+}
+
+type orchestratedPlatform struct {
+	config  Config
+	options []ServiceOption
+}
+
+// OrchestratorOption configures an Orchestrator during construction.
+type OrchestratorOption func(*Orchestrator)
+
+// WithMaxConcurrency caps the number of products in flight across all
+// platforms. Zero leaves each platform limited only by its own Parallelism.
+func WithMaxConcurrency(maxConcurrency int) OrchestratorOption {
+	return func(orchestrator *Orchestrator) {
+		orchestrator.maxConcurrency = maxConcurrency
+	}
+}
+
+// WithOrchestratorLogger sets the logger used for routing diagnostics.
+func WithOrchestratorLogger(logger Logger) OrchestratorOption {
+	return func(orchestrator *Orchestrator) {
+		orchestrator.logger = EnsureLogger(logger)
+	}
+}
+
+// NewOrchestrator constructs an Orchestrator that emits every result on results.
+func NewOrchestrator(results chan<- *Result, options ...OrchestratorOption) (*Orchestrator, error) {
+	if results == nil {
+		return nil, fmt.Errorf("crawler: results channel is required")
+	}
+	orchestrator := &Orchestrator{
+		results:   results,
+		logger:    noopLogger{},
+		now:       time.Now,
+		platforms: make(map[string]orchestratedPlatform),
+	}
+	for _, option := range options {
+		option(orchestrator)
+	}
+	if orchestrator.maxConcurrency < 0 {
+		return nil, fmt.Errorf("crawler: max concurrency must be non-negative (got %d)", orchestrator.maxConcurrency)
+	}
+	return orchestrator, nil
+}
+
+// Register binds cfg to products whose Platform matches cfg.PlatformID. The
+// match ignores case and surrounding whitespace. A fresh Service is built from
+// cfg and options for every Run.
+func (orchestrator *Orchestrator) Register(cfg Config, options ...ServiceOption) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	platformKey := normalizePlatformKey(cfg.PlatformID)
+	orchestrator.mu.Lock()
+	defer orchestrator.mu.Unlock()
+	if _, exists := orchestrator.platforms[platformKey]; exists {
+		return fmt.Errorf("crawler: platform %q is already registered", cfg.PlatformID)
+	}
+	orchestrator.platforms[platformKey] = orchestratedPlatform{
+		config:  cfg,
+		options: append([]ServiceOption(nil), options...),
+	}
+	return nil
+}
+
+// PlatformReport summarises one platform's share of an orchestrated run.
+type PlatformReport struct {
+	PlatformID string
+	Products   int
+	Results    int
+	Succeeded  int
+	// Failed counts every unsuccessful result, including NotFound ones.
+	Failed   int
+	NotFound int
+	Duration time.Duration
+	Err      error
+}
+
+// RunReport summarises an orchestrated run across all platforms.
+type RunReport struct {
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Platforms  map[string]PlatformReport
+	// Unrouted counts products whose platform had no registered config. Each
+	// one is emitted as a failed result.
+	Unrouted int
+}
+
+// Totals sums the per-platform counters.
+func (report RunReport) Totals() PlatformReport {
+	totals := PlatformReport{Duration: report.FinishedAt.Sub(report.StartedAt)}
+	var platformErrors []error
+	for _, platformID := range report.PlatformIDs() {
+		platformReport := report.Platforms[platformID]
+		totals.Products += platformReport.Products
+		totals.Results += platformReport.Results
+		totals.Succeeded += platformReport.Succeeded
+		totals.Failed += platformReport.Failed
+		totals.NotFound += platformReport.NotFound
+		if platformReport.Err != nil {
+			platformErrors = append(platformErrors, platformReport.Err)
+		}
+	}
+	totals.Err = errors.Join(platformErrors...)
+	return totals
+}
+
+// PlatformIDs returns the reported platform identifiers in sorted order.
+func (report RunReport) PlatformIDs() []string {
+	platformIDs := make([]string, 0, len(report.Platforms))
+	for platformID := range report.Platforms {
+		platformIDs = append(platformIDs, platformID)
+	}
+	sort.Strings(platformIDs)
+	return platformIDs
+}
+
+// Run routes products to their platform services, runs them concurrently and
+// blocks until all finish or ctx is cancelled. It returns ctx.Err() on
+// cancellation, otherwise the joined errors of any platform that failed.
+func (orchestrator *Orchestrator) Run(ctx context.Context, products []Product) (RunReport, error) {
+	if len(products) == 0 {
+		return RunReport{}, fmt.Errorf("crawler: no products provided")
+	}
+
+	orchestrator.mu.Lock()
+	platforms := make(map[string]orchestratedPlatform, len(orchestrator.platforms))
+	for platformKey, platform := range orchestrator.platforms {
+		platforms[platformKey] = platform
+	}
+	orchestrator.mu.Unlock()
+
+	report := RunReport{
+		StartedAt: orchestrator.now(),
+		Platforms: make(map[string]PlatformReport),
+	}
+
+	routed := make(map[string][]Product)
+	var routeOrder []string
+	var unrouted []Product
+	for _, product := range products {
+		platformKey := normalizePlatformKey(product.Platform)
+		if _, ok := platforms[platformKey]; !ok {
+			unrouted = append(unrouted, product)
+			continue
+		}
+		if _, seen := routed[platformKey]; !seen {
+			routeOrder = append(routeOrder, platformKey)
+		}
+		routed[platformKey] = append(routed[platformKey], product)
+	}
+
+	for _, product := range unrouted {
+		orchestrator.logger.Warning("No crawler registered for platform %q; skipping product %s", product.Platform, product.ID)
+		orchestrator.results <- &Result{
+			ProductID:         product.ID,
+			OriginalProductID: product.ID,
+			OriginalURL:       product.OriginalURL,
+			ProductURL:        product.URL,
+			ProductPlatform:   product.Platform,
+			ErrorMessage:      fmt.Sprintf("crawler: no config registered for platform %q", product.Platform),
+		}
+	}
+	report.Unrouted = len(unrouted)
+
+	var sharedSlots chan struct{}
+	if orchestrator.maxConcurrency > 0 {
+		sharedSlots = make(chan struct{}, orchestrator.maxConcurrency)
+	}
+
+	var reportMu sync.Mutex
+	var waitGroup sync.WaitGroup
+	for _, platformKey := range routeOrder {
+		platform := platforms[platformKey]
+		platformProducts := routed[platformKey]
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			platformReport := orchestrator.runPlatform(ctx, platform, platformProducts, sharedSlots)
+			reportMu.Lock()
+			report.Platforms[platform.config.PlatformID] = platformReport
+			reportMu.Unlock()
+		}()
+	}
+	waitGroup.Wait()
+	report.FinishedAt = orchestrator.now()
+
+	if ctx.Err() != nil {
+		return report, ctx.Err()
+	}
+	return report, report.Totals().Err
+}
+
+func (orchestrator *Orchestrator) runPlatform(ctx context.Context, platform orchestratedPlatform, products []Product, sharedSlots chan struct{}) PlatformReport {
+	platformReport := PlatformReport{
+		PlatformID: platform.config.PlatformID,
+		Products:   len(products),
+	}
+	startedAt := orchestrator.now()
+
+	platformResults := make(chan *Result)
+	options := append(append([]ServiceOption(nil), platform.options...), withSharedProductSlots(sharedSlots))
+	service, err := NewService(platform.config, platformResults, options...)
+	if err != nil {
+		platformReport.Err = fmt.Errorf("crawler: platform %s: %w", platform.config.PlatformID, err)
+		return platformReport
+	}
+
+	forwardDone := make(chan struct{})
+	go func() {
+		defer close(forwardDone)
+		for result := range platformResults {
+			platformReport.Results++
+			switch {
+			case result.Success:
+				platformReport.Succeeded++
+			case result.IsNotFound():
+				platformReport.Failed++
+				platformReport.NotFound++
+			default:
+				platformReport.Failed++
+			}
+			orchestrator.results <- result
+		}
+	}()
+
+	runErr := service.Run(ctx, products)
+	close(platformResults)
+	<-forwardDone
+
+	platformReport.Duration = orchestrator.now().Sub(startedAt)
+	if runErr != nil && !errors.Is(runErr, ctx.Err()) {
+		platformReport.Err = fmt.Errorf("crawler: platform %s: %w", platform.config.PlatformID, runErr)
+	}
+	return platformReport
+}
+
+// withSharedProductSlots makes the service reserve a slot from a budget shared
+// with other services in addition to its own.
+func withSharedProductSlots(slots chan struct{}) ServiceOption {
+	return func(service *Service) {
+		service.sharedSlots = slots
+	}
+}
+
+func normalizePlatformKey(platformID string) string {
+	return strings.ToLower(strings.TrimSpace(platformID))
+}
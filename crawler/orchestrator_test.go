@@ -0,0 +1,249 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newOrchestratorTestConfig(platformID string, allowedHost string, parallelism int) Config {
+	return Config{
+		PlatformID: platformID,
+		Scraper: ScraperConfig{
+			MaxDepth:    1,
+			Parallelism: parallelism,
+		},
+		Platform: PlatformConfig{
+			AllowedDomains: []string{allowedHost},
+		},
+		RuleEvaluator: fixedRuleEvaluator{},
+		Logger:        noopLogger{},
+	}
+}
+
+func TestOrchestratorRoutesProductsByPlatform(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == "/missing" {
+			http.NotFound(writer, request)
+			return
+		}
+		writer.Header().Set("Content-Type", "text/html")
+		_, _ = writer.Write([]byte(`<html><head><title>Product</title></head><body></body></html>`))
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	results := make(chan *Result, 8)
+	orchestrator, err := NewOrchestrator(results)
+	require.NoError(t, err)
+	require.NoError(t, orchestrator.Register(newOrchestratorTestConfig("ALPHA", serverURL.Hostname(), 1)))
+	require.NoError(t, orchestrator.Register(newOrchestratorTestConfig("BETA", serverURL.Hostname(), 1)))
+
+	report, runErr := orchestrator.Run(context.Background(), []Product{
+		{ID: "A-1", Platform: "ALPHA", URL: server.URL + "/a-1"},
+		{ID: "B-1", Platform: " beta ", URL: server.URL + "/b-1"},
+		{ID: "B-2", Platform: "BETA", URL: server.URL + "/missing"},
+		{ID: "G-1", OriginalID: "legacy-g-1", Platform: "GAMMA", URL: server.URL + "/g-1"},
+	})
+	require.NoError(t, runErr)
+	close(results)
+
+	byProduct := make(map[string]*Result)
+	for result := range results {
+		byProduct[result.ProductID] = result
+	}
+	require.Len(t, byProduct, 4)
+	require.True(t, byProduct["A-1"].Success)
+	require.True(t, byProduct["B-1"].Success)
+	require.True(t, byProduct["B-2"].IsNotFound())
+	require.False(t, byProduct["G-1"].Success)
+	require.Contains(t, byProduct["G-1"].ErrorMessage, "GAMMA")
+	for productID, result := range byProduct {
+		require.Equal(t, productID, result.OriginalProductID)
+	}
+
+	require.Equal(t, 1, report.Unrouted)
+	require.Equal(t, []string{"ALPHA", "BETA"}, report.PlatformIDs())
+	require.Equal(t, PlatformReport{PlatformID: "ALPHA", Products: 1, Results: 1, Succeeded: 1}, withoutDuration(report.Platforms["ALPHA"]))
+	require.Equal(t, PlatformReport{PlatformID: "BETA", Products: 2, Results: 2, Succeeded: 1, Failed: 1, NotFound: 1}, withoutDuration(report.Platforms["BETA"]))
+
+	totals := report.Totals()
+	require.Equal(t, 3, totals.Products)
+	require.Equal(t, 2, totals.Succeeded)
+	require.NoError(t, totals.Err)
+}
+
+func withoutDuration(report PlatformReport) PlatformReport {
+	report.Duration = 0
+	return report
+}
+
+func TestOrchestratorSharesConcurrencyBudgetAcrossPlatforms(t *testing.T) {
+	t.Parallel()
+
+	var inFlightMu sync.Mutex
+	inFlight := 0
+	peakInFlight := 0
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		inFlightMu.Lock()
+		inFlight++
+		if inFlight > peakInFlight {
+			peakInFlight = inFlight
+		}
+		inFlightMu.Unlock()
+		time.Sleep(30 * time.Millisecond)
+		inFlightMu.Lock()
+		inFlight--
+		inFlightMu.Unlock()
+		writer.Header().Set("Content-Type", "text/html")
+		_, _ = writer.Write([]byte(`<html><head><title>Product</title></head><body></body></html>`))
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	results := make(chan *Result, 16)
+	orchestrator, err := NewOrchestrator(results, WithMaxConcurrency(2), WithOrchestratorLogger(nil))
+	require.NoError(t, err)
+	require.NoError(t, orchestrator.Register(newOrchestratorTestConfig("ALPHA", serverURL.Hostname(), 4)))
+	require.NoError(t, orchestrator.Register(newOrchestratorTestConfig("BETA", serverURL.Hostname(), 4)))
+
+	var products []Product
+	for _, platformID := range []string{"ALPHA", "BETA"} {
+		for index := 0; index < 4; index++ {
+			productID := platformID + "-" + string(rune('0'+index))
+			products = append(products, Product{ID: productID, Platform: platformID, URL: server.URL + "/" + productID})
+		}
+	}
+
+	report, runErr := orchestrator.Run(context.Background(), products)
+	require.NoError(t, runErr)
+	require.Equal(t, 8, report.Totals().Succeeded)
+	require.Len(t, results, 8)
+
+	inFlightMu.Lock()
+	defer inFlightMu.Unlock()
+	require.LessOrEqual(t, peakInFlight, 2)
+}
+
+func TestOrchestratorReportsPlatformFailures(t *testing.T) {
+	t.Parallel()
+
+	results := make(chan *Result, 1)
+	orchestrator, err := NewOrchestrator(results)
+	require.NoError(t, err)
+
+	cfg := newOrchestratorTestConfig("ALPHA", "shop.test", 1)
+	cfg.Scraper.ProxyList = []string{newDeadProxyURL(t)}
+	cfg.Scraper.ProxyProbe = ProxyProbeConfig{ProbeURL: "http://probe.test/ping", Timeout: 2 * time.Second}
+	require.NoError(t, orchestrator.Register(cfg))
+
+	report, runErr := orchestrator.Run(context.Background(), []Product{
+		{ID: "A-1", Platform: "ALPHA", URL: "http://shop.test/a-1"},
+	})
+	require.ErrorIs(t, runErr, ErrInsufficientProxies)
+	require.ErrorIs(t, report.Platforms["ALPHA"].Err, ErrInsufficientProxies)
+	require.Zero(t, report.Platforms["ALPHA"].Results)
+}
+
+func TestOrchestratorValidatesConstructionAndRegistration(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewOrchestrator(nil)
+	require.Error(t, err)
+
+	_, err = NewOrchestrator(make(chan *Result), WithMaxConcurrency(-1))
+	require.Error(t, err)
+
+	orchestrator, err := NewOrchestrator(make(chan *Result))
+	require.NoError(t, err)
+	require.Error(t, orchestrator.Register(Config{}))
+	require.NoError(t, orchestrator.Register(newOrchestratorTestConfig("ALPHA", "shop.test", 1)))
+	require.Error(t, orchestrator.Register(newOrchestratorTestConfig("alpha", "shop.test", 1)))
+
+	_, err = orchestrator.Run(context.Background(), nil)
+	require.Error(t, err)
+}
+
+func TestOrchestratorReportsServiceErrorsFailedPagesAndCancellation(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		http.Error(writer, "unavailable", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	results := make(chan *Result, 4)
+	orchestrator, err := NewOrchestrator(results)
+	require.NoError(t, err)
+	require.NoError(t, orchestrator.Register(newOrchestratorTestConfig("ALPHA", serverURL.Hostname(), 1)))
+	broken := newOrchestratorTestConfig("BETA", serverURL.Hostname(), 1)
+	broken.Scraper.ProxySource = ProxySourceFunc(func() ([]string, error) { return nil, errors.New("source down") })
+	require.NoError(t, orchestrator.Register(broken))
+
+	products := []Product{
+		{ID: "A-1", Platform: "ALPHA", URL: server.URL + "/a-1"},
+		{ID: "B-1", Platform: "BETA", URL: server.URL + "/b-1"},
+	}
+	report, runErr := orchestrator.Run(context.Background(), products)
+	require.ErrorContains(t, runErr, "platform BETA")
+	require.ErrorContains(t, report.Platforms["BETA"].Err, "source down")
+	require.Equal(t, 1, report.Platforms["ALPHA"].Failed)
+	require.Zero(t, report.Platforms["ALPHA"].NotFound)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, runErr = orchestrator.Run(ctx, products[:1])
+	require.ErrorIs(t, runErr, context.Canceled)
+}
+
+func TestDispatchReturnsItsSlotWhenTheSharedBudgetIsExhausted(t *testing.T) {
+	t.Parallel()
+
+	service := &Service{
+		productSlots: make(chan struct{}, 1),
+		sharedSlots:  make(chan struct{}, 1),
+		control:      newRunControl(1),
+		logger:       noopLogger{},
+	}
+	service.sharedSlots <- struct{}{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	service.dispatchQueuedProducts(ctx, newProductQueue([]Product{{ID: "P1", URL: "https://example.com/p1"}}))
+	require.Empty(t, service.productSlots)
+	require.ErrorIs(t, service.acquireSharedSlot(ctx), context.DeadlineExceeded)
+}
+
+func TestDispatchFinishesAnIdlePlatformWithoutTheSharedBudget(t *testing.T) {
+	t.Parallel()
+
+	service := &Service{
+		productSlots: make(chan struct{}, 1),
+		sharedSlots:  make(chan struct{}, 1),
+		control:      newRunControl(1),
+		logger:       noopLogger{},
+	}
+	service.sharedSlots <- struct{}{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	queue := newProductQueue(nil)
+	service.dispatchQueuedProducts(ctx, queue)
+	require.NoError(t, ctx.Err())
+	require.True(t, queue.isClosed())
+	require.Empty(t, service.productSlots)
+	require.Len(t, service.sharedSlots, 1)
+}
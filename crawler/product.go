@@ -1,6 +1,9 @@
 package crawler
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // Product describes a single page to crawl.
 type Product struct {
@@ -9,6 +12,18 @@ type Product struct {
 	URL         string
 	OriginalID  string
 	OriginalURL string
+	// Priority orders pending products; higher values are dispatched first.
+	Priority int
+	// Deadline is optional; a product still pending after it is reported as
+	// failed with ErrProductDeadlineExceeded instead of being visited.
+	Deadline time.Time
+	// Timeout is optional; when positive it replaces
+	// ScraperConfig.ProductTimeout for this product.
+	Timeout time.Duration
+	// ProxyTags is optional; when set the product is only fetched through
+	// proxies whose ScraperConfig.ProxyTags match, and fails with
+	// ErrNoMatchingProxy when the pool has none.
+	ProxyTags ProxyTags
 }
 
 // NewProduct constructs a Product after validating mandatory fields.
@@ -50,3 +65,31 @@ func WithOriginalURL(originalURL string) ProductOption {
 		product.OriginalURL = originalURL
 	}
 }
+
+// WithPriority sets the scheduling priority; higher values run first.
+func WithPriority(priority int) ProductOption {
+	return func(product *Product) {
+		product.Priority = priority
+	}
+}
+
+// WithDeadline sets the time after which the product is no longer worth visiting.
+func WithDeadline(deadline time.Time) ProductOption {
+	return func(product *Product) {
+		product.Deadline = deadline
+	}
+}
+
+// WithTimeout bounds the total time spent on the product, retries included.
+func WithTimeout(timeout time.Duration) ProductOption {
+	return func(product *Product) {
+		product.Timeout = timeout
+	}
+}
+
+// WithProxyTags requires the product to be fetched through a proxy carrying tags.
+func WithProxyTags(tags ProxyTags) ProductOption {
+	return func(product *Product) {
+		product.ProxyTags = tags
+	}
+}
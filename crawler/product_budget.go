@@ -0,0 +1,134 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+)
+
+const (
+	productDeadlineHeader = "X-Crawler-Product-Deadline"
+
+	ctxProductBudgetKey   = "crawler_product_budget"
+	ctxProductDeadlineKey = "crawler_product_deadline"
+	ctxProductTimedOutKey = "crawler_product_timed_out"
+)
+
+// ErrProductTimeout is reported for products that used up their time budget,
+// ScraperConfig.ProductTimeout or Product.Timeout, before a final answer.
+var ErrProductTimeout = errors.New("crawler: product time budget exceeded")
+
+// productBudget returns the total time product may take, or zero when it is
+// unbounded.
+func (cfg ScraperConfig) productBudget(product Product) time.Duration {
+	if product.Timeout > 0 {
+		return product.Timeout
+	}
+	return cfg.ProductTimeout
+}
+
+// startProductBudget records when the product's budget runs out.
+func startProductBudget(ctx *colly.Context, budget time.Duration, now time.Time) {
+	if budget <= 0 {
+		return
+	}
+	ctx.Put(ctxProductBudgetKey, budget)
+	ctx.Put(ctxProductDeadlineKey, now.Add(budget))
+}
+
+func productDeadline(ctx *colly.Context) (time.Time, bool) {
+	if ctx == nil {
+		return time.Time{}, false
+	}
+	deadline, ok := ctx.GetAny(ctxProductDeadlineKey).(time.Time)
+	return deadline, ok
+}
+
+// productBudgetExhausted reports whether the product's budget ran out, or a
+// retry was refused because it would have.
+func productBudgetExhausted(ctx *colly.Context) bool {
+	deadline, ok := productDeadline(ctx)
+	if !ok {
+		return false
+	}
+	timedOut, _ := ctx.GetAny(ctxProductTimedOutKey).(bool)
+	return timedOut || !time.Now().Before(deadline)
+}
+
+// productBudgetAllows reports whether the product can still afford to wait
+// before another attempt, marking it timed out when it cannot.
+func productBudgetAllows(ctx *colly.Context, wait time.Duration) bool {
+	deadline, ok := productDeadline(ctx)
+	if !ok {
+		return true
+	}
+	if time.Now().Add(wait).Before(deadline) {
+		return true
+	}
+	ctx.Put(ctxProductTimedOutKey, true)
+	return false
+}
+
+// productTimeoutText describes a product failure caused by its budget running
+// out, keeping the last error for context.
+func productTimeoutText(ctx *colly.Context, errorText string) string {
+	budget, _ := ctx.GetAny(ctxProductBudgetKey).(time.Duration)
+	timeoutErr := fmt.Errorf("%w after %s", ErrProductTimeout, budget)
+	ctx.Put(ctxProductErrorKey, timeoutErr)
+	if errorText == "" {
+		return timeoutErr.Error()
+	}
+	return fmt.Sprintf("%v (last error: %s)", timeoutErr, errorText)
+}
+
+// registerProductBudget copies the product's deadline onto every attempt so
+// productBudgetTransport can bound it.
+func registerProductBudget(collector *colly.Collector) {
+	collector.OnRequest(func(request *colly.Request) {
+		if deadline, ok := productDeadline(request.Ctx); ok {
+			request.Headers.Set(productDeadlineHeader, deadline.Format(time.RFC3339Nano))
+		}
+	})
+}
+
+// productBudgetTransport moves the deadline from productDeadlineHeader onto
+// the request context, so an attempt still running when the product's budget
+// runs out is aborted.
+type productBudgetTransport struct {
+	base http.RoundTripper
+}
+
+func newProductBudgetTransport(base http.RoundTripper) http.RoundTripper {
+	return &productBudgetTransport{base: base}
+}
+
+func (transport *productBudgetTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	encoded := req.Header.Get(productDeadlineHeader)
+	if encoded == "" {
+		return transport.base.RoundTrip(req)
+	}
+	outbound := req.Clone(req.Context())
+	outbound.Header.Del(productDeadlineHeader)
+	deadline, err := time.Parse(time.RFC3339Nano, encoded)
+	if err != nil {
+		return transport.base.RoundTrip(outbound)
+	}
+
+	requestCtx, cancel := context.WithDeadline(req.Context(), deadline)
+	outbound = outbound.WithContext(requestCtx)
+	resp, err := transport.base.RoundTrip(outbound)
+	if err != nil || resp == nil || resp.Body == nil {
+		cancel()
+	} else {
+		// The body is still streamed under requestCtx.
+		resp.Body = &cleanupOnCloseBody{ReadCloser: resp.Body, cleanup: cancel}
+	}
+	if resp != nil {
+		resp.Request = req
+	}
+	return resp, err
+}
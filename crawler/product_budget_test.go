@@ -0,0 +1,137 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceEnforcesProductTimeBudget(t *testing.T) {
+	t.Parallel()
+
+	var failingHits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch request.URL.Path {
+		case "/p/failing":
+			failingHits.Add(1)
+			writer.WriteHeader(http.StatusServiceUnavailable)
+			return
+		case "/p/stalled":
+			select {
+			case <-time.After(5 * time.Second):
+			case <-request.Context().Done():
+				return
+			}
+		}
+		writer.Header().Set("Content-Type", "text/html")
+		_, _ = writer.Write([]byte("<html><head><title>Product</title></head><body></body></html>"))
+	}))
+	t.Cleanup(server.Close)
+	parsed, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	results := make(chan *Result, 3)
+	service, err := NewService(Config{
+		PlatformID:    "TEST",
+		Scraper:       ScraperConfig{MaxDepth: 1, Parallelism: 1, RetryCount: 3, ProductTimeout: time.Second},
+		Platform:      PlatformConfig{AllowedDomains: []string{parsed.Hostname()}},
+		RuleEvaluator: fixedRuleEvaluator{},
+		Logger:        noopLogger{},
+	}, results)
+	require.NoError(t, err)
+
+	stalled, err := NewProduct("stalled", "TEST", server.URL+"/p/stalled", WithTimeout(200*time.Millisecond))
+	require.NoError(t, err)
+	started := time.Now()
+	require.NoError(t, service.Run(context.Background(), []Product{
+		{ID: "failing", Platform: "TEST", URL: server.URL + "/p/failing"},
+		stalled,
+		{ID: "healthy", Platform: "TEST", URL: server.URL + "/p/healthy"},
+	}))
+	require.Less(t, time.Since(started), 2*time.Second)
+	close(results)
+
+	byProduct := make(map[string]*Result)
+	for result := range results {
+		byProduct[result.ProductID] = result
+	}
+	require.False(t, byProduct["failing"].Success)
+	require.Contains(t, byProduct["failing"].ErrorMessage, "crawler: product time budget exceeded after 1s (last error:")
+	require.EqualValues(t, 1, failingHits.Load())
+	require.False(t, byProduct["stalled"].Success)
+	require.Contains(t, byProduct["stalled"].ErrorMessage, "crawler: product time budget exceeded after 200ms")
+	require.Contains(t, byProduct["stalled"].ErrorMessage, "deadline exceeded")
+	require.True(t, byProduct["healthy"].Success)
+
+	require.ErrorContains(t, ScraperConfig{Parallelism: 1, ProductTimeout: -time.Second}.Validate(), "product timeout must be non-negative")
+}
+
+func TestProductBudgetTransportBoundsAttempt(t *testing.T) {
+	var forwarded *http.Request
+	transport := newProductBudgetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		forwarded = req
+		return nil, req.Context().Err()
+	}))
+
+	req, err := http.NewRequest(http.MethodGet, "https://shop.test", nil)
+	require.NoError(t, err)
+	req.Header.Set(productDeadlineHeader, time.Now().Add(-time.Second).Format(time.RFC3339Nano))
+	_, err = transport.RoundTrip(req)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Empty(t, forwarded.Header.Get(productDeadlineHeader))
+
+	plain, err := http.NewRequest(http.MethodGet, "https://shop.test", nil)
+	require.NoError(t, err)
+	_, err = transport.RoundTrip(plain)
+	require.NoError(t, err)
+	require.Same(t, plain, forwarded)
+}
+
+func TestProductBudgetHelpersWithoutABudget(t *testing.T) {
+	t.Parallel()
+
+	_, ok := productDeadline(nil)
+	require.False(t, ok)
+
+	ctx := colly.NewContext()
+	require.False(t, productBudgetExhausted(ctx))
+	require.True(t, productBudgetAllows(ctx, time.Hour))
+
+	startProductBudget(ctx, time.Minute, time.Now())
+	require.True(t, productBudgetAllows(ctx, time.Second))
+	require.False(t, productBudgetExhausted(ctx))
+	require.False(t, productBudgetAllows(ctx, time.Hour))
+	require.True(t, productBudgetExhausted(ctx))
+
+	require.Equal(t, "crawler: product time budget exceeded after 1m0s", productTimeoutText(ctx, ""))
+	require.ErrorIs(t, ctx.GetAny(ctxProductErrorKey).(error), ErrProductTimeout)
+}
+
+func TestProductBudgetTransportForwardsUnparsableDeadlines(t *testing.T) {
+	t.Parallel()
+
+	var forwarded *http.Request
+	transport := newProductBudgetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		forwarded = req
+		_, hasDeadline := req.Context().Deadline()
+		require.False(t, hasDeadline)
+		return &http.Response{StatusCode: http.StatusNoContent, Body: http.NoBody, Request: req}, nil
+	}))
+
+	req, err := http.NewRequest(http.MethodGet, "https://shop.test", nil)
+	require.NoError(t, err)
+	req.Header.Set(productDeadlineHeader, "soon")
+	resp, err := transport.RoundTrip(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.NotSame(t, req, forwarded)
+	require.Empty(t, forwarded.Header.Get(productDeadlineHeader))
+	require.Equal(t, "soon", req.Header.Get(productDeadlineHeader))
+}
@@ -0,0 +1,77 @@
+package crawler
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ProductURLPatterns extracts product IDs from URLs. The zero value matches
+// nothing, so InferRedirect never reports a redirect.
+type ProductURLPatterns struct {
+	expressions []*regexp.Regexp
+}
+
+// CompileProductURLPatterns compiles patterns in order. Each pattern must
+// capture the ID, through a group named "id" or its first capture group.
+func CompileProductURLPatterns(patterns []string) (ProductURLPatterns, error) {
+	compiled := ProductURLPatterns{}
+	for _, pattern := range patterns {
+		expression, err := regexp.Compile(pattern)
+		if err != nil {
+			return ProductURLPatterns{}, fmt.Errorf("product id pattern %q: %w", pattern, err)
+		}
+		if expression.NumSubexp() == 0 {
+			return ProductURLPatterns{}, fmt.Errorf("product id pattern %q has no capture group", pattern)
+		}
+		compiled.expressions = append(compiled.expressions, expression)
+	}
+	return compiled, nil
+}
+
+// ProductID returns the ID captured by the first pattern matching rawURL, or
+// an empty string when none matches.
+func (patterns ProductURLPatterns) ProductID(rawURL string) string {
+	if strings.TrimSpace(rawURL) == "" {
+		return ""
+	}
+	for _, expression := range patterns.expressions {
+		match := expression.FindStringSubmatch(rawURL)
+		if match == nil {
+			continue
+		}
+		group := expression.SubexpIndex("id")
+		if group < 0 {
+			group = 1
+		}
+		if productID := strings.TrimSpace(match[group]); productID != "" {
+			return productID
+		}
+	}
+	return ""
+}
+
+// InferRedirect implements PlatformHooks.InferRedirect from URL patterns, so
+// platform hooks can delegate to it. The canonical URL is trusted over the
+// final URL; IDs are compared case-insensitively. When productID is empty the
+// ID captured from originalURL is used instead.
+func (patterns ProductURLPatterns) InferRedirect(productID, originalURL, finalURL, canonicalURL string) (bool, string) {
+	expectedID := strings.TrimSpace(productID)
+	if expectedID == "" {
+		expectedID = patterns.ProductID(originalURL)
+	}
+	if expectedID == "" {
+		return false, ""
+	}
+	for _, candidateURL := range []string{canonicalURL, finalURL} {
+		candidateID := patterns.ProductID(candidateURL)
+		if candidateID == "" {
+			continue
+		}
+		if strings.EqualFold(candidateID, expectedID) {
+			return false, ""
+		}
+		return true, candidateID
+	}
+	return false, ""
+}
@@ -124,6 +124,27 @@ func (tracker *proxyHealthTracker) recordFailure(proxy string, immediate bool) {
 	}
 }
 
+// SyncProxies aligns tracked state with the current proxy pool: new proxies
+// start healthy, surviving proxies keep their history, and retired proxies are
+// forgotten.
+func (tracker *proxyHealthTracker) SyncProxies(proxies []string) {
+	if tracker == nil {
+		return
+	}
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	active := make(map[string]struct{}, len(proxies))
+	for _, proxy := range proxies {
+		active[proxy] = struct{}{}
+		tracker.ensureState(proxy)
+	}
+	for proxy := range tracker.states {
+		if _, ok := active[proxy]; !ok {
+			delete(tracker.states, proxy)
+		}
+	}
+}
+
 func (tracker *proxyHealthTracker) ensureState(key string) *proxyHealthState {
 	if state, ok := tracker.states[key]; ok {
 		return state
@@ -39,3 +39,18 @@ func TestProxyHealthTrackerQuarantinesCriticalFailureImmediately(t *testing.T) {
 
 	require.False(t, tracker.IsAvailable("http://proxy-three:8080"))
 }
+
+func TestProxyHealthTrackerSyncProxiesKeepsSurvivorHistory(t *testing.T) {
+	tracker := newProxyHealthTracker([]string{"http://kept:8080", "http://retired:8080"}, nil)
+	tracker.now = func() time.Time { return time.Unix(0, 0) }
+	tracker.RecordCriticalFailure("http://kept:8080")
+
+	tracker.SyncProxies([]string{"http://kept:8080", "http://added:8080"})
+
+	require.False(t, tracker.IsAvailable("http://kept:8080"))
+	require.True(t, tracker.IsAvailable("http://added:8080"))
+	require.NotContains(t, tracker.states, "http://retired:8080")
+
+	var missing *proxyHealthTracker
+	require.NotPanics(t, func() { missing.SyncProxies([]string{"http://kept:8080"}) })
+}
@@ -0,0 +1,186 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInsufficientProxies is returned when fewer proxies than required pass the
+// liveness probe.
+var ErrInsufficientProxies = errors.New("crawler: insufficient healthy proxies")
+
+const (
+	defaultProxyProbeTimeout     = 10 * time.Second
+	defaultProxyProbeConcurrency = 16
+	proxyProbeDrainLimit         = 64 * 1024
+)
+
+// ProxyProbeConfig configures a proxy liveness check. Probing is enabled when
+// ProbeURL is set.
+type ProxyProbeConfig struct {
+	// ProbeURL is fetched through every proxy; a response below 400 marks the
+	// proxy healthy.
+	ProbeURL string
+	// Timeout bounds each probe. Defaults to 10 seconds.
+	Timeout time.Duration
+	// Concurrency caps simultaneous probes. Defaults to 16.
+	Concurrency int
+	// MinHealthy is the number of healthy proxies required. Defaults to 1, or
+	// to 0 when no proxies are configured.
+	MinHealthy int
+	// InsecureSkipVerify disables TLS verification for the probe requests.
+	InsecureSkipVerify bool
+}
+
+// Enabled reports whether a probe URL has been configured.
+func (cfg ProxyProbeConfig) Enabled() bool {
+	return strings.TrimSpace(cfg.ProbeURL) != ""
+}
+
+// Validate checks the probe URL and numeric bounds, reporting every problem.
+func (cfg ProxyProbeConfig) Validate() error {
+	var problems []error
+	probeURL, err := url.Parse(strings.TrimSpace(cfg.ProbeURL))
+	if err != nil || probeURL.Scheme == "" || probeURL.Host == "" {
+		problems = append(problems, fmt.Errorf("proxy probe url %q is invalid", cfg.ProbeURL))
+	}
+	if cfg.Timeout < 0 {
+		problems = append(problems, fmt.Errorf("proxy probe timeout must be non-negative (got %s)", cfg.Timeout))
+	}
+	if cfg.Concurrency < 0 {
+		problems = append(problems, fmt.Errorf("proxy probe concurrency must be non-negative (got %d)", cfg.Concurrency))
+	}
+	if cfg.MinHealthy < 0 {
+		problems = append(problems, fmt.Errorf("proxy probe min healthy must be non-negative (got %d)", cfg.MinHealthy))
+	}
+	return errors.Join(problems...)
+}
+
+// ProxyProbeResult describes the outcome of probing a single proxy.
+type ProxyProbeResult struct {
+	ProxyURL   string
+	Healthy    bool
+	StatusCode int
+	Latency    time.Duration
+	Err        error
+}
+
+// ProbeProxies concurrently fetches cfg.ProbeURL through each proxy and returns
+// one result per proxy in input order. It returns ErrInsufficientProxies, along
+// with the full results, when fewer than cfg.MinHealthy proxies are usable.
+func ProbeProxies(ctx context.Context, proxies []string, cfg ProxyProbeConfig) ([]ProxyProbeResult, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("crawler: %w", err)
+	}
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = defaultProxyProbeTimeout
+	}
+	concurrency := cfg.Concurrency
+	if concurrency == 0 {
+		concurrency = defaultProxyProbeConcurrency
+	}
+
+	candidates := make([]string, 0, len(proxies))
+	for _, proxy := range proxies {
+		if trimmed := strings.TrimSpace(proxy); trimmed != "" {
+			candidates = append(candidates, trimmed)
+		}
+	}
+	minHealthy := cfg.MinHealthy
+	if minHealthy == 0 {
+		minHealthy = min(1, len(candidates))
+	}
+
+	results := make([]ProxyProbeResult, len(candidates))
+	semaphore := make(chan struct{}, concurrency)
+	var waitGroup sync.WaitGroup
+	for index, proxy := range candidates {
+		waitGroup.Add(1)
+		go func(index int, proxy string) {
+			defer waitGroup.Done()
+			select {
+			case semaphore <- struct{}{}:
+			case <-ctx.Done():
+				results[index] = ProxyProbeResult{ProxyURL: proxy, Err: ctx.Err()}
+				return
+			}
+			defer func() { <-semaphore }()
+			results[index] = probeProxy(ctx, proxy, strings.TrimSpace(cfg.ProbeURL), timeout, cfg.InsecureSkipVerify)
+		}(index, proxy)
+	}
+	waitGroup.Wait()
+
+	healthy := 0
+	for _, result := range results {
+		if result.Healthy {
+			healthy++
+		}
+	}
+	if healthy < minHealthy {
+		return results, fmt.Errorf("%w: %d of %d proxies usable, %d required", ErrInsufficientProxies, healthy, len(results), minHealthy)
+	}
+	return results, nil
+}
+
+func probeProxy(ctx context.Context, proxy, probeURL string, timeout time.Duration, insecureSkipVerify bool) ProxyProbeResult {
+	result := ProxyProbeResult{ProxyURL: proxy}
+	proxyEndpoint, err := url.Parse(proxy)
+	if err != nil {
+		// The *url.Error repeats the raw URL, credentials included.
+		result.Err = fmt.Errorf("crawler: invalid proxy %q: %w", sanitizeProxyURL(proxy), errors.Unwrap(err))
+		return result
+	}
+
+	transport := newCrawlerHTTPTransport(insecureSkipVerify, timeout)
+	transport.Proxy = http.ProxyURL(proxyEndpoint)
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport, Timeout: timeout}
+
+	// ProbeProxies validated probeURL, so building the request cannot fail.
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, probeURL, nil)
+
+	startedAt := time.Now()
+	response, err := client.Do(request)
+	result.Latency = time.Since(startedAt)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, proxyProbeDrainLimit))
+
+	result.StatusCode = response.StatusCode
+	if response.StatusCode >= http.StatusBadRequest {
+		result.Err = fmt.Errorf("crawler: probe returned status %d", response.StatusCode)
+		return result
+	}
+	result.Healthy = true
+	return result
+}
+
+// seedProxyHealth records probe outcomes so the rotator skips dead proxies from
+// the first request on.
+func seedProxyHealth(tracker proxyHealth, results []ProxyProbeResult) {
+	if tracker == nil {
+		return
+	}
+	for _, result := range results {
+		proxyKey := result.ProxyURL
+		if endpoint, err := url.Parse(result.ProxyURL); err == nil {
+			proxyKey = endpoint.String()
+		}
+		if result.Healthy {
+			tracker.RecordSuccess(proxyKey)
+			continue
+		}
+		tracker.RecordCriticalFailure(proxyKey)
+	}
+}
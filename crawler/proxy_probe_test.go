@@ -0,0 +1,248 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newProbeProxyServer(t *testing.T, statusCode int) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.WriteHeader(statusCode)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func newDeadProxyURL(t *testing.T) string {
+	t.Helper()
+	server := httptest.NewServer(http.NotFoundHandler())
+	deadURL := server.URL
+	server.Close()
+	return deadURL
+}
+
+func TestProbeProxiesReportsResultsInInputOrder(t *testing.T) {
+	t.Parallel()
+
+	healthyProxy := newProbeProxyServer(t, http.StatusNoContent)
+	blockedProxy := newProbeProxyServer(t, http.StatusForbidden)
+	deadProxy := newDeadProxyURL(t)
+
+	results, err := ProbeProxies(context.Background(), []string{deadProxy, " ", healthyProxy, blockedProxy}, ProxyProbeConfig{
+		ProbeURL: "http://probe.test/ping",
+		Timeout:  2 * time.Second,
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	require.Equal(t, deadProxy, results[0].ProxyURL)
+	require.False(t, results[0].Healthy)
+	require.Error(t, results[0].Err)
+
+	require.Equal(t, healthyProxy, results[1].ProxyURL)
+	require.True(t, results[1].Healthy)
+	require.Equal(t, http.StatusNoContent, results[1].StatusCode)
+	require.Positive(t, results[1].Latency)
+	require.NoError(t, results[1].Err)
+
+	require.Equal(t, blockedProxy, results[2].ProxyURL)
+	require.False(t, results[2].Healthy)
+	require.Equal(t, http.StatusForbidden, results[2].StatusCode)
+}
+
+func TestProbeProxiesFailsWhenTooFewAreHealthy(t *testing.T) {
+	t.Parallel()
+
+	healthyProxy := newProbeProxyServer(t, http.StatusOK)
+	deadProxy := newDeadProxyURL(t)
+
+	results, err := ProbeProxies(context.Background(), []string{healthyProxy, deadProxy}, ProxyProbeConfig{
+		ProbeURL:    "http://probe.test/ping",
+		Timeout:     2 * time.Second,
+		Concurrency: 1,
+		MinHealthy:  2,
+	})
+	require.ErrorIs(t, err, ErrInsufficientProxies)
+	require.Len(t, results, 2)
+	require.True(t, results[0].Healthy)
+}
+
+func TestProbeProxiesRejectsInvalidConfig(t *testing.T) {
+	t.Parallel()
+
+	testCases := []ProxyProbeConfig{
+		{ProbeURL: "not a url"},
+		{ProbeURL: "http://probe.test", Timeout: -time.Second},
+		{ProbeURL: "http://probe.test", Concurrency: -1},
+		{ProbeURL: "http://probe.test", MinHealthy: -1},
+	}
+	for _, probeConfig := range testCases {
+		_, err := ProbeProxies(context.Background(), []string{"http://proxy.test:8080"}, probeConfig)
+		require.Error(t, err)
+	}
+}
+
+func TestScraperConfigValidatesEnabledProxyProbe(t *testing.T) {
+	t.Parallel()
+
+	cfg := ScraperConfig{MaxDepth: 1, Parallelism: 1, ProxyProbe: ProxyProbeConfig{ProbeURL: "/relative"}}
+	require.Error(t, cfg.Validate())
+
+	cfg.ProxyProbe = ProxyProbeConfig{}
+	require.NoError(t, cfg.Validate())
+}
+
+func TestSeedProxyHealthPausesFailedProxies(t *testing.T) {
+	t.Parallel()
+
+	tracker := newProxyHealthTracker(nil, nil)
+	seedProxyHealth(tracker, []ProxyProbeResult{
+		{ProxyURL: "http://good.test:8080", Healthy: true},
+		{ProxyURL: "http://bad.test:8080"},
+	})
+
+	require.True(t, tracker.IsAvailable("http://good.test:8080"))
+	require.False(t, tracker.IsAvailable("http://bad.test:8080"))
+
+	seedProxyHealth(nil, []ProxyProbeResult{{ProxyURL: "http://bad.test:8080"}})
+}
+
+func TestServiceRunFailsFastWhenProxyProbeFails(t *testing.T) {
+	t.Parallel()
+
+	results := make(chan *Result, 1)
+	cfg := Config{
+		PlatformID: "TEST",
+		Scraper: ScraperConfig{
+			MaxDepth:                   1,
+			Parallelism:                1,
+			ProxyList:                  []string{newDeadProxyURL(t)},
+			ProxyCircuitBreakerEnabled: true,
+			ProxyProbe: ProxyProbeConfig{
+				ProbeURL: "http://probe.test/ping",
+				Timeout:  2 * time.Second,
+			},
+		},
+		Platform: PlatformConfig{
+			AllowedDomains: []string{"shop.test"},
+		},
+		RuleEvaluator: fixedRuleEvaluator{},
+		Logger:        noopLogger{},
+	}
+
+	service, err := NewService(cfg, results)
+	require.NoError(t, err)
+
+	runErr := service.Run(context.Background(), []Product{
+		{ID: "PROBE-1", Platform: "TEST", URL: "http://shop.test/product/PROBE-1"},
+	})
+	require.ErrorIs(t, runErr, ErrInsufficientProxies)
+	require.Empty(t, results)
+}
+
+func TestProbeProxiesWithoutProxiesRequiresNoneByDefault(t *testing.T) {
+	t.Parallel()
+
+	results, err := ProbeProxies(context.Background(), []string{" "}, ProxyProbeConfig{ProbeURL: "http://probe.test/ping"})
+	require.NoError(t, err)
+	require.Empty(t, results)
+
+	_, err = ProbeProxies(context.Background(), nil, ProxyProbeConfig{ProbeURL: "http://probe.test/ping", MinHealthy: 1})
+	require.ErrorIs(t, err, ErrInsufficientProxies)
+}
+
+func TestServiceRunWithProbeAndNoProxies(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.Header().Set("Content-Type", "text/html")
+		_, _ = writer.Write([]byte(`<html><head><title>Direct</title></head><body></body></html>`))
+	}))
+	t.Cleanup(server.Close)
+
+	results := make(chan *Result, 1)
+	service, err := NewService(Config{
+		PlatformID: "TEST",
+		Scraper: ScraperConfig{
+			MaxDepth:    1,
+			Parallelism: 1,
+			ProxyProbe: ProxyProbeConfig{
+				ProbeURL: "http://probe.test/ping",
+				Timeout:  2 * time.Second,
+			},
+		},
+		Platform: PlatformConfig{
+			AllowedDomains: []string{"127.0.0.1"},
+		},
+		RuleEvaluator: fixedRuleEvaluator{},
+		Logger:        noopLogger{},
+	}, results)
+	require.NoError(t, err)
+
+	require.NoError(t, service.Run(context.Background(), []Product{
+		{ID: "DIRECT-1", Platform: "TEST", URL: server.URL + "/product/DIRECT-1"},
+	}))
+	require.True(t, (<-results).Success)
+}
+
+func TestProbeProxiesReportsInvalidAndUnprobedProxies(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	defer close(release)
+	stalled := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		<-release
+	}))
+	t.Cleanup(stalled.Close)
+
+	// With one probe slot, the proxy that waits for it gives up with ctx.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	results, err := ProbeProxies(ctx, []string{stalled.URL, stalled.URL}, ProxyProbeConfig{
+		ProbeURL:    "http://probe.test/ping",
+		Concurrency: 1,
+	})
+	require.ErrorIs(t, err, ErrInsufficientProxies)
+	require.Len(t, results, 2)
+	for _, result := range results {
+		require.ErrorIs(t, result.Err, context.DeadlineExceeded)
+	}
+
+	results, err = ProbeProxies(context.Background(), []string{"http://user:secret@[bad"}, ProxyProbeConfig{
+		ProbeURL: "http://probe.test/ping",
+	})
+	require.ErrorIs(t, err, ErrInsufficientProxies)
+	require.ErrorContains(t, results[0].Err, "invalid proxy")
+	require.NotContains(t, results[0].Err.Error(), "secret")
+}
+
+func TestWarmUpProxiesReportsSourceFailuresAndHealthyProxies(t *testing.T) {
+	t.Parallel()
+
+	service := &Service{
+		config: Config{Scraper: ScraperConfig{
+			ProxySource: ProxySourceFunc(func() ([]string, error) { return nil, errors.New("source down") }),
+			ProxyProbe:  ProxyProbeConfig{ProbeURL: "http://probe.test/ping"},
+		}},
+		logger: noopLogger{},
+	}
+	require.ErrorContains(t, service.warmUpProxies(context.Background()), "load proxies for probe: source down")
+
+	logger := &capturingLogger{}
+	service = &Service{
+		config: Config{Scraper: ScraperConfig{
+			ProxyList:  []string{newProbeProxyServer(t, http.StatusNoContent)},
+			ProxyProbe: ProxyProbeConfig{ProbeURL: "http://probe.test/ping", Timeout: 2 * time.Second},
+		}},
+		logger: logger,
+	}
+	require.NoError(t, service.warmUpProxies(context.Background()))
+	require.Empty(t, logger.warnings)
+}
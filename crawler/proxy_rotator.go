@@ -1,7 +1,7 @@
 package crawler
 
 import (
-	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -11,7 +11,51 @@ import (
 	"github.com/gocolly/colly/v2"
 )
 
-func newProxyRotator(rawProxies []string, tracker proxyHealth, logger Logger) (colly.ProxyFunc, error) {
+var errNoProxiesAvailable = errors.New("crawler: no proxies available")
+
+func newProxyRotator(rawProxies []string, tags map[string]ProxyTags, tracker proxyHealth, logger Logger) (colly.ProxyFunc, error) {
+	parsed, err := parseProxyList(rawProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	rotator := &proxyRotator{
+		proxies:  parsed,
+		tags:     indexProxyTags(tags),
+		tracker:  tracker,
+		logger:   logger,
+		position: -1,
+	}
+	return rotator.nextProxy, nil
+}
+
+// newSourceProxyRotator builds a rotator that reloads its proxy list from the
+// source before each selection. The initial load must succeed so configuration
+// errors surface when the service is constructed.
+func newSourceProxyRotator(source ProxySource, tags map[string]ProxyTags, tracker proxyHealth, logger Logger) (colly.ProxyFunc, error) {
+	rawProxies, err := source.Proxies()
+	if err != nil {
+		return nil, fmt.Errorf("crawler: load proxies: %w", err)
+	}
+	parsed, err := parseProxyList(rawProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	rotator := &proxyRotator{
+		proxies:   parsed,
+		source:    source,
+		signature: proxyListSignature(rawProxies),
+		tags:      indexProxyTags(tags),
+		tracker:   tracker,
+		logger:    logger,
+		position:  -1,
+	}
+	rotator.syncTracker()
+	return rotator.nextProxy, nil
+}
+
+func parseProxyList(rawProxies []string) ([]*url.URL, error) {
 	if len(rawProxies) == 0 {
 		return nil, fmt.Errorf("crawler: proxy list is empty")
 	}
@@ -33,47 +77,114 @@ func newProxyRotator(rawProxies []string, tracker proxyHealth, logger Logger) (c
 	if len(parsed) == 0 {
 		return nil, fmt.Errorf("crawler: proxy list is empty")
 	}
+	return parsed, nil
+}
 
-	rotator := &proxyRotator{
-		proxies:  parsed,
-		tracker:  tracker,
-		logger:   logger,
-		position: -1,
-	}
-	return rotator.nextProxy, nil
+func proxyListSignature(rawProxies []string) string {
+	return strings.Join(rawProxies, "\n")
+}
+
+// proxyPoolSynchronizer is implemented by health trackers that can drop state
+// for retired proxies when the pool changes.
+type proxyPoolSynchronizer interface {
+	SyncProxies(proxies []string)
 }
 
 type proxyRotator struct {
-	mu       sync.Mutex
-	proxies  []*url.URL
-	tracker  proxyHealth
-	logger   Logger
-	position int
+	mu        sync.Mutex
+	proxies   []*url.URL
+	source    ProxySource
+	signature string
+	tags      map[string]ProxyTags
+	tracker   proxyHealth
+	logger    Logger
+	position  int
 }
 
+// nextProxy picks the next available proxy carrying the tags the request
+// requires. When every matching proxy is cooling down the last one is reused,
+// as for untagged requests; when none matches it fails with ErrNoMatchingProxy.
 func (rotator *proxyRotator) nextProxy(request *http.Request) (*url.URL, error) {
 	rotator.mu.Lock()
 	defer rotator.mu.Unlock()
+	rotator.refresh()
+	if len(rotator.proxies) == 0 {
+		return nil, errNoProxiesAvailable
+	}
+	required := requiredProxyTags(request)
+	var fallback *url.URL
 	for attempts := 0; attempts < len(rotator.proxies); attempts++ {
 		rotator.position = (rotator.position + 1) % len(rotator.proxies)
 		candidate := rotator.proxies[rotator.position]
+		if !rotator.tags[candidate.String()].Matches(required) {
+			continue
+		}
 		if rotator.tracker == nil || rotator.tracker.IsAvailable(candidate.String()) {
-			attachProxyURL(request, candidate.String())
+			recordProxyURL(request, candidate.String())
 			return candidate, nil
 		}
+		fallback = candidate
+	}
+	if fallback == nil {
+		return nil, noMatchingProxyError(required)
+	}
+	if rotator.logger != nil {
+		rotator.logger.Warning("All proxies unavailable; reusing %s", fallback.Host)
+	}
+	recordProxyURL(request, fallback.String())
+	return fallback, nil
+}
+
+// refresh reloads the proxy list from the source when it has changed. Requests
+// already dispatched keep the proxy they were given; only later selections see
+// the new list. Load or parse failures keep the current list in service.
+func (rotator *proxyRotator) refresh() {
+	if rotator.source == nil {
+		return
+	}
+	rawProxies, err := rotator.source.Proxies()
+	if err != nil {
+		rotator.warn("Failed to reload proxies; keeping %d current: %v", len(rotator.proxies), err)
+		return
+	}
+	signature := proxyListSignature(rawProxies)
+	if signature == rotator.signature {
+		return
+	}
+	rotator.signature = signature
+	if countProxies(rawProxies) == 0 {
+		rotator.warn("Proxy source returned no proxies")
+		rotator.proxies = nil
+		rotator.syncTracker()
+		return
+	}
+	parsed, err := parseProxyList(rawProxies)
+	if err != nil {
+		rotator.warn("Ignoring reloaded proxy list; keeping %d current: %v", len(rotator.proxies), err)
+		return
 	}
-	candidate := rotator.proxies[rotator.position]
 	if rotator.logger != nil {
-		rotator.logger.Warning("All proxies unavailable; reusing %s", candidate.Host)
+		rotator.logger.Info("Proxy pool reloaded: %d -> %d proxies", len(rotator.proxies), len(parsed))
 	}
-	attachProxyURL(request, candidate.String())
-	return candidate, nil
+	rotator.proxies = parsed
+	rotator.position = -1
+	rotator.syncTracker()
 }
 
-func attachProxyURL(request *http.Request, proxyURL string) {
-	if request == nil || strings.TrimSpace(proxyURL) == "" {
+func (rotator *proxyRotator) syncTracker() {
+	synchronizer, ok := rotator.tracker.(proxyPoolSynchronizer)
+	if !ok {
 		return
 	}
-	ctx := context.WithValue(request.Context(), colly.ProxyURLKey, proxyURL)
-	*request = *request.WithContext(ctx)
+	proxies := make([]string, 0, len(rotator.proxies))
+	for _, endpoint := range rotator.proxies {
+		proxies = append(proxies, endpoint.String())
+	}
+	synchronizer.SyncProxies(proxies)
+}
+
+func (rotator *proxyRotator) warn(format string, args ...interface{}) {
+	if rotator.logger != nil {
+		rotator.logger.Warning(format, args...)
+	}
 }
@@ -0,0 +1,192 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultProxyFilePollInterval = 30 * time.Second
+	proxyFileCommentPrefix       = "#"
+)
+
+// ProxySource supplies the proxy endpoints the crawler rotates through. The
+// rotator consults the source before every request, so implementations should
+// answer quickly and cache anything expensive. Returning an error keeps the
+// previously loaded list in service.
+type ProxySource interface {
+	Proxies() ([]string, error)
+}
+
+// ProxySourceFunc adapts a callback to the ProxySource interface.
+type ProxySourceFunc func() ([]string, error)
+
+// Proxies invokes the callback.
+func (source ProxySourceFunc) Proxies() ([]string, error) {
+	if source == nil {
+		return nil, nil
+	}
+	return source()
+}
+
+// StaticProxySource serves a fixed proxy list.
+type StaticProxySource []string
+
+// Proxies returns a copy of the static list.
+func (source StaticProxySource) Proxies() ([]string, error) {
+	return append([]string(nil), source...), nil
+}
+
+// FileProxySource serves proxies from a newline-delimited file and reloads it
+// whenever the file changes. Blank lines and lines starting with "#" are
+// ignored. The file is checked for changes at most once per poll interval.
+type FileProxySource struct {
+	path         string
+	pollInterval time.Duration
+	now          func() time.Time
+	mu           sync.Mutex
+	proxies      []string
+	modTime      time.Time
+	size         int64
+	lastCheck    time.Time
+}
+
+// NewFileProxySource loads the proxy file at path and returns a source that
+// reloads it when its modification time or size changes. A non-positive poll
+// interval uses a 30 second default.
+func NewFileProxySource(path string, pollInterval time.Duration) (*FileProxySource, error) {
+	cleanPath := strings.TrimSpace(path)
+	if cleanPath == "" {
+		return nil, fmt.Errorf("crawler: proxy file path is required")
+	}
+	if pollInterval <= 0 {
+		pollInterval = defaultProxyFilePollInterval
+	}
+	source := &FileProxySource{
+		path:         cleanPath,
+		pollInterval: pollInterval,
+		now:          time.Now,
+	}
+	if err := source.reload(); err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+// Proxies returns the most recently loaded proxy list, reloading the file
+// first when the poll interval has elapsed and the file has changed.
+func (source *FileProxySource) Proxies() ([]string, error) {
+	source.mu.Lock()
+	defer source.mu.Unlock()
+	if source.now().Sub(source.lastCheck) >= source.pollInterval {
+		if err := source.reloadLocked(); err != nil {
+			return append([]string(nil), source.proxies...), err
+		}
+	}
+	return append([]string(nil), source.proxies...), nil
+}
+
+func (source *FileProxySource) reload() error {
+	source.mu.Lock()
+	defer source.mu.Unlock()
+	return source.reloadLocked()
+}
+
+func (source *FileProxySource) reloadLocked() error {
+	source.lastCheck = source.now()
+	info, err := os.Stat(source.path)
+	if err != nil {
+		return fmt.Errorf("crawler: stat proxy file %s: %w", source.path, err)
+	}
+	if source.proxies != nil && info.ModTime().Equal(source.modTime) && info.Size() == source.size {
+		return nil
+	}
+	content, err := os.ReadFile(source.path)
+	if err != nil {
+		return fmt.Errorf("crawler: read proxy file %s: %w", source.path, err)
+	}
+	source.proxies = parseProxyLines(content)
+	source.modTime = info.ModTime()
+	source.size = info.Size()
+	return nil
+}
+
+// proxySnapshot wraps a Service's ProxySource. The rotator loads through it
+// before each selection; every other reader, such as retry pacing, reads the
+// list the rotator loaded last instead of consulting the source again.
+type proxySnapshot struct {
+	source  ProxySource
+	mu      sync.Mutex
+	proxies []string
+	loaded  bool
+}
+
+func newProxySnapshot(source ProxySource) ProxySource {
+	if source == nil {
+		return nil
+	}
+	if _, ok := source.(*proxySnapshot); ok {
+		return source
+	}
+	return &proxySnapshot{source: source}
+}
+
+// Proxies loads the wrapped source and remembers a successful answer.
+func (snapshot *proxySnapshot) Proxies() ([]string, error) {
+	proxies, err := snapshot.source.Proxies()
+	if err != nil {
+		return proxies, err
+	}
+	snapshot.mu.Lock()
+	snapshot.proxies = append([]string(nil), proxies...)
+	snapshot.loaded = true
+	snapshot.mu.Unlock()
+	return proxies, nil
+}
+
+func (snapshot *proxySnapshot) cached() ([]string, error) {
+	snapshot.mu.Lock()
+	if !snapshot.loaded {
+		snapshot.mu.Unlock()
+		return snapshot.Proxies()
+	}
+	defer snapshot.mu.Unlock()
+	return append([]string(nil), snapshot.proxies...), nil
+}
+
+// cachedProxies returns the last list loaded through a proxySnapshot, and
+// loads any other source directly.
+func cachedProxies(source ProxySource) ([]string, error) {
+	if snapshot, ok := source.(*proxySnapshot); ok {
+		return snapshot.cached()
+	}
+	return source.Proxies()
+}
+
+func parseProxyLines(content []byte) []string {
+	proxies := make([]string, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, proxyFileCommentPrefix) {
+			continue
+		}
+		proxies = append(proxies, line)
+	}
+	return proxies
+}
+
+func countProxies(proxies []string) int {
+	count := 0
+	for _, proxy := range proxies {
+		if strings.TrimSpace(proxy) != "" {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,165 @@
+package crawler
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileProxySourceParsesLinesAndSkipsComments(t *testing.T) {
+	proxyFile := filepath.Join(t.TempDir(), "proxies.txt")
+	require.NoError(t, os.WriteFile(proxyFile, []byte("# vendor pool\nhttp://proxy-one.test:8080\n\n  http://proxy-two.test:8080  \n"), 0o644))
+
+	source, err := NewFileProxySource(proxyFile, time.Minute)
+	require.NoError(t, err)
+
+	proxies, err := source.Proxies()
+	require.NoError(t, err)
+	require.Equal(t, []string{"http://proxy-one.test:8080", "http://proxy-two.test:8080"}, proxies)
+}
+
+func TestFileProxySourceReloadsAfterPollInterval(t *testing.T) {
+	proxyFile := filepath.Join(t.TempDir(), "proxies.txt")
+	require.NoError(t, os.WriteFile(proxyFile, []byte("http://proxy-one.test:8080\n"), 0o644))
+
+	source, err := NewFileProxySource(proxyFile, time.Minute)
+	require.NoError(t, err)
+	currentTime := time.Unix(0, 0)
+	source.now = func() time.Time { return currentTime }
+	source.lastCheck = currentTime
+
+	require.NoError(t, os.WriteFile(proxyFile, []byte("http://proxy-two.test:8080\nhttp://proxy-three.test:8080\n"), 0o644))
+	require.NoError(t, os.Chtimes(proxyFile, time.Now().Add(time.Hour), time.Now().Add(time.Hour)))
+
+	proxies, err := source.Proxies()
+	require.NoError(t, err)
+	require.Equal(t, []string{"http://proxy-one.test:8080"}, proxies, "reload must wait for the poll interval")
+
+	currentTime = currentTime.Add(time.Minute)
+	proxies, err = source.Proxies()
+	require.NoError(t, err)
+	require.Equal(t, []string{"http://proxy-two.test:8080", "http://proxy-three.test:8080"}, proxies)
+}
+
+func TestFileProxySourceKeepsLastListWhenFileDisappears(t *testing.T) {
+	proxyFile := filepath.Join(t.TempDir(), "proxies.txt")
+	require.NoError(t, os.WriteFile(proxyFile, []byte("http://proxy-one.test:8080\n"), 0o644))
+
+	source, err := NewFileProxySource(proxyFile, time.Nanosecond)
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(proxyFile))
+
+	proxies, err := source.Proxies()
+	require.Error(t, err)
+	require.Equal(t, []string{"http://proxy-one.test:8080"}, proxies)
+}
+
+func TestNewFileProxySourceValidatesPath(t *testing.T) {
+	_, err := NewFileProxySource("  ", time.Second)
+	require.Error(t, err)
+
+	_, err = NewFileProxySource(filepath.Join(t.TempDir(), "missing.txt"), time.Second)
+	require.Error(t, err)
+
+	_, err = NewFileProxySource(t.TempDir(), time.Second)
+	require.ErrorContains(t, err, "read proxy file")
+}
+
+func TestNewFileProxySourceDefaultsPollInterval(t *testing.T) {
+	proxyFile := filepath.Join(t.TempDir(), "proxies.txt")
+	require.NoError(t, os.WriteFile(proxyFile, []byte("http://proxy-one.test:8080\n"), 0o644))
+
+	source, err := NewFileProxySource(proxyFile, 0)
+	require.NoError(t, err)
+	require.Equal(t, defaultProxyFilePollInterval, source.pollInterval)
+}
+
+func TestStaticProxySourceReturnsCopy(t *testing.T) {
+	source := StaticProxySource{"http://proxy-one.test:8080"}
+
+	proxies, err := source.Proxies()
+	require.NoError(t, err)
+	proxies[0] = "mutated"
+
+	again, err := source.Proxies()
+	require.NoError(t, err)
+	require.Equal(t, []string{"http://proxy-one.test:8080"}, again)
+}
+
+func TestProxySourceFuncDelegatesAndToleratesNil(t *testing.T) {
+	expectedErr := errors.New("vendor api down")
+	source := ProxySourceFunc(func() ([]string, error) {
+		return nil, expectedErr
+	})
+	_, err := source.Proxies()
+	require.ErrorIs(t, err, expectedErr)
+
+	var nilSource ProxySourceFunc
+	proxies, err := nilSource.Proxies()
+	require.NoError(t, err)
+	require.Empty(t, proxies)
+}
+
+func TestScraperConfigProxyPoolSizePrefersSource(t *testing.T) {
+	cfg := ScraperConfig{
+		ProxyList:   []string{"http://static.test:8080"},
+		ProxySource: StaticProxySource{"http://one.test:8080", " ", "http://two.test:8080"},
+	}
+	require.Equal(t, 2, cfg.proxyPoolSize())
+
+	cfg.ProxySource = nil
+	require.Equal(t, 1, cfg.proxyPoolSize())
+}
+
+func TestRetryHandlerUsesLiveProxyPoolSize(t *testing.T) {
+	proxies := []string{"http://one.test:8080"}
+	handler := &retryHandler{
+		proxySource: ProxySourceFunc(func() ([]string, error) { return proxies, nil }),
+	}
+
+	require.True(t, handler.shouldDelay(1, RetryOptions{}))
+
+	proxies = []string{"http://one.test:8080", "http://two.test:8080"}
+	require.False(t, handler.shouldDelay(1, RetryOptions{}))
+	require.True(t, handler.shouldDelay(2, RetryOptions{}))
+}
+
+func TestProxySnapshotServesRotatorLoadWithoutReloading(t *testing.T) {
+	loads := 0
+	proxies := []string{"http://one.test:8080"}
+	source := newProxySnapshot(ProxySourceFunc(func() ([]string, error) {
+		loads++
+		if proxies == nil {
+			return nil, errors.New("vendor api down")
+		}
+		return proxies, nil
+	}))
+	require.Same(t, source, newProxySnapshot(source))
+	require.Nil(t, newProxySnapshot(nil))
+
+	cached, err := cachedProxies(source)
+	require.NoError(t, err)
+	require.Equal(t, []string{"http://one.test:8080"}, cached)
+	require.Equal(t, 1, loads)
+
+	proxies = []string{"http://one.test:8080", "http://two.test:8080"}
+	cfg := ScraperConfig{ProxySource: source}
+	require.Equal(t, 1, cfg.proxyPoolSize())
+	handler := &retryHandler{proxySource: source}
+	require.Equal(t, 1, handler.currentProxyPoolSize())
+	require.Equal(t, 1, loads)
+
+	_, err = source.Proxies()
+	require.NoError(t, err)
+	require.Equal(t, 2, cfg.proxyPoolSize())
+
+	proxies = nil
+	_, err = source.Proxies()
+	require.Error(t, err)
+	require.Equal(t, 2, cfg.proxyPoolSize())
+	require.Equal(t, 3, loads)
+}
@@ -0,0 +1,163 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// proxyTagsHeader carries a product's required proxy tags from the colly
+// request to the proxy selector. It is stripped before the request leaves the
+// process.
+const proxyTagsHeader = "X-Crawler-Proxy-Tags"
+
+// ErrNoMatchingProxy reports that no configured proxy carries the tags a
+// product requires. Such products fail without being retried.
+var ErrNoMatchingProxy = errors.New("crawler: no proxy matches the required tags")
+
+// ProxyTags describes a proxy with free-form attributes such as
+// {"country": "DE", "vendor": "acme", "type": "residential"}. On a Product the
+// same type lists the attributes a proxy must have to fetch it.
+type ProxyTags map[string]string
+
+// Matches reports whether tags has every key in required with the same value.
+// Keys and values compare case-insensitively; an empty requirement matches
+// every proxy.
+func (tags ProxyTags) Matches(required ProxyTags) bool {
+	for key, want := range required {
+		found := false
+		for candidateKey, value := range tags {
+			if strings.EqualFold(strings.TrimSpace(candidateKey), strings.TrimSpace(key)) {
+				found = strings.EqualFold(strings.TrimSpace(value), strings.TrimSpace(want))
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// String renders the tags as sorted key=value pairs.
+func (tags ProxyTags) String() string {
+	pairs := make([]string, 0, len(tags))
+	for key, value := range tags {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// noMatchingProxyError wraps ErrNoMatchingProxy with the unmet requirement.
+func noMatchingProxyError(required ProxyTags) error {
+	return fmt.Errorf("%w: %s", ErrNoMatchingProxy, required)
+}
+
+// indexProxyTags keys the configured tags by the parsed proxy URL, the form
+// the rotator and health tracker identify proxies by.
+func indexProxyTags(tags map[string]ProxyTags) map[string]ProxyTags {
+	if len(tags) == 0 {
+		return nil
+	}
+	indexed := make(map[string]ProxyTags, len(tags))
+	for rawProxy, proxyTags := range tags {
+		value := strings.TrimSpace(rawProxy)
+		if endpoint, err := url.Parse(value); err == nil {
+			value = endpoint.String()
+		}
+		indexed[value] = proxyTags
+	}
+	return indexed
+}
+
+// hasProxyMatching reports whether any proxy currently in the pool carries
+// required. A pool that cannot be loaded is given the benefit of the doubt;
+// the rotator reports the failure when the request is sent.
+func (cfg ScraperConfig) hasProxyMatching(required ProxyTags) bool {
+	if len(required) == 0 {
+		return true
+	}
+	proxies, err := cfg.currentProxies()
+	if err != nil {
+		return true
+	}
+	tags := indexProxyTags(cfg.ProxyTags)
+	for _, rawProxy := range proxies {
+		value := strings.TrimSpace(rawProxy)
+		if value == "" {
+			continue
+		}
+		if endpoint, parseErr := url.Parse(value); parseErr == nil {
+			value = endpoint.String()
+		}
+		if tags[value].Matches(required) {
+			return true
+		}
+	}
+	return false
+}
+
+// registerProxyTags copies the product's required tags onto every attempt so
+// proxyTagsTransport can hand them to the proxy selector.
+func registerProxyTags(collector *colly.Collector) {
+	collector.OnRequest(func(request *colly.Request) {
+		required, ok := request.Ctx.GetAny(ctxProxyTagsKey).(ProxyTags)
+		if !ok || len(required) == 0 {
+			return
+		}
+		encoded := url.Values{}
+		for key, value := range required {
+			encoded.Set(key, value)
+		}
+		request.Headers.Set(proxyTagsHeader, encoded.Encode())
+	})
+}
+
+type proxyTagsContextKey struct{}
+
+// requiredProxyTags returns the tags the proxy for req must carry.
+func requiredProxyTags(req *http.Request) ProxyTags {
+	if req == nil {
+		return nil
+	}
+	required, _ := req.Context().Value(proxyTagsContextKey{}).(ProxyTags)
+	return required
+}
+
+// proxyTagsTransport moves the required tags from proxyTagsHeader into the
+// request context, where the proxy selector of the underlying http.Transport
+// reads them.
+type proxyTagsTransport struct {
+	base http.RoundTripper
+}
+
+func newProxyTagsTransport(base http.RoundTripper) http.RoundTripper {
+	return &proxyTagsTransport{base: base}
+}
+
+func (transport *proxyTagsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	encoded := req.Header.Get(proxyTagsHeader)
+	if encoded == "" {
+		return transport.base.RoundTrip(req)
+	}
+	required := ProxyTags{}
+	if values, err := url.ParseQuery(encoded); err == nil {
+		for key := range values {
+			required[key] = values.Get(key)
+		}
+	}
+	outbound := req.Clone(context.WithValue(req.Context(), proxyTagsContextKey{}, required))
+	outbound.Header.Del(proxyTagsHeader)
+	resp, err := transport.base.RoundTrip(outbound)
+	if resp != nil {
+		resp.Request = req
+	}
+	return resp, err
+}
@@ -0,0 +1,176 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/gocolly/colly/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func countryProxy(t *testing.T, country string, seen *sync.Map) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		seen.Store(country, request.Header.Get(proxyTagsHeader))
+		writer.Header().Set("Content-Type", "text/html")
+		_, _ = writer.Write([]byte("<html><head><title>via " + country + "</title></head><body></body></html>"))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestServiceRoutesProductsThroughMatchingProxies(t *testing.T) {
+	t.Parallel()
+
+	var seen sync.Map
+	usProxy := countryProxy(t, "US", &seen)
+	deProxy := countryProxy(t, "DE", &seen)
+
+	results := make(chan *Result, 4)
+	service, err := NewService(Config{
+		PlatformID: "TEST",
+		Scraper: ScraperConfig{
+			MaxDepth:    1,
+			Parallelism: 2,
+			ProxyList:   []string{usProxy.URL, deProxy.URL},
+			ProxyTags: map[string]ProxyTags{
+				usProxy.URL: {"country": "US", "type": "residential"},
+				deProxy.URL: {"country": "DE", "type": "datacenter"},
+			},
+		},
+		Platform:      PlatformConfig{AllowedDomains: []string{"shop.test"}},
+		RuleEvaluator: fixedRuleEvaluator{},
+		Logger:        noopLogger{},
+	}, results)
+	require.NoError(t, err)
+
+	products := []Product{
+		{ID: "us", Platform: "TEST", URL: "http://shop.test/p/us", ProxyTags: ProxyTags{"country": "us"}},
+		{ID: "de", Platform: "TEST", URL: "http://shop.test/p/de", ProxyTags: ProxyTags{"Country": "DE", "type": "datacenter"}},
+		{ID: "jp", Platform: "TEST", URL: "http://shop.test/p/jp", ProxyTags: ProxyTags{"country": "JP"}},
+	}
+	require.NoError(t, service.Run(context.Background(), products))
+	close(results)
+
+	byProduct := make(map[string]*Result)
+	for result := range results {
+		byProduct[result.ProductID] = result
+	}
+	require.Len(t, byProduct, 3)
+	require.True(t, byProduct["us"].Success)
+	require.Equal(t, "via US", byProduct["us"].ProductTitle)
+	require.True(t, byProduct["de"].Success)
+	require.Equal(t, "via DE", byProduct["de"].ProductTitle)
+	require.False(t, byProduct["jp"].Success)
+	require.Equal(t, "crawler: no proxy matches the required tags: country=JP", byProduct["jp"].ErrorMessage)
+
+	header, _ := seen.Load("US")
+	require.Empty(t, header)
+}
+
+func TestProxyRotatorSelectsOnlyMatchingProxies(t *testing.T) {
+	raw := []string{"http://us-1.test:8080", "http://de-1.test:8080", "http://us-2.test:8080"}
+	tags := map[string]ProxyTags{
+		" http://us-1.test:8080 ": {"country": "US"},
+		"http://de-1.test:8080":   {"country": "DE"},
+		"http://us-2.test:8080":   {"country": "US"},
+	}
+	tracker := &unavailableProxyHealth{}
+	logger := &capturingLogger{}
+	proxyFn, err := newProxyRotator(raw, tags, nil, logger)
+	require.NoError(t, err)
+
+	request := func(required ProxyTags) *http.Request {
+		req, reqErr := http.NewRequest(http.MethodGet, "https://shop.test", nil)
+		require.NoError(t, reqErr)
+		return req.WithContext(context.WithValue(req.Context(), proxyTagsContextKey{}, required))
+	}
+	var hosts []string
+	for range 3 {
+		selected, selectErr := proxyFn(request(ProxyTags{"country": "US"}))
+		require.NoError(t, selectErr)
+		hosts = append(hosts, selected.Hostname())
+	}
+	require.Equal(t, []string{"us-1.test", "us-2.test", "us-1.test"}, hosts)
+
+	selected, err := proxyFn(request(nil))
+	require.NoError(t, err)
+	require.Equal(t, "de-1.test", selected.Hostname())
+
+	_, err = proxyFn(request(ProxyTags{"country": "JP"}))
+	require.True(t, errors.Is(err, ErrNoMatchingProxy))
+
+	cooling, err := newProxyRotator(raw, tags, tracker, logger)
+	require.NoError(t, err)
+	selected, err = cooling(request(ProxyTags{"country": "DE"}))
+	require.NoError(t, err)
+	require.Equal(t, "de-1.test", selected.Hostname())
+	require.Len(t, logger.warnings, 1)
+}
+
+func TestProxyTagsTransportMovesTagsIntoContext(t *testing.T) {
+	var forwarded *http.Request
+	transport := newProxyTagsTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		forwarded = req
+		return &http.Response{StatusCode: http.StatusOK, Request: req}, nil
+	}))
+
+	req, err := http.NewRequest(http.MethodGet, "https://shop.test", nil)
+	require.NoError(t, err)
+	req.Header.Set(proxyTagsHeader, "country=DE&vendor=acme")
+	resp, err := transport.RoundTrip(req)
+	require.NoError(t, err)
+	require.Same(t, req, resp.Request)
+	require.Empty(t, forwarded.Header.Get(proxyTagsHeader))
+	require.Equal(t, ProxyTags{"country": "DE", "vendor": "acme"}, requiredProxyTags(forwarded))
+	require.Equal(t, "country=DE&vendor=acme", req.Header.Get(proxyTagsHeader))
+
+	plain, err := http.NewRequest(http.MethodGet, "https://shop.test", nil)
+	require.NoError(t, err)
+	_, err = transport.RoundTrip(plain)
+	require.NoError(t, err)
+	require.Same(t, plain, forwarded)
+	require.Nil(t, requiredProxyTags(nil))
+}
+
+func TestProxyTagsMatching(t *testing.T) {
+	tags := ProxyTags{"Country": "US", "type": "residential"}
+	require.True(t, tags.Matches(nil))
+	require.True(t, tags.Matches(ProxyTags{"country": "us"}))
+	require.False(t, tags.Matches(ProxyTags{"country": "DE"}))
+	require.False(t, tags.Matches(ProxyTags{"vendor": "acme"}))
+	require.False(t, ProxyTags(nil).Matches(ProxyTags{"country": "US"}))
+	require.Equal(t, "Country=US,type=residential", tags.String())
+
+	cfg := ScraperConfig{ProxyList: []string{"http://us.test:8080"}, ProxyTags: map[string]ProxyTags{"http://us.test:8080": tags}}
+	require.True(t, cfg.hasProxyMatching(ProxyTags{"country": "US"}))
+	require.False(t, cfg.hasProxyMatching(ProxyTags{"country": "JP"}))
+	require.False(t, ScraperConfig{}.hasProxyMatching(ProxyTags{"country": "US"}))
+	padded := cfg
+	padded.ProxyList = []string{"  ", " http://us.test:8080 "}
+	require.True(t, padded.hasProxyMatching(ProxyTags{"country": "US"}))
+	cfg.ProxySource = ProxySourceFunc(func() ([]string, error) { return nil, errors.New("offline") })
+	require.True(t, cfg.hasProxyMatching(ProxyTags{"country": "JP"}))
+
+	product, err := NewProduct("1", "TEST", "https://shop.test/1", WithProxyTags(ProxyTags{"country": "US"}))
+	require.NoError(t, err)
+	require.Equal(t, ProxyTags{"country": "US"}, product.ProxyTags)
+}
+
+func TestHandleCollectorErrorDoesNotRetryWithoutMatchingProxy(t *testing.T) {
+	processor := &stubResponseProcessor{}
+	retryHandler := &stubRetryHandler{}
+	request, err := http.NewRequest(http.MethodGet, "https://shop.test/p/1", nil)
+	require.NoError(t, err)
+	response := &colly.Response{Ctx: colly.NewContext(), Request: &colly.Request{URL: request.URL}}
+
+	handleCollectorError(response, &url.Error{Op: "Get", URL: request.URL.String(), Err: noMatchingProxyError(ProxyTags{"country": "JP"})}, processor, retryHandler, nil, &capturingLogger{})
+	require.Empty(t, retryHandler.calls)
+	require.Len(t, processor.results, 1)
+	require.Contains(t, processor.results[0].errorText, "country=JP")
+}
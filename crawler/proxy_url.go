@@ -45,3 +45,9 @@ func describeProxyForLog(rawProxyURL string) string {
 	}
 	return sanitizedProxyURL
 }
+
+// RedactProxyURL removes the user credentials from a proxy URL so it can be
+// logged or persisted, for example from Result.ProxyURL.
+func RedactProxyURL(rawProxyURL string) string {
+	return sanitizeProxyURL(rawProxyURL)
+}
@@ -0,0 +1,152 @@
+package crawler
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// redirectTraceHeader links a colly request attempt to the round trips the
+// HTTP client makes for it. It is stripped before the request leaves the
+// process.
+const redirectTraceHeader = "X-Crawler-Redirect-Trace"
+
+// RedirectHop is one HTTP round trip made while fetching a product page. The
+// hops of a request are recorded in order; every hop except the last carries
+// a 3xx status and its Location header. Duration is the time until the
+// response headers arrived.
+type RedirectHop struct {
+	URL        string        `json:"url"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Location   string        `json:"location,omitempty"`
+	StartedAt  time.Time     `json:"started_at"`
+	Duration   time.Duration `json:"duration"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// RedirectChainFromContext returns the hops recorded for the request owning
+// ctx, or nil before the response headers arrived.
+func RedirectChainFromContext(ctx *colly.Context) []RedirectHop {
+	if ctx == nil {
+		return nil
+	}
+	hops, _ := ctx.GetAny(ctxRedirectChainKey).([]RedirectHop)
+	return hops
+}
+
+// redirectTracker collects hops, and the proxy of the latest one, per request
+// attempt between the transport, which sees every round trip, and the colly
+// callbacks, which own the context.
+type redirectTracker struct {
+	mu       sync.Mutex
+	chains   map[string][]RedirectHop
+	proxies  map[string]string
+	sequence atomic.Uint64
+	now      func() time.Time
+}
+
+func newRedirectTracker() *redirectTracker {
+	return &redirectTracker{chains: make(map[string][]RedirectHop), proxies: make(map[string]string), now: time.Now}
+}
+
+// Register tags every attempt with a fresh trace id and moves the recorded
+// hops into the request context, and the proxy into the request's ProxyURL,
+// once headers arrive or the attempt fails.
+// Retries get their own id so a chain never mixes attempts.
+func (tracker *redirectTracker) Register(collector *colly.Collector) {
+	collector.OnRequest(tracker.begin)
+	collector.OnResponseHeaders(tracker.attach)
+	collector.OnError(func(resp *colly.Response, _ error) {
+		tracker.attach(resp)
+	})
+}
+
+func (tracker *redirectTracker) begin(request *colly.Request) {
+	traceID := strconv.FormatUint(tracker.sequence.Add(1), 10)
+	request.Headers.Set(redirectTraceHeader, traceID)
+	request.Ctx.Put(ctxRedirectTraceKey, traceID)
+	request.Ctx.Put(ctxRedirectChainKey, []RedirectHop(nil))
+}
+
+func (tracker *redirectTracker) attach(resp *colly.Response) {
+	if resp == nil || resp.Ctx == nil {
+		return
+	}
+	hops, proxyURL := tracker.take(resp.Ctx.Get(ctxRedirectTraceKey))
+	if len(hops) > 0 {
+		resp.Ctx.Put(ctxRedirectChainKey, hops)
+	}
+	if proxyURL != "" && resp.Request != nil {
+		resp.Request.ProxyURL = proxyURL
+	}
+}
+
+func (tracker *redirectTracker) record(traceID string, hop RedirectHop, proxyURL string) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	tracker.chains[traceID] = append(tracker.chains[traceID], hop)
+	if proxyURL != "" {
+		tracker.proxies[traceID] = proxyURL
+	}
+}
+
+func (tracker *redirectTracker) take(traceID string) ([]RedirectHop, string) {
+	if traceID == "" {
+		return nil, ""
+	}
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	hops := tracker.chains[traceID]
+	proxyURL := tracker.proxies[traceID]
+	delete(tracker.chains, traceID)
+	delete(tracker.proxies, traceID)
+	return hops, proxyURL
+}
+
+// redirectRecordingTransport records a RedirectHop for every round trip that
+// carries a trace id. The HTTP client follows redirects above the transport,
+// so each hop passes through here with the trace header copied along. Each hop
+// also gets a proxyURLHolder on its outbound clone, so the proxy chosen for it
+// is reported without touching the caller's request.
+type redirectRecordingTransport struct {
+	base    http.RoundTripper
+	tracker *redirectTracker
+}
+
+func newRedirectRecordingTransport(base http.RoundTripper, tracker *redirectTracker) http.RoundTripper {
+	return &redirectRecordingTransport{base: base, tracker: tracker}
+}
+
+func (transport *redirectRecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	traceID := req.Header.Get(redirectTraceHeader)
+	if traceID == "" {
+		return transport.base.RoundTrip(req)
+	}
+	outboundCtx, proxyURL := withProxyURLHolder(req.Context())
+	outbound := req.Clone(outboundCtx)
+	outbound.Header.Del(redirectTraceHeader)
+
+	startedAt := transport.tracker.now()
+	resp, err := transport.base.RoundTrip(outbound)
+	hop := RedirectHop{
+		URL:       req.URL.String(),
+		StartedAt: startedAt,
+		Duration:  transport.tracker.now().Sub(startedAt),
+	}
+	if err != nil {
+		hop.Error = err.Error()
+	}
+	if resp != nil {
+		hop.StatusCode = resp.StatusCode
+		if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+			hop.Location = resp.Header.Get("Location")
+		}
+		resp.Request = req
+	}
+	transport.tracker.record(traceID, hop, proxyURL.get())
+	return resp, err
+}
@@ -0,0 +1,220 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceRecordsRedirectChainAndInfersRedirectFromPatterns(t *testing.T) {
+	t.Parallel()
+
+	leakedTraceHeaders := make(chan string, 8)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if traceID := request.Header.Get(redirectTraceHeader); traceID != "" {
+			leakedTraceHeaders <- traceID
+		}
+		switch request.URL.Path {
+		case "/p/A1":
+			http.Redirect(writer, request, "/go/A1", http.StatusMovedPermanently)
+		case "/go/A1":
+			http.Redirect(writer, request, "/p/B2?ref=moved", http.StatusFound)
+		default:
+			writer.Header().Set("Content-Type", "text/html")
+			_, _ = writer.Write([]byte(`<html><head><title>Product</title><link rel="canonical" href="/p/B2"></head><body></body></html>`))
+		}
+	}))
+	defer server.Close()
+	parsed, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	results := make(chan *Result, 2)
+	service, err := NewService(Config{
+		PlatformID: "TEST",
+		Scraper:    ScraperConfig{MaxDepth: 1, Parallelism: 1},
+		Platform: PlatformConfig{
+			AllowedDomains:    []string{parsed.Hostname()},
+			ProductIDPatterns: []string{`/p/(?P<id>[A-Z0-9]+)`},
+		},
+		RuleEvaluator: fixedRuleEvaluator{},
+		Logger:        noopLogger{},
+	}, results)
+	require.NoError(t, err)
+
+	started := time.Now()
+	require.NoError(t, service.Run(context.Background(), []Product{
+		{ID: "A1", Platform: "TEST", URL: server.URL + "/p/A1"},
+		{ID: "B2", Platform: "TEST", URL: server.URL + "/p/B2"},
+	}))
+	close(results)
+	close(leakedTraceHeaders)
+	require.Empty(t, leakedTraceHeaders)
+
+	byProduct := make(map[string]*Result)
+	for result := range results {
+		byProduct[result.OriginalProductID] = result
+	}
+
+	redirected := byProduct["A1"]
+	require.True(t, redirected.Success)
+	require.Equal(t, "B2", redirected.ProductID)
+	require.Equal(t, server.URL+"/p/B2?ref=moved", redirected.FinalURL)
+	require.Len(t, redirected.RedirectChain, 3)
+	require.Equal(t, server.URL+"/p/A1", redirected.RedirectChain[0].URL)
+	require.Equal(t, http.StatusMovedPermanently, redirected.RedirectChain[0].StatusCode)
+	require.Equal(t, "/go/A1", redirected.RedirectChain[0].Location)
+	require.Equal(t, http.StatusFound, redirected.RedirectChain[1].StatusCode)
+	require.Equal(t, "/p/B2?ref=moved", redirected.RedirectChain[1].Location)
+	require.Equal(t, server.URL+"/p/B2?ref=moved", redirected.RedirectChain[2].URL)
+	require.Equal(t, http.StatusOK, redirected.RedirectChain[2].StatusCode)
+	require.Empty(t, redirected.RedirectChain[2].Location)
+	for _, hop := range redirected.RedirectChain {
+		require.False(t, hop.StartedAt.Before(started))
+		require.GreaterOrEqual(t, hop.Duration, time.Duration(0))
+	}
+
+	direct := byProduct["B2"]
+	require.True(t, direct.Success)
+	require.Equal(t, "B2", direct.ProductID)
+	require.Nil(t, direct.RedirectChain)
+}
+
+func TestRedirectRecordingTransportRecordsFailedRoundTrips(t *testing.T) {
+	t.Parallel()
+
+	tracker := newRedirectTracker()
+	fixed := time.Unix(1_700_000_000, 0)
+	tracker.now = func() time.Time { return fixed }
+	transport := newRedirectRecordingTransport(roundTripFunc(func(request *http.Request) (*http.Response, error) {
+		if request.Header.Get(redirectTraceHeader) != "" {
+			return nil, errors.New("trace header leaked")
+		}
+		return nil, errors.New("connection refused")
+	}), tracker)
+
+	untraced, err := http.NewRequest(http.MethodGet, "http://shop.test/p/1", nil)
+	require.NoError(t, err)
+	_, err = transport.RoundTrip(untraced)
+	require.ErrorContains(t, err, "connection refused")
+	require.Empty(t, tracker.chains)
+
+	traced := untraced.Clone(context.Background())
+	traced.Header.Set(redirectTraceHeader, "7")
+	_, err = transport.RoundTrip(traced)
+	require.ErrorContains(t, err, "connection refused")
+	require.Equal(t, "7", traced.Header.Get(redirectTraceHeader))
+
+	ctx := colly.NewContext()
+	ctx.Put(ctxRedirectTraceKey, "7")
+	tracker.attach(&colly.Response{Ctx: ctx})
+	require.Equal(t, []RedirectHop{{URL: "http://shop.test/p/1", StartedAt: fixed, Error: "connection refused"}}, RedirectChainFromContext(ctx))
+	require.Empty(t, tracker.chains)
+
+	tracker.attach(&colly.Response{Ctx: ctx})
+	require.Len(t, RedirectChainFromContext(ctx), 1)
+	tracker.attach(nil)
+	require.Nil(t, RedirectChainFromContext(nil))
+	hops, proxyURL := tracker.take("")
+	require.Nil(t, hops)
+	require.Empty(t, proxyURL)
+}
+
+func TestRedirectRecordingTransportReportsTheProxyOfTheLastHop(t *testing.T) {
+	t.Parallel()
+
+	tracker := newRedirectTracker()
+	proxies := []string{"http://proxy-one.test:8080", "http://proxy-two.test:8080"}
+	transport := newRedirectRecordingTransport(roundTripFunc(func(request *http.Request) (*http.Response, error) {
+		recordProxyURL(request, proxies[0])
+		proxies = proxies[1:]
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: request}, nil
+	}), tracker)
+
+	for range 2 {
+		req, err := http.NewRequest(http.MethodGet, "http://shop.test/p/1", nil)
+		require.NoError(t, err)
+		req.Header.Set(redirectTraceHeader, "9")
+		ctx := req.Context()
+		resp, err := transport.RoundTrip(req)
+		require.NoError(t, err)
+		require.Same(t, req, resp.Request)
+		require.Equal(t, ctx, req.Context())
+	}
+
+	ctx := colly.NewContext()
+	ctx.Put(ctxRedirectTraceKey, "9")
+	request := &colly.Request{Ctx: ctx}
+	tracker.attach(&colly.Response{Ctx: ctx, Request: request})
+	require.Equal(t, "http://proxy-two.test:8080", request.ProxyURL)
+	require.Len(t, RedirectChainFromContext(ctx), 2)
+	require.Empty(t, tracker.proxies)
+}
+
+func TestProductURLPatternsInferRedirect(t *testing.T) {
+	t.Parallel()
+
+	patterns, err := CompileProductURLPatterns([]string{`/dp/(?P<id>[A-Z0-9]{4})`, `[?&]sku=(\w*)`})
+	require.NoError(t, err)
+
+	require.Equal(t, "AB12", patterns.ProductID("https://shop.test/x/dp/AB12/ref"))
+	require.Equal(t, "s9", patterns.ProductID("https://shop.test/item?sku=s9"))
+	require.Empty(t, patterns.ProductID("https://shop.test/item?sku="))
+	require.Empty(t, patterns.ProductID(" "))
+
+	redirected, productID := patterns.InferRedirect("AB12", "https://shop.test/dp/AB12", "https://shop.test/dp/CD34", "")
+	require.True(t, redirected)
+	require.Equal(t, "CD34", productID)
+
+	redirected, productID = patterns.InferRedirect("ab12", "", "https://shop.test/dp/CD34", "https://shop.test/dp/AB12")
+	require.False(t, redirected)
+	require.Empty(t, productID)
+
+	redirected, productID = patterns.InferRedirect("", "https://shop.test/dp/AB12", "https://shop.test/home", "https://shop.test/item?sku=EF56")
+	require.True(t, redirected)
+	require.Equal(t, "EF56", productID)
+
+	redirected, _ = patterns.InferRedirect("", "https://shop.test/home", "https://shop.test/dp/CD34", "")
+	require.False(t, redirected)
+	redirected, _ = patterns.InferRedirect("AB12", "", "https://shop.test/home", "")
+	require.False(t, redirected)
+
+	redirected, _ = ProductURLPatterns{}.InferRedirect("AB12", "", "https://shop.test/dp/CD34", "")
+	require.False(t, redirected)
+
+	_, err = CompileProductURLPatterns([]string{`/dp/(`})
+	require.ErrorContains(t, err, "product id pattern \"/dp/(\"")
+	_, err = CompileProductURLPatterns([]string{`/dp/\w+`})
+	require.ErrorContains(t, err, "has no capture group")
+
+	err = PlatformConfig{AllowedDomains: []string{"shop.test"}, ProductIDPatterns: []string{`/dp/`}}.Validate()
+	require.ErrorContains(t, err, "has no capture group")
+}
+
+func TestInferRedirectFallsBackToProductIDPatterns(t *testing.T) {
+	t.Parallel()
+
+	processor := &responseProcessor{
+		platformHooks:    noopPlatformHooks{},
+		redirectPatterns: PlatformConfig{ProductIDPatterns: []string{`/p/(\w+)`}}.productIDPatterns(),
+		logger:           noopLogger{},
+	}
+	ctx := colly.NewContext()
+	processor.inferRedirect(unknownProductID, "http://shop.test/p/OLD", "http://shop.test/p/NEW", "", ctx)
+	require.Equal(t, "NEW", ctx.Get(ctxRedirectedProductKey))
+
+	hooked := &responseProcessor{
+		platformHooks:    &redirectingPlatformHooks{redirected: true, redirectedID: "HOOK"},
+		redirectPatterns: processor.redirectPatterns,
+		logger:           noopLogger{},
+	}
+	hookedCtx := colly.NewContext()
+	hooked.inferRedirect("OLD", "http://shop.test/p/OLD", "http://shop.test/p/NEW", "", hookedCtx)
+	require.Equal(t, "HOOK", hookedCtx.Get(ctxRedirectedProductKey))
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/gocolly/colly/v2"
+	"github.com/tyemirov/utils/crawler/structured"
 )
 
 const detailIncompleteMessage = "detail page content missing"
@@ -26,6 +27,7 @@ type responseProcessor struct {
 	platformConfig   PlatformConfig
 	ruleEvaluator    RuleEvaluator
 	platformHooks    PlatformHooks
+	redirectPatterns ProductURLPatterns
 	retryHandler     RetryHandler
 	proxyTracker     proxyHealth
 	filePersister    FilePersister
@@ -47,17 +49,18 @@ func newResponseProcessor(
 	logger Logger,
 ) ResponseProcessor {
 	return &responseProcessor{
-		scraperConfig:  cfg.Scraper,
-		platformConfig: cfg.Platform,
-		ruleEvaluator:  cfg.RuleEvaluator,
-		platformHooks:  ensurePlatformHooks(cfg.PlatformHooks),
-		retryHandler:   retryHandler,
-		proxyTracker:   proxyTracker,
-		filePersister:  filePersister,
-		results:        results,
-		platformID:     cfg.PlatformID,
-		runFolder:      strings.TrimSpace(cfg.RunFolder),
-		logger:         logger,
+		scraperConfig:    cfg.Scraper,
+		platformConfig:   cfg.Platform,
+		ruleEvaluator:    cfg.RuleEvaluator,
+		platformHooks:    ensurePlatformHooks(cfg.PlatformHooks),
+		redirectPatterns: cfg.Platform.productIDPatterns(),
+		retryHandler:     retryHandler,
+		proxyTracker:     proxyTracker,
+		filePersister:    filePersister,
+		results:          results,
+		platformID:       cfg.PlatformID,
+		runFolder:        strings.TrimSpace(cfg.RunFolder),
+		logger:           logger,
 	}
 }
 
@@ -73,12 +76,27 @@ func (processor *responseProcessor) handleResponse(resp *colly.Response) {
 	productID := getProductIDFromContext(resp)
 	fileExtension := filepath.Ext(resp.FileName())
 
+	if limit := processor.scraperConfig.maxBodySize(); len(resp.Body) > limit {
+		err := bodyTooLargeError(limit)
+		processor.logger.Error("Response for ProductID %s exceeds %d bytes: %s", productID, limit, resp.Request.URL)
+		resp.Ctx.Put(ctxProductErrorKey, err)
+		processor.SendFinalResult(resp, false, err.Error())
+		return
+	}
+
 	for _, handler := range processor.responseHandlers {
 		if handler.HandleBinaryResponse(resp, productID, fileExtension) {
 			return
 		}
 	}
 
+	if resp.Headers != nil {
+		if decoded, sourceCharset := decodeToUTF8(resp.Body, resp.Headers.Get("Content-Type")); sourceCharset != "" {
+			processor.logger.Debug("Transcoded ProductID %s from %s to UTF-8", productID, sourceCharset)
+			resp.Body = decoded
+		}
+	}
+
 	document, _ := goquery.NewDocumentFromReader(bytes.NewReader(resp.Body))
 
 	pageTitleText := extractDocumentTitle(document)
@@ -160,6 +178,9 @@ func (processor *responseProcessor) handleResponse(resp *colly.Response) {
 		processor.recordProxySuccess(resp)
 	}
 
+	if processor.scraperConfig.ExtractStructuredData {
+		structured.Store(resp.Ctx, structured.Extract(document))
+	}
 	for _, handler := range processor.responseHandlers {
 		handler.BeforeEvaluation(resp, document)
 	}
@@ -237,10 +258,11 @@ func (processor *responseProcessor) retryByDecision(resp *colly.Response, decisi
 }
 
 func (processor *responseProcessor) alternativeProxyRetryCount() int {
-	if len(processor.scraperConfig.ProxyList) <= 1 {
+	proxyPoolSize := processor.scraperConfig.proxyPoolSize()
+	if proxyPoolSize <= 1 {
 		return 0
 	}
-	return len(processor.scraperConfig.ProxyList) - 1
+	return proxyPoolSize - 1
 }
 
 func (processor *responseProcessor) recordCriticalProxyFailure(resp *colly.Response) {
@@ -260,6 +282,13 @@ func responseProxyURL(resp *colly.Response) string {
 
 func (processor *responseProcessor) inferRedirect(productID, originalURL, finalURL, canonicalURL string, ctx *colly.Context) {
 	redirected, redirectedProductID := processor.platformHooks.InferRedirect(productID, originalURL, finalURL, canonicalURL)
+	if !redirected {
+		expectedProductID := productID
+		if expectedProductID == unknownProductID {
+			expectedProductID = ""
+		}
+		redirected, redirectedProductID = processor.redirectPatterns.InferRedirect(expectedProductID, originalURL, finalURL, canonicalURL)
+	}
 	if redirected {
 		ctx.Put(ctxRedirectedKey, true)
 		if redirectedProductID != "" {
@@ -272,6 +301,9 @@ func (processor *responseProcessor) inferRedirect(productID, originalURL, finalU
 }
 
 func (processor *responseProcessor) SendFinalResult(resp *colly.Response, success bool, errorText string) {
+	if !success && resp != nil && productBudgetExhausted(resp.Ctx) {
+		errorText = productTimeoutText(resp.Ctx, errorText)
+	}
 	result := processor.buildResult(resp, success, errorText)
 	processor.emitResult(resp, result)
 }
@@ -343,6 +375,10 @@ func (processor *responseProcessor) buildResult(resp *colly.Response, success bo
 	}
 	finalURL := ctx.Get(ctxFinalURLKey)
 	canonicalURL := ctx.Get(ctxCanonicalURLKey)
+	var redirectChain []RedirectHop
+	if hops := RedirectChainFromContext(ctx); len(hops) > 1 {
+		redirectChain = hops
+	}
 	proxyURL := ""
 	if resp.Request != nil {
 		proxyURL = resp.Request.ProxyURL
@@ -366,6 +402,7 @@ func (processor *responseProcessor) buildResult(resp *colly.Response, success bo
 		OriginalURL:             originalURL,
 		FinalURL:                finalURL,
 		CanonicalURL:            canonicalURL,
+		RedirectChain:           redirectChain,
 		ProxyURL:                strings.TrimSpace(proxyURL),
 		ProductURL:              productURL,
 		ProductTitle:            productTitle,
@@ -0,0 +1,49 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	defaultMaxBodySize = 10 << 20
+	// fallbackCharset is what charset.DetermineEncoding reports when neither
+	// a byte order mark, the Content-Type nor a <meta> tag declares one.
+	fallbackCharset = "windows-1252"
+)
+
+// ErrBodyTooLarge marks a product whose page exceeded ScraperConfig.MaxBodySize.
+// Such products fail without retry.
+var ErrBodyTooLarge = errors.New("crawler: response body exceeds size limit")
+
+func bodyTooLargeError(limit int) error {
+	return fmt.Errorf("%w of %d bytes", ErrBodyTooLarge, limit)
+}
+
+// decodeToUTF8 transcodes a textual body to UTF-8 using its byte order mark
+// or <meta> charset declaration, falling back to windows-1252 for undeclared
+// bodies that are not valid UTF-8, as browsers do. It returns the charset it
+// decoded from, or "" when body is left as is. Bodies whose Content-Type
+// declares a charset were already transcoded by colly.
+func decodeToUTF8(body []byte, contentType string) ([]byte, string) {
+	if len(body) == 0 || strings.Contains(strings.ToLower(contentType), "charset") {
+		return body, ""
+	}
+	switch media := mediaType(contentType); {
+	case media == "", strings.HasPrefix(media, "text/"), strings.Contains(media, "html"), strings.Contains(media, "xml"):
+	default:
+		return body, ""
+	}
+	encoding, name, certain := charset.DetermineEncoding(body, contentType)
+	if name == "utf-8" || !certain && name == fallbackCharset && utf8.Valid(body) {
+		return body, ""
+	}
+	// The WHATWG decoders replace malformed input with U+FFFD instead of
+	// failing, so decoding cannot return an error.
+	decoded, _ := encoding.NewDecoder().Bytes(body)
+	return decoded, name
+}
@@ -0,0 +1,97 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+)
+
+func encodeText(t *testing.T, encoder *encoding.Encoder, text string) []byte {
+	t.Helper()
+	encoded, err := encoder.Bytes([]byte(text))
+	require.NoError(t, err)
+	return encoded
+}
+
+func TestDecodeToUTF8(t *testing.T) {
+	cyrillic := "<html><head><meta charset=\"windows-1251\"><title>Привет</title></head></html>"
+	japaneseText := "<html><head><meta http-equiv=\"Content-Type\" content=\"text/html; charset=Shift_JIS\"><title>商品</title></head></html>"
+	testCases := []struct {
+		name        string
+		body        []byte
+		contentType string
+		want        string
+		charset     string
+	}{
+		{name: "meta charset", body: encodeText(t, charmap.Windows1251.NewEncoder(), cyrillic), contentType: "text/html", want: cyrillic, charset: "windows-1251"},
+		{name: "http-equiv charset", body: encodeText(t, japanese.ShiftJIS.NewEncoder(), japaneseText), want: japaneseText, charset: "shift_jis"},
+		{name: "undeclared legacy bytes", body: encodeText(t, charmap.Windows1252.NewEncoder(), "<title>Café</title>"), contentType: "text/html", want: "<title>Café</title>", charset: "windows-1252"},
+		{name: "undeclared utf-8", body: []byte("<title>Café</title>"), contentType: "text/html", want: "<title>Café</title>"},
+		{name: "transcoded by colly", body: []byte("<title>Café</title>"), contentType: "text/html; charset=Shift_JIS", want: "<title>Café</title>"},
+		{name: "malformed shift_jis", body: []byte("<meta charset=\"Shift_JIS\"><title>\x81</title>"), contentType: "text/html", want: "<meta charset=\"Shift_JIS\"><title>\ufffd</title>", charset: "shift_jis"},
+		{name: "binary", body: []byte{0xff, 0xd8, 0xff}, contentType: "image/jpeg", want: "\xff\xd8\xff"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			decoded, sourceCharset := decodeToUTF8(testCase.body, testCase.contentType)
+			require.Equal(t, testCase.want, string(decoded))
+			require.Equal(t, testCase.charset, sourceCharset)
+		})
+	}
+}
+
+func TestServiceTranscodesAndLimitsProductPages(t *testing.T) {
+	t.Parallel()
+
+	var oversizedHits atomic.Int32
+	shiftJIS := encodeText(t, japanese.ShiftJIS.NewEncoder(), `<html><head><meta charset="Shift_JIS"><title>日本の商品</title></head><body></body></html>`)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "text/html")
+		switch request.URL.Path {
+		case "/p/sjis":
+			_, _ = writer.Write(shiftJIS)
+		case "/p/huge":
+			oversizedHits.Add(1)
+			_, _ = writer.Write([]byte("<html><head><title>Huge</title></head><body>" + strings.Repeat("x", 4096) + "</body></html>"))
+		}
+	}))
+	t.Cleanup(server.Close)
+	parsed, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	results := make(chan *Result, 2)
+	service, err := NewService(Config{
+		PlatformID:    "TEST",
+		Scraper:       ScraperConfig{MaxDepth: 1, Parallelism: 2, RetryCount: 2, MaxBodySize: 1024},
+		Platform:      PlatformConfig{AllowedDomains: []string{parsed.Hostname()}},
+		RuleEvaluator: fixedRuleEvaluator{},
+		Logger:        noopLogger{},
+	}, results)
+	require.NoError(t, err)
+	require.NoError(t, service.Run(context.Background(), []Product{
+		{ID: "sjis", Platform: "TEST", URL: server.URL + "/p/sjis"},
+		{ID: "huge", Platform: "TEST", URL: server.URL + "/p/huge"},
+	}))
+	close(results)
+
+	byProduct := make(map[string]*Result)
+	for result := range results {
+		byProduct[result.ProductID] = result
+	}
+	require.True(t, byProduct["sjis"].Success)
+	require.Equal(t, "日本の商品", byProduct["sjis"].ProductTitle)
+	require.False(t, byProduct["huge"].Success)
+	require.Equal(t, "crawler: response body exceeds size limit of 1024 bytes", byProduct["huge"].ErrorMessage)
+	require.EqualValues(t, 1, oversizedHits.Load())
+
+	require.ErrorContains(t, ScraperConfig{Parallelism: 1, MaxBodySize: -1}.Validate(), "max body size must be non-negative")
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/PuerkitoBio/goquery"
 	"github.com/gocolly/colly/v2"
 	"github.com/stretchr/testify/require"
+	"github.com/tyemirov/utils/crawler/structured"
 )
 
 func TestResponseProcessorSaveFileSkipsWhenPersisterNil(t *testing.T) {
@@ -631,6 +632,57 @@ func TestHandleResponseCallsBeforeAndAfterEvaluationHandlers(t *testing.T) {
 	require.Equal(t, "EVAL-PRODUCT-001", firstHandler.afterEvalResults[0].ProductID)
 }
 
+type structuredDataCapturingHandler struct {
+	NoopResponseHandler
+	data  *structured.Data
+	found bool
+}
+
+func (handler *structuredDataCapturingHandler) BeforeEvaluation(resp *colly.Response, _ *goquery.Document) {
+	handler.data, handler.found = structured.FromContext(resp.Ctx)
+}
+
+func TestHandleResponseExposesStructuredDataToHandlers(t *testing.T) {
+	t.Parallel()
+
+	for _, enabled := range []bool{true, false} {
+		results := make(chan *Result, 1)
+		handler := &structuredDataCapturingHandler{}
+		processor := &responseProcessor{
+			scraperConfig:    ScraperConfig{ExtractStructuredData: enabled},
+			platformID:       "TEST",
+			platformHooks:    noopPlatformHooks{},
+			retryHandler:     newRetryHandler(ScraperConfig{RetryCount: 0}, noopLogger{}),
+			ruleEvaluator:    &countingRuleEvaluator{configured: 1},
+			results:          results,
+			logger:           noopLogger{},
+			responseHandlers: []ResponseHandler{handler},
+		}
+
+		response := newTestResponse("STRUCTURED-001")
+		response.Body = []byte(`<html><head><title>Kettle</title>
+<script type="application/ld+json">{"@type": "Product", "name": "Kettle", "offers": {"price": "24.99"}}</script>
+</head><body></body></html>`)
+		response.StatusCode = http.StatusOK
+		headers := http.Header{}
+		response.Headers = &headers
+		pageURL, parseErr := url.Parse("https://example.com/product/STRUCTURED-001")
+		require.NoError(t, parseErr)
+		response.Request.URL = pageURL
+
+		processor.handleResponse(response)
+		<-results
+
+		require.Equal(t, enabled, handler.found)
+		if enabled {
+			product, ok := handler.data.FirstProduct()
+			require.True(t, ok)
+			require.Equal(t, "Kettle", product.Name)
+			require.Equal(t, "24.99", product.Offers[0].Price)
+		}
+	}
+}
+
 func TestHandleResponseBinaryHandlerShortCircuitsWhenReturningTrue(t *testing.T) {
 	t.Parallel()
 
@@ -6,22 +6,24 @@ import (
 
 // Result represents the normalized outcome of crawling a single product page.
 type Result struct {
-	ProductID               string       `json:"product_id" csv:"ID"`
-	OriginalProductID       string       `json:"original_product_id,omitempty" csv:"OriginalID"`
-	OriginalURL             string       `json:"original_url,omitempty" csv:""`
-	FinalURL                string       `json:"final_url,omitempty" csv:""`
-	CanonicalURL            string       `json:"canonical_url,omitempty" csv:""`
-	ProxyURL                string       `json:"proxy_url,omitempty" csv:"ProxyURL"`
-	ProductURL              string       `json:"product_url" csv:"URL"`
-	ProductTitle            string       `json:"product_title,omitempty" csv:"Title"`
-	ProductPlatform         string       `json:"product_platform"`
-	Success                 bool         `json:"success"`
-	ErrorMessage            string       `json:"error_message,omitempty" csv:"ErrorMessage"`
-	HTTPStatusCode          int          `json:"http_status_code,omitempty" csv:"HTTPStatusCode"`
-	Progress                int          `json:"progress,omitempty"`
-	RuleResults             []RuleResult `json:"results,omitempty"`
-	ConfiguredVerifierCount int          `json:"-" csv:"-"`
-	ScoreOverride           *int         `json:"-" csv:"-"`
+	ProductID               string         `json:"product_id" csv:"ID"`
+	OriginalProductID       string         `json:"original_product_id,omitempty" csv:"OriginalID"`
+	OriginalURL             string         `json:"original_url,omitempty" csv:""`
+	FinalURL                string         `json:"final_url,omitempty" csv:""`
+	CanonicalURL            string         `json:"canonical_url,omitempty" csv:""`
+	RedirectChain           []RedirectHop  `json:"redirect_chain,omitempty" csv:"-"`
+	ProxyURL                string         `json:"proxy_url,omitempty" csv:"ProxyURL"`
+	ProductURL              string         `json:"product_url" csv:"URL"`
+	ProductTitle            string         `json:"product_title,omitempty" csv:"Title"`
+	ProductPlatform         string         `json:"product_platform"`
+	Success                 bool           `json:"success"`
+	ErrorMessage            string         `json:"error_message,omitempty" csv:"ErrorMessage"`
+	HTTPStatusCode          int            `json:"http_status_code,omitempty" csv:"HTTPStatusCode"`
+	Progress                int            `json:"progress,omitempty"`
+	RuleResults             []RuleResult   `json:"results,omitempty"`
+	Images                  []ImageSummary `json:"images,omitempty" csv:"-"`
+	ConfiguredVerifierCount int            `json:"-" csv:"-"`
+	ScoreOverride           *int           `json:"-" csv:"-"`
 }
 
 // IsNotFound reports whether the HTTP status code represents a missing page.
@@ -0,0 +1,179 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// StoredResult is what a ResultStore keeps for one product. Latest is the
+// most recent crawl and decides crawl outcome events; LastSuccess is the most
+// recent successful crawl and is what page content and rules are compared
+// with, so an outage does not hide regressions. LastSuccess is nil until the
+// product has been crawled successfully.
+type StoredResult struct {
+	Latest      *Result
+	LastSuccess *Result
+}
+
+// ResultStore keeps a StoredResult per ProductID so later crawls can be
+// compared with it.
+type ResultStore interface {
+	LoadResult(ctx context.Context, productID string) (StoredResult, bool, error)
+	SaveResult(ctx context.Context, productID string, stored StoredResult) error
+}
+
+// MemoryResultStore is an in-process ResultStore. It is safe for concurrent use.
+type MemoryResultStore struct {
+	mu      sync.RWMutex
+	results map[string]StoredResult
+}
+
+// NewMemoryResultStore constructs an empty MemoryResultStore.
+func NewMemoryResultStore() *MemoryResultStore {
+	return &MemoryResultStore{results: make(map[string]StoredResult)}
+}
+
+// LoadResult returns a copy of the stored results for productID.
+func (store *MemoryResultStore) LoadResult(_ context.Context, productID string) (StoredResult, bool, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	stored, found := store.results[productID]
+	if !found {
+		return StoredResult{}, false, nil
+	}
+	return cloneStoredResult(stored), true, nil
+}
+
+// SaveResult stores a copy of stored, replacing any earlier one.
+func (store *MemoryResultStore) SaveResult(_ context.Context, productID string, stored StoredResult) error {
+	if stored.Latest == nil {
+		return errors.New("crawler: result is required")
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.results[productID] = cloneStoredResult(stored)
+	return nil
+}
+
+// DiffAgainstStore diffs current against the stored results for its ProductID
+// and saves it. Crawl outcome and status events compare with the latest
+// crawl, so a product that keeps failing reports ChangeCrawlFailed once and
+// ChangeCrawlRecovered when it comes back. Page content and rules compare
+// with the last successful crawl, so changes made during an outage are
+// reported on recovery.
+func DiffAgainstStore(ctx context.Context, store ResultStore, current *Result) ([]ChangeEvent, error) {
+	if store == nil {
+		return nil, errors.New("crawler: result store is required")
+	}
+	if current == nil {
+		return nil, errors.New("crawler: result is required")
+	}
+	stored, found, err := store.LoadResult(ctx, current.ProductID)
+	if err != nil {
+		return nil, fmt.Errorf("crawler: load previous result for %s: %w", current.ProductID, err)
+	}
+	if !found {
+		stored = StoredResult{}
+	}
+	events := diffStoredResult(stored, current)
+	next := StoredResult{Latest: current, LastSuccess: stored.LastSuccess}
+	if current.Success {
+		next.LastSuccess = current
+	}
+	if err := store.SaveResult(ctx, current.ProductID, next); err != nil {
+		return events, fmt.Errorf("crawler: save result for %s: %w", current.ProductID, err)
+	}
+	return events, nil
+}
+
+// diffStoredResult diffs current against the latest crawl and, when current
+// recovers from a failure, adds the content changes since the last success.
+func diffStoredResult(stored StoredResult, current *Result) []ChangeEvent {
+	events := DiffResults(stored.Latest, current)
+	if stored.Latest == nil || stored.Latest.Success || !current.Success || stored.LastSuccess == nil {
+		return events
+	}
+	for _, event := range DiffResults(stored.LastSuccess, current) {
+		if event.Kind != ChangeHTTPStatus {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// ChangeHandler receives the change events for one product.
+type ChangeHandler func(ctx context.Context, events []ChangeEvent) error
+
+// ChangeDetector is a ResultSink that runs DiffAgainstStore for every result
+// and hands non-empty event lists to a ChangeHandler, so change detection can
+// sit next to other sinks in DrainResults.
+type ChangeDetector struct {
+	store    ResultStore
+	onChange ChangeHandler
+}
+
+// NewChangeDetector constructs a ChangeDetector over store.
+func NewChangeDetector(store ResultStore, onChange ChangeHandler) (*ChangeDetector, error) {
+	if store == nil {
+		return nil, errors.New("crawler: result store is required")
+	}
+	if onChange == nil {
+		return nil, errors.New("crawler: change handler is required")
+	}
+	return &ChangeDetector{store: store, onChange: onChange}, nil
+}
+
+// WriteResults diffs each result in order. Every result is processed even
+// when an earlier one fails; the errors are joined.
+func (detector *ChangeDetector) WriteResults(ctx context.Context, results []*Result) error {
+	var errs []error
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		events, err := DiffAgainstStore(ctx, detector.store, result)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if len(events) == 0 {
+			continue
+		}
+		if err := detector.onChange(ctx, events); err != nil {
+			errs = append(errs, fmt.Errorf("crawler: handle changes for %s: %w", result.ProductID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close implements ResultSink; the store is owned by the caller.
+func (detector *ChangeDetector) Close() error {
+	return nil
+}
+
+func cloneStoredResult(stored StoredResult) StoredResult {
+	clone := StoredResult{Latest: cloneResult(stored.Latest)}
+	if stored.LastSuccess != nil {
+		clone.LastSuccess = cloneResult(stored.LastSuccess)
+	}
+	return clone
+}
+
+func cloneResult(result *Result) *Result {
+	clone := *result
+	if result.ScoreOverride != nil {
+		score := *result.ScoreOverride
+		clone.ScoreOverride = &score
+	}
+	clone.RedirectChain = append([]RedirectHop(nil), result.RedirectChain...)
+	clone.Images = append([]ImageSummary(nil), result.Images...)
+	if result.RuleResults != nil {
+		clone.RuleResults = make([]RuleResult, len(result.RuleResults))
+		for index, rule := range result.RuleResults {
+			rule.VerificationResults = append([]VerificationResult(nil), rule.VerificationResults...)
+			clone.RuleResults[index] = rule
+		}
+	}
+	return &clone
+}
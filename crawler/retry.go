@@ -23,6 +23,7 @@ type retryHandler struct {
 	maxRetries    int
 	logger        Logger
 	proxyPoolSize int
+	proxySource   ProxySource
 	sleepFn       func(time.Duration)
 }
 
@@ -31,6 +32,7 @@ func newRetryHandler(scraper ScraperConfig, logger Logger) RetryHandler {
 		maxRetries:    scraper.RetryCount,
 		logger:        logger,
 		proxyPoolSize: len(scraper.ProxyList),
+		proxySource:   scraper.ProxySource,
 		sleepFn:       time.Sleep,
 	}
 }
@@ -54,8 +56,16 @@ func (handler *retryHandler) Retry(response *colly.Response, options RetryOption
 	}
 
 	nextAttempt := attempt + 1
+	var delay time.Duration
 	if handler.shouldDelay(nextAttempt, options) {
-		handler.sleep(handler.backoffDuration(attempt))
+		delay = handler.backoffDuration(attempt)
+	}
+	if !productBudgetAllows(response.Ctx, delay) {
+		handler.logger.Warning("Time budget exhausted for URL: %s; not retrying", response.Request.URL.String())
+		return false
+	}
+	if delay > 0 {
+		handler.sleep(delay)
 	}
 
 	response.Ctx.Put(retryCountKey, nextAttempt)
@@ -88,10 +98,19 @@ func (handler *retryHandler) shouldDelay(nextAttempt int, options RetryOptions)
 	if options.SkipDelay {
 		return false
 	}
-	if handler.proxyPoolSize <= 1 {
+	proxyPoolSize := handler.currentProxyPoolSize()
+	if proxyPoolSize <= 1 {
 		return true
 	}
-	return nextAttempt%handler.proxyPoolSize == 0
+	return nextAttempt%proxyPoolSize == 0
+}
+
+func (handler *retryHandler) currentProxyPoolSize() int {
+	if handler.proxySource == nil {
+		return handler.proxyPoolSize
+	}
+	proxies, _ := cachedProxies(handler.proxySource)
+	return countProxies(proxies)
 }
 
 func (handler *retryHandler) backoffDuration(attempt int) time.Duration {
@@ -0,0 +1,155 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+	"github.com/temoto/robotstxt"
+)
+
+// ErrDisallowedByRobots is reported for products whose URL the host's
+// robots.txt disallows when ScraperConfig.RespectRobotsTxt is set.
+var ErrDisallowedByRobots = errors.New("crawler: disallowed by robots.txt")
+
+const (
+	robotsFetchTimeout = 30 * time.Second
+	robotsMaxBodyBytes = 512 << 10
+)
+
+// robotsPolicy fetches robots.txt once per scheme and host and answers whether
+// a product URL may be crawled. Hosts whose robots.txt could not be fetched
+// are not cached, so the next product for that host tries again.
+type robotsPolicy struct {
+	client    *http.Client
+	userAgent string
+	logger    Logger
+
+	// limitHost, when set, is called once per cached robots.txt, before any
+	// product of that host is allowed, with the host's Crawl-delay.
+	limitHost func(host string, crawlDelay time.Duration) error
+
+	mu    sync.Mutex
+	hosts map[string]*robotsHost
+}
+
+type robotsHost struct {
+	ready      chan struct{}
+	data       *robotstxt.RobotsData
+	crawlDelay time.Duration
+	err        error
+}
+
+func newRobotsPolicy(client *http.Client, userAgent string, logger Logger) *robotsPolicy {
+	return &robotsPolicy{
+		client:    client,
+		userAgent: userAgent,
+		logger:    EnsureLogger(logger),
+		hosts:     make(map[string]*robotsHost),
+	}
+}
+
+// Allowed returns nil when rawURL may be crawled, ErrDisallowedByRobots when
+// robots.txt forbids it, and the fetch error when robots.txt could not be
+// read. A 4xx robots.txt allows everything and a 5xx forbids everything.
+func (policy *robotsPolicy) Allowed(ctx context.Context, rawURL string) error {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("crawler: parse product url: %w", err)
+	}
+	host, err := policy.host(ctx, target)
+	if err != nil {
+		return err
+	}
+	if !host.data.TestAgent(target.RequestURI(), policy.userAgent) {
+		return ErrDisallowedByRobots
+	}
+	return nil
+}
+
+func (policy *robotsPolicy) host(ctx context.Context, target *url.URL) (*robotsHost, error) {
+	origin := target.Scheme + "://" + target.Host
+	policy.mu.Lock()
+	host, found := policy.hosts[origin]
+	if !found {
+		host = &robotsHost{ready: make(chan struct{})}
+		policy.hosts[origin] = host
+	}
+	policy.mu.Unlock()
+
+	if !found {
+		host.data, host.err = policy.fetch(ctx, origin)
+		if host.err == nil {
+			host.crawlDelay = host.data.FindGroup(policy.userAgent).CrawlDelay
+			if host.crawlDelay > 0 {
+				policy.logger.Info("robots.txt for %s sets a crawl delay of %s", origin, host.crawlDelay)
+			}
+			if policy.limitHost != nil {
+				if err := policy.limitHost(target.Host, host.crawlDelay); err != nil {
+					policy.logger.Warning("Failed to limit requests to %s: %v", target.Host, err)
+				}
+			}
+		} else {
+			policy.mu.Lock()
+			delete(policy.hosts, origin)
+			policy.mu.Unlock()
+		}
+		close(host.ready)
+	}
+
+	select {
+	case <-host.ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if host.err != nil {
+		return nil, host.err
+	}
+	return host, nil
+}
+
+func (policy *robotsPolicy) fetch(ctx context.Context, origin string) (*robotstxt.RobotsData, error) {
+	fetchCtx, cancel := context.WithTimeout(ctx, robotsFetchTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(fetchCtx, http.MethodGet, origin+"/robots.txt", nil)
+	if err != nil {
+		return nil, fmt.Errorf("crawler: build robots.txt request for %s: %w", origin, err)
+	}
+	response, err := policy.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("crawler: fetch robots.txt for %s: %w", origin, err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(response.Body, robotsMaxBodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("crawler: read robots.txt for %s: %w", origin, err)
+	}
+	data, err := robotstxt.FromStatusAndBytes(response.StatusCode, body)
+	if err != nil {
+		return nil, fmt.Errorf("crawler: parse robots.txt for %s: %w", origin, err)
+	}
+	return data, nil
+}
+
+// robotsLimitRule is the colly limit for one host under ScraperConfig. A host
+// with a Crawl-delay gets one request at a time, spaced by the longer of its
+// Crawl-delay and RateLimit.
+func robotsLimitRule(scraper ScraperConfig, host string, crawlDelay time.Duration) *colly.LimitRule {
+	rule := collectorLimitRule(scraper)
+	rule.DomainGlob = ""
+	rule.DomainRegexp = "^" + regexp.QuoteMeta(host) + "$"
+	if crawlDelay > 0 {
+		rule.Parallelism = 1
+		if crawlDelay > rule.Delay {
+			rule.Delay, rule.RandomDelay = crawlDelay, 0
+		}
+	}
+	return rule
+}
@@ -0,0 +1,280 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceRespectsRobotsTxtAndCrawlDelay(t *testing.T) {
+	t.Parallel()
+
+	var robotsFetches atomic.Int32
+	var mu sync.Mutex
+	visits := make(map[string]time.Time)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == "/robots.txt" {
+			robotsFetches.Add(1)
+			_, _ = writer.Write([]byte("User-agent: *\nDisallow: /private\nCrawl-delay: 0.2\n\nUser-agent: auditbot\nDisallow: /\n"))
+			return
+		}
+		mu.Lock()
+		visits[request.URL.Path] = time.Now()
+		mu.Unlock()
+		writer.Header().Set("Content-Type", "text/html")
+		_, _ = writer.Write([]byte(`<html><head><title>Product</title></head><body></body></html>`))
+	}))
+	defer server.Close()
+	parsed, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	results := make(chan *Result, 3)
+	service, err := NewService(Config{
+		PlatformID:    "TEST",
+		Scraper:       ScraperConfig{MaxDepth: 1, Parallelism: 2, RespectRobotsTxt: true},
+		Platform:      PlatformConfig{AllowedDomains: []string{parsed.Hostname()}},
+		RuleEvaluator: fixedRuleEvaluator{},
+		Logger:        noopLogger{},
+	}, results)
+	require.NoError(t, err)
+
+	require.NoError(t, service.Run(context.Background(), []Product{
+		{ID: "1", Platform: "TEST", URL: server.URL + "/p/1"},
+		{ID: "2", Platform: "TEST", URL: server.URL + "/private/2"},
+		{ID: "3", Platform: "TEST", URL: server.URL + "/p/3"},
+	}))
+	close(results)
+
+	byProduct := make(map[string]*Result)
+	for result := range results {
+		byProduct[result.ProductID] = result
+	}
+	require.True(t, byProduct["1"].Success)
+	require.True(t, byProduct["3"].Success)
+	require.False(t, byProduct["2"].Success)
+	require.Equal(t, ErrDisallowedByRobots.Error(), byProduct["2"].ErrorMessage)
+	require.Equal(t, int32(1), robotsFetches.Load())
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.NotContains(t, visits, "/private/2")
+	gap := visits["/p/3"].Sub(visits["/p/1"])
+	if gap < 0 {
+		gap = -gap
+	}
+	require.GreaterOrEqual(t, gap, 150*time.Millisecond)
+}
+
+func TestRobotsPolicyHandlesStatusesAndAgents(t *testing.T) {
+	t.Parallel()
+
+	var status atomic.Int32
+	status.Store(http.StatusOK)
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		fetches.Add(1)
+		writer.WriteHeader(int(status.Load()))
+		_, _ = writer.Write([]byte("User-agent: *\nDisallow: /private\n\nUser-agent: auditbot\nDisallow: /\n"))
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	generic := newRobotsPolicy(server.Client(), "", nil)
+	require.NoError(t, generic.Allowed(ctx, server.URL+"/p/1?q=/private"))
+	require.ErrorIs(t, generic.Allowed(ctx, server.URL+"/private/1"), ErrDisallowedByRobots)
+
+	agent := newRobotsPolicy(server.Client(), "AuditBot/2.0", nil)
+	require.ErrorIs(t, agent.Allowed(ctx, server.URL+"/p/1"), ErrDisallowedByRobots)
+	require.Equal(t, int32(2), fetches.Load())
+
+	status.Store(http.StatusNotFound)
+	missing := newRobotsPolicy(server.Client(), "", nil)
+	require.NoError(t, missing.Allowed(ctx, server.URL+"/private/1"))
+
+	status.Store(http.StatusServiceUnavailable)
+	unavailable := newRobotsPolicy(server.Client(), "", nil)
+	require.ErrorIs(t, unavailable.Allowed(ctx, server.URL+"/p/1"), ErrDisallowedByRobots)
+
+	status.Store(http.StatusNotModified)
+	require.ErrorContains(t, newRobotsPolicy(server.Client(), "", nil).Allowed(ctx, server.URL+"/p/1"), "parse robots.txt")
+
+	require.ErrorContains(t, generic.Allowed(ctx, "://bad"), "parse product url")
+}
+
+func TestRobotsPolicyRetriesFailedFetches(t *testing.T) {
+	t.Parallel()
+
+	failures := 1
+	client := &http.Client{Transport: roundTripFunc(func(request *http.Request) (*http.Response, error) {
+		if failures > 0 {
+			failures--
+			return nil, errors.New("proxy refused")
+		}
+		return httptest.NewRecorder().Result(), nil
+	})}
+	policy := newRobotsPolicy(client, "", nil)
+	ctx := context.Background()
+
+	require.ErrorContains(t, policy.Allowed(ctx, "http://shop.test/p/1"), "fetch robots.txt for http://shop.test")
+	require.Empty(t, policy.hosts)
+	require.NoError(t, policy.Allowed(ctx, "http://shop.test/p/1"))
+	require.Len(t, policy.hosts, 1)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	pending := &robotsHost{ready: make(chan struct{})}
+	policy.hosts["http://slow.test"] = pending
+	require.ErrorIs(t, policy.Allowed(cancelled, "http://slow.test/p/1"), context.Canceled)
+}
+
+func TestRobotsPolicyReportsUnbuildableAndUnreadableRobots(t *testing.T) {
+	t.Parallel()
+
+	client := &http.Client{Transport: roundTripFunc(func(request *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(iotest.ErrReader(errors.New("connection reset"))),
+			Request:    request,
+		}, nil
+	})}
+	policy := newRobotsPolicy(client, "", nil)
+	ctx := context.Background()
+
+	require.ErrorContains(t, policy.Allowed(ctx, "/p/1"), "build robots.txt request for ://")
+	require.ErrorContains(t, policy.Allowed(ctx, "http://shop.test/p/1"), "read robots.txt for http://shop.test: connection reset")
+	require.Empty(t, policy.hosts)
+}
+
+func TestNewServiceReportsRobotsClientProxyFailures(t *testing.T) {
+	t.Parallel()
+
+	var loads atomic.Int32
+	_, err := NewService(Config{
+		PlatformID: "TEST",
+		Scraper: ScraperConfig{
+			Parallelism:      1,
+			RespectRobotsTxt: true,
+			ProxySource: ProxySourceFunc(func() ([]string, error) {
+				if loads.Add(1) > 1 {
+					return nil, errors.New("source down")
+				}
+				return []string{"http://proxy-one.test:8080"}, nil
+			}),
+		},
+		Platform:      PlatformConfig{AllowedDomains: []string{"shop.test"}},
+		RuleEvaluator: fixedRuleEvaluator{},
+	}, make(chan *Result, 1))
+	require.ErrorContains(t, err, "load proxies: source down")
+}
+
+func TestRobotsPolicyLimitsEachCachedHost(t *testing.T) {
+	t.Parallel()
+
+	client := &http.Client{Transport: roundTripFunc(func(request *http.Request) (*http.Response, error) {
+		body := "User-agent: *\nCrawl-delay: 2\n"
+		if request.URL.Host == "fast.test" {
+			body = "User-agent: *\nAllow: /\n"
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Request: request}, nil
+	})}
+	policy := newRobotsPolicy(client, "", nil)
+	limits := make(map[string]time.Duration)
+	policy.limitHost = func(host string, crawlDelay time.Duration) error {
+		limits[host] = crawlDelay
+		if host == "fast.test" {
+			return errors.New("bad rule")
+		}
+		return nil
+	}
+	ctx := context.Background()
+
+	require.NoError(t, policy.Allowed(ctx, "http://slow.test:8080/p/1"))
+	require.NoError(t, policy.Allowed(ctx, "http://slow.test:8080/p/2"))
+	require.NoError(t, policy.Allowed(ctx, "http://fast.test/p/1"))
+	require.Equal(t, map[string]time.Duration{"slow.test:8080": 2 * time.Second, "fast.test": 0}, limits)
+}
+
+func TestRobotsLimitRuleCombinesCrawlDelayAndRateLimit(t *testing.T) {
+	t.Parallel()
+
+	scraper := ScraperConfig{Parallelism: 4, RateLimit: time.Second}
+
+	rule := robotsLimitRule(scraper, "[::1]:8080", 0)
+	require.Equal(t, 4, rule.Parallelism)
+	require.Equal(t, time.Second, rule.Delay)
+	require.Equal(t, 500*time.Millisecond, rule.RandomDelay)
+	require.NoError(t, rule.Init())
+	require.True(t, rule.Match("[::1]:8080"))
+	require.False(t, rule.Match("[::1]:80800"))
+
+	rule = robotsLimitRule(scraper, "shop.test", 3*time.Second)
+	require.Equal(t, 1, rule.Parallelism)
+	require.Equal(t, 3*time.Second, rule.Delay)
+	require.Zero(t, rule.RandomDelay)
+
+	rule = robotsLimitRule(scraper, "shop.test", 100*time.Millisecond)
+	require.Equal(t, 1, rule.Parallelism)
+	require.Equal(t, time.Second, rule.Delay)
+	require.Equal(t, 500*time.Millisecond, rule.RandomDelay)
+}
+
+func TestServiceFetchesRobotsTxtOffTheDispatchLoop(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == "/robots.txt" {
+			<-release
+		}
+		writer.Header().Set("Content-Type", "text/html")
+		_, _ = writer.Write([]byte(`<html><head><title>Slow</title></head></html>`))
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "text/html")
+		_, _ = writer.Write([]byte(`<html><head><title>Fast</title></head></html>`))
+	}))
+	defer fast.Close()
+
+	results := make(chan *Result, 2)
+	service, err := NewService(Config{
+		PlatformID:    "TEST",
+		Scraper:       ScraperConfig{MaxDepth: 1, Parallelism: 2, RespectRobotsTxt: true},
+		Platform:      PlatformConfig{AllowedDomains: []string{"127.0.0.1"}},
+		RuleEvaluator: fixedRuleEvaluator{},
+		Logger:        noopLogger{},
+	}, results)
+	require.NoError(t, err)
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- service.Run(context.Background(), []Product{
+			{ID: "SLOW", Platform: "TEST", URL: slow.URL + "/p/1"},
+			{ID: "FAST", Platform: "TEST", URL: fast.URL + "/p/2"},
+		})
+	}()
+
+	select {
+	case result := <-results:
+		require.Equal(t, "FAST", result.ProductID)
+		require.True(t, result.Success)
+	case <-time.After(5 * time.Second):
+		t.Fatal("product on another host waited for a pending robots.txt")
+	}
+	close(release)
+	require.NoError(t, <-runErr)
+	result := <-results
+	require.Equal(t, "SLOW", result.ProductID)
+	require.True(t, result.Success)
+}
@@ -0,0 +1,402 @@
+package crawler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+	"golang.org/x/net/html"
+	"gopkg.in/yaml.v3"
+)
+
+// Selector types understood by DeclarativeVerification.SelectorType.
+const (
+	SelectorTypeCSS   = "css"
+	SelectorTypeXPath = "xpath"
+)
+
+// Extraction modes understood by DeclarativeVerification.Extract.
+const (
+	ExtractText      = "text"
+	ExtractAttribute = "attr"
+	ExtractCount     = "count"
+)
+
+// DeclarativeRuleSet is the file format read by DeclarativeRuleEvaluator. It is
+// written in YAML; JSON documents are accepted as well.
+type DeclarativeRuleSet struct {
+	Rules []DeclarativeRule `yaml:"rules"`
+}
+
+// DeclarativeRule groups verifications reported together as one RuleResult.
+type DeclarativeRule struct {
+	ID             string `yaml:"id"`
+	Description    string `yaml:"description"`
+	ReportingOrder int    `yaml:"reporting_order"`
+	// Verifications must all pass for the rule to pass.
+	Verifications []DeclarativeVerification `yaml:"verifications"`
+}
+
+// DeclarativeVerification selects elements, extracts a value from them and
+// asserts on it. Each verification counts once towards the configured
+// verifier count.
+type DeclarativeVerification struct {
+	ID          string `yaml:"id"`
+	Description string `yaml:"description"`
+	Selector    string `yaml:"selector"`
+	// SelectorType is "css" (default) or "xpath".
+	SelectorType string `yaml:"selector_type"`
+	// Extract is "text" (default), "attr" or "count". Text and attr read the
+	// first matching element.
+	Extract string `yaml:"extract"`
+	// Attribute names the attribute read when Extract is "attr".
+	Attribute      string               `yaml:"attribute"`
+	Assert         DeclarativeAssertion `yaml:"assert"`
+	ReportingOrder int                  `yaml:"reporting_order"`
+	// IncludeValue exposes the extracted value in reports.
+	IncludeValue bool `yaml:"include_value"`
+}
+
+// DeclarativeAssertion lists the checks applied to an extracted value. Every
+// configured check must hold. With no checks configured the selector must
+// match at least one element.
+type DeclarativeAssertion struct {
+	// Exists requires the selector to match (true) or not match (false).
+	Exists *bool `yaml:"exists"`
+	// Regex must match the extracted value.
+	Regex string `yaml:"regex"`
+	// MinLength is the minimum number of characters in the extracted value.
+	MinLength *int `yaml:"min_length"`
+	// Min and Max bound the first number found in the extracted value.
+	Min *float64 `yaml:"min"`
+	Max *float64 `yaml:"max"`
+}
+
+// DeclarativeRuleEvaluator is a RuleEvaluator driven by a DeclarativeRuleSet,
+// so checks can be added without writing Go.
+type DeclarativeRuleEvaluator struct {
+	rules         []compiledRule
+	verifierCount int
+}
+
+type compiledRule struct {
+	id             string
+	description    string
+	reportingOrder int
+	verifications  []compiledVerification
+}
+
+type compiledVerification struct {
+	id             string
+	description    string
+	reportingOrder int
+	includeValue   bool
+	cssMatcher     goquery.Matcher
+	xpathExpr      *xpath.Expr
+	extract        string
+	attribute      string
+	exists         *bool
+	pattern        *regexp.Regexp
+	minLength      *int
+	minimum        *float64
+	maximum        *float64
+}
+
+var numberPattern = regexp.MustCompile(`[-+]?\d[\d,]*(?:\.\d+)?`)
+
+// LoadDeclarativeRuleEvaluator reads a YAML or JSON rule file.
+func LoadDeclarativeRuleEvaluator(path string) (*DeclarativeRuleEvaluator, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("crawler: read rule file: %w", err)
+	}
+	return ParseDeclarativeRuleEvaluator(content)
+}
+
+// ParseDeclarativeRuleEvaluator decodes a YAML or JSON rule document. Unknown
+// keys are rejected so typos surface instead of silently disabling a check.
+func ParseDeclarativeRuleEvaluator(content []byte) (*DeclarativeRuleEvaluator, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	var ruleSet DeclarativeRuleSet
+	if err := decoder.Decode(&ruleSet); err != nil {
+		return nil, fmt.Errorf("crawler: decode rule file: %w", err)
+	}
+	return NewDeclarativeRuleEvaluator(ruleSet)
+}
+
+// NewDeclarativeRuleEvaluator validates and compiles a rule set.
+func NewDeclarativeRuleEvaluator(ruleSet DeclarativeRuleSet) (*DeclarativeRuleEvaluator, error) {
+	if len(ruleSet.Rules) == 0 {
+		return nil, errors.New("crawler: rule set has no rules")
+	}
+	evaluator := &DeclarativeRuleEvaluator{}
+	ruleIndexByID := make(map[string]int, len(ruleSet.Rules))
+	for ruleIndex, rule := range ruleSet.Rules {
+		if rule.ID != "" {
+			if firstIndex, duplicate := ruleIndexByID[rule.ID]; duplicate {
+				return nil, fmt.Errorf("crawler: rules #%d and #%d have duplicate id %q", firstIndex+1, ruleIndex+1, rule.ID)
+			}
+			ruleIndexByID[rule.ID] = ruleIndex
+		}
+		compiled, err := compileRule(ruleIndex, rule)
+		if err != nil {
+			return nil, err
+		}
+		evaluator.verifierCount += len(compiled.verifications)
+		evaluator.rules = append(evaluator.rules, compiled)
+	}
+	sort.SliceStable(evaluator.rules, func(left, right int) bool {
+		return evaluator.rules[left].reportingOrder < evaluator.rules[right].reportingOrder
+	})
+	return evaluator, nil
+}
+
+func compileRule(ruleIndex int, rule DeclarativeRule) (compiledRule, error) {
+	ruleName := describeDeclarativeItem(rule.ID, rule.Description, ruleIndex)
+	if len(rule.Verifications) == 0 {
+		return compiledRule{}, fmt.Errorf("crawler: rule %s has no verifications", ruleName)
+	}
+	compiled := compiledRule{
+		id:             rule.ID,
+		description:    rule.Description,
+		reportingOrder: defaultReportingOrder(rule.ReportingOrder, ruleIndex),
+	}
+	seenIDs := make(map[string]struct{}, len(rule.Verifications))
+	for verificationIndex, verification := range rule.Verifications {
+		if verification.ID != "" {
+			if _, duplicate := seenIDs[verification.ID]; duplicate {
+				return compiledRule{}, fmt.Errorf("crawler: rule %s has duplicate verification id %q", ruleName, verification.ID)
+			}
+			seenIDs[verification.ID] = struct{}{}
+		}
+		compiledCheck, err := compileVerification(verificationIndex, verification)
+		if err != nil {
+			return compiledRule{}, fmt.Errorf("crawler: rule %s: %w", ruleName, err)
+		}
+		compiled.verifications = append(compiled.verifications, compiledCheck)
+	}
+	sort.SliceStable(compiled.verifications, func(left, right int) bool {
+		return compiled.verifications[left].reportingOrder < compiled.verifications[right].reportingOrder
+	})
+	return compiled, nil
+}
+
+func compileVerification(index int, verification DeclarativeVerification) (compiledVerification, error) {
+	name := describeDeclarativeItem(verification.ID, verification.Description, index)
+	compiled := compiledVerification{
+		id:             verification.ID,
+		description:    verification.Description,
+		reportingOrder: defaultReportingOrder(verification.ReportingOrder, index),
+		includeValue:   verification.IncludeValue,
+		attribute:      strings.TrimSpace(verification.Attribute),
+		exists:         verification.Assert.Exists,
+		minLength:      verification.Assert.MinLength,
+		minimum:        verification.Assert.Min,
+		maximum:        verification.Assert.Max,
+	}
+
+	selector := strings.TrimSpace(verification.Selector)
+	if selector == "" {
+		return compiledVerification{}, fmt.Errorf("verification %s: selector is required", name)
+	}
+	switch strings.ToLower(strings.TrimSpace(verification.SelectorType)) {
+	case "", SelectorTypeCSS:
+		matcher, err := cascadia.Compile(selector)
+		if err != nil {
+			return compiledVerification{}, fmt.Errorf("verification %s: invalid css selector %q: %w", name, selector, err)
+		}
+		compiled.cssMatcher = matcher
+	case SelectorTypeXPath:
+		expr, err := xpath.Compile(selector)
+		if err != nil {
+			return compiledVerification{}, fmt.Errorf("verification %s: invalid xpath selector %q: %w", name, selector, err)
+		}
+		compiled.xpathExpr = expr
+	default:
+		return compiledVerification{}, fmt.Errorf("verification %s: unknown selector type %q", name, verification.SelectorType)
+	}
+
+	compiled.extract = strings.ToLower(strings.TrimSpace(verification.Extract))
+	switch compiled.extract {
+	case "":
+		compiled.extract = ExtractText
+	case ExtractText, ExtractCount:
+	case ExtractAttribute:
+		if compiled.attribute == "" {
+			return compiledVerification{}, fmt.Errorf("verification %s: attribute is required for attr extraction", name)
+		}
+	default:
+		return compiledVerification{}, fmt.Errorf("verification %s: unknown extraction %q", name, verification.Extract)
+	}
+
+	if verification.Assert.Regex != "" {
+		pattern, err := regexp.Compile(verification.Assert.Regex)
+		if err != nil {
+			return compiledVerification{}, fmt.Errorf("verification %s: invalid regex: %w", name, err)
+		}
+		compiled.pattern = pattern
+	}
+	if compiled.minLength != nil && *compiled.minLength < 0 {
+		return compiledVerification{}, fmt.Errorf("verification %s: min_length must be non-negative", name)
+	}
+	if compiled.minimum != nil && compiled.maximum != nil && *compiled.minimum > *compiled.maximum {
+		return compiledVerification{}, fmt.Errorf("verification %s: min %v exceeds max %v", name, *compiled.minimum, *compiled.maximum)
+	}
+	return compiled, nil
+}
+
+// ConfiguredVerifierCount returns the total number of verifications across
+// all rules.
+func (evaluator *DeclarativeRuleEvaluator) ConfiguredVerifierCount() int {
+	return evaluator.verifierCount
+}
+
+// Evaluate runs every rule against document in reporting order.
+func (evaluator *DeclarativeRuleEvaluator) Evaluate(productID string, document *goquery.Document) (RuleEvaluation, error) {
+	if document == nil || document.Selection == nil || len(document.Nodes) == 0 {
+		return RuleEvaluation{}, fmt.Errorf("crawler: no document to evaluate for product %s", productID)
+	}
+	root := document.Nodes[0]
+	evaluation := RuleEvaluation{
+		Passed:             true,
+		ConfiguredVerifier: evaluator.verifierCount,
+		RuleResults:        make([]RuleResult, 0, len(evaluator.rules)),
+	}
+	for _, rule := range evaluator.rules {
+		ruleResult := RuleResult{
+			ID:                  rule.id,
+			Description:         rule.description,
+			Passed:              true,
+			ReportingOrder:      rule.reportingOrder,
+			VerificationResults: make([]VerificationResult, 0, len(rule.verifications)),
+		}
+		passedCount := 0
+		for _, verification := range rule.verifications {
+			verificationResult := verification.evaluate(document, root)
+			if verificationResult.Passed {
+				passedCount++
+			} else {
+				ruleResult.Passed = false
+			}
+			ruleResult.VerificationResults = append(ruleResult.VerificationResults, verificationResult)
+		}
+		ruleResult.Message = fmt.Sprintf("%d of %d checks passed", passedCount, len(rule.verifications))
+		if !ruleResult.Passed {
+			evaluation.Passed = false
+		}
+		evaluation.RuleResults = append(evaluation.RuleResults, ruleResult)
+	}
+	return evaluation, nil
+}
+
+func (verification compiledVerification) evaluate(document *goquery.Document, root *html.Node) VerificationResult {
+	var matches []*html.Node
+	if verification.xpathExpr != nil {
+		matches = htmlquery.QuerySelectorAll(root, verification.xpathExpr)
+	} else {
+		matches = document.FindMatcher(verification.cssMatcher).Nodes
+	}
+
+	value := verification.extractValue(matches)
+	passed, message := verification.check(len(matches), value)
+	return VerificationResult{
+		ID:             verification.id,
+		Description:    verification.description,
+		Passed:         passed,
+		Message:        message,
+		Value:          value,
+		ReportingOrder: verification.reportingOrder,
+		IncludeValue:   verification.includeValue,
+	}
+}
+
+func (verification compiledVerification) extractValue(matches []*html.Node) string {
+	if verification.extract == ExtractCount {
+		return strconv.Itoa(len(matches))
+	}
+	if len(matches) == 0 {
+		return ""
+	}
+	if verification.extract == ExtractAttribute {
+		return strings.TrimSpace(htmlquery.SelectAttr(matches[0], verification.attribute))
+	}
+	return strings.Join(strings.Fields(htmlquery.InnerText(matches[0])), " ")
+}
+
+func (verification compiledVerification) check(matchCount int, value string) (bool, string) {
+	if verification.exists != nil {
+		if *verification.exists != (matchCount > 0) {
+			if *verification.exists {
+				return false, "no element matched selector"
+			}
+			return false, fmt.Sprintf("expected no match, found %d", matchCount)
+		}
+		if !*verification.exists {
+			return true, "no element matched, as expected"
+		}
+	}
+	if matchCount == 0 && verification.extract != ExtractCount {
+		return false, "no element matched selector"
+	}
+	if verification.pattern != nil && !verification.pattern.MatchString(value) {
+		return false, fmt.Sprintf("value %q does not match %s", value, verification.pattern.String())
+	}
+	if verification.minLength != nil {
+		if length := utf8.RuneCountInString(value); length < *verification.minLength {
+			return false, fmt.Sprintf("value length %d below minimum %d", length, *verification.minLength)
+		}
+	}
+	if verification.minimum != nil || verification.maximum != nil {
+		number, ok := parseFirstNumber(value)
+		if !ok {
+			return false, fmt.Sprintf("value %q is not numeric", value)
+		}
+		if verification.minimum != nil && number < *verification.minimum {
+			return false, fmt.Sprintf("value %v below minimum %v", number, *verification.minimum)
+		}
+		if verification.maximum != nil && number > *verification.maximum {
+			return false, fmt.Sprintf("value %v above maximum %v", number, *verification.maximum)
+		}
+	}
+	return true, "passed"
+}
+
+func parseFirstNumber(value string) (float64, bool) {
+	match := numberPattern.FindString(value)
+	if match == "" {
+		return 0, false
+	}
+	// The pattern only admits digits, commas and one decimal point, so the
+	// only possible error is a range overflow, which still yields ±Inf.
+	number, _ := strconv.ParseFloat(strings.ReplaceAll(match, ",", ""), 64)
+	return number, true
+}
+
+func defaultReportingOrder(configured int, index int) int {
+	if configured != 0 {
+		return configured
+	}
+	return index + 1
+}
+
+func describeDeclarativeItem(id, description string, index int) string {
+	switch {
+	case id != "":
+		return strconv.Quote(id)
+	case description != "":
+		return strconv.Quote(description)
+	default:
+		return fmt.Sprintf("#%d", index+1)
+	}
+}
@@ -0,0 +1,178 @@
+package crawler
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/require"
+)
+
+const declarativeProductPage = `<html><body>
+<h1 class="title">  Stainless   Kettle 1.7L </h1>
+<span id="price">$1,249.00</span>
+<img id="main" src="kettle.jpg" alt="Kettle front view">
+<ul id="bullets"><li>Fast</li><li>Quiet</li></ul>
+</body></html>`
+
+func parseDeclarativeDocument(t *testing.T, markup string) *goquery.Document {
+	t.Helper()
+	document, err := goquery.NewDocumentFromReader(strings.NewReader(markup))
+	require.NoError(t, err)
+	return document
+}
+
+func TestDeclarativeRuleEvaluatorEvaluatesFixtureInReportingOrder(t *testing.T) {
+	t.Parallel()
+
+	evaluator, err := LoadDeclarativeRuleEvaluator(filepath.Join("testdata", "declarative_rules.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, 6, evaluator.ConfiguredVerifierCount())
+
+	evaluation, err := evaluator.Evaluate("KETTLE-1", parseDeclarativeDocument(t, declarativeProductPage))
+	require.NoError(t, err)
+	require.False(t, evaluation.Passed)
+	require.Equal(t, 6, evaluation.ConfiguredVerifier)
+	require.Len(t, evaluation.RuleResults, 2)
+
+	content := evaluation.RuleResults[0]
+	require.Equal(t, "content", content.ID)
+	require.False(t, content.Passed)
+	require.Equal(t, "3 of 4 checks passed", content.Message)
+	require.Equal(t, []string{"title", "image", "bullets", "no-warning"}, verificationIDs(content))
+	require.Equal(t, "Stainless Kettle 1.7L", content.VerificationResults[0].Value)
+	require.Equal(t, "Kettle front view", content.VerificationResults[1].Value)
+	require.Equal(t, "2", content.VerificationResults[2].Value)
+	require.False(t, content.VerificationResults[2].Passed)
+	require.Contains(t, content.VerificationResults[2].Message, "below minimum 3")
+	require.True(t, content.VerificationResults[3].Passed)
+
+	pricing := evaluation.RuleResults[1]
+	require.Equal(t, "pricing", pricing.ID)
+	require.False(t, pricing.Passed)
+	require.True(t, pricing.VerificationResults[0].Passed)
+	require.True(t, pricing.VerificationResults[0].IncludeValue)
+	require.False(t, pricing.VerificationResults[1].Passed)
+	require.Contains(t, pricing.VerificationResults[1].Message, "above maximum 1000")
+
+	result := Result{Success: true, RuleResults: evaluation.RuleResults}
+	require.Equal(t, 66, result.CalculateScore(evaluator.ConfiguredVerifierCount()))
+}
+
+func verificationIDs(rule RuleResult) []string {
+	ids := make([]string, 0, len(rule.VerificationResults))
+	for _, verification := range rule.VerificationResults {
+		ids = append(ids, verification.ID)
+	}
+	return ids
+}
+
+func TestDeclarativeRuleEvaluatorAcceptsJSON(t *testing.T) {
+	t.Parallel()
+
+	evaluator, err := ParseDeclarativeRuleEvaluator([]byte(`{
+		"rules": [{
+			"description": "Has title",
+			"verifications": [{"description": "title exists", "selector": "h1"}]
+		}]
+	}`))
+	require.NoError(t, err)
+
+	evaluation, err := evaluator.Evaluate("P1", parseDeclarativeDocument(t, declarativeProductPage))
+	require.NoError(t, err)
+	require.True(t, evaluation.Passed)
+	require.Equal(t, 1, evaluation.RuleResults[0].ReportingOrder)
+	require.Equal(t, 1, evaluation.RuleResults[0].VerificationResults[0].ReportingOrder)
+}
+
+func TestDeclarativeRuleEvaluatorReportsFailedAssertions(t *testing.T) {
+	t.Parallel()
+
+	document := parseDeclarativeDocument(t, `<html><body><p class="warning">Recalled</p><span id="sku">n/a</span></body></html>`)
+	testCases := []struct {
+		name            string
+		verification    string
+		expectedMessage string
+	}{
+		{name: "missing element", verification: `selector: "h1"`, expectedMessage: "no element matched selector"},
+		{name: "unexpected element", verification: "selector: \".warning\"\n        assert: {exists: false}", expectedMessage: "expected no match, found 1"},
+		{name: "required element", verification: "selector: \"h1\"\n        assert: {exists: true}", expectedMessage: "no element matched selector"},
+		{name: "regex", verification: "selector: \"#sku\"\n        assert: {regex: '^[A-Z]+$'}", expectedMessage: "does not match"},
+		{name: "min length", verification: "selector: \"#sku\"\n        assert: {min_length: 5}", expectedMessage: "below minimum 5"},
+		{name: "not numeric", verification: "selector: \"#sku\"\n        assert: {min: 1}", expectedMessage: "not numeric"},
+		{name: "missing attribute element", verification: "selector: \"img\"\n        extract: attr\n        attribute: alt", expectedMessage: "no element matched selector"},
+	}
+	for _, testCase := range testCases {
+		evaluator, err := ParseDeclarativeRuleEvaluator([]byte("rules:\n  - id: rule\n    verifications:\n      - " + testCase.verification + "\n"))
+		require.NoError(t, err, testCase.name)
+
+		evaluation, err := evaluator.Evaluate("P1", document)
+		require.NoError(t, err, testCase.name)
+		require.False(t, evaluation.Passed, testCase.name)
+		require.Contains(t, evaluation.RuleResults[0].VerificationResults[0].Message, testCase.expectedMessage, testCase.name)
+	}
+}
+
+func TestDeclarativeRuleEvaluatorRejectsInvalidRules(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]string{
+		"unknown key":          "rules:\n  - verifications:\n      - selector: h1\n        selecter: h2\n",
+		"no rules":             "rules: []\n",
+		"no verifications":     "rules:\n  - id: empty\n",
+		"missing selector":     "rules:\n  - verifications:\n      - description: nothing\n",
+		"bad css":              "rules:\n  - verifications:\n      - selector: 'h1[['\n",
+		"bad xpath":            "rules:\n  - verifications:\n      - selector: '//h1[['\n        selector_type: xpath\n",
+		"unknown selector":     "rules:\n  - verifications:\n      - selector: h1\n        selector_type: jsonpath\n",
+		"unknown extraction":   "rules:\n  - verifications:\n      - selector: h1\n        extract: html\n",
+		"attr without name":    "rules:\n  - verifications:\n      - selector: img\n        extract: attr\n",
+		"bad regex":            "rules:\n  - verifications:\n      - selector: h1\n        assert: {regex: '('}\n",
+		"negative min length":  "rules:\n  - verifications:\n      - selector: h1\n        assert: {min_length: -1}\n",
+		"inverted range":       "rules:\n  - verifications:\n      - selector: h1\n        assert: {min: 5, max: 1}\n",
+		"duplicate ids":        "rules:\n  - description: dup\n    verifications:\n      - {id: a, selector: h1}\n      - {id: a, selector: h2}\n",
+		"malformed document":   "rules: [\n",
+		"invalid nested value": "rules:\n  - verifications:\n      - selector: h1\n        assert: {min: high}\n",
+	}
+	for name, document := range testCases {
+		_, err := ParseDeclarativeRuleEvaluator([]byte(document))
+		require.Error(t, err, name)
+	}
+
+	_, err := ParseDeclarativeRuleEvaluator([]byte("rules:\n  - {id: a, verifications: [{selector: h1}]}\n  - {id: b, verifications: [{selector: h2}]}\n  - {id: a, verifications: [{selector: h3}]}\n"))
+	require.ErrorContains(t, err, `rules #1 and #3 have duplicate id "a"`)
+
+	_, err = LoadDeclarativeRuleEvaluator(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.Error(t, err)
+}
+
+func TestDeclarativeRuleEvaluatorRequiresDocument(t *testing.T) {
+	t.Parallel()
+
+	evaluator, err := NewDeclarativeRuleEvaluator(DeclarativeRuleSet{Rules: []DeclarativeRule{{
+		Verifications: []DeclarativeVerification{{Selector: "h1"}},
+	}}})
+	require.NoError(t, err)
+
+	_, err = evaluator.Evaluate("P1", nil)
+	require.Error(t, err)
+}
+
+func TestDeclarativeRuleEvaluatorXPathAttributeExtraction(t *testing.T) {
+	t.Parallel()
+
+	minimum := 1.5
+	evaluator, err := NewDeclarativeRuleEvaluator(DeclarativeRuleSet{Rules: []DeclarativeRule{{
+		ID: "media",
+		Verifications: []DeclarativeVerification{
+			{ID: "src", Selector: "//img[@id='main']", SelectorType: "XPath", Extract: "attr", Attribute: "src", Assert: DeclarativeAssertion{Regex: `\.jpg$`}},
+			{ID: "size", Selector: "h1", Assert: DeclarativeAssertion{Min: &minimum}},
+		},
+	}}})
+	require.NoError(t, err)
+
+	evaluation, err := evaluator.Evaluate("P1", parseDeclarativeDocument(t, declarativeProductPage))
+	require.NoError(t, err)
+	require.True(t, evaluation.Passed)
+	require.Equal(t, "kettle.jpg", evaluation.RuleResults[0].VerificationResults[0].Value)
+}
@@ -0,0 +1,162 @@
+package crawler
+
+import (
+	"container/heap"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrProductDeadlineExceeded is reported for products whose deadline passed
+// before they could be dispatched.
+var ErrProductDeadlineExceeded = errors.New("crawler: product deadline exceeded")
+
+// ErrServiceNotRunning is returned by Enqueue when no Run is accepting work.
+var ErrServiceNotRunning = errors.New("crawler: service is not running")
+
+// productQueue orders pending products by descending priority, then earliest
+// deadline, then submission order. It is safe for concurrent use.
+type productQueue struct {
+	mu       sync.Mutex
+	pending  productHeap
+	sequence uint64
+	closed   bool
+	wake     chan struct{}
+}
+
+func newProductQueue(products []Product) *productQueue {
+	queue := &productQueue{wake: make(chan struct{}, 1)}
+	for _, product := range products {
+		queue.pending = append(queue.pending, queue.newEntry(product))
+	}
+	heap.Init(&queue.pending)
+	return queue
+}
+
+// push adds products unless the queue has been closed.
+func (queue *productQueue) push(products ...Product) bool {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	if queue.closed {
+		return false
+	}
+	for _, product := range products {
+		heap.Push(&queue.pending, queue.newEntry(product))
+	}
+	queue.signal()
+	return true
+}
+
+func (queue *productQueue) pop() (Product, bool) {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	if len(queue.pending) == 0 {
+		return Product{}, false
+	}
+	entry := heap.Pop(&queue.pending).(queuedProduct)
+	return entry.product, true
+}
+
+// closeIfEmpty stops accepting products when nothing is pending and reports
+// whether the queue is now closed.
+func (queue *productQueue) closeIfEmpty() bool {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	if len(queue.pending) > 0 {
+		return false
+	}
+	queue.closed = true
+	return true
+}
+
+// drain stops accepting products and removes the pending ones, returned in
+// dispatch order.
+func (queue *productQueue) drain() []Product {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	queue.closed = true
+	remaining := make([]Product, 0, len(queue.pending))
+	for len(queue.pending) > 0 {
+		remaining = append(remaining, heap.Pop(&queue.pending).(queuedProduct).product)
+	}
+	queue.signal()
+	return remaining
+}
+
+func (queue *productQueue) isEmpty() bool {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	return len(queue.pending) == 0
+}
+
+func (queue *productQueue) isClosed() bool {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	return queue.closed
+}
+
+// close stops accepting products.
+func (queue *productQueue) close() {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	queue.closed = true
+}
+
+func (queue *productQueue) signal() {
+	select {
+	case queue.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (queue *productQueue) newEntry(product Product) queuedProduct {
+	queue.sequence++
+	return queuedProduct{product: product, sequence: queue.sequence}
+}
+
+type queuedProduct struct {
+	product  Product
+	sequence uint64
+}
+
+type productHeap []queuedProduct
+
+func (entries productHeap) Len() int { return len(entries) }
+
+func (entries productHeap) Less(left, right int) bool {
+	leftProduct, rightProduct := entries[left].product, entries[right].product
+	if leftProduct.Priority != rightProduct.Priority {
+		return leftProduct.Priority > rightProduct.Priority
+	}
+	if !leftProduct.Deadline.Equal(rightProduct.Deadline) {
+		return deadlineBefore(leftProduct.Deadline, rightProduct.Deadline)
+	}
+	return entries[left].sequence < entries[right].sequence
+}
+
+func (entries productHeap) Swap(left, right int) {
+	entries[left], entries[right] = entries[right], entries[left]
+}
+
+func (entries *productHeap) Push(value interface{}) {
+	*entries = append(*entries, value.(queuedProduct))
+}
+
+func (entries *productHeap) Pop() interface{} {
+	previous := *entries
+	last := previous[len(previous)-1]
+	*entries = previous[:len(previous)-1]
+	return last
+}
+
+// deadlineBefore orders deadlines earliest first with the zero value (no
+// deadline) last.
+func deadlineBefore(left, right time.Time) bool {
+	if left.IsZero() {
+		return false
+	}
+	if right.IsZero() {
+		return true
+	}
+	return left.Before(right)
+}
@@ -0,0 +1,259 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductQueueOrdersByPriorityDeadlineAndSubmission(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1_700_000_000, 0)
+	queue := newProductQueue([]Product{
+		{ID: "bulk-1"},
+		{ID: "late-deadline", Priority: 5, Deadline: now.Add(time.Hour)},
+		{ID: "bulk-2"},
+		{ID: "no-deadline", Priority: 5},
+		{ID: "early-deadline", Priority: 5, Deadline: now.Add(time.Minute)},
+	})
+	require.True(t, queue.push(Product{ID: "urgent", Priority: 10}))
+
+	var order []string
+	for {
+		product, ok := queue.pop()
+		if !ok {
+			break
+		}
+		order = append(order, product.ID)
+	}
+	require.Equal(t, []string{"urgent", "early-deadline", "late-deadline", "no-deadline", "bulk-1", "bulk-2"}, order)
+
+	require.True(t, queue.closeIfEmpty())
+	require.False(t, queue.push(Product{ID: "too-late"}))
+}
+
+func TestDeadlineBeforeOrdersMissingDeadlinesLast(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1_700_000_000, 0)
+	require.True(t, deadlineBefore(now, now.Add(time.Minute)))
+	require.False(t, deadlineBefore(now.Add(time.Minute), now))
+	require.True(t, deadlineBefore(now, time.Time{}))
+	require.False(t, deadlineBefore(time.Time{}, now))
+	require.False(t, deadlineBefore(time.Time{}, time.Time{}))
+}
+
+func TestProductQueueCloseIfEmptyKeepsPendingWork(t *testing.T) {
+	t.Parallel()
+
+	queue := newProductQueue([]Product{{ID: "pending"}})
+	require.False(t, queue.closeIfEmpty())
+	require.True(t, queue.push(Product{ID: "more"}))
+}
+
+func TestNewProductAppliesPriorityAndDeadline(t *testing.T) {
+	t.Parallel()
+
+	deadline := time.Unix(1_700_000_000, 0)
+	product, err := NewProduct("id", "PLATFORM", "http://example.com", WithPriority(3), WithDeadline(deadline))
+	require.NoError(t, err)
+	require.Equal(t, 3, product.Priority)
+	require.Equal(t, deadline, product.Deadline)
+}
+
+type orderRecordingServer struct {
+	mu      sync.Mutex
+	visited []string
+}
+
+func (recorder *orderRecordingServer) record(path string) {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.visited = append(recorder.visited, path)
+}
+
+func (recorder *orderRecordingServer) order() []string {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	return append([]string(nil), recorder.visited...)
+}
+
+func newSchedulingTestService(t *testing.T, serverURL string, results chan<- *Result) *Service {
+	t.Helper()
+	parsed, err := url.Parse(serverURL)
+	require.NoError(t, err)
+	service, err := NewService(Config{
+		PlatformID: "TEST",
+		Scraper: ScraperConfig{
+			MaxDepth:    1,
+			Parallelism: 1,
+		},
+		Platform: PlatformConfig{
+			AllowedDomains: []string{parsed.Hostname()},
+		},
+		RuleEvaluator: fixedRuleEvaluator{},
+		Logger:        noopLogger{},
+	}, results)
+	require.NoError(t, err)
+	return service
+}
+
+func TestServiceRunVisitsProductsByPriority(t *testing.T) {
+	t.Parallel()
+
+	recorder := &orderRecordingServer{}
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		recorder.record(request.URL.Path)
+		writer.Header().Set("Content-Type", "text/html")
+		_, _ = writer.Write([]byte(`<html><head><title>Product</title></head><body></body></html>`))
+	}))
+	defer server.Close()
+
+	results := make(chan *Result, 3)
+	service := newSchedulingTestService(t, server.URL, results)
+
+	require.NoError(t, service.Run(context.Background(), []Product{
+		{ID: "low", Platform: "TEST", URL: server.URL + "/low"},
+		{ID: "high", Platform: "TEST", URL: server.URL + "/high", Priority: 9},
+		{ID: "mid", Platform: "TEST", URL: server.URL + "/mid", Priority: 4},
+	}))
+	require.Equal(t, []string{"/high", "/mid", "/low"}, recorder.order())
+}
+
+func TestServiceRunReportsProductsPastDeadline(t *testing.T) {
+	t.Parallel()
+
+	recorder := &orderRecordingServer{}
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		recorder.record(request.URL.Path)
+		writer.Header().Set("Content-Type", "text/html")
+		_, _ = writer.Write([]byte(`<html><head><title>Product</title></head><body></body></html>`))
+	}))
+	defer server.Close()
+
+	results := make(chan *Result, 2)
+	service := newSchedulingTestService(t, server.URL, results)
+	now := time.Unix(1_700_000_000, 0)
+	service.now = func() time.Time { return now }
+
+	require.NoError(t, service.Run(context.Background(), []Product{
+		{ID: "expired", Platform: "TEST", URL: server.URL + "/expired", Deadline: now.Add(-time.Second)},
+		{ID: "fresh", Platform: "TEST", URL: server.URL + "/fresh", Deadline: now.Add(time.Minute)},
+	}))
+	close(results)
+
+	byProduct := make(map[string]*Result)
+	for result := range results {
+		byProduct[result.ProductID] = result
+	}
+	require.False(t, byProduct["expired"].Success)
+	require.Equal(t, ErrProductDeadlineExceeded.Error(), byProduct["expired"].ErrorMessage)
+	require.Equal(t, server.URL+"/expired", byProduct["expired"].ProductURL)
+	require.True(t, byProduct["fresh"].Success)
+	require.Equal(t, []string{"/fresh"}, recorder.order())
+}
+
+func TestServiceEnqueueInjectsUrgentProductsIntoRunningCrawl(t *testing.T) {
+	t.Parallel()
+
+	recorder := &orderRecordingServer{}
+	firstStarted := make(chan struct{})
+	releaseFirst := make(chan struct{})
+	var startOnce sync.Once
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		recorder.record(request.URL.Path)
+		if request.URL.Path == "/bulk-1" {
+			startOnce.Do(func() { close(firstStarted) })
+			<-releaseFirst
+		}
+		writer.Header().Set("Content-Type", "text/html")
+		_, _ = writer.Write([]byte(`<html><head><title>Product</title></head><body></body></html>`))
+	}))
+	defer server.Close()
+
+	results := make(chan *Result, 4)
+	service := newSchedulingTestService(t, server.URL, results)
+	require.ErrorIs(t, service.Enqueue(Product{ID: "early"}), ErrServiceNotRunning)
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- service.Run(context.Background(), []Product{
+			{ID: "bulk-1", Platform: "TEST", URL: server.URL + "/bulk-1"},
+			{ID: "bulk-2", Platform: "TEST", URL: server.URL + "/bulk-2"},
+			{ID: "bulk-3", Platform: "TEST", URL: server.URL + "/bulk-3"},
+		})
+	}()
+
+	<-firstStarted
+	require.NoError(t, service.Enqueue(Product{ID: "urgent", Platform: "TEST", URL: server.URL + "/urgent", Priority: 100}))
+	close(releaseFirst)
+
+	require.NoError(t, <-runErr)
+	require.Equal(t, []string{"/bulk-1", "/urgent", "/bulk-2", "/bulk-3"}, recorder.order())
+	require.Len(t, results, 4)
+	require.ErrorIs(t, service.Enqueue(Product{ID: "after"}), ErrServiceNotRunning)
+}
+
+func TestServiceEnqueueKeepsRunAliveUntilInjectedWorkFinishes(t *testing.T) {
+	t.Parallel()
+
+	recorder := &orderRecordingServer{}
+	lastStarted := make(chan struct{})
+	releaseLast := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		recorder.record(request.URL.Path)
+		if request.URL.Path == "/only" {
+			close(lastStarted)
+			<-releaseLast
+		}
+		writer.Header().Set("Content-Type", "text/html")
+		_, _ = writer.Write([]byte(`<html><head><title>Product</title></head><body></body></html>`))
+	}))
+	defer server.Close()
+
+	results := make(chan *Result, 2)
+	service := newSchedulingTestService(t, server.URL, results)
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- service.Run(context.Background(), []Product{
+			{ID: "only", Platform: "TEST", URL: server.URL + "/only"},
+		})
+	}()
+
+	<-lastStarted
+	require.NoError(t, service.Enqueue(Product{ID: "late", Platform: "TEST", URL: server.URL + "/late"}))
+	close(releaseLast)
+
+	require.NoError(t, <-runErr)
+	require.Equal(t, []string{"/only", "/late"}, recorder.order())
+}
+
+func TestServiceRunStopsWaitingForWorkOnCancellation(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		select {
+		case <-request.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	results := make(chan *Result, 2)
+	service := newSchedulingTestService(t, server.URL, results)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := service.Run(ctx, []Product{
+		{ID: "slow", Platform: "TEST", URL: server.URL + "/slow"},
+	})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
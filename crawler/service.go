@@ -2,6 +2,7 @@ package crawler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/cookiejar"
@@ -20,13 +21,24 @@ type Service struct {
 	responseProcessor   ResponseProcessor
 	retryHandler        RetryHandler
 	filePersister       FilePersister
+	proxyTracker        proxyHealth
 	logger              Logger
 	requestHook         RequestHook
 	ctxMu               sync.RWMutex
 	runCtx              context.Context
 	productSlots        chan struct{}
+	sharedSlots         chan struct{}
+	queueMu             sync.Mutex
+	queue               *productQueue
+	runCancel           context.CancelFunc
+	runDone             chan struct{}
+	control             *runControl
+	now                 func() time.Time
 	responseHandlers    []ResponseHandler
 	serviceHook         ServiceHook
+	robots              *robotsPolicy
+	robotsDispatches    sync.WaitGroup
+	captures            *captureRegistry
 }
 
 const defaultCollyRequestTimeout = 10 * time.Second
@@ -40,6 +52,7 @@ func NewService(cfg Config, results chan<- *Result, options ...ServiceOption) (*
 	if err := cfg.Validate(); err != nil {
 		return nil, err
 	}
+	cfg.Scraper.ProxySource = newProxySnapshot(cfg.Scraper.ProxySource)
 
 	logger := EnsureLogger(cfg.Logger)
 	filePersister := cfg.FilePersister
@@ -71,9 +84,31 @@ func NewService(cfg Config, results chan<- *Result, options ...ServiceOption) (*
 		return nil, err
 	}
 
+	var robots *robotsPolicy
+	if cfg.Scraper.RespectRobotsTxt {
+		robotsClient, err := NewHTTPClient(cfg.Scraper, logger)
+		if err != nil {
+			return nil, err
+		}
+		robots = newRobotsPolicy(robotsClient, cfg.Scraper.RobotsUserAgent, logger)
+	}
+
 	responseProcessor := newResponseProcessor(cfg, retryHandler, proxyTracker, filePersister, results, logger)
 
 	requestConfigurator.Configure(collector)
+	redirectTracker := newRedirectTracker()
+	redirectTracker.Register(collector)
+	registerProxyTags(collector)
+	registerProductBudget(collector)
+	if robots != nil {
+		robots.limitHost = func(host string, crawlDelay time.Duration) error {
+			return collector.Limit(robotsLimitRule(cfg.Scraper, host, crawlDelay))
+		}
+	}
+	captures := newCaptureRegistry(cfg.Scraper.Capture)
+	if captures != nil {
+		registerCapture(collector)
+	}
 	setupErrorHandling(collector, responseProcessor, retryHandler, proxyTracker, logger)
 	responseProcessor.Setup(collector)
 
@@ -85,10 +120,15 @@ func NewService(cfg Config, results chan<- *Result, options ...ServiceOption) (*
 		responseProcessor:   responseProcessor,
 		retryHandler:        retryHandler,
 		filePersister:       filePersister,
+		proxyTracker:        proxyTracker,
 		logger:              logger,
 		requestHook:         requestHook,
 		productSlots:        make(chan struct{}, cfg.Scraper.Parallelism),
+		control:             newRunControl(cfg.Scraper.Parallelism),
 		serviceHook:         noopServiceHook{},
+		now:                 time.Now,
+		robots:              robots,
+		captures:            captures,
 	}
 
 	for _, option := range options {
@@ -96,11 +136,23 @@ func NewService(cfg Config, results chan<- *Result, options ...ServiceOption) (*
 	}
 
 	bindResponseHandlersRuntime(service.responseHandlers, collector, filePersister, retryHandler)
-	responseProcessor.SetResultCallback(service.releaseProductSlot)
+	responseProcessor.SetResultCallback(func(resp *colly.Response) {
+		service.saveCapture(resp)
+		service.releaseProductSlot(resp)
+	})
 	responseProcessor.SetResponseHandlers(service.responseHandlers)
 
-	contextTransport := newContextAwareTransport(transport, service.currentRunContext)
-	panicSafeTransport := newPanicSafeTransport(contextTransport, logger)
+	roundTripper := newCaptureTransport(newInternalHeaderStrippingTransport(transport), captures)
+	roundTripper = newContextAwareTransport(roundTripper, service.currentRunContext)
+	roundTripper = newProxyTagsTransport(roundTripper)
+	if cfg.Scraper.CoalesceDuplicateURLs {
+		roundTripper = newCoalescingTransport(roundTripper, cfg.Scraper.maxBodySize(), logger)
+	}
+	// The budget wraps coalescing so a follower stops waiting on a slow
+	// leader once its own product runs out of time.
+	roundTripper = newProductBudgetTransport(roundTripper)
+	roundTripper = newRedirectRecordingTransport(roundTripper, redirectTracker)
+	panicSafeTransport := newPanicSafeTransport(roundTripper, logger)
 	collector.WithTransport(panicSafeTransport)
 
 	service.serviceHook.AfterInit(collector, panicSafeTransport)
@@ -124,67 +176,226 @@ func bindResponseHandlersRuntime(
 }
 
 // Run visits each product URL once and blocks until completion or context cancellation.
+// Products are dispatched by descending Priority, then earliest Deadline, then
+// slice order; more can be added while it runs with Enqueue. Pause, Resume,
+// SetParallelism and Drain control it while it runs.
 func (service *Service) Run(ctx context.Context, products []Product) error {
 	if len(products) == 0 {
 		return fmt.Errorf("crawler: no products provided")
 	}
 
-	cleanup := service.assignRunContext(ctx)
+	runCtx, cancelRun := context.WithCancel(ctx)
+	defer cancelRun()
+	cleanup := service.assignRunContext(runCtx)
 	defer cleanup()
 
-	service.serviceHook.BeforeRun(ctx)
+	if err := service.warmUpProxies(runCtx); err != nil {
+		service.closeFilePersister()
+		return err
+	}
 
-	for _, product := range products {
-		select {
-		case <-ctx.Done():
+	service.serviceHook.BeforeRun(runCtx)
+
+	queue := newProductQueue(products)
+	done := make(chan struct{})
+	defer close(done)
+	service.setActiveRun(queue, cancelRun, done)
+	service.dispatchQueuedProducts(runCtx, queue)
+	queue.close()
+
+	service.robotsDispatches.Wait()
+	service.collector.Wait()
+	service.setActiveRun(nil, nil, nil)
+
+	service.serviceHook.AfterRun()
+
+	service.closeFilePersister()
+	return ctx.Err()
+}
+
+// Enqueue adds products to a running crawl. They are ordered against the
+// pending products by Priority and Deadline, so urgent work can overtake a bulk
+// run. It returns ErrServiceNotRunning when no Run is accepting products.
+func (service *Service) Enqueue(products ...Product) error {
+	service.queueMu.Lock()
+	queue := service.queue
+	service.queueMu.Unlock()
+	if queue == nil || !queue.push(products...) {
+		return ErrServiceNotRunning
+	}
+	return nil
+}
+
+// dispatchQueuedProducts visits queued products in priority order until the
+// queue is empty with nothing in flight, or ctx is cancelled. Slots are
+// reserved before picking the next product so late, urgent arrivals are
+// considered for every free slot. The shared Orchestrator slot is only taken
+// once the queue has work, so an idle platform neither holds nor waits for
+// the shared budget.
+func (service *Service) dispatchQueuedProducts(ctx context.Context, queue *productQueue) {
+	for {
+		service.waitForDispatch(ctx, queue)
+		if ctx.Err() != nil {
 			service.logger.Info("Crawler received shutdown signal. Stopping loop...")
-			goto Cleanup
-		default:
+			return
+		}
+		if err := service.acquireProductSlot(ctx); err != nil {
+			service.logger.Info("Crawler received shutdown signal. Stopping loop...")
+			return
 		}
-		if err := service.processProduct(ctx, product); err != nil {
-			service.logger.Warning("Failed to process product %s: %v", product.ID, err)
-			if ctx.Err() != nil {
-				goto Cleanup
+		if queue.isEmpty() {
+			service.returnProductSlot()
+			if service.productsInFlight() == 0 && queue.closeIfEmpty() {
+				return
+			}
+			select {
+			case <-queue.wake:
+			case <-ctx.Done():
 			}
+			continue
+		}
+		if err := service.acquireSharedSlot(ctx); err != nil {
+			service.returnProductSlot()
+			service.logger.Info("Crawler received shutdown signal. Stopping loop...")
+			return
 		}
+		product, ok := queue.pop()
+		if !ok {
+			// Drain emptied the queue while the shared slot was awaited.
+			service.returnSharedSlot()
+			service.returnProductSlot()
+			continue
+		}
+		service.logger.Debug("Reserved crawler slot for product %s", product.ID)
+		service.dispatchProduct(ctx, product)
 	}
+}
 
-Cleanup:
-	service.collector.Wait()
+// setActiveRun publishes the running crawl to Enqueue and Drain; done is
+// closed once Run returns.
+func (service *Service) setActiveRun(queue *productQueue, cancel context.CancelFunc, done chan struct{}) {
+	service.queueMu.Lock()
+	service.queue, service.runCancel, service.runDone = queue, cancel, done
+	service.queueMu.Unlock()
+}
 
-	service.serviceHook.AfterRun()
+func (service *Service) signalQueue() {
+	service.queueMu.Lock()
+	queue := service.queue
+	service.queueMu.Unlock()
+	if queue != nil {
+		queue.signal()
+	}
+}
 
-	if service.filePersister != nil {
-		if err := service.filePersister.Close(); err != nil {
-			service.logger.Error("Failed to close file persister: %v", err)
+// warmUpProxies probes the configured proxies before any product is visited,
+// seeding the health tracker with the outcome.
+func (service *Service) warmUpProxies(ctx context.Context) error {
+	probeConfig := service.config.Scraper.ProxyProbe
+	if !probeConfig.Enabled() {
+		return nil
+	}
+	proxies, err := service.config.Scraper.currentProxies()
+	if err != nil {
+		return fmt.Errorf("crawler: load proxies for probe: %w", err)
+	}
+	probeConfig.InsecureSkipVerify = probeConfig.InsecureSkipVerify || service.config.Scraper.InsecureSkipVerify
+	results, probeErr := ProbeProxies(ctx, proxies, probeConfig)
+	seedProxyHealth(service.proxyTracker, results)
+	for _, result := range results {
+		if result.Healthy {
+			service.logger.Debug("Proxy %s healthy (status=%d, latency=%s)", describeProxyForLog(result.ProxyURL), result.StatusCode, result.Latency)
+			continue
 		}
+		service.logger.Warning("Proxy %s failed probe: %v", describeProxyForLog(result.ProxyURL), result.Err)
 	}
-	return ctx.Err()
+	return probeErr
 }
 
-func (service *Service) processProduct(ctx context.Context, product Product) error {
-	if err := service.reserveProductSlot(ctx, product.ID); err != nil {
-		return err
+func (service *Service) closeFilePersister() {
+	if service.filePersister == nil {
+		return
 	}
+	if err := service.filePersister.Close(); err != nil {
+		service.logger.Error("Failed to close file persister: %v", err)
+	}
+}
 
+// dispatchProduct visits a product whose slot has already been reserved. The
+// slot is released once the product's final result is emitted.
+func (service *Service) dispatchProduct(ctx context.Context, product Product) {
 	requestContext := colly.NewContext()
 	requestContext.Put(ctxProductIDKey, product.ID)
 	requestContext.Put(ctxProductPlatformKey, product.Platform)
 	requestContext.Put(ctxProductURLKey, product.URL)
 	requestContext.Put(ctxRunContextKey, ctx)
+	if len(product.ProxyTags) > 0 {
+		requestContext.Put(ctxProxyTagsKey, product.ProxyTags)
+	}
+
+	if !product.Deadline.IsZero() && service.now().After(product.Deadline) {
+		service.logger.Warning("Skipping product %s: deadline %s passed", product.ID, product.Deadline.Format(time.RFC3339))
+		requestContext.Put(ctxProductErrorKey, ErrProductDeadlineExceeded)
+		service.responseProcessor.SendFinalResult(&colly.Response{Ctx: requestContext}, false, ErrProductDeadlineExceeded.Error())
+		return
+	}
+
+	if service.robots != nil {
+		// The host's robots.txt may still have to be fetched, so the product
+		// waits for it off the dispatch loop; its slot stays reserved.
+		service.robotsDispatches.Add(1)
+		go func() {
+			defer service.robotsDispatches.Done()
+			if robotsErr := service.robots.Allowed(ctx, product.URL); robotsErr != nil {
+				service.logger.Warning("Skipping product %s: %v", product.ID, robotsErr)
+				requestContext.Put(ctxProductErrorKey, robotsErr)
+				service.responseProcessor.SendFinalResult(&colly.Response{Ctx: requestContext}, false, robotsErr.Error())
+				return
+			}
+			service.visitProduct(ctx, product, requestContext)
+		}()
+		return
+	}
+	service.visitProduct(ctx, product, requestContext)
+}
+
+// visitProduct requests a product that passed its deadline and robots.txt
+// checks.
+func (service *Service) visitProduct(ctx context.Context, product Product, requestContext *colly.Context) {
+	if !service.config.Scraper.hasProxyMatching(product.ProxyTags) {
+		proxyErr := noMatchingProxyError(product.ProxyTags)
+		service.logger.Warning("Skipping product %s: %v", product.ID, proxyErr)
+		requestContext.Put(ctxProductErrorKey, proxyErr)
+		service.responseProcessor.SendFinalResult(&colly.Response{Ctx: requestContext}, false, proxyErr.Error())
+		return
+	}
 
 	if hookErr := service.requestHook.BeforeRequest(ctx, product); hookErr != nil {
 		requestContext.Put(ctxProductErrorKey, hookErr)
 		service.responseProcessor.SendFinalResult(&colly.Response{Ctx: requestContext}, false, hookErr.Error())
-		return nil
+		return
 	}
 
+	startProductBudget(requestContext, service.config.Scraper.productBudget(product), service.now())
+	service.captures.start(requestContext, product)
 	service.logger.Debug("Visiting URL for product: %+v", product)
 	if err := service.collector.Request(http.MethodGet, product.URL, nil, requestContext, nil); err != nil {
 		service.logger.Error("Failed to visit URL: %s, Error: %v", product.URL, err)
 		service.releaseProductSlotByID(product.ID)
 	}
-	return nil
+}
+
+// collectorLimitRule spreads Parallelism and RateLimit over every host.
+func collectorLimitRule(scraper ScraperConfig) *colly.LimitRule {
+	limitRule := &colly.LimitRule{
+		DomainGlob:  "*",
+		Parallelism: scraper.Parallelism,
+	}
+	if scraper.RateLimit > 0 {
+		limitRule.Delay = scraper.RateLimit
+		limitRule.RandomDelay = scraper.RateLimit / 2
+	}
+	return limitRule
 }
 
 func newCollector(cfg Config, logger Logger) (*colly.Collector, proxyHealth, http.RoundTripper, error) {
@@ -194,6 +405,9 @@ func newCollector(cfg Config, logger Logger) (*colly.Collector, proxyHealth, htt
 		colly.Async(),
 		colly.MaxDepth(cfg.Scraper.MaxDepth),
 		colly.IgnoreRobotsTxt(),
+		// One byte past the limit lets handleResponse tell a page that fits
+		// from one colly truncated.
+		colly.MaxBodySize(cfg.Scraper.maxBodySize()+1),
 	)
 
 	transport := newCrawlerHTTPTransport(cfg.Scraper.InsecureSkipVerify, cfg.Scraper.HTTPTimeout)
@@ -205,41 +419,70 @@ func newCollector(cfg Config, logger Logger) (*colly.Collector, proxyHealth, htt
 		webCollector.SetRequestTimeout(cfg.Scraper.HTTPTimeout)
 	}
 
-	limitRule := &colly.LimitRule{
-		DomainGlob:  "*",
-		Parallelism: cfg.Scraper.Parallelism,
+	// colly applies the first matching rule, so with robots.txt compliance
+	// each host gets its own rule, carrying its Crawl-delay, once its
+	// robots.txt is cached instead of sharing a catch-all.
+	if !cfg.Scraper.RespectRobotsTxt {
+		_ = webCollector.Limit(collectorLimitRule(cfg.Scraper))
 	}
-	if cfg.Scraper.RateLimit > 0 {
-		limitRule.Delay = cfg.Scraper.RateLimit
-		limitRule.RandomDelay = cfg.Scraper.RateLimit / 2
+
+	tracker, err := configureProxies(webCollector, cfg.Scraper, logger)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
-	_ = webCollector.Limit(limitRule)
+	cookieJar, _ := cookiejar.New(nil)
+	webCollector.SetCookieJar(cookieJar)
+	return webCollector, tracker, transport, nil
+}
 
+func configureProxies(webCollector *colly.Collector, scraper ScraperConfig, logger Logger) (proxyHealth, error) {
+	proxyFn, tracker, err := newScraperProxyFunc(scraper, logger)
+	if err != nil {
+		return nil, err
+	}
+	if proxyFn != nil {
+		webCollector.SetProxyFunc(proxyFn)
+	}
+	return tracker, nil
+}
+
+// newScraperProxyFunc builds the proxy selector for scraper, or nil when no
+// proxies are configured. The health tracker is nil unless the circuit breaker
+// is enabled for a rotating pool.
+func newScraperProxyFunc(scraper ScraperConfig, logger Logger) (colly.ProxyFunc, proxyHealth, error) {
 	var tracker proxyHealth
-	proxyHealthEnabled := cfg.Scraper.ProxyCircuitBreakerEnabled
-	switch len(cfg.Scraper.ProxyList) {
+	proxyHealthEnabled := scraper.ProxyCircuitBreakerEnabled
+	if scraper.ProxySource != nil {
+		if proxyHealthEnabled {
+			tracker = newProxyHealthTracker(nil, logger)
+		}
+		proxyFn, err := newSourceProxyRotator(scraper.ProxySource, scraper.ProxyTags, tracker, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		return proxyFn, tracker, nil
+	}
+
+	switch len(scraper.ProxyList) {
 	case 0:
+		return nil, nil, nil
 	case 1:
-		proxyFn, err := newProxyRotator(cfg.Scraper.ProxyList, nil, logger)
+		proxyFn, err := newProxyRotator(scraper.ProxyList, scraper.ProxyTags, nil, logger)
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, err
 		}
-		webCollector.SetProxyFunc(proxyFn)
+		return proxyFn, nil, nil
 	default:
 		if proxyHealthEnabled {
-			tracker = newProxyHealthTracker(cfg.Scraper.ProxyList, logger)
+			tracker = newProxyHealthTracker(scraper.ProxyList, logger)
 		}
-		proxyFn, err := newProxyRotator(cfg.Scraper.ProxyList, tracker, logger)
+		proxyFn, err := newProxyRotator(scraper.ProxyList, scraper.ProxyTags, tracker, logger)
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, err
 		}
-		webCollector.SetProxyFunc(proxyFn)
+		return proxyFn, tracker, nil
 	}
-
-	cookieJar, _ := cookiejar.New(nil)
-	webCollector.SetCookieJar(cookieJar)
-	return webCollector, tracker, transport, nil
 }
 
 func shouldOverrideCollectorRequestTimeout(timeout time.Duration) bool {
@@ -272,7 +515,7 @@ func handleCollectorError(resp *colly.Response, err error, processor ResponsePro
 	resp.Ctx.Put(ctxHTTPStatusCodeKey, resp.StatusCode)
 	resp.Ctx.Put(ctxProductErrorKey, err)
 
-	if resp.StatusCode == http.StatusNotFound {
+	if resp.StatusCode == http.StatusNotFound || errors.Is(err, ErrNoMatchingProxy) || errors.Is(err, ErrBodyTooLarge) || runAborted(resp.Ctx) || productBudgetExhausted(resp.Ctx) {
 		processor.SendFinalResult(resp, false, errorText)
 		return
 	}
@@ -286,6 +529,13 @@ func handleCollectorError(resp *colly.Response, err error, processor ResponsePro
 	}
 }
 
+// runAborted reports whether the run that dispatched the request has been
+// cancelled, in which case retrying it would only fail again.
+func runAborted(ctx *colly.Context) bool {
+	runCtx, ok := ctx.GetAny(ctxRunContextKey).(context.Context)
+	return ok && runCtx.Err() != nil
+}
+
 func extractErrorLogFields(resp *colly.Response) (urlValue string, statusCode int, proxyURL string) {
 	urlValue = unknownURLValue
 	if resp == nil {
@@ -318,19 +568,51 @@ func recordProxyFailure(tracker proxyHealth, resp *colly.Response) {
 	tracker.RecordFailure(resp.Request.ProxyURL)
 }
 
-func (service *Service) reserveProductSlot(ctx context.Context, productID string) error {
+func (service *Service) acquireProductSlot(ctx context.Context) error {
 	if service == nil || service.productSlots == nil {
 		return nil
 	}
 	select {
 	case service.productSlots <- struct{}{}:
-		service.logger.Debug("Reserved crawler slot for product %s", productID)
 		return nil
 	case <-ctx.Done():
 		return ctx.Err()
 	}
 }
 
+// acquireSharedSlot takes a slot from the Orchestrator's shared budget, if
+// any. It is released together with the product slot.
+func (service *Service) acquireSharedSlot(ctx context.Context) error {
+	if service == nil || service.sharedSlots == nil {
+		return nil
+	}
+	select {
+	case service.sharedSlots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// returnProductSlot gives back a product slot that was acquired but never used.
+func (service *Service) returnProductSlot() {
+	if service == nil || service.productSlots == nil {
+		return
+	}
+	<-service.productSlots
+}
+
+// returnSharedSlot gives back a shared slot that was acquired but never used.
+func (service *Service) returnSharedSlot() {
+	if service.sharedSlots != nil {
+		<-service.sharedSlots
+	}
+}
+
+func (service *Service) productsInFlight() int {
+	return len(service.productSlots)
+}
+
 func (service *Service) releaseProductSlot(resp *colly.Response) {
 	productID := unknownProductID
 	if resp != nil && resp.Ctx != nil {
@@ -348,7 +630,15 @@ func (service *Service) releaseProductSlotByID(productID string) {
 		service.logger.Debug("Released crawler slot for product %s", productID)
 	default:
 		service.logger.Warning("Crawler slot release called without reservation for product %s", productID)
+		return
+	}
+	if service.sharedSlots != nil {
+		select {
+		case <-service.sharedSlots:
+		default:
+		}
 	}
+	service.signalQueue()
 }
 
 func (service *Service) assignRunContext(ctx context.Context) func() {
@@ -0,0 +1,153 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultDrainBatchSize     = 100
+	defaultDrainFlushInterval = time.Second
+)
+
+// ResultSink persists crawler results. WriteResults receives results in
+// batches and should make them durable before returning; Close releases any
+// resources after the final batch. Implementations live in crawler/sinks.
+type ResultSink interface {
+	WriteResults(ctx context.Context, results []*Result) error
+	Close() error
+}
+
+// DrainOption configures DrainResults.
+type DrainOption func(*drainSettings)
+
+type drainSettings struct {
+	batchSize     int
+	flushInterval time.Duration
+	logger        Logger
+}
+
+// WithDrainBatchSize sets how many results are buffered before each sink is
+// written. Values below one write every result individually.
+func WithDrainBatchSize(batchSize int) DrainOption {
+	return func(settings *drainSettings) {
+		settings.batchSize = batchSize
+	}
+}
+
+// WithDrainFlushInterval bounds how long a partial batch waits before being
+// written. Zero or negative values wait for a full batch or the end of input.
+func WithDrainFlushInterval(flushInterval time.Duration) DrainOption {
+	return func(settings *drainSettings) {
+		settings.flushInterval = flushInterval
+	}
+}
+
+// WithDrainLogger reports sink failures as they happen.
+func WithDrainLogger(logger Logger) DrainOption {
+	return func(settings *drainSettings) {
+		settings.logger = EnsureLogger(logger)
+	}
+}
+
+// DrainResults reads results until the channel is closed or ctx is cancelled,
+// writing them to every sink in batches. Remaining buffered results are
+// flushed and all sinks are closed before it returns. A failing sink does not
+// stop the others or the drain; its errors are joined into the returned error
+// together with ctx.Err() when cancelled.
+func DrainResults(ctx context.Context, results <-chan *Result, sinks []ResultSink, options ...DrainOption) error {
+	if results == nil {
+		return errors.New("crawler: results channel is required")
+	}
+	if len(sinks) == 0 {
+		return errors.New("crawler: at least one result sink is required")
+	}
+	for index, sink := range sinks {
+		if sink == nil {
+			return fmt.Errorf("crawler: result sink %d is nil", index)
+		}
+	}
+	settings := drainSettings{
+		batchSize:     defaultDrainBatchSize,
+		flushInterval: defaultDrainFlushInterval,
+		logger:        noopLogger{},
+	}
+	for _, option := range options {
+		option(&settings)
+	}
+	if settings.batchSize < 1 {
+		settings.batchSize = 1
+	}
+
+	drainer := &resultDrainer{sinks: sinks, logger: settings.logger}
+	var tick <-chan time.Time
+	if settings.flushInterval > 0 {
+		ticker := time.NewTicker(settings.flushInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	batch := make([]*Result, 0, settings.batchSize)
+	var cancelErr error
+drain:
+	for {
+		select {
+		case result, ok := <-results:
+			if !ok {
+				break drain
+			}
+			if result == nil {
+				continue
+			}
+			batch = append(batch, result)
+			if len(batch) >= settings.batchSize {
+				drainer.write(ctx, batch)
+				batch = make([]*Result, 0, settings.batchSize)
+			}
+		case <-tick:
+			if len(batch) > 0 {
+				drainer.write(ctx, batch)
+				batch = make([]*Result, 0, settings.batchSize)
+			}
+		case <-ctx.Done():
+			cancelErr = ctx.Err()
+			break drain
+		}
+	}
+
+	// The final flush must outlive a cancelled run so buffered results are kept.
+	if len(batch) > 0 {
+		drainer.write(context.WithoutCancel(ctx), batch)
+	}
+	drainer.close()
+	return errors.Join(append(drainer.errs, cancelErr)...)
+}
+
+type resultDrainer struct {
+	sinks  []ResultSink
+	logger Logger
+	errs   []error
+}
+
+func (drainer *resultDrainer) write(ctx context.Context, batch []*Result) {
+	for index, sink := range drainer.sinks {
+		if err := sink.WriteResults(ctx, batch); err != nil {
+			drainer.record(fmt.Errorf("crawler: result sink %d: write %d results: %w", index, len(batch), err))
+		}
+	}
+}
+
+func (drainer *resultDrainer) close() {
+	for index, sink := range drainer.sinks {
+		if err := sink.Close(); err != nil {
+			drainer.record(fmt.Errorf("crawler: result sink %d: close: %w", index, err))
+		}
+	}
+}
+
+func (drainer *resultDrainer) record(err error) {
+	drainer.logger.Error("%v", err)
+	drainer.errs = append(drainer.errs, err)
+}
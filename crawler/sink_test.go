@@ -0,0 +1,146 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordingResultSink struct {
+	mu       sync.Mutex
+	batches  [][]string
+	closed   bool
+	writeErr error
+	closeErr error
+	written  chan struct{}
+}
+
+func (sink *recordingResultSink) WriteResults(ctx context.Context, results []*Result) error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	ids := make([]string, 0, len(results))
+	for _, result := range results {
+		ids = append(ids, result.ProductID)
+	}
+	sink.batches = append(sink.batches, ids)
+	if sink.written != nil {
+		sink.written <- struct{}{}
+	}
+	return sink.writeErr
+}
+
+func (sink *recordingResultSink) Close() error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	sink.closed = true
+	return sink.closeErr
+}
+
+func (sink *recordingResultSink) snapshot() ([][]string, bool) {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	return append([][]string(nil), sink.batches...), sink.closed
+}
+
+func TestDrainResultsBatchesAndFlushesOnClose(t *testing.T) {
+	t.Parallel()
+
+	results := make(chan *Result, 8)
+	for _, productID := range []string{"1", "2", "3"} {
+		results <- &Result{ProductID: productID}
+	}
+	results <- nil
+	results <- &Result{ProductID: "4"}
+	results <- &Result{ProductID: "5"}
+	close(results)
+
+	first := &recordingResultSink{}
+	failing := &recordingResultSink{writeErr: errors.New("disk full"), closeErr: errors.New("sync failed")}
+	logger := &capturingLogger{}
+	err := DrainResults(context.Background(), results, []ResultSink{first, failing},
+		WithDrainBatchSize(2), WithDrainFlushInterval(0), WithDrainLogger(logger))
+
+	require.ErrorContains(t, err, "result sink 1: write 2 results: disk full")
+	require.ErrorContains(t, err, "result sink 1: close: sync failed")
+	batches, closed := first.snapshot()
+	require.Equal(t, [][]string{{"1", "2"}, {"3", "4"}, {"5"}}, batches)
+	require.True(t, closed)
+	failedBatches, failingClosed := failing.snapshot()
+	require.Len(t, failedBatches, 3)
+	require.True(t, failingClosed)
+	require.NotEmpty(t, logger.errors)
+}
+
+func TestDrainResultsFlushesPartialBatchesOnInterval(t *testing.T) {
+	t.Parallel()
+
+	results := make(chan *Result)
+	sink := &recordingResultSink{written: make(chan struct{}, 4)}
+	done := make(chan error, 1)
+	go func() {
+		done <- DrainResults(context.Background(), results, []ResultSink{sink}, WithDrainFlushInterval(10*time.Millisecond))
+	}()
+
+	results <- &Result{ProductID: "1"}
+	select {
+	case <-sink.written:
+	case <-time.After(2 * time.Second):
+		t.Fatal("partial batch was not flushed on interval")
+	}
+	close(results)
+	require.NoError(t, <-done)
+
+	batches, closed := sink.snapshot()
+	require.Equal(t, [][]string{{"1"}}, batches)
+	require.True(t, closed)
+}
+
+func TestDrainResultsFlushesBufferedResultsWhenCancelled(t *testing.T) {
+	t.Parallel()
+
+	results := make(chan *Result, 2)
+	results <- &Result{ProductID: "1"}
+	sink := &recordingResultSink{written: make(chan struct{}, 1)}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- DrainResults(ctx, results, []ResultSink{sink}, WithDrainBatchSize(0), WithDrainFlushInterval(0))
+	}()
+	<-sink.written
+	results <- &Result{ProductID: "2"}
+	require.Eventually(t, func() bool { return len(results) == 0 }, 2*time.Second, time.Millisecond)
+	cancel()
+
+	require.ErrorIs(t, <-done, context.Canceled)
+	batches, closed := sink.snapshot()
+	require.Equal(t, [][]string{{"1"}, {"2"}}, batches)
+	require.True(t, closed)
+
+	buffered := make(chan *Result, 1)
+	buffered <- &Result{ProductID: "3"}
+	bufferedSink := &recordingResultSink{}
+	cancelled, cancelNow := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancelNow()
+	}()
+	require.ErrorIs(t, DrainResults(cancelled, buffered, []ResultSink{bufferedSink}, WithDrainFlushInterval(0)), context.Canceled)
+	bufferedBatches, _ := bufferedSink.snapshot()
+	require.Equal(t, [][]string{{"3"}}, bufferedBatches)
+}
+
+func TestDrainResultsValidatesArguments(t *testing.T) {
+	t.Parallel()
+
+	results := make(chan *Result)
+	require.ErrorContains(t, DrainResults(context.Background(), nil, []ResultSink{&recordingResultSink{}}), "results channel is required")
+	require.ErrorContains(t, DrainResults(context.Background(), results, nil), "at least one result sink is required")
+	require.ErrorContains(t, DrainResults(context.Background(), results, []ResultSink{nil}), "result sink 0 is nil")
+}
@@ -0,0 +1,269 @@
+package sinks
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tyemirov/utils/crawler"
+)
+
+const (
+	csvTagName            = "csv"
+	csvKeyValueOption     = "keyValue"
+	csvKeyPathSeparator   = " / "
+	csvUnnamedKey         = "(unnamed)"
+	csvFilePermissions    = 0o644
+	csvSkippedColumnToken = "-"
+)
+
+// CSVConfig configures a CSVSink.
+type CSVConfig struct {
+	// Path of the file to create. Mandatory; an existing file is truncated.
+	Path string
+	// Columns pins the header. When empty the header is taken from the first
+	// batch written.
+	Columns []string
+	// Delimiter defaults to a comma.
+	Delimiter rune
+}
+
+// CSVSink writes results as CSV rows using the csv struct tags on
+// crawler.Result. A tag names the column, "-" skips the field and an empty or
+// missing tag uses the Go field name. Rule and verification results are
+// flattened: the field tagged "keyValue" (their Description) becomes a column
+// prefix, so a rule "Title" yields "Title passed" and "Title message", and
+// its verification "H1" yields "Title / H1 value". Inside such keyed structs
+// only fields with an explicit csv name become columns.
+//
+// Because CSV has a fixed header, columns first seen after the header is
+// written are dropped; set Columns when rule sets vary between results.
+type CSVSink struct {
+	mu            sync.Mutex
+	file          *os.File
+	writer        *csv.Writer
+	columns       []string
+	headerWritten bool
+	closed        bool
+}
+
+// NewCSVSink creates the file described by cfg.
+func NewCSVSink(cfg CSVConfig) (*CSVSink, error) {
+	if strings.TrimSpace(cfg.Path) == "" {
+		return nil, errors.New("sinks: csv path is required")
+	}
+	file, err := os.OpenFile(cfg.Path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, csvFilePermissions)
+	if err != nil {
+		return nil, fmt.Errorf("sinks: create csv file: %w", err)
+	}
+	writer := csv.NewWriter(file)
+	if cfg.Delimiter != 0 {
+		writer.Comma = cfg.Delimiter
+	}
+	return &CSVSink{
+		file:    file,
+		writer:  writer,
+		columns: append([]string(nil), cfg.Columns...),
+	}, nil
+}
+
+// WriteResults writes one row per result, emitting the header first when
+// needed, and flushes the file.
+func (sink *CSVSink) WriteResults(_ context.Context, results []*crawler.Result) error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if sink.closed {
+		return ErrSinkClosed
+	}
+
+	rows := make([][]csvCell, 0, len(results))
+	for _, result := range results {
+		if result != nil {
+			rows = append(rows, resultCSVCells(result))
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	if sink.columns == nil {
+		sink.columns = csvColumnsOf(rows)
+	}
+	if !sink.headerWritten {
+		if err := sink.writer.Write(sink.columns); err != nil {
+			return fmt.Errorf("sinks: write csv header: %w", err)
+		}
+		sink.headerWritten = true
+	}
+
+	for _, cells := range rows {
+		values := make(map[string]string, len(cells))
+		for _, cell := range cells {
+			values[cell.Column] = cell.Value
+		}
+		record := make([]string, len(sink.columns))
+		for index, column := range sink.columns {
+			record[index] = values[column]
+		}
+		if err := sink.writer.Write(record); err != nil {
+			return fmt.Errorf("sinks: write csv row: %w", err)
+		}
+	}
+	sink.writer.Flush()
+	if err := sink.writer.Error(); err != nil {
+		return fmt.Errorf("sinks: flush csv: %w", err)
+	}
+	return nil
+}
+
+// Close flushes and closes the file.
+func (sink *CSVSink) Close() error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if sink.closed {
+		return nil
+	}
+	sink.closed = true
+	sink.writer.Flush()
+	if err := errors.Join(sink.writer.Error(), sink.file.Close()); err != nil {
+		return fmt.Errorf("sinks: close csv: %w", err)
+	}
+	return nil
+}
+
+// csvCell is one column of a flattened result.
+type csvCell struct {
+	Column string
+	Value  string
+}
+
+// resultCSVCells flattens result into ordered column/value pairs following
+// the rules documented on CSVSink.
+func resultCSVCells(result *crawler.Result) []csvCell {
+	redacted := *result
+	redacted.ProxyURL = crawler.RedactProxyURL(result.ProxyURL)
+	var cells []csvCell
+	appendCSVCells(reflect.ValueOf(redacted), "", &cells)
+	return cells
+}
+
+func csvColumnsOf(rows [][]csvCell) []string {
+	seen := make(map[string]struct{})
+	var columns []string
+	for _, cells := range rows {
+		for _, cell := range cells {
+			if _, exists := seen[cell.Column]; exists {
+				continue
+			}
+			seen[cell.Column] = struct{}{}
+			columns = append(columns, cell.Column)
+		}
+	}
+	return columns
+}
+
+func appendCSVCells(value reflect.Value, prefix string, cells *[]csvCell) {
+	valueType := value.Type()
+	keyed := false
+	for index := 0; index < valueType.NumField(); index++ {
+		if _, options := parseCSVTag(valueType.Field(index)); hasCSVOption(options, csvKeyValueOption) {
+			key := strings.TrimSpace(formatCSVValue(value.Field(index)))
+			if key == "" {
+				key = csvUnnamedKey
+			}
+			prefix = joinCSVPrefix(prefix, key)
+			keyed = true
+			break
+		}
+	}
+
+	for index := 0; index < valueType.NumField(); index++ {
+		field := valueType.Field(index)
+		if !field.IsExported() {
+			continue
+		}
+		name, options := parseCSVTag(field)
+		if name == csvSkippedColumnToken || hasCSVOption(options, csvKeyValueOption) {
+			continue
+		}
+		fieldValue := value.Field(index)
+		if nested, ok := nestedCSVStructs(fieldValue); ok {
+			for _, element := range nested {
+				appendCSVCells(element, prefix, cells)
+			}
+			continue
+		}
+		if name == "" {
+			if keyed {
+				continue
+			}
+			name = field.Name
+		}
+		column := name
+		if prefix != "" {
+			column = prefix + " " + name
+		}
+		*cells = append(*cells, csvCell{Column: column, Value: formatCSVValue(fieldValue)})
+	}
+}
+
+// nestedCSVStructs returns the elements of a slice of structs, which are
+// flattened instead of written as a single column.
+func nestedCSVStructs(value reflect.Value) ([]reflect.Value, bool) {
+	if value.Kind() != reflect.Slice || value.Type().Elem().Kind() != reflect.Struct {
+		return nil, false
+	}
+	elements := make([]reflect.Value, 0, value.Len())
+	for index := 0; index < value.Len(); index++ {
+		elements = append(elements, value.Index(index))
+	}
+	return elements, true
+}
+
+func parseCSVTag(field reflect.StructField) (string, []string) {
+	tag, ok := field.Tag.Lookup(csvTagName)
+	if !ok {
+		return "", nil
+	}
+	parts := strings.Split(tag, ",")
+	return strings.TrimSpace(parts[0]), parts[1:]
+}
+
+func hasCSVOption(options []string, option string) bool {
+	for _, candidate := range options {
+		if strings.TrimSpace(candidate) == option {
+			return true
+		}
+	}
+	return false
+}
+
+func joinCSVPrefix(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + csvKeyPathSeparator + key
+}
+
+func formatCSVValue(value reflect.Value) string {
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return ""
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.String:
+		return value.String()
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10)
+	}
+	return fmt.Sprint(value.Interface())
+}
@@ -0,0 +1,178 @@
+package sinks
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/tyemirov/utils/crawler"
+)
+
+const (
+	defaultJSONLFilePrefix = "results"
+	jsonlFilePermissions   = 0o644
+	jsonlDirPermissions    = 0o755
+)
+
+// JSONLConfig configures a JSONLSink.
+type JSONLConfig struct {
+	// Directory receives the files. Mandatory; created when missing.
+	Directory string
+	// FilePrefix names files as <prefix>-0001.jsonl. Defaults to "results".
+	FilePrefix string
+	// MaxFileBytes starts a new file once the current one would grow past it.
+	// Zero disables size-based rotation.
+	MaxFileBytes int64
+	// MaxRecordsPerFile starts a new file after this many results. Zero
+	// disables count-based rotation.
+	MaxRecordsPerFile int
+}
+
+// JSONLSink writes one JSON-encoded Result per line, rotating files by size
+// or record count. Existing files are never overwritten; numbering skips
+// past them.
+type JSONLSink struct {
+	config JSONLConfig
+
+	mu          sync.Mutex
+	file        *os.File
+	writer      *bufio.Writer
+	fileIndex   int
+	fileBytes   int64
+	fileRecords int
+	files       []string
+	closed      bool
+}
+
+// NewJSONLSink validates cfg and prepares its directory. Files are created on
+// the first write.
+func NewJSONLSink(cfg JSONLConfig) (*JSONLSink, error) {
+	if strings.TrimSpace(cfg.Directory) == "" {
+		return nil, errors.New("sinks: jsonl directory is required")
+	}
+	if cfg.MaxFileBytes < 0 {
+		return nil, fmt.Errorf("sinks: jsonl max file bytes must be non-negative (got %d)", cfg.MaxFileBytes)
+	}
+	if cfg.MaxRecordsPerFile < 0 {
+		return nil, fmt.Errorf("sinks: jsonl max records per file must be non-negative (got %d)", cfg.MaxRecordsPerFile)
+	}
+	if strings.TrimSpace(cfg.FilePrefix) == "" {
+		cfg.FilePrefix = defaultJSONLFilePrefix
+	}
+	if err := os.MkdirAll(cfg.Directory, jsonlDirPermissions); err != nil {
+		return nil, fmt.Errorf("sinks: create jsonl directory: %w", err)
+	}
+	return &JSONLSink{config: cfg}, nil
+}
+
+// WriteResults appends results and flushes them to disk.
+func (sink *JSONLSink) WriteResults(_ context.Context, results []*crawler.Result) error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if sink.closed {
+		return ErrSinkClosed
+	}
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		redacted := *result
+		redacted.ProxyURL = crawler.RedactProxyURL(result.ProxyURL)
+		line, err := jsonMarshalFunc(redacted)
+		if err != nil {
+			return fmt.Errorf("sinks: encode result %s: %w", result.ProductID, err)
+		}
+		line = append(line, '\n')
+		if sink.shouldRotate(int64(len(line))) {
+			if err := sink.closeFile(); err != nil {
+				return err
+			}
+		}
+		if sink.file == nil {
+			if err := sink.openNextFile(); err != nil {
+				return err
+			}
+		}
+		if _, err := sink.writer.Write(line); err != nil {
+			return fmt.Errorf("sinks: write %s: %w", sink.file.Name(), err)
+		}
+		sink.fileBytes += int64(len(line))
+		sink.fileRecords++
+	}
+	if sink.writer == nil {
+		return nil
+	}
+	if err := sink.writer.Flush(); err != nil {
+		return fmt.Errorf("sinks: flush %s: %w", sink.file.Name(), err)
+	}
+	return nil
+}
+
+// Files lists the paths written so far in creation order.
+func (sink *JSONLSink) Files() []string {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	return append([]string(nil), sink.files...)
+}
+
+// Close flushes and closes the current file. Further writes fail with
+// ErrSinkClosed.
+func (sink *JSONLSink) Close() error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if sink.closed {
+		return nil
+	}
+	sink.closed = true
+	return sink.closeFile()
+}
+
+func (sink *JSONLSink) shouldRotate(lineBytes int64) bool {
+	if sink.file == nil || sink.fileRecords == 0 {
+		return false
+	}
+	if sink.config.MaxRecordsPerFile > 0 && sink.fileRecords >= sink.config.MaxRecordsPerFile {
+		return true
+	}
+	return sink.config.MaxFileBytes > 0 && sink.fileBytes+lineBytes > sink.config.MaxFileBytes
+}
+
+func (sink *JSONLSink) openNextFile() error {
+	for {
+		sink.fileIndex++
+		path := filepath.Join(sink.config.Directory, fmt.Sprintf("%s-%04d.jsonl", sink.config.FilePrefix, sink.fileIndex))
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, jsonlFilePermissions)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("sinks: create %s: %w", path, err)
+		}
+		sink.file = file
+		sink.writer = bufio.NewWriter(file)
+		sink.fileBytes = 0
+		sink.fileRecords = 0
+		sink.files = append(sink.files, path)
+		return nil
+	}
+}
+
+func (sink *JSONLSink) closeFile() error {
+	if sink.file == nil {
+		return nil
+	}
+	file := sink.file
+	flushErr := sink.writer.Flush()
+	closeErr := file.Close()
+	sink.file = nil
+	sink.writer = nil
+	if err := errors.Join(flushErr, closeErr); err != nil {
+		return fmt.Errorf("sinks: close %s: %w", file.Name(), err)
+	}
+	return nil
+}
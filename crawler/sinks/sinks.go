@@ -0,0 +1,18 @@
+// Package sinks provides crawler.ResultSink implementations: rotating JSONL
+// files, CSV files that follow the csv struct tags on crawler.Result, and a
+// GORM-backed SQL table. Combine them with crawler.DrainResults to persist a
+// results channel with batching. Every sink strips credentials from
+// Result.ProxyURL before writing it.
+package sinks
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// ErrSinkClosed is returned by WriteResults after Close.
+var ErrSinkClosed = errors.New("sinks: sink is closed")
+
+// jsonMarshalFunc is the JSON marshal function used by the sinks. It defaults
+// to json.Marshal and can be replaced for dependency injection.
+var jsonMarshalFunc = json.Marshal
@@ -0,0 +1,141 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tyemirov/utils/crawler"
+	"gorm.io/gorm"
+)
+
+const defaultSQLTableName = "crawler_results"
+
+// resultRecord is the row layout of a SQLSink table. Rule results are stored
+// as their JSON encoding.
+type resultRecord struct {
+	ID                uint   `gorm:"primaryKey"`
+	RunID             string `gorm:"size:128;index"`
+	ProductID         string `gorm:"size:256;not null;index"`
+	OriginalProductID string `gorm:"size:256"`
+	Platform          string `gorm:"size:64"`
+	ProductURL        string
+	OriginalURL       string
+	FinalURL          string
+	CanonicalURL      string
+	ProxyURL          string
+	Title             string
+	Success           bool
+	HTTPStatusCode    int
+	ErrorMessage      string
+	Score             int
+	RuleResults       string
+	CreatedAt         time.Time
+}
+
+// SQLConfig configures a SQLSink.
+type SQLConfig struct {
+	// Database is the GORM handle rows are written through. Mandatory.
+	Database *gorm.DB
+	// TableName defaults to "crawler_results".
+	TableName string
+	// RunID is stored on every row so several runs can share one table.
+	RunID string
+}
+
+// SQLSink inserts one row per result into a table managed with GORM. The
+// database handle is owned by the caller; Close does not close it.
+type SQLSink struct {
+	database  *gorm.DB
+	tableName string
+	runID     string
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewSQLSink validates cfg. Call Migrate to create the table.
+func NewSQLSink(cfg SQLConfig) (*SQLSink, error) {
+	if cfg.Database == nil {
+		return nil, errors.New("sinks: sql database is required")
+	}
+	tableName := strings.TrimSpace(cfg.TableName)
+	if tableName == "" {
+		tableName = defaultSQLTableName
+	}
+	return &SQLSink{database: cfg.Database, tableName: tableName, runID: cfg.RunID}, nil
+}
+
+// Migrate creates or updates the results table.
+func (sink *SQLSink) Migrate(ctx context.Context) error {
+	if err := sink.database.WithContext(ctx).Table(sink.tableName).AutoMigrate(&resultRecord{}); err != nil {
+		return fmt.Errorf("sinks: migrate %s: %w", sink.tableName, err)
+	}
+	return nil
+}
+
+// WriteResults inserts results in a single statement.
+func (sink *SQLSink) WriteResults(ctx context.Context, results []*crawler.Result) error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if sink.closed {
+		return ErrSinkClosed
+	}
+	records := make([]resultRecord, 0, len(results))
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		record, err := sink.recordFor(result)
+		if err != nil {
+			return err
+		}
+		records = append(records, record)
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	if err := sink.database.WithContext(ctx).Table(sink.tableName).Create(&records).Error; err != nil {
+		return fmt.Errorf("sinks: insert into %s: %w", sink.tableName, err)
+	}
+	return nil
+}
+
+// Close stops further writes.
+func (sink *SQLSink) Close() error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	sink.closed = true
+	return nil
+}
+
+func (sink *SQLSink) recordFor(result *crawler.Result) (resultRecord, error) {
+	ruleResults := ""
+	if len(result.RuleResults) > 0 {
+		encoded, err := jsonMarshalFunc(result.RuleResults)
+		if err != nil {
+			return resultRecord{}, fmt.Errorf("sinks: encode rule results for %s: %w", result.ProductID, err)
+		}
+		ruleResults = string(encoded)
+	}
+	return resultRecord{
+		RunID:             sink.runID,
+		ProductID:         result.ProductID,
+		OriginalProductID: result.OriginalProductID,
+		Platform:          result.ProductPlatform,
+		ProductURL:        result.ProductURL,
+		OriginalURL:       result.OriginalURL,
+		FinalURL:          result.FinalURL,
+		CanonicalURL:      result.CanonicalURL,
+		ProxyURL:          crawler.RedactProxyURL(result.ProxyURL),
+		Title:             result.ProductTitle,
+		Success:           result.Success,
+		HTTPStatusCode:    result.HTTPStatusCode,
+		ErrorMessage:      result.ErrorMessage,
+		Score:             result.CalculateScore(0),
+		RuleResults:       ruleResults,
+	}, nil
+}
@@ -0,0 +1,43 @@
+package sources
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/tyemirov/utils/crawler"
+)
+
+// IDExtractor derives a product ID from a listed URL. Returning false skips
+// the URL, which is how category and content pages in a sitemap are filtered.
+type IDExtractor func(productURL string) (string, bool)
+
+// LastPathSegment uses the final non-empty path segment as the ID, so
+// "https://shop.test/p/B0042/" yields "B0042".
+func LastPathSegment(productURL string) (string, bool) {
+	parsed, err := url.Parse(productURL)
+	if err != nil {
+		return "", false
+	}
+	segment := path.Base(strings.TrimRight(parsed.Path, "/"))
+	if segment == "." || segment == "/" || segment == "" {
+		return "", false
+	}
+	return segment, true
+}
+
+// RegexpIDExtractor matches pattern against each URL and returns the
+// submatch named "id", or the first submatch when there is no such group,
+// following crawler.CompileProductURLPatterns. URLs that do not match are
+// skipped.
+func RegexpIDExtractor(pattern string) (IDExtractor, error) {
+	patterns, err := crawler.CompileProductURLPatterns([]string{pattern})
+	if err != nil {
+		return nil, fmt.Errorf("sources: %w", err)
+	}
+	return func(productURL string) (string, bool) {
+		id := patterns.ProductID(productURL)
+		return id, id != ""
+	}, nil
+}
@@ -0,0 +1,199 @@
+package sources
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	defaultCSVURLColumn = "url"
+	defaultCSVIDColumn  = "id"
+)
+
+var utf8ByteOrderMark = []byte{0xef, 0xbb, 0xbf}
+
+// CSVColumns names the header columns read from CSV feeds. Matching is case
+// insensitive. When the ID column is absent or empty for a row the
+// IDExtractor is applied to the URL instead.
+type CSVColumns struct {
+	URL string
+	ID  string
+	// Delimiter defaults to a comma.
+	Delimiter rune
+}
+
+// listingHandler receives entries as they are parsed. Each callback reports
+// false when parsing should stop.
+type listingHandler struct {
+	entry   func(listingEntry) bool
+	sitemap func(string) bool
+}
+
+// parseListing detects whether body is an XML listing (sitemap, sitemap
+// index, RSS or Atom) or a CSV feed and parses it accordingly.
+func parseListing(body io.Reader, columns CSVColumns, handler listingHandler) error {
+	buffered := bufio.NewReader(body)
+	if prefix, _ := buffered.Peek(len(utf8ByteOrderMark)); bytes.Equal(prefix, utf8ByteOrderMark) {
+		_, _ = buffered.Discard(len(utf8ByteOrderMark))
+	}
+	for {
+		next, err := buffered.Peek(1)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read listing: %w", err)
+		}
+		switch next[0] {
+		case ' ', '\t', '\r', '\n':
+			_, _ = buffered.Discard(1)
+			continue
+		case '<':
+			return parseXMLListing(buffered, handler)
+		}
+		return parseCSVListing(buffered, columns, handler)
+	}
+}
+
+type sitemapLocation struct {
+	Loc string `xml:"loc"`
+}
+
+type feedEntry struct {
+	Links []feedLink `xml:"link"`
+	ID    string     `xml:"http://base.google.com/ns/1.0 id"`
+}
+
+type feedLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Text string `xml:",chardata"`
+}
+
+// productURL prefers an Atom alternate link and falls back to the RSS link
+// element text.
+func (entry feedEntry) productURL() string {
+	for _, link := range entry.Links {
+		href := strings.TrimSpace(link.Href)
+		if href != "" && (link.Rel == "" || link.Rel == "alternate") {
+			return href
+		}
+		if text := strings.TrimSpace(link.Text); href == "" && text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+func parseXMLListing(body io.Reader, handler listingHandler) error {
+	decoder := xml.NewDecoder(body)
+	decoder.CharsetReader = charset.NewReaderLabel
+	root := ""
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			if root == "" {
+				return errors.New("empty xml listing")
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid xml listing: %w", err)
+		}
+		start, isStart := token.(xml.StartElement)
+		if !isStart {
+			continue
+		}
+		if root == "" {
+			root = start.Name.Local
+			switch root {
+			case "urlset", "sitemapindex", "rss", "feed", "RDF":
+				continue
+			}
+			return fmt.Errorf("unsupported listing root element <%s>", root)
+		}
+
+		keepGoing := true
+		switch {
+		case root == "sitemapindex" && start.Name.Local == "sitemap":
+			var location sitemapLocation
+			if err := decoder.DecodeElement(&location, &start); err != nil {
+				return fmt.Errorf("invalid sitemap index entry: %w", err)
+			}
+			if loc := strings.TrimSpace(location.Loc); loc != "" {
+				keepGoing = handler.sitemap(loc)
+			}
+		case root == "urlset" && start.Name.Local == "url":
+			var location sitemapLocation
+			if err := decoder.DecodeElement(&location, &start); err != nil {
+				return fmt.Errorf("invalid sitemap entry: %w", err)
+			}
+			keepGoing = handler.entry(listingEntry{url: location.Loc})
+		case root != "urlset" && root != "sitemapindex" && (start.Name.Local == "item" || start.Name.Local == "entry"):
+			var entry feedEntry
+			if err := decoder.DecodeElement(&entry, &start); err != nil {
+				return fmt.Errorf("invalid feed entry: %w", err)
+			}
+			keepGoing = handler.entry(listingEntry{url: entry.productURL(), id: entry.ID})
+		}
+		if !keepGoing {
+			return nil
+		}
+	}
+}
+
+func parseCSVListing(body io.Reader, columns CSVColumns, handler listingHandler) error {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	if columns.Delimiter != 0 {
+		reader.Comma = columns.Delimiter
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("invalid csv header: %w", err)
+	}
+	urlColumn, idColumn := -1, -1
+	for index, name := range header {
+		name = strings.TrimSpace(name)
+		switch {
+		case strings.EqualFold(name, columns.URL):
+			urlColumn = index
+		case columns.ID != "" && strings.EqualFold(name, columns.ID):
+			idColumn = index
+		}
+	}
+	if urlColumn < 0 {
+		return fmt.Errorf("csv header has no %q column", columns.URL)
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid csv record: %w", err)
+		}
+		entry := listingEntry{url: csvField(record, urlColumn), id: csvField(record, idColumn)}
+		if !handler.entry(entry) {
+			return nil
+		}
+	}
+}
+
+func csvField(record []string, column int) string {
+	if column < 0 || column >= len(record) {
+		return ""
+	}
+	return record[column]
+}
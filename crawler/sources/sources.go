@@ -0,0 +1,365 @@
+// Package sources builds crawler.Product lists from the listings platforms
+// already publish: XML sitemaps and sitemap indexes (optionally gzipped),
+// RSS and Atom feeds, and CSV product feeds. Each listed URL is passed through
+// an IDExtractor, duplicates are dropped, and the resulting products are
+// streamed so large catalogues can be fed into Service.Enqueue as they arrive.
+//
+// Fetches go through crawler.NewHTTPClient by default, so listings are read via
+// the same proxies and timeouts as the crawl itself.
+package sources
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/tyemirov/utils/crawler"
+)
+
+const (
+	defaultMaxSitemapDepth = 3
+	defaultMaxListingSize  = 256 << 20
+	gzipMagicFirstByte     = 0x1f
+	gzipMagicSecondByte    = 0x8b
+)
+
+// Item is one value produced by Stream: either a product or an error
+// describing a listing that could not be read. Errors do not stop the stream.
+type Item struct {
+	Product crawler.Product
+	Err     error
+}
+
+// Reader fetches listings and converts their entries into products.
+type Reader struct {
+	platform        string
+	client          *http.Client
+	idExtractor     IDExtractor
+	csvColumns      CSVColumns
+	maxSitemapDepth int
+	maxListingSize  int64
+	logger          crawler.Logger
+	// scraper is set by WithScraperConfig; the client is built from it once
+	// every option has been applied, so it shares the final logger.
+	scraper *crawler.ScraperConfig
+}
+
+// Option customises a Reader.
+type Option func(*Reader) error
+
+// WithHTTPClient overrides the client used for fetches.
+func WithHTTPClient(client *http.Client) Option {
+	return func(reader *Reader) error {
+		if client == nil {
+			return errors.New("sources: http client is required")
+		}
+		reader.client = client
+		reader.scraper = nil
+		return nil
+	}
+}
+
+// WithScraperConfig fetches listings through crawler.NewHTTPClient(scraper),
+// sharing the crawler's proxy pool and timeouts.
+func WithScraperConfig(scraper crawler.ScraperConfig) Option {
+	return func(reader *Reader) error {
+		reader.scraper = &scraper
+		reader.client = nil
+		return nil
+	}
+}
+
+// WithIDExtractor sets how product IDs are derived from listed URLs. The
+// default is LastPathSegment.
+func WithIDExtractor(extractor IDExtractor) Option {
+	return func(reader *Reader) error {
+		if extractor == nil {
+			return errors.New("sources: id extractor is required")
+		}
+		reader.idExtractor = extractor
+		return nil
+	}
+}
+
+// WithCSVColumns sets the header names read from CSV feeds.
+func WithCSVColumns(columns CSVColumns) Option {
+	return func(reader *Reader) error {
+		if strings.TrimSpace(columns.URL) == "" {
+			return errors.New("sources: csv url column is required")
+		}
+		reader.csvColumns = columns
+		return nil
+	}
+}
+
+// WithMaxSitemapDepth limits how many sitemap index levels are followed.
+func WithMaxSitemapDepth(depth int) Option {
+	return func(reader *Reader) error {
+		if depth < 1 {
+			return fmt.Errorf("sources: max sitemap depth must be at least 1 (got %d)", depth)
+		}
+		reader.maxSitemapDepth = depth
+		return nil
+	}
+}
+
+// WithMaxListingSize limits how many bytes are read from one listing, after
+// gzip inflation. The default is 256 MiB.
+func WithMaxListingSize(size int64) Option {
+	return func(reader *Reader) error {
+		if size < 1 {
+			return fmt.Errorf("sources: max listing size must be at least 1 byte (got %d)", size)
+		}
+		reader.maxListingSize = size
+		return nil
+	}
+}
+
+// WithLogger routes debug output about skipped entries and nested sitemaps,
+// and the proxy warnings of a client built by WithScraperConfig.
+func WithLogger(logger crawler.Logger) Option {
+	return func(reader *Reader) error {
+		reader.logger = crawler.EnsureLogger(logger)
+		return nil
+	}
+}
+
+// NewReader constructs a Reader producing products for platform. Without
+// WithHTTPClient or WithScraperConfig it uses crawler.NewHTTPClient with a
+// zero ScraperConfig, which dials directly.
+func NewReader(platform string, options ...Option) (*Reader, error) {
+	platform = strings.TrimSpace(platform)
+	if platform == "" {
+		return nil, errors.New("sources: platform is required")
+	}
+	reader := &Reader{
+		platform:        platform,
+		idExtractor:     LastPathSegment,
+		csvColumns:      CSVColumns{URL: defaultCSVURLColumn, ID: defaultCSVIDColumn},
+		maxSitemapDepth: defaultMaxSitemapDepth,
+		maxListingSize:  defaultMaxListingSize,
+		logger:          crawler.EnsureLogger(nil),
+	}
+	for _, option := range options {
+		if err := option(reader); err != nil {
+			return nil, err
+		}
+	}
+	if reader.client == nil {
+		scraper := crawler.ScraperConfig{}
+		if reader.scraper != nil {
+			scraper = *reader.scraper
+		}
+		client, err := crawler.NewHTTPClient(scraper, reader.logger)
+		if err != nil {
+			return nil, fmt.Errorf("sources: %w", err)
+		}
+		reader.client = client
+	}
+	return reader, nil
+}
+
+// Stream reads each listing URL in order and sends the products it yields,
+// deduplicated by ID across all listings. The format of each listing is
+// detected from its content. The channel is closed once every listing has
+// been read or ctx is cancelled.
+func (reader *Reader) Stream(ctx context.Context, listingURLs ...string) <-chan Item {
+	items := make(chan Item)
+	go func() {
+		defer close(items)
+		run := &streamRun{
+			reader:  reader,
+			ctx:     ctx,
+			items:   items,
+			seenIDs: make(map[string]struct{}),
+			visited: make(map[string]struct{}),
+		}
+		for _, listingURL := range listingURLs {
+			if !run.readListing(strings.TrimSpace(listingURL), 0) {
+				return
+			}
+		}
+	}()
+	return items
+}
+
+// Collect drains Stream into a slice. Listing errors are joined and returned
+// alongside every product that could be read.
+func (reader *Reader) Collect(ctx context.Context, listingURLs ...string) ([]crawler.Product, error) {
+	var products []crawler.Product
+	var errs []error
+	for item := range reader.Stream(ctx, listingURLs...) {
+		if item.Err != nil {
+			errs = append(errs, item.Err)
+			continue
+		}
+		products = append(products, item.Product)
+	}
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return products, errors.Join(errs...)
+}
+
+// streamRun holds the per-Stream dedup state.
+type streamRun struct {
+	reader  *Reader
+	ctx     context.Context
+	items   chan<- Item
+	seenIDs map[string]struct{}
+	visited map[string]struct{}
+}
+
+// listingEntry is one URL found in a listing, with an ID when the listing
+// publishes one explicitly.
+type listingEntry struct {
+	url string
+	id  string
+}
+
+// readListing fetches and parses one listing. It reports false once the
+// consumer is gone and streaming must stop.
+func (run *streamRun) readListing(listingURL string, depth int) bool {
+	if listingURL == "" {
+		return true
+	}
+	if _, seen := run.visited[listingURL]; seen {
+		run.reader.logger.Debug("Skipping already read listing %s", listingURL)
+		return true
+	}
+	run.visited[listingURL] = struct{}{}
+
+	body, err := run.reader.open(run.ctx, listingURL)
+	if err != nil {
+		return run.fail(err)
+	}
+	defer body.Close()
+
+	var nestedSitemaps []string
+	handler := listingHandler{
+		entry: run.emit,
+		sitemap: func(sitemapURL string) bool {
+			nestedSitemaps = append(nestedSitemaps, sitemapURL)
+			return true
+		},
+	}
+	if err := parseListing(body, run.reader.csvColumns, handler); err != nil {
+		if !run.fail(fmt.Errorf("sources: %s: %w", listingURL, err)) {
+			return false
+		}
+	}
+	if run.ctx.Err() != nil {
+		return false
+	}
+
+	if len(nestedSitemaps) > 0 && depth >= run.reader.maxSitemapDepth {
+		return run.fail(fmt.Errorf("sources: %s: sitemap index nesting exceeds %d levels", listingURL, run.reader.maxSitemapDepth))
+	}
+	for _, sitemapURL := range nestedSitemaps {
+		run.reader.logger.Debug("Following nested sitemap %s", sitemapURL)
+		if !run.readListing(sitemapURL, depth+1) {
+			return false
+		}
+	}
+	return true
+}
+
+func (run *streamRun) emit(entry listingEntry) bool {
+	productURL := strings.TrimSpace(entry.url)
+	if productURL == "" {
+		return true
+	}
+	id := strings.TrimSpace(entry.id)
+	if id == "" {
+		extracted, ok := run.reader.idExtractor(productURL)
+		if !ok {
+			run.reader.logger.Debug("No product id in %s; skipping", productURL)
+			return true
+		}
+		id = extracted
+	}
+	if _, seen := run.seenIDs[id]; seen {
+		return true
+	}
+	run.seenIDs[id] = struct{}{}
+	return run.send(Item{Product: crawler.Product{ID: id, Platform: run.reader.platform, URL: productURL}})
+}
+
+func (run *streamRun) fail(err error) bool {
+	return run.send(Item{Err: err})
+}
+
+func (run *streamRun) send(item Item) bool {
+	select {
+	case run.items <- item:
+		return true
+	case <-run.ctx.Done():
+		return false
+	}
+}
+
+// open fetches listingURL and returns its body, transparently inflating gzip
+// payloads whether or not the server labelled them. Reading more than
+// maxListingSize bytes fails, which also stops gzip bombs.
+func (reader *Reader) open(ctx context.Context, listingURL string) (io.ReadCloser, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, listingURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("sources: %s: %w", listingURL, err)
+	}
+	response, err := reader.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("sources: %s: %w", listingURL, err)
+	}
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		response.Body.Close()
+		return nil, fmt.Errorf("sources: %s: unexpected status %d", listingURL, response.StatusCode)
+	}
+
+	buffered := bufio.NewReader(response.Body)
+	magic, _ := buffered.Peek(2)
+	if !bytes.Equal(magic, []byte{gzipMagicFirstByte, gzipMagicSecondByte}) {
+		return readCloser{Reader: reader.limit(buffered), closer: response.Body}, nil
+	}
+	inflated, err := gzip.NewReader(buffered)
+	if err != nil {
+		response.Body.Close()
+		return nil, fmt.Errorf("sources: %s: %w", listingURL, err)
+	}
+	return readCloser{Reader: reader.limit(inflated), closer: response.Body}, nil
+}
+
+func (reader *Reader) limit(body io.Reader) io.Reader {
+	return &sizeLimitedReader{reader: io.LimitReader(body, reader.maxListingSize+1), max: reader.maxListingSize}
+}
+
+// sizeLimitedReader fails once more than max bytes were read, rather than
+// silently truncating the listing the way io.LimitReader alone would.
+type sizeLimitedReader struct {
+	reader io.Reader
+	read   int64
+	max    int64
+}
+
+func (body *sizeLimitedReader) Read(buffer []byte) (int, error) {
+	count, err := body.reader.Read(buffer)
+	body.read += int64(count)
+	if body.read > body.max {
+		return count, fmt.Errorf("listing exceeds %d bytes", body.max)
+	}
+	return count, err
+}
+
+type readCloser struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (body readCloser) Close() error {
+	return body.closer.Close()
+}
@@ -0,0 +1,408 @@
+package sources
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tyemirov/utils/crawler"
+)
+
+func newListingServer(t *testing.T, listings map[string]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, ok := listings[request.URL.Path]
+		if !ok {
+			http.NotFound(writer, request)
+			return
+		}
+		body = strings.ReplaceAll(body, "{{base}}", "http://"+request.Host)
+		if strings.HasSuffix(request.URL.Path, ".gz") {
+			var compressed bytes.Buffer
+			gzipWriter := gzip.NewWriter(&compressed)
+			_, _ = gzipWriter.Write([]byte(body))
+			_ = gzipWriter.Close()
+			payload := compressed.Bytes()
+			if strings.HasSuffix(request.URL.Path, "-truncated.gz") {
+				payload = payload[:len(payload)/2]
+			}
+			writer.Header().Set("Content-Type", "application/x-gzip")
+			_, _ = writer.Write(payload)
+			return
+		}
+		_, _ = writer.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// truncatedCSVListing returns a CSV feed large enough that serving half of
+// its gzip stream cuts a record short. Every row has an empty URL.
+func truncatedCSVListing() string {
+	var listing strings.Builder
+	listing.WriteString("url,filler\n")
+	for index := 0; index < 2048; index++ {
+		listing.WriteString(",")
+		listing.WriteString(strconv.Itoa(index * 7919 % 104729))
+		listing.WriteString("\n")
+	}
+	return listing.String()
+}
+
+// recordingLogger keeps every warning it receives.
+type recordingLogger struct {
+	mu       sync.Mutex
+	warnings []string
+}
+
+func (logger *recordingLogger) Debug(string, ...interface{}) {}
+func (logger *recordingLogger) Info(string, ...interface{})  {}
+func (logger *recordingLogger) Error(string, ...interface{}) {}
+
+func (logger *recordingLogger) Warning(format string, args ...interface{}) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	logger.warnings = append(logger.warnings, fmt.Sprintf(format, args...))
+}
+
+func productIDs(products []crawler.Product) []string {
+	ids := make([]string, 0, len(products))
+	for _, product := range products {
+		ids = append(ids, product.ID)
+	}
+	return ids
+}
+
+func TestCollectFollowsSitemapIndexesAndGzip(t *testing.T) {
+	t.Parallel()
+
+	server := newListingServer(t, map[string]string{
+		"/sitemap.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>{{base}}/products-1.xml.gz</loc></sitemap>
+  <sitemap><loc>{{base}}/products-2.xml</loc></sitemap>
+  <sitemap><loc> </loc></sitemap>
+  <sitemap><loc>{{base}}/missing.xml</loc></sitemap>
+</sitemapindex>`,
+		"/products-1.xml.gz": `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:image="http://www.google.com/schemas/sitemap-image/1.1">
+  <url><loc>https://shop.test/p/A1</loc><image:image><image:loc>https://cdn.test/a1.jpg</image:loc></image:image></url>
+  <url><loc>https://shop.test/about</loc></url>
+  <url><loc>https://shop.test/p/B2/</loc></url>
+</urlset>`,
+		"/products-2.xml": "\xef\xbb\xbf\n<urlset><url><loc>https://shop.test/p/B2?ref=dup</loc></url><url><loc>https://shop.test/p/C3</loc></url><url><loc></loc></url></urlset>",
+	})
+
+	extractor, err := RegexpIDExtractor(`/p/(?P<id>[A-Z0-9]+)`)
+	require.NoError(t, err)
+	reader, err := NewReader(" AMZN ", WithIDExtractor(extractor), WithLogger(nil))
+	require.NoError(t, err)
+
+	products, err := reader.Collect(context.Background(), server.URL+"/sitemap.xml", server.URL+"/sitemap.xml")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "missing.xml: unexpected status 404")
+	require.Equal(t, []string{"A1", "B2", "C3"}, productIDs(products))
+	require.Equal(t, crawler.Product{ID: "A1", Platform: "AMZN", URL: "https://shop.test/p/A1"}, products[0])
+	require.Equal(t, "https://shop.test/p/B2/", products[1].URL)
+}
+
+func TestCollectReadsRSSAtomAndCSVFeeds(t *testing.T) {
+	t.Parallel()
+
+	server := newListingServer(t, map[string]string{
+		"/feed.rss": `<?xml version="1.0" encoding="ISO-8859-1"?>
+<rss version="2.0" xmlns:g="http://base.google.com/ns/1.0" xmlns:atom="http://www.w3.org/2005/Atom">
+  <channel>
+    <link>https://shop.test/</link>
+    <atom:link href="https://shop.test/feed.rss" rel="self"/>
+    <item><title>Caf` + "\xe9" + `</title><link> https://shop.test/item/cafe </link><g:id>SKU-1</g:id></item>
+    <item><link>https://shop.test/item/kettle</link></item>
+  </channel>
+</rss>`,
+		"/feed.atom": `<feed xmlns="http://www.w3.org/2005/Atom">
+  <link href="https://shop.test/" rel="alternate"/>
+  <entry><link rel="edit" href="https://shop.test/edit/1"/><link href="https://shop.test/item/lamp"/></entry>
+  <entry><link rel="alternate" href="https://shop.test/item/kettle"/></entry>
+  <entry><title>no link</title></entry>
+</feed>`,
+		"/feed.tsv": "Product ID\tLINK\nSKU-0\t\n  SKU-7\thttps://shop.test/item/desk\nshort\n\thttps://shop.test/item/chair\n",
+	})
+
+	reader, err := NewReader("SHOP", WithCSVColumns(CSVColumns{URL: "link", ID: "product id", Delimiter: '\t'}))
+	require.NoError(t, err)
+
+	products, err := reader.Collect(context.Background(), server.URL+"/feed.rss", server.URL+"/feed.atom", server.URL+"/feed.tsv", " ")
+	require.NoError(t, err)
+	require.Equal(t, []string{"SKU-1", "kettle", "lamp", "SKU-7", "chair"}, productIDs(products))
+	require.Equal(t, "https://shop.test/item/cafe", products[0].URL)
+}
+
+func TestCollectReportsMalformedListings(t *testing.T) {
+	t.Parallel()
+
+	server := newListingServer(t, map[string]string{
+		"/html":                   `<html><body>not a listing</body></html>`,
+		"/broken.xml":             `<urlset><url><loc>https://shop.test/p/1</loc></url></urlset></extra>`,
+		"/bad-url.xml":            `<urlset><url><loc>https://shop.test/p/2</loc><loc></url></urlset>`,
+		"/bad-item.xml":           `<rss><channel><item><link></item></channel></rss>`,
+		"/bad-index":              `<sitemapindex><sitemap><loc></sitemap></sitemapindex>`,
+		"/no-url.csv":             "sku,name\n1,Desk\n",
+		"/quoted.csv":             "url\n\"https://shop.test/p/3\"x\"\n",
+		"/truncated-truncated.gz": truncatedCSVListing(),
+		"/empty":                  "  \n",
+		"/comment":                "<!-- nothing -->",
+		"/header-truncated.gz":    "url",
+		"/gzip":                   "\x1f\x8bbroken-header",
+	})
+	reader, err := NewReader("SHOP")
+	require.NoError(t, err)
+
+	listings := []string{"/html", "/broken.xml", "/bad-url.xml", "/bad-item.xml", "/bad-index", "/no-url.csv", "/truncated-truncated.gz", "/empty", "/comment", "/gzip", "/header-truncated.gz"}
+	urls := make([]string, 0, len(listings)+1)
+	for _, listing := range listings {
+		urls = append(urls, server.URL+listing)
+	}
+	urls = append(urls, "http://%zz")
+
+	products, err := reader.Collect(context.Background(), urls...)
+	require.Equal(t, []string{"1"}, productIDs(products))
+	for _, expected := range []string{
+		"unsupported listing root element <html>",
+		"broken.xml: invalid xml listing",
+		"invalid sitemap entry",
+		"invalid feed entry",
+		"invalid sitemap index entry",
+		`csv header has no "url" column`,
+		"invalid csv record",
+		"header-truncated.gz: read listing",
+		"comment: empty xml listing",
+		"gzip: gzip: invalid header",
+		"invalid URL escape",
+	} {
+		require.Contains(t, err.Error(), expected)
+	}
+
+	quoted, err := reader.Collect(context.Background(), server.URL+"/quoted.csv")
+	require.NoError(t, err)
+	require.Len(t, quoted, 1)
+}
+
+func TestCollectLimitsSitemapNesting(t *testing.T) {
+	t.Parallel()
+
+	server := newListingServer(t, map[string]string{
+		"/index-1.xml":  `<sitemapindex><sitemap><loc>{{base}}/index-2.xml</loc></sitemap></sitemapindex>`,
+		"/index-2.xml":  `<sitemapindex><sitemap><loc>{{base}}/index-1.xml</loc></sitemap><sitemap><loc>{{base}}/products.xml</loc></sitemap></sitemapindex>`,
+		"/products.xml": `<urlset><url><loc>https://shop.test/p/1</loc></url></urlset>`,
+	})
+
+	reader, err := NewReader("SHOP")
+	require.NoError(t, err)
+	products, err := reader.Collect(context.Background(), server.URL+"/index-1.xml")
+	require.NoError(t, err)
+	require.Equal(t, []string{"1"}, productIDs(products))
+
+	shallow, err := NewReader("SHOP", WithMaxSitemapDepth(1))
+	require.NoError(t, err)
+	products, err = shallow.Collect(context.Background(), server.URL+"/index-1.xml")
+	require.Empty(t, products)
+	require.ErrorContains(t, err, "sitemap index nesting exceeds 1 levels")
+}
+
+func TestStreamStopsWhenContextIsCancelled(t *testing.T) {
+	t.Parallel()
+
+	var listing strings.Builder
+	listing.WriteString("url\n")
+	for index := 0; index < 50; index++ {
+		listing.WriteString("https://shop.test/p/")
+		listing.WriteString(strings.Repeat("x", index+1))
+		listing.WriteString("\n")
+	}
+	server := newListingServer(t, map[string]string{
+		"/feed.csv":  listing.String(),
+		"/index.xml": `<sitemapindex><sitemap><loc>{{base}}/feed.csv</loc></sitemap></sitemapindex>`,
+		"/bad.xml":   `<urlset><url>`,
+	})
+
+	reader, err := NewReader("SHOP", WithScraperConfig(crawler.ScraperConfig{}))
+	require.NoError(t, err)
+
+	for _, listingURL := range []string{server.URL + "/feed.csv", server.URL + "/index.xml", server.URL + "/bad.xml", server.URL + "/missing"} {
+		ctx, cancel := context.WithCancel(context.Background())
+		items := reader.Stream(ctx, listingURL, listingURL)
+		if listingURL == server.URL+"/feed.csv" {
+			first := <-items
+			require.NoError(t, first.Err)
+			require.Equal(t, "x", first.Product.ID)
+		}
+		cancel()
+		for range items {
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	products, err := reader.Collect(ctx, server.URL+"/feed.csv")
+	require.Empty(t, products)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (roundTrip roundTripperFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return roundTrip(request)
+}
+
+func TestReadListingStopsWithoutConsumer(t *testing.T) {
+	t.Parallel()
+
+	listings := map[string]string{
+		"/feed.xml":  `<rss><channel><item><link>https://shop.test/p/A1</link></item><item><link>https://shop.test/p/B2</link></item></channel></rss>`,
+		"/index.xml": `<sitemapindex><sitemap><loc>http://shop.test/feed.xml</loc></sitemap><sitemap><loc>http://shop.test/other.xml</loc></sitemap></sitemapindex>`,
+		"/bad.xml":   `<urlset><url>`,
+	}
+	// The client ignores cancellation so the listings are still read, and it
+	// cancels the run once the feed is fetched, so every send then finds the
+	// context done with nobody receiving.
+	var cancelRun context.CancelFunc
+	client := &http.Client{Transport: roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+		if request.URL.Path == "/feed.xml" {
+			cancelRun()
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(listings[request.URL.Path]))}, nil
+	})}
+	reader, err := NewReader("SHOP", WithHTTPClient(client))
+	require.NoError(t, err)
+
+	for _, listingURL := range []string{"http://shop.test/feed.xml", "http://shop.test/index.xml", "http://shop.test/bad.xml"} {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancelRun = cancel
+		if listingURL == "http://shop.test/bad.xml" {
+			cancel()
+		}
+		run := &streamRun{reader: reader, ctx: ctx, items: make(chan Item), seenIDs: map[string]struct{}{}, visited: map[string]struct{}{}}
+		require.False(t, run.readListing(listingURL, 0), listingURL)
+		cancel()
+	}
+}
+
+func TestReaderOptionsValidateInput(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewReader(" ")
+	require.ErrorContains(t, err, "platform is required")
+	_, err = NewReader("SHOP", WithHTTPClient(nil))
+	require.ErrorContains(t, err, "http client is required")
+	_, err = NewReader("SHOP", WithIDExtractor(nil))
+	require.ErrorContains(t, err, "id extractor is required")
+	_, err = NewReader("SHOP", WithCSVColumns(CSVColumns{}))
+	require.ErrorContains(t, err, "csv url column is required")
+	_, err = NewReader("SHOP", WithMaxSitemapDepth(0))
+	require.ErrorContains(t, err, "max sitemap depth must be at least 1")
+	_, err = NewReader("SHOP", WithMaxListingSize(0))
+	require.ErrorContains(t, err, "max listing size must be at least 1 byte")
+	_, err = NewReader("SHOP", WithScraperConfig(crawler.ScraperConfig{ProxyList: []string{"://bad"}}))
+	require.ErrorContains(t, err, "sources:")
+
+	reader, err := NewReader("SHOP", WithHTTPClient(http.DefaultClient))
+	require.NoError(t, err)
+	require.Same(t, http.DefaultClient, reader.client)
+	reader, err = NewReader("SHOP", WithScraperConfig(crawler.ScraperConfig{}), WithHTTPClient(http.DefaultClient))
+	require.NoError(t, err)
+	require.Same(t, http.DefaultClient, reader.client)
+	reader, err = NewReader("SHOP", WithHTTPClient(http.DefaultClient), WithScraperConfig(crawler.ScraperConfig{}))
+	require.NoError(t, err)
+	require.NotSame(t, http.DefaultClient, reader.client)
+}
+
+func TestCollectRejectsOversizedListings(t *testing.T) {
+	t.Parallel()
+
+	sitemap := `<urlset><url><loc>https://shop.test/p/A1</loc></url>` + strings.Repeat(" ", 1<<20) + `</urlset>`
+	server := newListingServer(t, map[string]string{
+		"/bomb.xml.gz": sitemap,
+		"/padded.xml":  sitemap,
+		"/wide.csv":    "url," + strings.Repeat("x", 8192) + "\n",
+		"/small.xml":   `<urlset><url><loc>https://shop.test/p/B2</loc></url></urlset>`,
+	})
+
+	reader, err := NewReader("SHOP", WithMaxListingSize(4096))
+	require.NoError(t, err)
+
+	products, err := reader.Collect(context.Background(), server.URL+"/bomb.xml.gz", server.URL+"/padded.xml", server.URL+"/wide.csv", server.URL+"/small.xml")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "bomb.xml.gz: invalid xml listing: listing exceeds 4096 bytes")
+	require.Contains(t, err.Error(), "padded.xml: invalid xml listing: listing exceeds 4096 bytes")
+	require.Contains(t, err.Error(), "wide.csv: invalid csv header: listing exceeds 4096 bytes")
+	require.Equal(t, []string{"A1", "B2"}, productIDs(products))
+}
+
+func TestReaderAppliesLoggerGivenAfterScraperConfig(t *testing.T) {
+	t.Parallel()
+
+	proxy := newListingServer(t, map[string]string{
+		"/products.xml": `<urlset><url><loc>https://shop.test/p/A1</loc></url></urlset>`,
+	})
+	var loads int
+	var loadsMu sync.Mutex
+	source := crawler.ProxySourceFunc(func() ([]string, error) {
+		loadsMu.Lock()
+		defer loadsMu.Unlock()
+		loads++
+		if loads > 1 {
+			return nil, errors.New("proxy file unreadable")
+		}
+		return []string{proxy.URL}, nil
+	})
+	logger := &recordingLogger{}
+
+	reader, err := NewReader("SHOP", WithScraperConfig(crawler.ScraperConfig{ProxySource: source}), WithLogger(logger))
+	require.NoError(t, err)
+
+	products, err := reader.Collect(context.Background(), "http://catalog.test/products.xml")
+	require.NoError(t, err)
+	require.Equal(t, []string{"A1"}, productIDs(products))
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	require.NotEmpty(t, logger.warnings)
+	require.Contains(t, logger.warnings[0], "proxy file unreadable")
+}
+
+func TestIDExtractors(t *testing.T) {
+	t.Parallel()
+
+	for input, expected := range map[string]string{
+		"https://shop.test/p/B0042/":    "B0042",
+		"https://shop.test/p/B0042?x=1": "B0042",
+		"https://shop.test/":            "",
+		"https://shop.test":             "",
+		"://bad":                        "",
+	} {
+		id, ok := LastPathSegment(input)
+		require.Equal(t, expected != "", ok, input)
+		require.Equal(t, expected, id, input)
+	}
+
+	extractor, err := RegexpIDExtractor(`dp/([A-Z0-9]{10})|/item/(\w+)`)
+	require.NoError(t, err)
+	id, ok := extractor("https://amazon.test/x/dp/B000000001")
+	require.True(t, ok)
+	require.Equal(t, "B000000001", id)
+	_, ok = extractor("https://amazon.test/item/lamp")
+	require.False(t, ok)
+
+	_, err = RegexpIDExtractor(`[`)
+	require.ErrorContains(t, err, `sources: product id pattern "["`)
+	_, err = RegexpIDExtractor(`/p/\d+`)
+	require.ErrorContains(t, err, "has no capture group")
+}
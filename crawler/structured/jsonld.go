@@ -0,0 +1,378 @@
+package structured
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const jsonLDMediaType = "application/ld+json"
+
+var (
+	jsonLDPrefixes = []string{"//<![CDATA[", "<![CDATA[", "<!--"}
+	jsonLDSuffixes = []string{"//]]>", "]]>", "-->"}
+)
+
+func extractJSONLD(document *goquery.Document, data *Data) {
+	blockIndex := 0
+	document.Find("script").Each(func(_ int, script *goquery.Selection) {
+		scriptType, _ := script.Attr("type")
+		if !strings.EqualFold(strings.TrimSpace(scriptType), jsonLDMediaType) {
+			return
+		}
+		blockIndex++
+		values, err := parseJSONLD(script.Text())
+		if err != nil {
+			data.Errors = append(data.Errors, fmt.Errorf("structured: json-ld block %d: %w", blockIndex, err))
+			return
+		}
+		for _, value := range values {
+			collectItems(value, SourceJSONLD, data)
+		}
+	})
+}
+
+// parseJSONLD decodes one script body. Blocks often arrive wrapped in comment
+// or CDATA markers, with trailing commas, raw newlines inside strings, JS
+// comments or several concatenated objects; those are repaired before giving up.
+func parseJSONLD(raw string) ([]interface{}, error) {
+	cleaned := strings.TrimSpace(raw)
+	for _, prefix := range jsonLDPrefixes {
+		cleaned = strings.TrimSpace(strings.TrimPrefix(cleaned, prefix))
+	}
+	for _, suffix := range jsonLDSuffixes {
+		cleaned = strings.TrimSpace(strings.TrimSuffix(cleaned, suffix))
+	}
+	if cleaned == "" {
+		return nil, nil
+	}
+	values, err := decodeJSONValues(cleaned)
+	if err == nil {
+		return values, nil
+	}
+	if repairedValues, repairErr := decodeJSONValues(repairJSON(cleaned)); repairErr == nil {
+		return repairedValues, nil
+	}
+	return nil, err
+}
+
+func decodeJSONValues(content string) ([]interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(content))
+	var values []interface{}
+	for {
+		var value interface{}
+		err := decoder.Decode(&value)
+		if errors.Is(err, io.EOF) {
+			return values, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+}
+
+// repairJSON escapes control characters inside strings and drops comments,
+// trailing commas and stray semicolons outside them.
+func repairJSON(content string) string {
+	var builder strings.Builder
+	builder.Grow(len(content))
+	inString := false
+	escaped := false
+	for index := 0; index < len(content); index++ {
+		character := content[index]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+				builder.WriteByte(character)
+			case character == '\\':
+				escaped = true
+				builder.WriteByte(character)
+			case character == '"':
+				inString = false
+				builder.WriteByte(character)
+			case character == '\n':
+				builder.WriteString(`\n`)
+			case character == '\r':
+				builder.WriteString(`\r`)
+			case character == '\t':
+				builder.WriteString(`\t`)
+			case character < 0x20:
+				builder.WriteString(fmt.Sprintf(`\u%04x`, character))
+			default:
+				builder.WriteByte(character)
+			}
+			continue
+		}
+		switch {
+		case character == '"':
+			inString = true
+			builder.WriteByte(character)
+		case character == '/' && index+1 < len(content) && content[index+1] == '/':
+			for index < len(content) && content[index] != '\n' {
+				index++
+			}
+		case character == '/' && index+1 < len(content) && content[index+1] == '*':
+			end := strings.Index(content[index+2:], "*/")
+			if end < 0 {
+				return builder.String()
+			}
+			index += end + 3
+		case character == ',' && closesAfterWhitespace(content[index+1:]):
+		case character == ';':
+		default:
+			builder.WriteByte(character)
+		}
+	}
+	return builder.String()
+}
+
+func closesAfterWhitespace(rest string) bool {
+	trimmed := strings.TrimLeft(rest, " \t\r\n")
+	return strings.HasPrefix(trimmed, "}") || strings.HasPrefix(trimmed, "]")
+}
+
+// collectItems walks a decoded JSON-LD (or microdata) tree and records the
+// products and breadcrumb lists it finds, including inside @graph or
+// mainEntity wrappers.
+func collectItems(node interface{}, source string, data *Data) {
+	switch value := node.(type) {
+	case []interface{}:
+		for _, child := range value {
+			collectItems(child, source, data)
+		}
+	case map[string]interface{}:
+		switch {
+		case hasType(value, "Product", "ProductGroup", "IndividualProduct", "ProductModel"):
+			data.Products = append(data.Products, parseProduct(value, source))
+			return
+		case hasType(value, "BreadcrumbList"):
+			data.Breadcrumbs = append(data.Breadcrumbs, parseBreadcrumbList(value))
+			return
+		}
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			collectItems(value[key], source, data)
+		}
+	}
+}
+
+func hasType(item map[string]interface{}, candidates ...string) bool {
+	for _, typeName := range typeNames(item["@type"]) {
+		for _, candidate := range candidates {
+			if strings.EqualFold(typeName, candidate) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func typeNames(value interface{}) []string {
+	var names []string
+	switch typed := value.(type) {
+	case string:
+		names = append(names, trimSchemaPrefix(typed))
+	case []interface{}:
+		for _, entry := range typed {
+			names = append(names, typeNames(entry)...)
+		}
+	}
+	return names
+}
+
+// trimSchemaPrefix reduces "https://schema.org/InStock" to "InStock".
+func trimSchemaPrefix(value string) string {
+	trimmed := strings.TrimSpace(value)
+	if index := strings.LastIndexAny(trimmed, "/#"); index >= 0 {
+		return trimmed[index+1:]
+	}
+	return trimmed
+}
+
+func parseProduct(item map[string]interface{}, source string) Product {
+	product := Product{
+		Source:      source,
+		Name:        textValue(item["name"]),
+		Description: textValue(item["description"]),
+		SKU:         textValue(item["sku"]),
+		MPN:         textValue(item["mpn"]),
+		Brand:       textValue(item["brand"]),
+		URL:         linkValue(item["url"]),
+		Images:      linkValues(item["image"]),
+		Offers:      parseOffers(item["offers"]),
+	}
+	for _, key := range []string{"gtin", "gtin13", "gtin12", "gtin14", "gtin8"} {
+		if gtin := textValue(item[key]); gtin != "" {
+			product.GTIN = gtin
+			break
+		}
+	}
+	if rating, ok := firstMap(item["aggregateRating"]); ok {
+		product.AggregateRating = &AggregateRating{
+			RatingValue: numberValue(rating["ratingValue"]),
+			BestRating:  numberValue(rating["bestRating"]),
+			WorstRating: numberValue(rating["worstRating"]),
+			ReviewCount: int(numberValue(rating["reviewCount"])),
+			RatingCount: int(numberValue(rating["ratingCount"])),
+		}
+	}
+	return product
+}
+
+func parseOffers(value interface{}) []Offer {
+	var offers []Offer
+	switch typed := value.(type) {
+	case []interface{}:
+		for _, entry := range typed {
+			offers = append(offers, parseOffers(entry)...)
+		}
+	case map[string]interface{}:
+		offer := Offer{
+			Price:         textValue(typed["price"]),
+			LowPrice:      textValue(typed["lowPrice"]),
+			HighPrice:     textValue(typed["highPrice"]),
+			PriceCurrency: textValue(typed["priceCurrency"]),
+			Availability:  trimSchemaPrefix(textValue(typed["availability"])),
+			ItemCondition: trimSchemaPrefix(textValue(typed["itemCondition"])),
+			URL:           linkValue(typed["url"]),
+			Seller:        textValue(typed["seller"]),
+		}
+		if specification, ok := firstMap(typed["priceSpecification"]); ok {
+			if offer.Price == "" {
+				offer.Price = textValue(specification["price"])
+			}
+			if offer.PriceCurrency == "" {
+				offer.PriceCurrency = textValue(specification["priceCurrency"])
+			}
+		}
+		offers = append(offers, offer)
+		if hasType(typed, "AggregateOffer") {
+			offers = append(offers, parseOffers(typed["offers"])...)
+		}
+	}
+	return offers
+}
+
+func parseBreadcrumbList(item map[string]interface{}) BreadcrumbList {
+	var list BreadcrumbList
+	elements, ok := item["itemListElement"].([]interface{})
+	if !ok {
+		if single, isMap := item["itemListElement"].(map[string]interface{}); isMap {
+			elements = []interface{}{single}
+		}
+	}
+	for index, element := range elements {
+		entry, isMap := element.(map[string]interface{})
+		if !isMap {
+			continue
+		}
+		breadcrumb := BreadcrumbItem{
+			Position: int(numberValue(entry["position"])),
+			Name:     textValue(entry["name"]),
+			URL:      linkValue(entry["item"]),
+		}
+		if breadcrumb.Position == 0 {
+			breadcrumb.Position = index + 1
+		}
+		if target, isTargetMap := entry["item"].(map[string]interface{}); isTargetMap && breadcrumb.Name == "" {
+			breadcrumb.Name = textValue(target["name"])
+		}
+		if breadcrumb.URL == "" {
+			breadcrumb.URL = linkValue(entry["url"])
+		}
+		list.Items = append(list.Items, breadcrumb)
+	}
+	sort.SliceStable(list.Items, func(left, right int) bool {
+		return list.Items[left].Position < list.Items[right].Position
+	})
+	return list
+}
+
+// textValue renders a JSON-LD value as text. Objects yield their name (or
+// @value/url/@id) and arrays their first non-empty entry.
+func textValue(value interface{}) string {
+	switch typed := value.(type) {
+	case string:
+		return strings.Join(strings.Fields(html.UnescapeString(typed)), " ")
+	case float64:
+		return strconv.FormatFloat(typed, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(typed)
+	case map[string]interface{}:
+		for _, key := range []string{"name", "@value", "url", "contentUrl", "@id"} {
+			if text := textValue(typed[key]); text != "" {
+				return text
+			}
+		}
+	case []interface{}:
+		for _, entry := range typed {
+			if text := textValue(entry); text != "" {
+				return text
+			}
+		}
+	}
+	return ""
+}
+
+// linkValue renders a value that should be a URL, preferring url/@id over
+// names on objects.
+func linkValue(value interface{}) string {
+	if item, ok := value.(map[string]interface{}); ok {
+		for _, key := range []string{"url", "contentUrl", "@id"} {
+			if link := textValue(item[key]); link != "" {
+				return link
+			}
+		}
+		return ""
+	}
+	return textValue(value)
+}
+
+func linkValues(value interface{}) []string {
+	var links []string
+	if entries, ok := value.([]interface{}); ok {
+		for _, entry := range entries {
+			links = append(links, linkValues(entry)...)
+		}
+		return links
+	}
+	if link := linkValue(value); link != "" {
+		links = append(links, link)
+	}
+	return links
+}
+
+func numberValue(value interface{}) float64 {
+	if number, ok := value.(float64); ok {
+		return number
+	}
+	number, _ := parseNumber(textValue(value))
+	return number
+}
+
+func firstMap(value interface{}) (map[string]interface{}, bool) {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		return typed, true
+	case []interface{}:
+		for _, entry := range typed {
+			if item, ok := entry.(map[string]interface{}); ok {
+				return item, true
+			}
+		}
+	}
+	return nil, false
+}
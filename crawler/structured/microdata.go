@@ -0,0 +1,91 @@
+package structured
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// extractMicrodata converts top-level itemscope elements into the same map
+// shape as decoded JSON-LD so both share one item walker.
+func extractMicrodata(document *goquery.Document, data *Data) {
+	document.Find("[itemscope]").Each(func(_ int, scope *goquery.Selection) {
+		if _, isProperty := scope.Attr("itemprop"); isProperty {
+			return
+		}
+		collectItems(microdataItem(scope), SourceMicrodata, data)
+	})
+}
+
+func microdataItem(scope *goquery.Selection) map[string]interface{} {
+	item := make(map[string]interface{})
+	if itemType, ok := scope.Attr("itemtype"); ok {
+		var types []interface{}
+		for _, typeURL := range strings.Fields(itemType) {
+			types = append(types, typeURL)
+		}
+		item["@type"] = types
+	}
+	collectMicrodataProperties(scope.Children(), item)
+	return item
+}
+
+// collectMicrodataProperties records itemprop values among elements, stopping
+// at nested itemscope elements whose properties belong to the nested item.
+func collectMicrodataProperties(elements *goquery.Selection, item map[string]interface{}) {
+	elements.Each(func(_ int, element *goquery.Selection) {
+		_, isScope := element.Attr("itemscope")
+		if properties, ok := element.Attr("itemprop"); ok {
+			var value interface{}
+			if isScope {
+				value = microdataItem(element)
+			} else {
+				value = microdataValue(element)
+			}
+			for _, property := range strings.Fields(properties) {
+				addMicrodataProperty(item, property, value)
+			}
+		}
+		if !isScope {
+			collectMicrodataProperties(element.Children(), item)
+		}
+	})
+}
+
+func addMicrodataProperty(item map[string]interface{}, property string, value interface{}) {
+	existing, ok := item[property]
+	if !ok {
+		item[property] = value
+		return
+	}
+	if values, isList := existing.([]interface{}); isList {
+		item[property] = append(values, value)
+		return
+	}
+	item[property] = []interface{}{existing, value}
+}
+
+func microdataValue(element *goquery.Selection) string {
+	if content, ok := element.Attr("content"); ok {
+		return strings.TrimSpace(content)
+	}
+	attribute := ""
+	switch goquery.NodeName(element) {
+	case "audio", "embed", "iframe", "img", "source", "track", "video":
+		attribute = "src"
+	case "a", "area", "link":
+		attribute = "href"
+	case "object":
+		attribute = "data"
+	case "data", "meter":
+		attribute = "value"
+	case "time":
+		attribute = "datetime"
+	}
+	if attribute != "" {
+		if value, ok := element.Attr(attribute); ok {
+			return strings.TrimSpace(value)
+		}
+	}
+	return strings.Join(strings.Fields(element.Text()), " ")
+}
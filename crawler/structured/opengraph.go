@@ -0,0 +1,66 @@
+package structured
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var openGraphPrefixes = []string{"og:", "product:"}
+
+// OpenGraph holds og: and product: meta properties. The common og: fields are
+// promoted; every property, including repeated ones, is kept in Properties.
+type OpenGraph struct {
+	Title       string
+	Type        string
+	URL         string
+	Image       string
+	Description string
+	SiteName    string
+	Properties  map[string][]string
+}
+
+// Get returns the first value of a property such as "product:price:amount".
+func (graph OpenGraph) Get(property string) string {
+	values := graph.Properties[strings.ToLower(strings.TrimSpace(property))]
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func extractOpenGraph(document *goquery.Document) OpenGraph {
+	graph := OpenGraph{Properties: make(map[string][]string)}
+	document.Find("meta").Each(func(_ int, meta *goquery.Selection) {
+		property, ok := meta.Attr("property")
+		if !ok {
+			property, _ = meta.Attr("name")
+		}
+		property = strings.ToLower(strings.TrimSpace(property))
+		if !hasOpenGraphPrefix(property) {
+			return
+		}
+		content, _ := meta.Attr("content")
+		content = strings.TrimSpace(content)
+		if content == "" {
+			return
+		}
+		graph.Properties[property] = append(graph.Properties[property], content)
+	})
+	graph.Title = graph.Get("og:title")
+	graph.Type = graph.Get("og:type")
+	graph.URL = graph.Get("og:url")
+	graph.Image = graph.Get("og:image")
+	graph.Description = graph.Get("og:description")
+	graph.SiteName = graph.Get("og:site_name")
+	return graph
+}
+
+func hasOpenGraphPrefix(property string) bool {
+	for _, prefix := range openGraphPrefixes {
+		if strings.HasPrefix(property, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+// Package structured extracts schema.org and OpenGraph data embedded in
+// crawled HTML documents. JSON-LD blocks, microdata and og:/product: meta tags
+// are normalised into typed Product, Offer, AggregateRating and BreadcrumbList
+// values so rule evaluators and platform hooks no longer parse them by hand.
+//
+// When crawler.ScraperConfig.ExtractStructuredData is enabled the crawler
+// stores the extracted Data on the response context before running
+// ResponseHandler.BeforeEvaluation; handlers retrieve it with FromContext.
+package structured
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+)
+
+// Sources recorded on Product.Source.
+const (
+	SourceJSONLD    = "json-ld"
+	SourceMicrodata = "microdata"
+)
+
+const contextKey = "crawler_structured_data"
+
+// Data holds every structured item found in a document.
+type Data struct {
+	Products    []Product
+	Breadcrumbs []BreadcrumbList
+	OpenGraph   OpenGraph
+	// Errors lists JSON-LD blocks that could not be parsed even after repair.
+	Errors []error
+}
+
+// Product is a schema.org Product or ProductGroup.
+type Product struct {
+	Source          string
+	Name            string
+	Description     string
+	SKU             string
+	GTIN            string
+	MPN             string
+	Brand           string
+	URL             string
+	Images          []string
+	Offers          []Offer
+	AggregateRating *AggregateRating
+}
+
+// Offer is a schema.org Offer or AggregateOffer. Prices are kept as published;
+// use Amount to read them as numbers.
+type Offer struct {
+	Price         string
+	LowPrice      string
+	HighPrice     string
+	PriceCurrency string
+	// Availability and ItemCondition drop the schema.org URL prefix, for
+	// example "InStock" or "NewCondition".
+	Availability  string
+	ItemCondition string
+	URL           string
+	Seller        string
+}
+
+// Amount parses Price, falling back to LowPrice, as a number. Thousands
+// separators and currency symbols are ignored.
+func (offer Offer) Amount() (float64, bool) {
+	for _, candidate := range []string{offer.Price, offer.LowPrice} {
+		if amount, ok := parseNumber(candidate); ok {
+			return amount, true
+		}
+	}
+	return 0, false
+}
+
+// AggregateRating summarises reviews for a product.
+type AggregateRating struct {
+	RatingValue float64
+	BestRating  float64
+	WorstRating float64
+	ReviewCount int
+	RatingCount int
+}
+
+// BreadcrumbList is an ordered navigation trail.
+type BreadcrumbList struct {
+	Items []BreadcrumbItem
+}
+
+// BreadcrumbItem is a single step of a BreadcrumbList.
+type BreadcrumbItem struct {
+	Position int
+	Name     string
+	URL      string
+}
+
+// Names returns the breadcrumb names in order.
+func (list BreadcrumbList) Names() []string {
+	names := make([]string, 0, len(list.Items))
+	for _, item := range list.Items {
+		names = append(names, item.Name)
+	}
+	return names
+}
+
+// Extract collects JSON-LD, microdata and OpenGraph data from document. It
+// never fails; unparseable JSON-LD is reported in Data.Errors.
+func Extract(document *goquery.Document) *Data {
+	data := &Data{OpenGraph: OpenGraph{Properties: map[string][]string{}}}
+	if document == nil {
+		return data
+	}
+	extractJSONLD(document, data)
+	extractMicrodata(document, data)
+	data.OpenGraph = extractOpenGraph(document)
+	return data
+}
+
+// FirstProduct returns the first product found, preferring JSON-LD.
+func (data *Data) FirstProduct() (Product, bool) {
+	if data == nil || len(data.Products) == 0 {
+		return Product{}, false
+	}
+	return data.Products[0], true
+}
+
+// Store attaches data to a crawler response context.
+func Store(ctx *colly.Context, data *Data) {
+	if ctx == nil || data == nil {
+		return
+	}
+	ctx.Put(contextKey, data)
+}
+
+// FromContext returns the Data stored on a crawler response context.
+func FromContext(ctx *colly.Context) (*Data, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	data, ok := ctx.GetAny(contextKey).(*Data)
+	return data, ok && data != nil
+}
+
+func parseNumber(raw string) (float64, bool) {
+	cleaned := strings.Map(func(r rune) rune {
+		if (r >= '0' && r <= '9') || r == '.' || r == '-' {
+			return r
+		}
+		return -1
+	}, raw)
+	if cleaned == "" {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(cleaned, 64)
+	if err != nil {
+		return 0, false
+	}
+	return value, true
+}
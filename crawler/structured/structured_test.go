@@ -0,0 +1,242 @@
+package structured
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func parseDocument(t *testing.T, markup string) *goquery.Document {
+	t.Helper()
+	document, err := goquery.NewDocumentFromReader(strings.NewReader(markup))
+	require.NoError(t, err)
+	return document
+}
+
+const jsonLDPage = `<html><head>
+<script type="application/ld+json">
+{
+  "@context": "https://schema.org",
+  "@graph": [
+    {
+      "@type": ["Product"],
+      "name": "Trail Runner &amp; Co",
+      "sku": "TR-42",
+      "gtin13": "0123456789012",
+      "brand": {"@type": "Brand", "name": "Stride"},
+      "image": ["https://shop.test/a.jpg", {"@type": "ImageObject", "url": "https://shop.test/b.jpg"}],
+      "offers": {
+        "@type": "AggregateOffer",
+        "lowPrice": 79.5,
+        "highPrice": "99.00",
+        "priceCurrency": "USD",
+        "offers": [
+          {"@type": "Offer", "price": "79.50", "availability": "https://schema.org/InStock", "itemCondition": "http://schema.org/NewCondition", "seller": {"@type": "Organization", "name": "Stride Store"}},
+          {"@type": "Offer", "priceSpecification": {"price": 99, "priceCurrency": "USD"}, "availability": "OutOfStock"}
+        ]
+      },
+      "aggregateRating": {"@type": "AggregateRating", "ratingValue": "4.6", "reviewCount": 128, "bestRating": 5}
+    },
+    {
+      "@type": "BreadcrumbList",
+      "itemListElement": [
+        {"@type": "ListItem", "position": 2, "name": "Running", "item": "https://shop.test/running"},
+        {"@type": "ListItem", "position": 1, "item": {"@id": "https://shop.test/shoes", "name": "Shoes"}}
+      ]
+    }
+  ]
+}
+</script>
+<meta property="og:title" content="Trail Runner">
+<meta property="og:image" content="https://shop.test/og-1.jpg">
+<meta property="og:image" content="https://shop.test/og-2.jpg">
+<meta name="product:price:amount" content="79.50">
+<meta property="twitter:card" content="summary">
+<meta property="og:description" content="">
+</head><body></body></html>`
+
+func TestExtractParsesJSONLDGraph(t *testing.T) {
+	t.Parallel()
+
+	data := Extract(parseDocument(t, jsonLDPage))
+	require.Empty(t, data.Errors)
+	require.Len(t, data.Products, 1)
+
+	product, ok := data.FirstProduct()
+	require.True(t, ok)
+	require.Equal(t, SourceJSONLD, product.Source)
+	require.Equal(t, "Trail Runner & Co", product.Name)
+	require.Equal(t, "TR-42", product.SKU)
+	require.Equal(t, "0123456789012", product.GTIN)
+	require.Equal(t, "Stride", product.Brand)
+	require.Equal(t, []string{"https://shop.test/a.jpg", "https://shop.test/b.jpg"}, product.Images)
+
+	require.Len(t, product.Offers, 3)
+	require.Equal(t, Offer{LowPrice: "79.5", HighPrice: "99.00", PriceCurrency: "USD"}, product.Offers[0])
+	require.Equal(t, "InStock", product.Offers[1].Availability)
+	require.Equal(t, "NewCondition", product.Offers[1].ItemCondition)
+	require.Equal(t, "Stride Store", product.Offers[1].Seller)
+	require.Equal(t, "99", product.Offers[2].Price)
+	require.Equal(t, "USD", product.Offers[2].PriceCurrency)
+	amount, ok := product.Offers[0].Amount()
+	require.True(t, ok)
+	require.Equal(t, 79.5, amount)
+
+	require.Equal(t, &AggregateRating{RatingValue: 4.6, BestRating: 5, ReviewCount: 128}, product.AggregateRating)
+
+	require.Len(t, data.Breadcrumbs, 1)
+	require.Equal(t, []string{"Shoes", "Running"}, data.Breadcrumbs[0].Names())
+	require.Equal(t, "https://shop.test/shoes", data.Breadcrumbs[0].Items[0].URL)
+
+	require.Equal(t, "Trail Runner", data.OpenGraph.Title)
+	require.Equal(t, "https://shop.test/og-1.jpg", data.OpenGraph.Image)
+	require.Equal(t, []string{"https://shop.test/og-1.jpg", "https://shop.test/og-2.jpg"}, data.OpenGraph.Properties["og:image"])
+	require.Equal(t, "79.50", data.OpenGraph.Get("Product:Price:Amount"))
+	require.Empty(t, data.OpenGraph.Get("twitter:card"))
+	require.Empty(t, data.OpenGraph.Description)
+}
+
+func TestExtractRepairsMalformedJSONLD(t *testing.T) {
+	t.Parallel()
+
+	page := "<html><head>" +
+		"<script type=\"Application/LD+JSON\">//<![CDATA[\n" +
+		"{\"@type\": \"Product\", // vendor comment\n" +
+		" \"name\": \"Line one\nline two\",\t/* block */\n" +
+		" \"description\": \"tab\there \\\"quoted\\\"\",\n" +
+		" \"offers\": [{\"price\": \"1,299.00\",},],\n" +
+		"};\n" +
+		"{\"@type\": \"BreadcrumbList\", \"itemListElement\": {\"name\": \"Home\", \"url\": \"https://shop.test/\"}}\n" +
+		"//]]></script>" +
+		"<script type=\"application/ld+json\"><!-- --></script>" +
+		"<script type=\"application/ld+json\">{\"@type\": \"Product\", \"name\": </script>" +
+		"<script type=\"application/ld+json\">{\"@type\": \"Product\" /* unterminated</script>" +
+		"<script type=\"text/javascript\">{\"@type\": \"Product\"}</script>" +
+		"</head></html>"
+
+	data := Extract(parseDocument(t, page))
+	require.Len(t, data.Products, 1)
+	require.Equal(t, "Line one line two", data.Products[0].Name)
+	require.Equal(t, `tab here "quoted"`, data.Products[0].Description)
+	amount, ok := data.Products[0].Offers[0].Amount()
+	require.True(t, ok)
+	require.Equal(t, 1299.0, amount)
+
+	require.Len(t, data.Breadcrumbs, 1)
+	require.Equal(t, BreadcrumbItem{Position: 1, Name: "Home", URL: "https://shop.test/"}, data.Breadcrumbs[0].Items[0])
+
+	require.Len(t, data.Errors, 2)
+	require.Contains(t, data.Errors[0].Error(), "json-ld block 3")
+}
+
+func TestExtractParsesMicrodata(t *testing.T) {
+	t.Parallel()
+
+	page := `<html><body>
+<div itemscope itemtype="https://schema.org/Product">
+  <h1 itemprop="name">Espresso   Machine</h1>
+  <img itemprop="image" src="https://shop.test/espresso.jpg">
+  <link itemprop="url" href="https://shop.test/espresso">
+  <span itemprop="gtin8" content="12345670"></span>
+  <div itemprop="brand" itemscope itemtype="https://schema.org/Brand"><span itemprop="name">Brewmaster</span></div>
+  <div itemprop="offers" itemscope itemtype="https://schema.org/Offer">
+    <meta itemprop="priceCurrency" content="EUR">
+    <span itemprop="price" content="349.00">€349</span>
+    <link itemprop="availability" href="https://schema.org/PreOrder">
+    <time itemprop="priceValidUntil" datetime="2026-12-31">Dec 31</time>
+  </div>
+  <div itemprop="aggregateRating" itemscope itemtype="https://schema.org/AggregateRating">
+    <data itemprop="ratingValue" value="4.2">4.2 stars</data>
+    <meter itemprop="ratingCount" value="17">17</meter>
+  </div>
+  <section><p itemprop="description">Makes <b>great</b> coffee</p></section>
+  <object itemprop="manual" data="https://shop.test/manual.pdf"></object>
+  <a itemprop="image" href="https://shop.test/alt.jpg">alt</a>
+  <a itemprop="image">missing href</a>
+</div>
+<ol itemscope itemtype="https://schema.org/BreadcrumbList">
+  <li itemprop="itemListElement" itemscope itemtype="https://schema.org/ListItem">
+    <a itemprop="item" href="https://shop.test/kitchen"><span itemprop="name">Kitchen</span></a>
+    <meta itemprop="position" content="1">
+  </li>
+</ol>
+<div itemscope itemtype="https://schema.org/Organization"><span itemprop="name">Shop</span></div>
+</body></html>`
+
+	data := Extract(parseDocument(t, page))
+	require.Len(t, data.Products, 1)
+	product := data.Products[0]
+	require.Equal(t, SourceMicrodata, product.Source)
+	require.Equal(t, "Espresso Machine", product.Name)
+	require.Equal(t, "Makes great coffee", product.Description)
+	require.Equal(t, "Brewmaster", product.Brand)
+	require.Equal(t, "12345670", product.GTIN)
+	require.Equal(t, "https://shop.test/espresso", product.URL)
+	require.Equal(t, []string{"https://shop.test/espresso.jpg", "https://shop.test/alt.jpg", "missing href"}, product.Images)
+	require.Equal(t, []Offer{{Price: "349.00", PriceCurrency: "EUR", Availability: "PreOrder"}}, product.Offers)
+	require.Equal(t, &AggregateRating{RatingValue: 4.2, RatingCount: 17}, product.AggregateRating)
+
+	require.Len(t, data.Breadcrumbs, 1)
+	require.Equal(t, []BreadcrumbItem{{Position: 1, Name: "Kitchen", URL: "https://shop.test/kitchen"}}, data.Breadcrumbs[0].Items)
+}
+
+func TestExtractHandlesEmptyInputs(t *testing.T) {
+	t.Parallel()
+
+	data := Extract(nil)
+	require.Empty(t, data.Products)
+	_, ok := data.FirstProduct()
+	require.False(t, ok)
+
+	var nilData *Data
+	_, ok = nilData.FirstProduct()
+	require.False(t, ok)
+
+	_, ok = Offer{Price: "call us"}.Amount()
+	require.False(t, ok)
+	_, ok = Offer{Price: "1.2.3"}.Amount()
+	require.False(t, ok)
+}
+
+func TestStoreAndFromContext(t *testing.T) {
+	t.Parallel()
+
+	ctx := colly.NewContext()
+	_, ok := FromContext(ctx)
+	require.False(t, ok)
+
+	data := &Data{Products: []Product{{Name: "Kettle"}}}
+	Store(ctx, data)
+	stored, ok := FromContext(ctx)
+	require.True(t, ok)
+	require.Same(t, data, stored)
+
+	Store(nil, data)
+	Store(ctx, nil)
+	_, ok = FromContext(nil)
+	require.False(t, ok)
+}
+
+func TestTextValueHandlesScalarsAndLists(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "true", textValue(true))
+	require.Equal(t, "second", textValue([]interface{}{"", "second"}))
+	require.Equal(t, "", textValue(map[string]interface{}{}))
+	require.Equal(t, "", textValue(nil))
+	require.Equal(t, "", linkValue(map[string]interface{}{"name": "only name"}))
+
+	item, ok := firstMap([]interface{}{"skip", map[string]interface{}{"a": "b"}})
+	require.True(t, ok)
+	require.Equal(t, "b", item["a"])
+	_, ok = firstMap([]interface{}{"skip"})
+	require.False(t, ok)
+
+	list := parseBreadcrumbList(map[string]interface{}{"itemListElement": []interface{}{"not an item"}})
+	require.Empty(t, list.Items)
+	require.Equal(t, `"x\u0001y"`, repairJSON("\"x\x01y\""))
+	require.Equal(t, `"a\r\nb"`, repairJSON("\"a\r\nb\""))
+}
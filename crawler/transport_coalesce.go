@@ -0,0 +1,166 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// newCoalescingTransport shares one in-flight GET between concurrent requests
+// for the same normalized URL. Every caller receives its own copy of the
+// response so each product is still evaluated and reported independently.
+// Shared bodies are buffered up to maxBodySize bytes; larger ones fail every
+// waiting request with ErrBodyTooLarge. Requests of products selected for
+// capture are always fetched on their own.
+func newCoalescingTransport(base http.RoundTripper, maxBodySize int, logger Logger) http.RoundTripper {
+	effectiveBase := base
+	if effectiveBase == nil {
+		effectiveBase = http.DefaultTransport
+	}
+	return &coalescingTransport{
+		base:        effectiveBase,
+		maxBodySize: maxBodySize,
+		logger:      EnsureLogger(logger),
+		inFlight:    make(map[string]*coalescedFetch),
+	}
+}
+
+type coalescingTransport struct {
+	base        http.RoundTripper
+	maxBodySize int
+	logger      Logger
+	mu          sync.Mutex
+	inFlight    map[string]*coalescedFetch
+}
+
+type coalescedFetch struct {
+	done     chan struct{}
+	response *http.Response
+	body     []byte
+	proxyURL string
+	err      error
+}
+
+func (transport *coalescingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Body != nil && req.Body != http.NoBody {
+		return transport.base.RoundTrip(req)
+	}
+	// A captured product records its own exchanges, so it never shares one.
+	if req.Header.Get(captureHeader) != "" {
+		return transport.base.RoundTrip(req)
+	}
+	// Products needing differently tagged proxies may see different pages.
+	fetchKey := normalizeCoalescingURL(req.URL) + "\n" + req.Header.Get(proxyTagsHeader)
+
+	transport.mu.Lock()
+	if fetch, ok := transport.inFlight[fetchKey]; ok {
+		transport.mu.Unlock()
+		return transport.follow(req, fetch)
+	}
+	fetch := &coalescedFetch{done: make(chan struct{})}
+	transport.inFlight[fetchKey] = fetch
+	transport.mu.Unlock()
+
+	transport.lead(req, fetch)
+
+	transport.mu.Lock()
+	delete(transport.inFlight, fetchKey)
+	transport.mu.Unlock()
+	close(fetch.done)
+
+	if fetch.err != nil {
+		return nil, fetch.err
+	}
+	return fetch.copyFor(req), nil
+}
+
+func (transport *coalescingTransport) lead(req *http.Request, fetch *coalescedFetch) {
+	response, err := transport.base.RoundTrip(req)
+	fetch.proxyURL = recordedProxyURL(req.Context())
+	if err != nil {
+		fetch.err = err
+		return
+	}
+	body, readErr := io.ReadAll(io.LimitReader(response.Body, int64(transport.maxBodySize)+1))
+	closeErr := response.Body.Close()
+	if readErr == nil {
+		readErr = closeErr
+	}
+	if readErr == nil && len(body) > transport.maxBodySize {
+		readErr = bodyTooLargeError(transport.maxBodySize)
+	}
+	if readErr != nil {
+		fetch.err = readErr
+		return
+	}
+	fetch.response = response
+	fetch.body = body
+}
+
+func (transport *coalescingTransport) follow(req *http.Request, fetch *coalescedFetch) (*http.Response, error) {
+	transport.logger.Debug("Coalescing request for %s with in-flight fetch", req.URL.String())
+	select {
+	case <-fetch.done:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	recordProxyURL(req, fetch.proxyURL)
+	if fetch.err != nil {
+		if isContextError(fetch.err) && req.Context().Err() == nil {
+			// The leader was cancelled on its own; this request is still live.
+			return transport.base.RoundTrip(req)
+		}
+		return nil, fetch.err
+	}
+	return fetch.copyFor(req), nil
+}
+
+func (fetch *coalescedFetch) copyFor(req *http.Request) *http.Response {
+	response := *fetch.response
+	response.Header = fetch.response.Header.Clone()
+	response.Trailer = fetch.response.Trailer.Clone()
+	response.Body = io.NopCloser(bytes.NewReader(fetch.body))
+	response.ContentLength = int64(len(fetch.body))
+	response.Request = req
+	return &response
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// normalizeCoalescingURL lowercases the scheme and host, drops default ports
+// and fragments, and sorts query parameters so equivalent URLs share a key.
+func normalizeCoalescingURL(requestURL *url.URL) string {
+	if requestURL == nil {
+		return ""
+	}
+	normalized := *requestURL
+	normalized.Scheme = strings.ToLower(normalized.Scheme)
+	hostname := strings.ToLower(normalized.Hostname())
+	port := normalized.Port()
+	if (normalized.Scheme == "http" && port == "80") || (normalized.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	switch {
+	case port != "":
+		normalized.Host = net.JoinHostPort(hostname, port)
+	case strings.Contains(hostname, ":"):
+		normalized.Host = "[" + hostname + "]"
+	default:
+		normalized.Host = hostname
+	}
+	if normalized.Path == "" {
+		normalized.Path = "/"
+	}
+	normalized.RawQuery = normalized.Query().Encode()
+	normalized.Fragment = ""
+	normalized.RawFragment = ""
+	return normalized.String()
+}
@@ -0,0 +1,406 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type blockingCountingTransport struct {
+	calls   atomic.Int32
+	started chan struct{}
+	release chan struct{}
+	err     error
+	proxy   string
+}
+
+func (transport *blockingCountingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if transport.calls.Add(1) == 1 && transport.started != nil {
+		close(transport.started)
+		<-transport.release
+	}
+	recordProxyURL(req, transport.proxy)
+	if transport.err != nil {
+		return nil, transport.err
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/html"}},
+		Body:       io.NopCloser(strings.NewReader("shared body")),
+		Request:    req,
+	}, nil
+}
+
+type coalescingCountingLogger struct {
+	noopLogger
+	coalesced atomic.Int32
+}
+
+func (logger *coalescingCountingLogger) Debug(format string, _ ...interface{}) {
+	if strings.HasPrefix(format, "Coalescing") {
+		logger.coalesced.Add(1)
+	}
+}
+
+func startCoalescedRequests(t *testing.T, transport http.RoundTripper, requestURLs []string) ([]*http.Response, []error, func()) {
+	t.Helper()
+	responses := make([]*http.Response, len(requestURLs))
+	errs := make([]error, len(requestURLs))
+	var waitGroup sync.WaitGroup
+	for index, requestURL := range requestURLs {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			ctx, _ := withProxyURLHolder(context.Background())
+			request, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+			require.NoError(t, err)
+			responses[index], errs[index] = transport.RoundTrip(request)
+		}()
+	}
+	return responses, errs, waitGroup.Wait
+}
+
+func TestCoalescingTransportSharesConcurrentFetches(t *testing.T) {
+	t.Parallel()
+
+	base := &blockingCountingTransport{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+		proxy:   "http://proxy.test:8080",
+	}
+	logger := &coalescingCountingLogger{}
+	transport := newCoalescingTransport(base, defaultMaxBodySize, logger)
+
+	leaderResponses, leaderErrs, waitLeader := startCoalescedRequests(t, transport, []string{"http://shop.test/item?b=2&a=1"})
+	<-base.started
+	followerResponses, followerErrs, waitFollowers := startCoalescedRequests(t, transport, []string{
+		"HTTP://Shop.test:80/item?a=1&b=2#reviews",
+		"http://shop.test/item?b=2&a=1",
+	})
+	require.Eventually(t, func() bool { return logger.coalesced.Load() == 2 }, 2*time.Second, 5*time.Millisecond)
+	close(base.release)
+	waitLeader()
+	waitFollowers()
+
+	require.Equal(t, int32(1), base.calls.Load())
+	for index, response := range append(leaderResponses, followerResponses...) {
+		require.NoError(t, append(leaderErrs, followerErrs...)[index])
+		body, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+		require.Equal(t, "shared body", string(body))
+		require.Equal(t, "http://proxy.test:8080", recordedProxyURL(response.Request.Context()))
+	}
+	require.NotSame(t, leaderResponses[0], followerResponses[0])
+}
+
+func TestCoalescingTransportFailsOversizedSharedBodies(t *testing.T) {
+	t.Parallel()
+
+	base := &blockingCountingTransport{started: make(chan struct{}), release: make(chan struct{})}
+	transport := newCoalescingTransport(base, len("shared body")-1, nil)
+
+	_, leaderErrs, waitLeader := startCoalescedRequests(t, transport, []string{"http://shop.test/item"})
+	<-base.started
+	_, followerErrs, waitFollowers := startCoalescedRequests(t, transport, []string{"http://shop.test/item"})
+	close(base.release)
+	waitLeader()
+	waitFollowers()
+
+	require.ErrorIs(t, leaderErrs[0], ErrBodyTooLarge)
+	require.ErrorIs(t, followerErrs[0], ErrBodyTooLarge)
+
+	processor := &stubResponseProcessor{}
+	retryHandler := &stubRetryHandler{}
+	request := httptest.NewRequest(http.MethodGet, "http://shop.test/item", nil)
+	response := &colly.Response{Ctx: colly.NewContext(), Request: &colly.Request{URL: request.URL}}
+	handleCollectorError(response, &url.Error{Op: "Get", URL: request.URL.String(), Err: leaderErrs[0]}, processor, retryHandler, nil, noopLogger{})
+	require.Empty(t, retryHandler.calls)
+	require.Len(t, processor.results, 1)
+}
+
+func TestCoalescingTransportSharesLeaderErrors(t *testing.T) {
+	t.Parallel()
+
+	fetchErr := errors.New("connection reset")
+	base := &blockingCountingTransport{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+		err:     fetchErr,
+	}
+	logger := &coalescingCountingLogger{}
+	transport := newCoalescingTransport(base, defaultMaxBodySize, logger)
+
+	_, leaderErrs, waitLeader := startCoalescedRequests(t, transport, []string{"http://shop.test/item"})
+	<-base.started
+	_, followerErrs, waitFollowers := startCoalescedRequests(t, transport, []string{"http://shop.test/item"})
+	require.Eventually(t, func() bool { return logger.coalesced.Load() == 1 }, 2*time.Second, 5*time.Millisecond)
+	close(base.release)
+	waitLeader()
+	waitFollowers()
+
+	require.ErrorIs(t, leaderErrs[0], fetchErr)
+	require.ErrorIs(t, followerErrs[0], fetchErr)
+	require.Equal(t, int32(1), base.calls.Load())
+}
+
+func TestCoalescingTransportRefetchesWhenLeaderIsCancelled(t *testing.T) {
+	t.Parallel()
+
+	base := &blockingCountingTransport{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+		err:     context.Canceled,
+	}
+	logger := &coalescingCountingLogger{}
+	transport := newCoalescingTransport(base, defaultMaxBodySize, logger)
+
+	_, leaderErrs, waitLeader := startCoalescedRequests(t, transport, []string{"http://shop.test/item"})
+	<-base.started
+	_, followerErrs, waitFollowers := startCoalescedRequests(t, transport, []string{"http://shop.test/item"})
+	require.Eventually(t, func() bool { return logger.coalesced.Load() == 1 }, 2*time.Second, 5*time.Millisecond)
+	close(base.release)
+	waitLeader()
+	waitFollowers()
+
+	require.ErrorIs(t, leaderErrs[0], context.Canceled)
+	require.ErrorIs(t, followerErrs[0], context.Canceled)
+	require.Equal(t, int32(2), base.calls.Load(), "the live follower must fetch on its own")
+}
+
+func TestCoalescingTransportFollowerHonoursOwnCancellation(t *testing.T) {
+	t.Parallel()
+
+	base := &blockingCountingTransport{started: make(chan struct{}), release: make(chan struct{})}
+	transport := newCoalescingTransport(base, defaultMaxBodySize, nil)
+
+	_, _, waitLeader := startCoalescedRequests(t, transport, []string{"http://shop.test/item"})
+	<-base.started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	request := httptest.NewRequest(http.MethodGet, "http://shop.test/item", nil).WithContext(ctx)
+	_, err := transport.RoundTrip(request)
+	require.ErrorIs(t, err, context.Canceled)
+
+	close(base.release)
+	waitLeader()
+}
+
+func TestCoalescingTransportBypassesRequestsWithBodies(t *testing.T) {
+	t.Parallel()
+
+	base := &blockingCountingTransport{}
+	transport := newCoalescingTransport(base, defaultMaxBodySize, nil)
+
+	_, err := transport.RoundTrip(httptest.NewRequest(http.MethodPost, "http://shop.test/item", strings.NewReader("payload")))
+	require.NoError(t, err)
+	_, err = transport.RoundTrip(httptest.NewRequest(http.MethodPost, "http://shop.test/item", strings.NewReader("payload")))
+	require.NoError(t, err)
+	require.Equal(t, int32(2), base.calls.Load())
+}
+
+func TestNewCoalescingTransportDefaultsBase(t *testing.T) {
+	t.Parallel()
+
+	transport, ok := newCoalescingTransport(nil, defaultMaxBodySize, nil).(*coalescingTransport)
+	require.True(t, ok)
+	require.Equal(t, http.DefaultTransport, transport.base)
+	require.NotNil(t, transport.logger)
+}
+
+func TestNormalizeCoalescingURL(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]string{
+		"HTTP://Shop.Test:80/item?b=2&a=1#frag": "http://shop.test/item?a=1&b=2",
+		"https://shop.test:443":                 "https://shop.test/",
+		"https://shop.test:8443/item":           "https://shop.test:8443/item",
+		"http://[::1]/item":                     "http://[::1]/item",
+	}
+	for rawURL, expected := range testCases {
+		parsed, err := url.Parse(rawURL)
+		require.NoError(t, err)
+		require.Equal(t, expected, normalizeCoalescingURL(parsed), rawURL)
+	}
+	require.Empty(t, normalizeCoalescingURL(nil))
+}
+
+func TestServiceCoalescesDuplicateProductURLs(t *testing.T) {
+	t.Parallel()
+
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		time.Sleep(300 * time.Millisecond)
+		writer.Header().Set("Content-Type", "text/html")
+		_, _ = writer.Write([]byte(`<html><head><title>Shared</title></head><body></body></html>`))
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	results := make(chan *Result, 2)
+	service, err := NewService(Config{
+		PlatformID: "TEST",
+		Scraper: ScraperConfig{
+			MaxDepth:              1,
+			Parallelism:           2,
+			CoalesceDuplicateURLs: true,
+		},
+		Platform: PlatformConfig{
+			AllowedDomains: []string{serverURL.Hostname()},
+		},
+		RuleEvaluator: fixedRuleEvaluator{},
+		Logger:        noopLogger{},
+	}, results)
+	require.NoError(t, err)
+
+	require.NoError(t, service.Run(context.Background(), []Product{
+		{ID: "VARIANT-RED", Platform: "TEST", URL: server.URL + "/item?color=all&size=m"},
+		{ID: "VARIANT-BLUE", Platform: "TEST", URL: server.URL + "/item?size=m&color=all"},
+	}))
+	close(results)
+
+	productIDs := make(map[string]bool)
+	for result := range results {
+		require.True(t, result.Success)
+		require.Equal(t, "Shared", result.ProductTitle)
+		productIDs[result.ProductID] = true
+	}
+	require.Equal(t, map[string]bool{"VARIANT-RED": true, "VARIANT-BLUE": true}, productIDs)
+	require.Equal(t, int32(1), hits.Load())
+}
+
+func TestServiceCoalescedFollowerKeepsItsOwnTimeBudget(t *testing.T) {
+	t.Parallel()
+
+	var hits atomic.Int32
+	leaderStarted := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		if hits.Add(1) == 1 {
+			close(leaderStarted)
+		}
+		time.Sleep(time.Second)
+		writer.Header().Set("Content-Type", "text/html")
+		_, _ = writer.Write([]byte(`<html><head><title>Shared</title></head><body></body></html>`))
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	results := make(chan *Result, 2)
+	service, err := NewService(Config{
+		PlatformID: "TEST",
+		Scraper: ScraperConfig{
+			MaxDepth:              1,
+			Parallelism:           2,
+			CoalesceDuplicateURLs: true,
+		},
+		Platform: PlatformConfig{
+			AllowedDomains: []string{serverURL.Hostname()},
+		},
+		RuleEvaluator: fixedRuleEvaluator{},
+		Logger:        noopLogger{},
+	}, results)
+	require.NoError(t, err)
+
+	follower, err := NewProduct("FOLLOWER", "TEST", server.URL+"/item", WithTimeout(200*time.Millisecond))
+	require.NoError(t, err)
+	followerDone := make(chan *Result, 1)
+	go func() {
+		<-leaderStarted
+		require.NoError(t, service.Enqueue(follower))
+	}()
+	go func() {
+		for result := range results {
+			if result.ProductID == follower.ID {
+				followerDone <- result
+			}
+		}
+	}()
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- service.Run(context.Background(), []Product{{ID: "LEADER", Platform: "TEST", URL: server.URL + "/item"}})
+	}()
+
+	select {
+	case result := <-followerDone:
+		require.False(t, result.Success)
+		require.Contains(t, result.ErrorMessage, "crawler: product time budget exceeded after 200ms")
+	case <-time.After(900 * time.Millisecond):
+		t.Fatal("the follower waited past its own time budget")
+	}
+	require.NoError(t, <-runErr)
+	close(results)
+	require.Equal(t, int32(1), hits.Load())
+}
+
+func TestServiceDoesNotCoalesceCapturedProducts(t *testing.T) {
+	t.Parallel()
+
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		time.Sleep(300 * time.Millisecond)
+		writer.Header().Set("Content-Type", "text/html")
+		_, _ = writer.Write([]byte(`<html><head><title>Shared</title></head><body></body></html>`))
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	persister := &mockFilePersister{}
+	results := make(chan *Result, 3)
+	service, err := NewService(Config{
+		PlatformID: "TEST",
+		Scraper: ScraperConfig{
+			MaxDepth:              1,
+			Parallelism:           3,
+			CoalesceDuplicateURLs: true,
+			Capture:               CaptureConfig{SampleRate: 1},
+		},
+		Platform: PlatformConfig{
+			AllowedDomains: []string{serverURL.Hostname()},
+		},
+		RuleEvaluator: fixedRuleEvaluator{},
+		FilePersister: persister,
+		Logger:        noopLogger{},
+	}, results)
+	require.NoError(t, err)
+
+	require.NoError(t, service.Run(context.Background(), []Product{
+		{ID: "RED", Platform: "TEST", URL: server.URL + "/item"},
+		{ID: "BLUE", Platform: "TEST", URL: server.URL + "/item"},
+		{ID: "GREEN", Platform: "TEST", URL: server.URL + "/item"},
+	}))
+	close(results)
+
+	for result := range results {
+		require.True(t, result.Success)
+	}
+	require.Equal(t, int32(3), hits.Load())
+
+	persister.mu.Lock()
+	defer persister.mu.Unlock()
+	captured := make(map[string]bool)
+	for _, saved := range persister.saved {
+		var document harDocument
+		require.NoError(t, json.Unmarshal(saved.content, &document))
+		require.Len(t, document.Log.Entries, 1)
+		captured[saved.name] = true
+	}
+	require.Equal(t, map[string]bool{"RED.har": true, "BLUE.har": true, "GREEN.har": true}, captured)
+}
@@ -2,9 +2,9 @@ package crawler
 
 import (
 	"context"
+	"io"
 	"net/http"
-
-	"github.com/gocolly/colly/v2"
+	"sync"
 )
 
 func newContextAwareTransport(base http.RoundTripper, ctxProvider func() context.Context) http.RoundTripper {
@@ -33,7 +33,8 @@ func (transport *contextAwareTransport) RoundTrip(req *http.Request) (*http.Resp
 				return nil, err
 			}
 			if done := runCtx.Done(); done != nil {
-				requestCtx, cancel := context.WithCancel(requestCtx)
+				var cancel context.CancelFunc
+				requestCtx, cancel = context.WithCancel(requestCtx)
 				stop := make(chan struct{})
 				go func(parent context.Context, notify <-chan struct{}, cancel context.CancelFunc, reqCtx context.Context) {
 					select {
@@ -51,26 +52,29 @@ func (transport *contextAwareTransport) RoundTrip(req *http.Request) (*http.Resp
 		}
 	}
 
-	if cleanup != nil {
-		defer cleanup()
-	}
-
 	updatedRequest := req.WithContext(requestCtx)
 	response, err := transport.base.RoundTrip(updatedRequest)
-	propagateProxyURLContext(req, updatedRequest)
+	if cleanup != nil {
+		// The body is still streamed under requestCtx, so the run context
+		// is watched until it is closed.
+		if err != nil || response == nil || response.Body == nil {
+			cleanup()
+		} else {
+			response.Body = &cleanupOnCloseBody{ReadCloser: response.Body, cleanup: cleanup}
+		}
+	}
 	return response, err
 }
 
-func propagateProxyURLContext(originalRequest *http.Request, updatedRequest *http.Request) {
-	if originalRequest == nil || updatedRequest == nil {
-		return
-	}
-	proxyURL, ok := updatedRequest.Context().Value(colly.ProxyURLKey).(string)
-	if !ok || proxyURL == "" {
-		return
-	}
-	if existingProxyURL, ok := originalRequest.Context().Value(colly.ProxyURLKey).(string); ok && existingProxyURL == proxyURL {
-		return
-	}
-	*originalRequest = *originalRequest.WithContext(context.WithValue(originalRequest.Context(), colly.ProxyURLKey, proxyURL))
+// cleanupOnCloseBody runs cleanup once when the response body is closed.
+type cleanupOnCloseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	cleanup func()
+}
+
+func (body *cleanupOnCloseBody) Close() error {
+	err := body.ReadCloser.Close()
+	body.once.Do(body.cleanup)
+	return err
 }
@@ -6,7 +6,6 @@ import (
 	"testing"
 	"time"
 
-	"github.com/gocolly/colly/v2"
 	"github.com/stretchr/testify/require"
 )
 
@@ -65,7 +64,7 @@ func TestContextAwareTransportPreservesSelectedProxyURL(t *testing.T) {
 	t.Parallel()
 
 	transport := newContextAwareTransport(contextRoundTripFunc(func(req *http.Request) (*http.Response, error) {
-		attachProxyURL(req, "http://proxy-one.test:8080")
+		recordProxyURL(req, "http://proxy-one.test:8080")
 		return &http.Response{
 			StatusCode: http.StatusNoContent,
 			Body:       http.NoBody,
@@ -73,13 +72,14 @@ func TestContextAwareTransportPreservesSelectedProxyURL(t *testing.T) {
 		}, nil
 	}), nil)
 
-	req, err := http.NewRequest(http.MethodGet, "https://example.com", nil)
-	require.NoError(t, err)
+	req, holder := newProxyURLHolderRequest(t, "https://example.com")
+	ctx := req.Context()
 
 	resp, err := transport.RoundTrip(req)
 	require.NoError(t, err)
 	require.Equal(t, http.StatusNoContent, resp.StatusCode)
-	require.Equal(t, "http://proxy-one.test:8080", req.Context().Value(colly.ProxyURLKey))
+	require.Equal(t, "http://proxy-one.test:8080", holder.get())
+	require.Equal(t, ctx, req.Context())
 }
 
 type contextRoundTripFunc func(req *http.Request) (*http.Response, error)
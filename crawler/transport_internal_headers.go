@@ -0,0 +1,53 @@
+package crawler
+
+import (
+	"net/http"
+	"strings"
+)
+
+// internalHeaderPrefix marks the headers colly callbacks use to hand
+// per-request state to the crawler's transports. They never leave the process.
+const internalHeaderPrefix = "X-Crawler-"
+
+func newInternalHeaderStrippingTransport(base http.RoundTripper) http.RoundTripper {
+	effectiveBase := base
+	if effectiveBase == nil {
+		effectiveBase = http.DefaultTransport
+	}
+	return &internalHeaderStrippingTransport{base: effectiveBase}
+}
+
+// internalHeaderStrippingTransport sits directly above the HTTP transport and
+// drops every internal header still on the request. Each crawler transport
+// removes its own header, but a transport that is skipped or bypassed, for
+// example when a ServiceHook rewires the chain, would otherwise let it reach
+// the origin.
+type internalHeaderStrippingTransport struct {
+	base http.RoundTripper
+}
+
+func (transport *internalHeaderStrippingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !hasInternalHeader(req.Header) {
+		return transport.base.RoundTrip(req)
+	}
+	outbound := req.Clone(req.Context())
+	for name := range outbound.Header {
+		if isInternalHeader(name) {
+			delete(outbound.Header, name)
+		}
+	}
+	return transport.base.RoundTrip(outbound)
+}
+
+func hasInternalHeader(header http.Header) bool {
+	for name := range header {
+		if isInternalHeader(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func isInternalHeader(name string) bool {
+	return len(name) >= len(internalHeaderPrefix) && strings.EqualFold(name[:len(internalHeaderPrefix)], internalHeaderPrefix)
+}
@@ -0,0 +1,39 @@
+package crawler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInternalHeaderStrippingTransportDropsEveryCrawlerHeader(t *testing.T) {
+	t.Parallel()
+
+	var outbound *http.Request
+	transport := newInternalHeaderStrippingTransport(contextRoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		outbound = req
+		return &http.Response{StatusCode: http.StatusNoContent, Body: http.NoBody, Request: req}, nil
+	}))
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	require.NoError(t, err)
+	req.Header.Set(captureHeader, "token")
+	req.Header.Set(productDeadlineHeader, "1")
+	req.Header.Set(proxyTagsHeader, "residential")
+	req.Header.Set(redirectTraceHeader, "trace")
+	req.Header["x-crawler-lowercase"] = []string{"raw"}
+	req.Header.Set("X-Custom", "kept")
+
+	_, err = transport.RoundTrip(req)
+	require.NoError(t, err)
+	require.NotSame(t, req, outbound)
+	require.Equal(t, http.Header{"X-Custom": {"kept"}}, outbound.Header)
+	require.Len(t, req.Header, 6)
+
+	plain, err := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	require.NoError(t, err)
+	_, err = transport.RoundTrip(plain)
+	require.NoError(t, err)
+	require.Same(t, plain, outbound)
+}
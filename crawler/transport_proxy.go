@@ -0,0 +1,56 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+type proxyURLHolderKey struct{}
+
+// proxyURLHolder receives the proxy chosen for a round trip. The Proxy
+// callback of http.Transport runs while net/http still owns the request, so it
+// records its choice here instead of rewriting the request; the redirect
+// tracker later reports it as the colly request's ProxyURL.
+type proxyURLHolder struct {
+	mu       sync.Mutex
+	proxyURL string
+}
+
+func (holder *proxyURLHolder) set(proxyURL string) {
+	holder.mu.Lock()
+	holder.proxyURL = proxyURL
+	holder.mu.Unlock()
+}
+
+func (holder *proxyURLHolder) get() string {
+	holder.mu.Lock()
+	defer holder.mu.Unlock()
+	return holder.proxyURL
+}
+
+// withProxyURLHolder returns ctx carrying a fresh holder for one round trip.
+func withProxyURLHolder(ctx context.Context) (context.Context, *proxyURLHolder) {
+	holder := &proxyURLHolder{}
+	return context.WithValue(ctx, proxyURLHolderKey{}, holder), holder
+}
+
+// recordProxyURL stores proxyURL in the holder carried by the request context,
+// if any. It never modifies the request itself.
+func recordProxyURL(request *http.Request, proxyURL string) {
+	if request == nil {
+		return
+	}
+	if holder, ok := request.Context().Value(proxyURLHolderKey{}).(*proxyURLHolder); ok {
+		holder.set(proxyURL)
+	}
+}
+
+// recordedProxyURL returns the proxy recorded in the holder carried by ctx, or
+// "" when none was chosen.
+func recordedProxyURL(ctx context.Context) string {
+	if holder, ok := ctx.Value(proxyURLHolderKey{}).(*proxyURLHolder); ok {
+		return holder.get()
+	}
+	return ""
+}
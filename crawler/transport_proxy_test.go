@@ -0,0 +1,38 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/gocolly/colly/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func newProxyURLHolderRequest(t *testing.T, target string) (*http.Request, *proxyURLHolder) {
+	t.Helper()
+	holder := &proxyURLHolder{}
+	ctx := context.WithValue(context.Background(), proxyURLHolderKey{}, holder)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	require.NoError(t, err)
+	return req, holder
+}
+
+func TestRecordProxyURLLeavesTheRequestUntouched(t *testing.T) {
+	t.Parallel()
+
+	require.NotPanics(t, func() { recordProxyURL(nil, "http://proxy-one.test:8080") })
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	require.NoError(t, err)
+	ctx := req.Context()
+	recordProxyURL(req, "http://proxy-one.test:8080")
+	require.Equal(t, ctx, req.Context())
+	require.Nil(t, req.Context().Value(colly.ProxyURLKey))
+
+	req, holder := newProxyURLHolderRequest(t, "https://example.com")
+	ctx = req.Context()
+	recordProxyURL(req, "http://proxy-one.test:8080")
+	require.Equal(t, ctx, req.Context())
+	require.Equal(t, "http://proxy-one.test:8080", holder.get())
+}
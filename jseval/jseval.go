@@ -10,9 +10,18 @@ import (
 
 type Config = browsertransport.Config
 type Result = browsertransport.Result
+type Pool = browsertransport.Pool
+type PoolOptions = browsertransport.PoolOptions
 
 var renderPage = browsertransport.RenderPage
 var renderPages = browsertransport.RenderPages
+var newPool = browsertransport.NewPool
+
+// NewPool returns a browser pool that Config.Pool can share between
+// RenderPages calls.
+func NewPool(options PoolOptions) *Pool {
+	return newPool(options)
+}
 
 // RenderPage renders one JavaScript-heavy page in a headless browser.
 func RenderPage(ctx context.Context, targetURL string, config Config) (*Result, error) {
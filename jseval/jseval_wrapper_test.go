@@ -70,3 +70,21 @@ func TestRenderPagesDelegatesToBrowserTransport(t *testing.T) {
 		t.Fatalf("RenderPages() = %#v %#v", results, resultErrors)
 	}
 }
+
+func TestNewPoolDelegatesToBrowserTransport(t *testing.T) {
+	originalNewPool := newPool
+	defer func() { newPool = originalNewPool }()
+
+	expected := originalNewPool(PoolOptions{})
+	defer expected.Close()
+	newPool = func(options PoolOptions) *Pool {
+		if options.MaxSessionsPerProfile != 3 || options.LaunchTimeout != time.Second {
+			t.Fatalf("options = %#v", options)
+		}
+		return expected
+	}
+
+	if pool := NewPool(PoolOptions{MaxSessionsPerProfile: 3, LaunchTimeout: time.Second}); pool != expected {
+		t.Fatalf("NewPool() = %p, want %p", pool, expected)
+	}
+}